@@ -3,8 +3,11 @@ package handler
 import (
 	"errors" // Thêm import
 	"net/http"
+	"smart_parking/internal/api/middleware"
 	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
 	"smart_parking/internal/service"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -58,3 +61,43 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, authResponse)
 }
+
+// GET /api/v1/users/:id/lots
+func (h *AuthHandler) GetUserLots(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID người dùng không hợp lệ"})
+		return
+	}
+	lotIDs, err := h.authService.AssignedLotIDs(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách bãi được phân công", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "lot_ids": lotIDs})
+}
+
+// PUT /api/v1/users/:id/lots
+func (h *AuthHandler) SetUserLots(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID người dùng không hợp lệ"})
+		return
+	}
+	var dto domain.UserLotsDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lotIDs, err := h.authService.SetAssignedLots(c.Request.Context(), userID, dto.LotIDs, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy người dùng hoặc bãi đỗ", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể phân công bãi đỗ", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "lot_ids": lotIDs})
+}
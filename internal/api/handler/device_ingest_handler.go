@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"smart_parking/internal/iot"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IngestTokenHeader = "X-Ingest-Token"
+	IngestTopicHeader = "X-Device-Topic"
+)
+
+// Kích thước tối đa một sự kiện gateway gửi lên
+const maxIngestBodyBytes = 256 << 10
+
+// DeviceIngestHandler nhận sự kiện thiết bị từ gateway tại bãi khi không có IoT Core / MQTT
+type DeviceIngestHandler struct {
+	source *iot.HTTPEventSource
+	token  string
+}
+
+func NewDeviceIngestHandler(source *iot.HTTPEventSource, token string) *DeviceIngestHandler {
+	return &DeviceIngestHandler{source: source, token: token}
+}
+
+// POST /ingest/device-events
+// Body là JSON sự kiện giống payload thiết bị publish lên MQTT; topic gốc (nếu có) qua header X-Device-Topic hoặc ?topic=.
+// 202 = đã xử lý (ack); 503 = xử lý lỗi, gateway giữ sự kiện và gửi lại sau Retry-After (nack).
+func (h *DeviceIngestHandler) IngestDeviceEvent(c *gin.Context) {
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader(IngestTokenHeader)), []byte(h.token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Ingest token không hợp lệ"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIngestBodyBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không đọc được sự kiện", "details": err.Error()})
+		return
+	}
+	if len(body) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sự kiện rỗng"})
+		return
+	}
+	if len(body) > maxIngestBodyBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Sự kiện vượt quá kích thước cho phép"})
+		return
+	}
+
+	topic := c.GetHeader(IngestTopicHeader)
+	if topic == "" {
+		topic = c.Query("topic")
+	}
+
+	if err := h.source.Deliver(c.Request.Context(), body, topic); err != nil {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Chưa xử lý được sự kiện, vui lòng gửi lại", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "accepted"})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EvidenceHandler struct {
+	evidenceService *service.EvidenceService
+}
+
+func NewEvidenceHandler(es *service.EvidenceService) *EvidenceHandler {
+	return &EvidenceHandler{evidenceService: es}
+}
+
+func respondEvidenceError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, service.ErrEvidenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy ảnh bằng chứng", "details": err.Error()})
+	case errors.Is(err, service.ErrEvidencePurged):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMsg, "details": err.Error()})
+	}
+}
+
+// GET /evidence/:id
+func (h *EvidenceHandler) GetEvidence(c *gin.Context) {
+	evidenceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence ID không hợp lệ"})
+		return
+	}
+
+	evidence, err := h.evidenceService.GetEvidence(c.Request.Context(), evidenceID)
+	if err != nil {
+		respondEvidenceError(c, err, "Lỗi khi lấy thông tin ảnh bằng chứng")
+		return
+	}
+	c.JSON(http.StatusOK, evidence)
+}
+
+// GET /evidence/:id/image
+func (h *EvidenceHandler) GetEvidenceImage(c *gin.Context) {
+	evidenceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence ID không hợp lệ"})
+		return
+	}
+
+	evidence, data, err := h.evidenceService.OpenEvidence(c.Request.Context(), evidenceID)
+	if err != nil {
+		respondEvidenceError(c, err, "Lỗi khi đọc ảnh bằng chứng")
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Evidence-SHA256", evidence.SHA256)
+	c.Data(http.StatusOK, evidence.ContentType, data)
+}
+
+// PUT /evidence/:id/hold
+func (h *EvidenceHandler) SetLegalHold(c *gin.Context) {
+	evidenceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence ID không hợp lệ"})
+		return
+	}
+	var dto domain.EvidenceHoldDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	evidence, err := h.evidenceService.SetLegalHold(c.Request.Context(), evidenceID, *dto.LegalHold)
+	if err != nil {
+		respondEvidenceError(c, err, "Không thể cập nhật trạng thái giữ ảnh bằng chứng")
+		return
+	}
+	c.JSON(http.StatusOK, evidence)
+}
+
+// GET /parking-sessions/:id/evidence
+func (h *EvidenceHandler) GetSessionEvidence(c *gin.Context) {
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID phiên đỗ xe không hợp lệ"})
+		return
+	}
+
+	images, err := h.evidenceService.FindBySession(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiên đỗ xe"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy ảnh bằng chứng của phiên", "details": err.Error()})
+		return
+	}
+	if images == nil {
+		images = []domain.EvidenceImage{}
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// GET /gate-events/:event_id/evidence
+func (h *EvidenceHandler) GetGateEventEvidence(c *gin.Context) {
+	images, err := h.evidenceService.FindByGateEvent(c.Request.Context(), c.Param("event_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy ảnh bằng chứng của gate event", "details": err.Error()})
+		return
+	}
+	if images == nil {
+		images = []domain.EvidenceImage{}
+	}
+	c.JSON(http.StatusOK, images)
+}
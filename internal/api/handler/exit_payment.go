@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExitPaymentHandler struct {
+	iotService *service.IoTService
+}
+
+func NewExitPaymentHandler(is *service.IoTService) *ExitPaymentHandler {
+	return &ExitPaymentHandler{iotService: is}
+}
+
+// POST /parking-sessions/:id/confirm-payment
+// Xác nhận phiên đã thanh toán đủ; nếu xe đang bị giữ tại cổng ra thì rào được mở tự động
+func (h *ExitPaymentHandler) ConfirmExitPayment(c *gin.Context) {
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+	result, err := h.iotService.ConfirmExitPayment(c.Request.Context(), sessionID, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondPaymentError(c, err, "Không thể xác nhận thanh toán để mở rào ra")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -2,24 +2,30 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"smart_parking/internal/api/middleware"
 	"smart_parking/internal/domain"
 	"smart_parking/internal/service"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Số frame tối đa trong một lần trigger LPR
+const maxLPRFramesPerTrigger = 10
+
 type GateEventHandler struct {
 	iotService     *service.IoTService
-	lprService     *service.LPRService
+	lprProvider    service.LPRProvider
 	parkingService *service.ParkingService
 }
 
-func NewGateEventHandler(iotService *service.IoTService, lprService *service.LPRService, parkingService *service.ParkingService) *GateEventHandler {
+func NewGateEventHandler(iotService *service.IoTService, lprProvider service.LPRProvider, parkingService *service.ParkingService) *GateEventHandler {
 	return &GateEventHandler{
 		iotService:     iotService,
-		lprService:     lprService,
+		lprProvider:    lprProvider,
 		parkingService: parkingService,
 	}
 }
@@ -31,6 +37,18 @@ func (h *GateEventHandler) TriggerLPR(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ: " + err.Error()})
 		return
 	}
+	request.Operator = c.GetString(middleware.UsernameKey)
+	request.OverrideReason = strings.TrimSpace(request.OverrideReason)
+	if request.OverrideWatchlist {
+		if !canOverrideWatchlist(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin được override danh sách cảnh báo"})
+			return
+		}
+		if request.OverrideReason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Override danh sách cảnh báo cần có lý do (override_reason)"})
+			return
+		}
+	}
 
 	// Nếu có manual override, sử dụng luôn
 	if request.ManualOverride != "" {
@@ -48,18 +66,39 @@ func (h *GateEventHandler) TriggerLPR(c *gin.Context) {
 		return
 	}
 
-	// Xử lý LPR với ảnh
-	imageBytes, err := base64DecodeImage(request.ImageBase64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu ảnh không hợp lệ"})
+	// Xử lý LPR với ảnh: gộp frame đơn và loạt frame thành một lần trigger
+	encodedFrames := request.ImagesBase64
+	if request.ImageBase64 != "" {
+		encodedFrames = append([]string{request.ImageBase64}, encodedFrames...)
+	}
+	if len(encodedFrames) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần image_base64, images_base64 hoặc manual_override"})
+		return
+	}
+	if len(encodedFrames) > maxLPRFramesPerTrigger {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Tối đa %d frame mỗi lần trigger", maxLPRFramesPerTrigger)})
 		return
 	}
+	frames := make([][]byte, 0, len(encodedFrames))
+	for _, encoded := range encodedFrames {
+		imageBytes, err := base64DecodeImage(encoded)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu ảnh không hợp lệ"})
+			return
+		}
+		frames = append(frames, imageBytes)
+	}
 
-	detectedPlate, confidence, err := h.lprService.ProcessImageForLPR(c.Request.Context(), imageBytes)
+	fused, err := h.iotService.RecognizeGateFrames(c.Request.Context(), h.lprProvider, request, frames)
 	if err != nil {
+		if errors.Is(err, service.ErrNoPlateDetected) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Không nhận dạng được biển số", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi xử lý LPR", "details": err.Error()})
 		return
 	}
+	detectedPlate, confidence := fused.Plate, fused.Confidence
 
 	// Xử lý kết quả LPR
 	err = h.iotService.ProcessLPRResult(c.Request.Context(), request, detectedPlate, confidence)
@@ -71,12 +110,23 @@ func (h *GateEventHandler) TriggerLPR(c *gin.Context) {
 	response := gin.H{
 		"message":        "LPR đã được xử lý thành công",
 		"detected_plate": detectedPlate,
+		"display_plate":  fused.Display,
+		"grammar":        fused.Grammar,
 		"confidence":     confidence,
 		"is_manual":      false,
+		"provider":       h.lprProvider.Name(),
+		"characters":     fused.Characters,
+		"voting_frames":  fused.VotingFrames,
+		"total_frames":   fused.TotalFrames,
+	}
+	if fused.Vehicle != nil {
+		response["vehicle"] = fused.Vehicle
 	}
 
 	// Thông tin thêm về việc tạo session
-	if confidence >= 0.8 && detectedPlate != "" {
+	threshold := h.iotService.LPRConfidenceThreshold(c.Request.Context(), request.EventID, request.CameraID)
+	response["confidence_threshold"] = threshold
+	if confidence >= threshold && detectedPlate != "" {
 		response["auto_session_created"] = true
 		response["next_action"] = "Session sẽ được tạo tự động"
 	} else if detectedPlate != "" {
@@ -105,14 +155,51 @@ func (h *GateEventHandler) CreateSessionFromEvent(c *gin.Context) {
 		LotID:             request.LotID,
 		Esp32ThingName:    request.Esp32ThingName,
 		VehicleIdentifier: request.DetectedPlate,
+		Operator:          c.GetString(middleware.UsernameKey),
+	}
+	// Thuộc tính xe operator nhập được ưu tiên hơn kết quả nhận diện từ ảnh
+	operatorVehicle := domain.VehicleAttributes{
+		VehicleClass: request.VehicleClass,
+		Color:        request.VehicleColor,
+		Make:         request.VehicleMake,
+	}
+	if !operatorVehicle.IsEmpty() {
+		sessionDTO.VehicleClass = operatorVehicle.VehicleClass
+		sessionDTO.VehicleColor = operatorVehicle.Color
+		sessionDTO.VehicleMake = operatorVehicle.Make
+		sessionDTO.VehicleSource = domain.VehicleSourceOperator
+	} else if vehicle := h.iotService.GateEventVehicle(c.Request.Context(), request.EventID); vehicle != nil {
+		sessionDTO.VehicleClass = vehicle.VehicleClass
+		sessionDTO.VehicleColor = vehicle.Color
+		sessionDTO.VehicleMake = vehicle.Make
+		sessionDTO.VehicleSource = domain.VehicleSourceLPR
 	}
 
 	session, err := h.parkingService.VehicleCheckIn(c.Request.Context(), sessionDTO)
 	if err != nil {
+		if errors.Is(err, service.ErrPlateFlagged) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrWatchlistUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrWatchlistUnavailable.Error(), "details": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidVehicleClass) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo phiên đỗ xe", "details": err.Error()})
 		return
 	}
 
+	// Biển số operator xác nhận / sửa là nhãn cho ảnh của gate event
+	h.iotService.RecordLPRCorrection(c.Request.Context(), request.EventID, request.DetectedPlate, "",
+		domain.LPRCorrectionSourceCreateSession, sessionDTO.Operator)
+	if !operatorVehicle.IsEmpty() {
+		h.iotService.CorrectGateEventVehicle(c.Request.Context(), request.EventID, operatorVehicle)
+	}
+
 	// TODO: Cập nhật gate event record với session ID
 	// h.iotService.UpdateGateEventWithSession(c.Request.Context(), request.EventID, session.ID)
 
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HolidayHandler struct {
+	tariffService *service.TariffService
+}
+
+func NewHolidayHandler(ts *service.TariffService) *HolidayHandler {
+	return &HolidayHandler{tariffService: ts}
+}
+
+// POST /holidays
+func (h *HolidayHandler) CreateHoliday(c *gin.Context) {
+	var dto domain.HolidayDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	holiday, err := h.tariffService.CreateHoliday(c.Request.Context(), dto)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTariff) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bãi đỗ xe"})
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicateEntry) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo ngày lễ", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, holiday)
+}
+
+// GET /holidays?lot_id=
+func (h *HolidayHandler) GetHolidays(c *gin.Context) {
+	var lotID *int
+	if lotIDStr := c.Query("lot_id"); lotIDStr != "" {
+		id, err := strconv.Atoi(lotIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+			return
+		}
+		lotID = &id
+	}
+
+	holidays, err := h.tariffService.GetHolidays(c.Request.Context(), lotID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách ngày lễ"})
+		return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
+
+// DELETE /holidays/:id
+func (h *HolidayHandler) DeleteHoliday(c *gin.Context) {
+	holidayID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Holiday ID không hợp lệ"})
+		return
+	}
+
+	if err := h.tariffService.DeleteHoliday(c.Request.Context(), holidayID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy ngày lễ để xóa"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa ngày lễ", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
 	"smart_parking/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -20,6 +24,9 @@ type ControlBarrierRequest struct {
 	Esp32ControllerID string `json:"esp32_controller_id" binding:"required"`           // Thing Name của ESP32
 	BarrierType       string `json:"barrier_type" binding:"required,oneof=entry exit"` // "entry" hoặc "exit"
 	Command           string `json:"command" binding:"required,oneof=open close"`      // "open" hoặc "close"
+	// Mở rào ra khi xe đang bị giữ chờ thanh toán (pay-before-exit) cần force + lý do
+	Force  bool   `json:"force,omitempty"`
+	Reason string `json:"reason,omitempty"`
 }
 
 // POST /iot/commands/barrier
@@ -30,13 +37,73 @@ func (h *IoTCommandHandler) ControlBarrier(c *gin.Context) {
 		return
 	}
 
+	if req.BarrierType == "exit" && req.Command == "open" {
+		holds, err := h.iotService.FindExitPaymentHolds(c.Request.Context(), req.Esp32ControllerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể kiểm tra trạng thái thanh toán tại cổng ra", "details": err.Error()})
+			return
+		}
+		// Bãi bật pay-before-exit: mở rào ra thủ công luôn cần lý do, kể cả khi chưa có xe bị giữ
+		requiresPayment, err := h.iotService.ExitBarrierRequiresPayment(c.Request.Context(), req.Esp32ControllerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể kiểm tra trạng thái thanh toán tại cổng ra", "details": err.Error()})
+			return
+		}
+		if len(holds) > 0 || requiresPayment {
+			if !req.Force || req.Reason == "" {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":          service.ErrExitPaymentHold.Error(),
+					"details":        "Xác nhận thanh toán hoặc gửi lại với force=true và reason để mở thủ công",
+					"gate_event_ids": exitHoldEventIDs(holds),
+				})
+				return
+			}
+			h.iotService.ReleaseExitPaymentHolds(c.Request.Context(), holds, c.GetString(middleware.UsernameKey), req.Reason)
+		}
+	}
+
 	requestID := uuid.New().String()
 
-	err := h.iotService.SendBarrierControlCommand(c.Request.Context(), req.Esp32ControllerID, req.BarrierType, req.Command, requestID)
+	err := h.iotService.SendBarrierControlCommand(c.Request.Context(), req.Esp32ControllerID, req.BarrierType, req.Command, requestID, c.GetString(middleware.UsernameKey))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCommandTarget) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrCommandRetryScheduled) {
+			c.JSON(http.StatusAccepted, gin.H{"message": service.ErrCommandRetryScheduled.Error(), "request_id": requestID, "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể gửi lệnh điều khiển rào chắn", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Lệnh điều khiển rào chắn đã được gửi", "request_id": requestID})
 }
+
+// GET /iot/commands/:request_id
+// Trạng thái lệnh: sent / acknowledged / executed (rào đã chuyển) / timed_out / failed
+func (h *IoTCommandHandler) GetCommandStatus(c *gin.Context) {
+	command, err := h.iotService.GetDeviceCommand(c.Request.Context(), c.Param("request_id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy lệnh", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrCommandTrackingDisabled) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy trạng thái lệnh", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, command)
+}
+
+func exitHoldEventIDs(holds []domain.GateEventRecord) []string {
+	ids := make([]string, 0, len(holds))
+	for _, hold := range holds {
+		ids = append(ids, hold.EventID)
+	}
+	return ids
+}
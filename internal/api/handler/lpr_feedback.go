@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LPRFeedbackHandler struct {
+	feedbackService *service.LPRFeedbackService
+}
+
+func NewLPRFeedbackHandler(fs *service.LPRFeedbackService) *LPRFeedbackHandler {
+	return &LPRFeedbackHandler{feedbackService: fs}
+}
+
+// GET /lpr/corrections
+func (h *LPRFeedbackHandler) GetCorrections(c *gin.Context) {
+	var filter domain.LPRCorrectionFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	corrections, err := h.feedbackService.FindCorrections(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách sửa biển số", "details": err.Error()})
+		return
+	}
+	if corrections == nil {
+		corrections = []domain.LPRCorrection{}
+	}
+	c.JSON(http.StatusOK, corrections)
+}
+
+// GET /lpr/accuracy
+func (h *LPRFeedbackHandler) GetAccuracy(c *gin.Context) {
+	var filter domain.LPRCorrectionFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	report, err := h.feedbackService.AccuracyReport(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tính độ chính xác LPR", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GET /lpr/dataset
+func (h *LPRFeedbackHandler) ExportDataset(c *gin.Context) {
+	var filter domain.LPRCorrectionFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	// Lấy dữ liệu trước khi ghi header để còn trả lỗi dạng JSON
+	corrections, err := h.feedbackService.FindCorrections(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách sửa biển số", "details": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("lpr-dataset-%s.zip", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "private, no-store")
+	c.Status(http.StatusOK)
+
+	exported, skipped, err := h.feedbackService.ExportDataset(c.Request.Context(), corrections, c.Writer)
+	if err != nil {
+		// Đã bắt đầu stream, chỉ có thể log; client nhận được file zip hỏng
+		log.Printf("LPRFeedbackHandler: Lỗi xuất bộ dữ liệu LPR: %v", err)
+		return
+	}
+	log.Printf("LPRFeedbackHandler: Đã xuất %d mẫu LPR (bỏ qua %d mẫu không có ảnh)", exported, skipped)
+}
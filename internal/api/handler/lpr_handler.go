@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
 	"log"
 	"net/http"
 	"smart_parking/internal/domain"
@@ -11,12 +12,12 @@ import (
 )
 
 type LPRHandler struct {
-	lprService     *service.LPRService
+	lprProvider    service.LPRProvider
 	parkingService *service.ParkingService // Có thể cần để tạo session sau khi LPR
 }
 
-func NewLPRHandler(lprService *service.LPRService, parkingService *service.ParkingService) *LPRHandler {
-	return &LPRHandler{lprService: lprService, parkingService: parkingService}
+func NewLPRHandler(lprProvider service.LPRProvider, parkingService *service.ParkingService) *LPRHandler {
+	return &LPRHandler{lprProvider: lprProvider, parkingService: parkingService}
 }
 
 // POST /api/v1/lpr/process-image
@@ -41,16 +42,26 @@ func (h *LPRHandler) ProcessImage(c *gin.Context) {
 	}
 	log.Printf("LPRHandler: Đã nhận %d bytes ảnh để xử lý LPR.", len(imageBytes))
 
-	detectedPlate, confidence, err := h.lprService.ProcessImageForLPR(c.Request.Context(), imageBytes)
-	if err != nil {
-		log.Printf("LPRHandler: Lỗi từ LPRService: %v", err)
+	result, err := h.lprProvider.Recognize(c.Request.Context(), imageBytes)
+	if err != nil && !errors.Is(err, service.ErrNoPlateDetected) {
+		log.Printf("LPRHandler: Lỗi từ LPR provider %s: %v", h.lprProvider.Name(), err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi xử lý ảnh LPR", "details": err.Error()})
 		return
 	}
 
-	if detectedPlate == "" {
+	if req.LotID > 0 && result != nil {
+		result.Candidates, err = h.parkingService.FilterLPRCandidatesForLot(c.Request.Context(), req.LotID, result.Candidates)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bãi đỗ", "details": err.Error()})
+			return
+		}
+	}
+
+	best := result.Best()
+	if best == nil {
 		c.JSON(http.StatusOK, domain.LPRResponseDTO{
 			DetectedPlate: "",
+			Provider:      h.lprProvider.Name(),
 			ErrorMessage:  "Không nhận dạng được biển số.",
 		})
 		return
@@ -62,7 +73,7 @@ func (h *LPRHandler) ProcessImage(c *gin.Context) {
 	// sessionDTO := domain.CreateParkingSessionDTO {
 	//     LotID: 1, // Ví dụ
 	//     Esp32ThingName: "ESP32_EntryGate_Sim", // Ví dụ
-	//     VehicleIdentifier: detectedPlate,
+	//     VehicleIdentifier: best.Plate,
 	//     EntryTime: entryTime,
 	// }
 	// _, sessionErr := h.parkingService.StartParkingSessionFromLPR(c.Request.Context(), sessionDTO) // Cần tạo hàm này
@@ -72,7 +83,10 @@ func (h *LPRHandler) ProcessImage(c *gin.Context) {
 	// }
 
 	c.JSON(http.StatusOK, domain.LPRResponseDTO{
-		DetectedPlate: detectedPlate,
-		Confidence:    confidence,
+		DetectedPlate: best.Plate,
+		DisplayPlate:  best.Display,
+		Confidence:    best.Confidence,
+		Provider:      result.Provider,
+		Candidates:    result.Candidates,
 	})
 }
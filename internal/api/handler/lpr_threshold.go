@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LPRThresholdHandler struct {
+	thresholdService *service.LPRThresholdService
+}
+
+func NewLPRThresholdHandler(ts *service.LPRThresholdService) *LPRThresholdHandler {
+	return &LPRThresholdHandler{thresholdService: ts}
+}
+
+// optionalLotID đọc query lot_id, không có thì trả về nil
+func optionalLotID(c *gin.Context) (*int, bool) {
+	raw := c.Query("lot_id")
+	if raw == "" {
+		return nil, true
+	}
+	lotID, err := strconv.Atoi(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+		return nil, false
+	}
+	return &lotID, true
+}
+
+// GET /lpr/thresholds?lot_id=
+func (h *LPRThresholdHandler) ListThresholds(c *gin.Context) {
+	lotID, ok := optionalLotID(c)
+	if !ok {
+		return
+	}
+
+	thresholds, err := h.thresholdService.List(c.Request.Context(), lotID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy ngưỡng LPR", "details": err.Error()})
+		return
+	}
+	if thresholds == nil {
+		thresholds = []domain.LPRThreshold{}
+	}
+	c.JSON(http.StatusOK, thresholds)
+}
+
+// PUT /lpr/thresholds
+func (h *LPRThresholdHandler) SetThreshold(c *gin.Context) {
+	var dto domain.LPRThresholdDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threshold, err := h.thresholdService.Set(c.Request.Context(), dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu ngưỡng LPR", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, threshold)
+}
+
+// DELETE /lpr/thresholds/:id
+func (h *LPRThresholdHandler) DeleteThreshold(c *gin.Context) {
+	thresholdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Threshold ID không hợp lệ"})
+		return
+	}
+
+	if err := h.thresholdService.Delete(c.Request.Context(), thresholdID, c.GetString(middleware.UsernameKey)); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy ngưỡng LPR"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa ngưỡng LPR", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// GET /lpr/thresholds/history?lot_id=&camera_id=
+func (h *LPRThresholdHandler) GetHistory(c *gin.Context) {
+	lotID, ok := optionalLotID(c)
+	if !ok {
+		return
+	}
+	var cameraID *string
+	if raw, exists := c.GetQuery("camera_id"); exists {
+		cameraID = &raw
+	}
+
+	changes, err := h.thresholdService.History(c.Request.Context(), lotID, cameraID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy lịch sử ngưỡng LPR", "details": err.Error()})
+		return
+	}
+	if changes == nil {
+		changes = []domain.LPRThresholdChange{}
+	}
+	c.JSON(http.StatusOK, changes)
+}
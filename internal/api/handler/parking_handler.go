@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"log"
 	"net/http"
+	"smart_parking/internal/api/middleware"
 	"smart_parking/internal/domain"
 	"smart_parking/internal/repository"
 	"smart_parking/internal/service"
 	"strconv"
+	"strings"
 
 	"errors"
 	"github.com/gin-gonic/gin"
@@ -26,6 +29,18 @@ func (h *ParkingSessionHandler) VehicleCheckIn(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ: " + err.Error()})
 		return
 	}
+	dto.Operator = c.GetString(middleware.UsernameKey)
+	dto.OverrideReason = strings.TrimSpace(dto.OverrideReason)
+	if dto.OverrideWatchlist {
+		if !canOverrideWatchlist(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Chỉ admin được override danh sách cảnh báo"})
+			return
+		}
+		if dto.OverrideReason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Override danh sách cảnh báo cần có lý do (override_reason)"})
+			return
+		}
+	}
 
 	session, err := h.parkingService.VehicleCheckIn(c.Request.Context(), dto)
 	if err != nil {
@@ -33,6 +48,18 @@ func (h *ParkingSessionHandler) VehicleCheckIn(c *gin.Context) {
 			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, service.ErrPlateFlagged) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrWatchlistUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": service.ErrWatchlistUnavailable.Error(), "details": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidVehicleClass) || errors.Is(err, service.ErrInvalidPlateGrammar) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if errors.Is(err, repository.ErrNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
@@ -43,6 +70,15 @@ func (h *ParkingSessionHandler) VehicleCheckIn(c *gin.Context) {
 	c.JSON(http.StatusCreated, session)
 }
 
+// canOverrideWatchlist - chỉ admin (theo role trong JWT) được cho xe trong danh sách cảnh báo qua cổng;
+// operator tạo qua /auth/register không có quyền này
+func canOverrideWatchlist(c *gin.Context) bool {
+	return c.GetString(middleware.UserRoleKey) == "admin"
+}
+
+// Số biển số gần giống tối đa trả về khi check-out không khớp phiên nào
+const checkOutCandidateLimit = 5
+
 // POST /parking-sessions/check-out
 func (h *ParkingSessionHandler) VehicleCheckOut(c *gin.Context) {
 	var dto domain.VehicleCheckOutDTO
@@ -54,7 +90,23 @@ func (h *ParkingSessionHandler) VehicleCheckOut(c *gin.Context) {
 	session, err := h.parkingService.VehicleCheckOut(c.Request.Context(), dto)
 	if err != nil {
 		if errors.Is(err, repository.ErrNoActiveSession) || errors.Is(err, repository.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			// Gợi ý biển số gần giống để operator gửi lại check-out kèm session_id đã xác nhận
+			candidates, findErr := h.parkingService.FindSimilarActiveSessions(c.Request.Context(), dto.LotID, dto.VehicleIdentifier, checkOutCandidateLimit)
+			if findErr != nil {
+				log.Printf("Lỗi tìm biển số gần giống cho xe '%s' tại bãi %d: %v", dto.VehicleIdentifier, dto.LotID, findErr)
+			}
+			if candidates == nil {
+				candidates = []domain.PlateCandidate{}
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "candidates": candidates})
+			return
+		}
+		if errors.Is(err, service.ErrPlateMismatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPaymentOutstanding) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể ghi nhận xe ra", "details": err.Error()})
@@ -83,6 +135,36 @@ func (h *ParkingSessionHandler) GetParkingSessionByID(c *gin.Context) {
 	c.JSON(http.StatusOK, session)
 }
 
+// PUT /parking-sessions/:id/vehicle
+func (h *ParkingSessionHandler) UpdateSessionVehicle(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID phiên đỗ xe không hợp lệ"})
+		return
+	}
+	var dto domain.VehicleAttributesDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dữ liệu không hợp lệ: " + err.Error()})
+		return
+	}
+	dto.Operator = c.GetString(middleware.UsernameKey)
+
+	session, err := h.parkingService.UpdateSessionVehicle(c.Request.Context(), id, dto)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVehicleClass) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiên đỗ xe"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật thông tin xe", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
 // GET /parking-lots/:lot_id/active-sessions
 func (h *ParkingSessionHandler) GetActiveSessionsByLotID(c *gin.Context) {
 	lotIDStr := c.Param("id")
@@ -114,3 +196,32 @@ func (h *ParkingSessionHandler) FindParkingSessions(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, sessions)
 }
+
+// GET /parking-lots/:id/active-sessions/similar?plate=&limit=
+func (h *ParkingSessionHandler) FindSimilarActiveSessions(c *gin.Context) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+		return
+	}
+	plate := c.Query("plate")
+	if plate == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu tham số plate"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số limit không hợp lệ"})
+		return
+	}
+
+	candidates, err := h.parkingService.FindSimilarActiveSessions(c.Request.Context(), lotID, plate, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tìm biển số gần giống", "details": err.Error()})
+		return
+	}
+	if candidates == nil {
+		candidates = []domain.PlateCandidate{}
+	}
+	c.JSON(http.StatusOK, candidates)
+}
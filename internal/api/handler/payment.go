@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+func NewPaymentHandler(ps *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{paymentService: ps}
+}
+
+// respondPaymentError map lỗi nghiệp vụ thanh toán sang HTTP status
+func respondPaymentError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiên đỗ xe hoặc giao dịch", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidPaymentAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPaymentNotAllowed), errors.Is(err, service.ErrPaymentOutstanding):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMsg, "details": err.Error()})
+	}
+}
+
+func parseSessionID(c *gin.Context) (int, bool) {
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session ID không hợp lệ"})
+		return 0, false
+	}
+	return sessionID, true
+}
+
+// GET /parking-sessions/:id/payments
+func (h *PaymentHandler) GetSessionPayments(c *gin.Context) {
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+	summary, err := h.paymentService.GetSessionPayments(c.Request.Context(), sessionID)
+	if err != nil {
+		respondPaymentError(c, err, "Lỗi khi lấy thông tin thanh toán")
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// POST /parking-sessions/:id/payments
+func (h *PaymentHandler) StartPayment(c *gin.Context) {
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+	var dto domain.StartPaymentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.StartPayment(c.Request.Context(), sessionID, dto)
+	if err != nil {
+		respondPaymentError(c, err, "Không thể tạo giao dịch thanh toán")
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// POST /parking-sessions/:id/payments/cash
+func (h *PaymentHandler) RecordCashPayment(c *gin.Context) {
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+	var dto domain.CashPaymentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.RecordCashPayment(c.Request.Context(), sessionID, dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondPaymentError(c, err, "Không thể ghi nhận thanh toán tiền mặt")
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// POST /parking-sessions/:id/waive
+func (h *PaymentHandler) WaivePayment(c *gin.Context) {
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+	var dto domain.WaivePaymentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần nhập lý do miễn phí", "details": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.WaivePayment(c.Request.Context(), sessionID, dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondPaymentError(c, err, "Không thể miễn phí cho phiên đỗ xe")
+		return
+	}
+	c.JSON(http.StatusCreated, payment)
+}
+
+// POST /payments/:payment_id/refunds
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	paymentID, err := strconv.Atoi(c.Param("payment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment ID không hợp lệ"})
+		return
+	}
+	var dto domain.RefundPaymentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := h.paymentService.RefundPayment(c.Request.Context(), paymentID, dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondPaymentError(c, err, "Không thể hoàn tiền")
+		return
+	}
+	c.JSON(http.StatusCreated, refund)
+}
+
+// POST /webhooks/payments/:gateway (không cần JWT, xác thực bằng chữ ký)
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không đọc được payload webhook"})
+		return
+	}
+
+	payment, err := h.paymentService.HandleWebhook(c.Request.Context(), c.Param("gateway"), payload, c.GetHeader(PaymentSignatureHeader))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhookSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		respondPaymentError(c, err, "Lỗi xử lý webhook thanh toán")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payment_id": payment.ID, "status": payment.Status})
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReservationHandler struct {
+	reservationService *service.ReservationService
+}
+
+func NewReservationHandler(rs *service.ReservationService) *ReservationHandler {
+	return &ReservationHandler{reservationService: rs}
+}
+
+func respondReservationError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy đặt chỗ, bãi đỗ hoặc chỗ đỗ", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidReservation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrReservationConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMsg, "details": err.Error()})
+	}
+}
+
+// POST /reservations
+func (h *ReservationHandler) CreateReservation(c *gin.Context) {
+	var dto domain.CreateReservationDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reservation, err := h.reservationService.CreateReservation(c.Request.Context(), dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondReservationError(c, err, "Không thể tạo đặt chỗ")
+		return
+	}
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// GET /reservations?lot_id=&status=&vehicle_identifier=
+func (h *ReservationHandler) FindReservations(c *gin.Context) {
+	var filter domain.ReservationFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	reservations, err := h.reservationService.FindReservations(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách đặt chỗ", "details": err.Error()})
+		return
+	}
+	if reservations == nil {
+		reservations = []domain.Reservation{}
+	}
+	c.JSON(http.StatusOK, reservations)
+}
+
+// GET /reservations/:id
+func (h *ReservationHandler) GetReservationByID(c *gin.Context) {
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reservation ID không hợp lệ"})
+		return
+	}
+
+	reservation, err := h.reservationService.GetReservation(c.Request.Context(), reservationID)
+	if err != nil {
+		respondReservationError(c, err, "Lỗi khi lấy thông tin đặt chỗ")
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
+
+// POST /reservations/:id/cancel
+func (h *ReservationHandler) CancelReservation(c *gin.Context) {
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reservation ID không hợp lệ"})
+		return
+	}
+
+	reservation, err := h.reservationService.CancelReservation(c.Request.Context(), reservationID)
+	if err != nil {
+		respondReservationError(c, err, "Không thể hủy đặt chỗ")
+		return
+	}
+	c.JSON(http.StatusOK, reservation)
+}
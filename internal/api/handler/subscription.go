@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	subscriptionService *service.SubscriptionService
+}
+
+func NewSubscriptionHandler(ss *service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{subscriptionService: ss}
+}
+
+func respondSubscriptionError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy vé tháng", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidSubscription):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMsg, "details": err.Error()})
+	}
+}
+
+// POST /subscriptions
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	var dto domain.SubscriptionDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.subscriptionService.CreateSubscription(c.Request.Context(), dto)
+	if err != nil {
+		respondSubscriptionError(c, err, "Không thể tạo vé tháng")
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// GET /subscriptions?lot_id=&vehicle_identifier=&active_only=
+func (h *SubscriptionHandler) FindSubscriptions(c *gin.Context) {
+	var filter domain.SubscriptionFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	subscriptions, err := h.subscriptionService.FindSubscriptions(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách vé tháng", "details": err.Error()})
+		return
+	}
+	if subscriptions == nil {
+		subscriptions = []domain.Subscription{}
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// GET /subscriptions/:id
+func (h *SubscriptionHandler) GetSubscriptionByID(c *gin.Context) {
+	subscriptionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription ID không hợp lệ"})
+		return
+	}
+
+	subscription, err := h.subscriptionService.GetSubscription(c.Request.Context(), subscriptionID)
+	if err != nil {
+		respondSubscriptionError(c, err, "Lỗi khi lấy thông tin vé tháng")
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// PUT /subscriptions/:id
+func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
+	subscriptionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription ID không hợp lệ"})
+		return
+	}
+	var dto domain.SubscriptionDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.subscriptionService.UpdateSubscription(c.Request.Context(), subscriptionID, dto)
+	if err != nil {
+		respondSubscriptionError(c, err, "Không thể cập nhật vé tháng")
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DELETE /subscriptions/:id
+func (h *SubscriptionHandler) DeleteSubscription(c *gin.Context) {
+	subscriptionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription ID không hợp lệ"})
+		return
+	}
+
+	if err := h.subscriptionService.DeleteSubscription(c.Request.Context(), subscriptionID); err != nil {
+		respondSubscriptionError(c, err, "Không thể xóa vé tháng")
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
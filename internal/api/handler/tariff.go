@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TariffHandler struct {
+	tariffService *service.TariffService
+}
+
+func NewTariffHandler(ts *service.TariffService) *TariffHandler {
+	return &TariffHandler{tariffService: ts}
+}
+
+// parseLotAndTariffID đọc :id và :tariff_id từ path
+func parseLotAndTariffID(c *gin.Context) (int, int, bool) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+		return 0, 0, false
+	}
+	tariffID, err := strconv.Atoi(c.Param("tariff_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tariff ID không hợp lệ"})
+		return 0, 0, false
+	}
+	return lotID, tariffID, true
+}
+
+// POST /parking-lots/:id/tariffs
+func (h *TariffHandler) CreateTariff(c *gin.Context) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+		return
+	}
+
+	var dto domain.TariffDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tariff, err := h.tariffService.CreateTariff(c.Request.Context(), lotID, dto)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTariff) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bãi đỗ xe"})
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicateEntry) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo biểu phí", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, tariff)
+}
+
+// GET /parking-lots/:id/tariffs
+func (h *TariffHandler) GetTariffsByLotID(c *gin.Context) {
+	lotID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lot ID không hợp lệ"})
+		return
+	}
+
+	tariffs, err := h.tariffService.GetTariffsByLotID(c.Request.Context(), lotID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách biểu phí"})
+		return
+	}
+	c.JSON(http.StatusOK, tariffs)
+}
+
+// GET /parking-lots/:id/tariffs/:tariff_id
+func (h *TariffHandler) GetTariffByID(c *gin.Context) {
+	lotID, tariffID, ok := parseLotAndTariffID(c)
+	if !ok {
+		return
+	}
+
+	tariff, err := h.tariffService.GetTariffByID(c.Request.Context(), lotID, tariffID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy biểu phí"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin biểu phí"})
+		return
+	}
+	c.JSON(http.StatusOK, tariff)
+}
+
+// PUT /parking-lots/:id/tariffs/:tariff_id
+// Nếu biểu phí đã có hiệu lực, một phiên bản mới được tạo và trả về thay vì sửa bản cũ;
+// is_active = false chỉ ngừng áp dụng phiên bản hiện tại
+func (h *TariffHandler) UpdateTariff(c *gin.Context) {
+	lotID, tariffID, ok := parseLotAndTariffID(c)
+	if !ok {
+		return
+	}
+
+	var dto domain.TariffDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tariff, err := h.tariffService.UpdateTariff(c.Request.Context(), lotID, tariffID, dto)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTariff) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy biểu phí để cập nhật"})
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicateEntry) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật biểu phí", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tariff)
+}
+
+// DELETE /parking-lots/:id/tariffs/:tariff_id
+func (h *TariffHandler) DeleteTariff(c *gin.Context) {
+	lotID, tariffID, ok := parseLotAndTariffID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tariffService.DeleteTariff(c.Request.Context(), lotID, tariffID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy biểu phí để xóa"})
+			return
+		}
+		if errors.Is(err, service.ErrTariffInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa biểu phí", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"smart_parking/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WatchlistHandler struct {
+	watchlistService *service.WatchlistService
+}
+
+func NewWatchlistHandler(ws *service.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{watchlistService: ws}
+}
+
+func respondWatchlistError(c *gin.Context, err error, fallbackMsg string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy mục trong danh sách cảnh báo", "details": err.Error()})
+	case errors.Is(err, service.ErrInvalidWatchlistEntry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallbackMsg, "details": err.Error()})
+	}
+}
+
+// POST /watchlist
+func (h *WatchlistHandler) CreateEntry(c *gin.Context) {
+	var dto domain.WatchlistDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.watchlistService.CreateEntry(c.Request.Context(), dto, c.GetString(middleware.UsernameKey))
+	if err != nil {
+		respondWatchlistError(c, err, "Không thể thêm biển số vào danh sách cảnh báo")
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GET /watchlist?vehicle_identifier=&reason_code=&active_only=
+func (h *WatchlistHandler) FindEntries(c *gin.Context) {
+	var filter domain.WatchlistFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	entries, err := h.watchlistService.FindEntries(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách cảnh báo", "details": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []domain.WatchlistEntry{}
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GET /watchlist/hits?vehicle_identifier=&lot_id=&limit=
+func (h *WatchlistHandler) FindHits(c *gin.Context) {
+	var filter domain.WatchlistHitFilterDTO
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tham số lọc không hợp lệ", "details": err.Error()})
+		return
+	}
+
+	hits, err := h.watchlistService.FindHits(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy nhật ký phát hiện", "details": err.Error()})
+		return
+	}
+	if hits == nil {
+		hits = []domain.WatchlistHit{}
+	}
+	c.JSON(http.StatusOK, hits)
+}
+
+// GET /watchlist/:id
+func (h *WatchlistHandler) GetEntryByID(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Watchlist ID không hợp lệ"})
+		return
+	}
+
+	entry, err := h.watchlistService.GetEntry(c.Request.Context(), entryID)
+	if err != nil {
+		respondWatchlistError(c, err, "Lỗi khi lấy thông tin mục cảnh báo")
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// PUT /watchlist/:id
+func (h *WatchlistHandler) UpdateEntry(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Watchlist ID không hợp lệ"})
+		return
+	}
+	var dto domain.WatchlistDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.watchlistService.UpdateEntry(c.Request.Context(), entryID, dto)
+	if err != nil {
+		respondWatchlistError(c, err, "Không thể cập nhật mục cảnh báo")
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// DELETE /watchlist/:id
+func (h *WatchlistHandler) DeleteEntry(c *gin.Context) {
+	entryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Watchlist ID không hợp lệ"})
+		return
+	}
+
+	if err := h.watchlistService.DeleteEntry(c.Request.Context(), entryID); err != nil {
+		respondWatchlistError(c, err, "Không thể xóa mục cảnh báo")
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
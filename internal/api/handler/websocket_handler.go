@@ -1,116 +1,610 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
+	"smart_parking/internal/config"
 	"smart_parking/internal/domain"
-	"sync"
+	"smart_parking/internal/service"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Cho phép kết nối từ mọi nguồn
-	},
+const (
+	// Số sự kiện chờ hub xử lý; hub chỉ đẩy vào hàng đợi của từng client nên hiếm khi đầy
+	wsBroadcastBuffer = 256
+	// Thời gian tối đa cho một lần ghi xuống client
+	wsWriteWait = 10 * time.Second
+	// Kích thước tối đa message client gửi lên (auth / subscribe / resume)
+	wsMaxMessageSize = 4096
+	// Chu kỳ nạp lại các bãi được phân công, để operator bị gỡ khỏi bãi thôi nhận sự kiện của bãi đó
+	wsAssignmentRefreshInterval = time.Minute
+	// Subprotocol mang JWT khi upgrade: Sec-WebSocket-Protocol: access_token, <jwt>
+	wsTokenProtocol = "access_token"
+)
+
+// wsEventRoles - Loại event chỉ gửi cho một số vai trò; loại không có trong map gửi cho mọi vai trò
+var wsEventRoles = map[domain.GateEventType][]string{
+	domain.GateEventWatchlistAlert: {"admin", "operator"},
+	domain.GateEventVIPArrived:     {"admin", "operator"},
+}
+
+// wsClient - Kết nối đã xác thực. Đăng ký chỉ được goroutine Start của manager đọc / ghi; mọi message gửi xuống
+// đi qua hàng đợi send và do writePump ghi, nên một client chậm không làm chậm các client khác.
+type wsClient struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	userID    int
+	username  string
+	role      string
+	expiresAt time.Time
+
+	allowedLots map[int]bool                  // nil = mọi bãi (admin)
+	lots        map[int]bool                  // Bãi đã đăng ký, nil = mọi bãi được phép
+	eventTypes  map[domain.GateEventType]bool // Loại event đã đăng ký, nil = mọi loại
+}
+
+// canSeeLot kiểm tra bãi nằm trong phạm vi được phân công
+func (c *wsClient) canSeeLot(lotID int) bool {
+	return c.allowedLots == nil || c.allowedLots[lotID]
+}
+
+func (c *wsClient) accepts(event wsEvent) bool {
+	if !c.canSeeLot(event.lotID) {
+		return false
+	}
+	if c.lots != nil && !c.lots[event.lotID] {
+		return false
+	}
+	if c.eventTypes != nil && !c.eventTypes[event.eventType] {
+		return false
+	}
+	if roles, ok := wsEventRoles[event.eventType]; ok {
+		for _, role := range roles {
+			if role == c.role {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type wsEvent struct {
+	seq       uint64
+	lotID     int
+	eventType domain.GateEventType
+	payload   []byte
+}
+
+// wsControl - Message điều khiển từ client (subscribe / unsubscribe / resume), xử lý trong goroutine Start
+type wsControl struct {
+	client  *wsClient
+	message domain.WSClientMessage
+}
+
+// wsAssignment - Danh sách bãi được phân công vừa nạp lại của client
+type wsAssignment struct {
+	client *wsClient
+	lotIDs []int
+}
+
+// wsRegistration - Client mới xác thực; resume != nil thì gửi lại các sự kiện bị lỡ ngay khi đăng ký
+type wsRegistration struct {
+	client *wsClient
+	resume *domain.WSClientMessage
 }
 
 type WebSocketManager struct {
-	clients    map[*websocket.Conn]bool // Kết nối WebSocket hiện tại
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
-	broadcast  chan []byte
-	mutex      sync.RWMutex
+	upgrader   websocket.Upgrader
+	clients    map[*wsClient]bool // Kết nối WebSocket hiện tại
+	register   chan wsRegistration
+	unregister chan *wsClient
+	control    chan wsControl
+	assignment chan wsAssignment
+	broadcast  chan domain.GateEventNotification
+
+	streamID   string // Đổi mỗi lần server khởi động, để client biết seq cũ không còn ý nghĩa
+	seq        uint64
+	replay     *wsReplayBuffer
+	sendQueue  int
+	pongWait   time.Duration
+	pingPeriod time.Duration
 }
 
-func NewWebSocketManager() *WebSocketManager {
+func NewWebSocketManager(cfg *config.Config) *WebSocketManager {
+	pongWait, sendQueue := cfg.WebSocketPongTimeout, cfg.WebSocketSendQueueSize
+	if pongWait <= 0 {
+		pongWait = 60 * time.Second
+	}
+	if sendQueue <= 0 {
+		sendQueue = 256
+	}
 	return &WebSocketManager{
-		clients:    make(map[*websocket.Conn]bool),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
-		broadcast:  make(chan []byte),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  cfg.WebSocketReadBufferSize,
+			WriteBufferSize: cfg.WebSocketWriteBufferSize,
+			CheckOrigin:     allowedOriginChecker(cfg.WebSocketAllowedOrigins),
+		},
+		clients:    make(map[*wsClient]bool),
+		register:   make(chan wsRegistration),
+		unregister: make(chan *wsClient),
+		control:    make(chan wsControl),
+		assignment: make(chan wsAssignment),
+		broadcast:  make(chan domain.GateEventNotification, wsBroadcastBuffer),
+		streamID:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		replay:     newWSReplayBuffer(cfg.WebSocketReplayBuffer),
+		sendQueue:  sendQueue,
+		pongWait:   pongWait,
+		pingPeriod: pongWait * 9 / 10,
+	}
+}
+
+// allowedOriginChecker trả về nil (gorilla chỉ cho phép cùng host) khi không cấu hình origin
+func allowedOriginChecker(origins []string) func(r *http.Request) bool {
+	if len(origins) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			return func(r *http.Request) bool { return true }
+		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || allowed[origin]
 	}
 }
 
 func (wsm *WebSocketManager) Start() {
 	for {
 		select {
-		case client := <-wsm.register:
-			wsm.mutex.Lock()
+		case registration := <-wsm.register:
+			client := registration.client
 			wsm.clients[client] = true
-			wsm.mutex.Unlock()
-			log.Printf("WebSocket client connected. Total: %d", len(wsm.clients))
+			log.Printf("WebSocket client '%s' (%s) connected. Total: %d", client.username, client.role, len(wsm.clients))
+			if registration.resume != nil {
+				wsm.replayTo(client, *registration.resume)
+			}
 
 		case client := <-wsm.unregister:
-			wsm.mutex.Lock()
 			if _, ok := wsm.clients[client]; ok {
-				delete(wsm.clients, client)
-				client.Close()
+				wsm.drop(client)
+			}
+			log.Printf("WebSocket client '%s' disconnected. Total: %d", client.username, len(wsm.clients))
+
+		case request := <-wsm.control:
+			if _, ok := wsm.clients[request.client]; !ok {
+				continue
+			}
+			if request.message.Type == domain.WSMessageResume {
+				wsm.replayTo(request.client, request.message)
+			} else {
+				wsm.applySubscription(request.client, request.message)
+			}
+
+		case update := <-wsm.assignment:
+			if _, ok := wsm.clients[update.client]; ok {
+				wsm.applyAssignment(update.client, update.lotIDs)
 			}
-			wsm.mutex.Unlock()
-			log.Printf("WebSocket client disconnected. Total: %d", len(wsm.clients))
 
-		case message := <-wsm.broadcast:
-			wsm.mutex.RLock()
+		case notification := <-wsm.broadcast:
+			wsm.seq++
+			notification.Seq = wsm.seq
+			payload, err := json.Marshal(notification)
+			if err != nil {
+				log.Printf("Error marshaling gate event: %v", err)
+				continue
+			}
+			event := wsEvent{seq: wsm.seq, lotID: notification.LotID, eventType: notification.EventType, payload: payload}
+			wsm.replay.add(event)
+
+			now := time.Now()
 			for client := range wsm.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					log.Printf("Error writing to WebSocket client: %v", err)
-					client.Close()
-					delete(wsm.clients, client)
+				if !client.expiresAt.IsZero() && now.After(client.expiresAt) {
+					wsm.send(client, domain.WSServerMessage{Type: domain.WSMessageError, Error: "token đã hết hạn"})
+					wsm.drop(client)
+					continue
+				}
+				if client.accepts(event) {
+					wsm.enqueue(client, event.payload)
 				}
 			}
-			wsm.mutex.RUnlock()
 		}
 	}
 }
 
-func (wsm *WebSocketManager) BroadcastGateEvent(event domain.GateEventNotification) {
-	message, err := json.Marshal(event)
+// replayTo gửi lại các sự kiện client được phép nhận có seq sau last_seq. stream_id khác stream hiện tại nghĩa là
+// server đã khởi động lại, mọi sự kiện còn trong buffer đều là sự kiện client chưa nhận.
+// Replay buffer có thể lớn hơn hàng đợi send: nếu số sự kiện cần gửi không vừa chỗ trống của hàng đợi thì không
+// gửi lại gì, chỉ trả replayed với missed=true để client tải lại trạng thái qua REST thay vì bị ngắt vì đầy hàng đợi.
+func (wsm *WebSocketManager) replayTo(client *wsClient, message domain.WSClientMessage) {
+	var lastSeq uint64
+	if message.LastSeq != nil {
+		lastSeq = *message.LastSeq
+	}
+	if message.StreamID != "" && message.StreamID != wsm.streamID {
+		lastSeq = 0
+	}
+	if lastSeq > wsm.seq {
+		lastSeq = wsm.seq
+	}
+
+	events := wsm.replay.since(lastSeq)
+	reply := domain.WSServerMessage{Type: domain.WSMessageReplayed, StreamID: wsm.streamID, Seq: wsm.seq}
+	// Sự kiện ngay sau last_seq đã bị đẩy ra khỏi buffer (hoặc thuộc lần chạy trước của server)
+	reply.Missed = lastSeq < wsm.seq && (len(events) == 0 || events[0].seq > lastSeq+1)
+	if message.StreamID != "" && message.StreamID != wsm.streamID {
+		reply.Missed = true
+	}
+	var accepted []wsEvent
+	for _, event := range events {
+		if client.accepts(event) {
+			accepted = append(accepted, event)
+		}
+	}
+	// Chừa một chỗ cho message replayed
+	if room := cap(client.send) - len(client.send) - 1; len(accepted) > room {
+		log.Printf("WebSocket: %d sự kiện cần gửi lại cho '%s' vượt chỗ trống hàng đợi (%d), yêu cầu client tải lại trạng thái",
+			len(accepted), client.username, room)
+		accepted = nil
+		reply.Missed = true
+	}
+	for _, event := range accepted {
+		if !wsm.enqueue(client, event.payload) {
+			return
+		}
+		reply.Replayed++
+	}
+	wsm.send(client, reply)
+	log.Printf("WebSocket: Gửi lại %d sự kiện sau seq %d cho '%s' (missed=%t)", reply.Replayed, lastSeq, client.username, reply.Missed)
+}
+
+// applySubscription thay (subscribe) hoặc bớt (unsubscribe) bãi / loại event của client. Bãi ngoài phạm vi
+// được phân công bị từ chối, đăng ký cũ được giữ nguyên.
+func (wsm *WebSocketManager) applySubscription(client *wsClient, message domain.WSClientMessage) {
+	var forbidden []string
+	for _, lotID := range message.LotIDs {
+		if !client.canSeeLot(lotID) {
+			forbidden = append(forbidden, strconv.Itoa(lotID))
+		}
+	}
+	if len(forbidden) > 0 {
+		wsm.send(client, domain.WSServerMessage{
+			Type:  domain.WSMessageError,
+			Error: fmt.Sprintf("không có quyền xem bãi %s", strings.Join(forbidden, ", ")),
+		})
+		return
+	}
+
+	switch message.Type {
+	case domain.WSMessageSubscribe:
+		// Danh sách rỗng = nhận tất cả
+		client.lots, client.eventTypes = nil, nil
+		if len(message.LotIDs) > 0 {
+			client.lots = make(map[int]bool, len(message.LotIDs))
+			for _, lotID := range message.LotIDs {
+				client.lots[lotID] = true
+			}
+		}
+		if len(message.EventTypes) > 0 {
+			client.eventTypes = make(map[domain.GateEventType]bool, len(message.EventTypes))
+			for _, eventType := range message.EventTypes {
+				client.eventTypes[eventType] = true
+			}
+		}
+	case domain.WSMessageUnsubscribe:
+		// Chỉ bớt được khỏi danh sách cụ thể; đang nhận tất cả thì cần subscribe lại với danh sách mong muốn
+		for _, lotID := range message.LotIDs {
+			delete(client.lots, lotID)
+		}
+		for _, eventType := range message.EventTypes {
+			delete(client.eventTypes, eventType)
+		}
+	}
+
+	reply := domain.WSServerMessage{Type: domain.WSMessageSubscribed}
+	if client.lots != nil {
+		reply.LotIDs = []int{}
+		for lotID := range client.lots {
+			reply.LotIDs = append(reply.LotIDs, lotID)
+		}
+		sort.Ints(reply.LotIDs)
+	}
+	if client.eventTypes != nil {
+		reply.EventTypes = []domain.GateEventType{}
+		for eventType := range client.eventTypes {
+			reply.EventTypes = append(reply.EventTypes, eventType)
+		}
+		sort.Slice(reply.EventTypes, func(i, j int) bool { return reply.EventTypes[i] < reply.EventTypes[j] })
+	}
+	wsm.send(client, reply)
+}
+
+// applyAssignment thay phạm vi bãi được phép của client và bỏ các bãi bị gỡ khỏi đăng ký. Chỉ báo client khi
+// danh sách thay đổi.
+func (wsm *WebSocketManager) applyAssignment(client *wsClient, lotIDs []int) {
+	allowed := make(map[int]bool, len(lotIDs))
+	for _, lotID := range lotIDs {
+		allowed[lotID] = true
+	}
+	changed := len(allowed) != len(client.allowedLots)
+	for lotID := range client.allowedLots {
+		if !allowed[lotID] {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	client.allowedLots = allowed
+	for lotID := range client.lots {
+		if !allowed[lotID] {
+			delete(client.lots, lotID)
+		}
+	}
+	sorted := append([]int{}, lotIDs...)
+	sort.Ints(sorted)
+	log.Printf("WebSocket: Bãi được phân công của '%s' thay đổi: %v", client.username, sorted)
+	wsm.send(client, domain.WSServerMessage{Type: domain.WSMessageLotsChanged, AllowedLots: sorted})
+}
+
+func (wsm *WebSocketManager) send(client *wsClient, message domain.WSServerMessage) {
+	payload, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling gate event: %v", err)
+		log.Printf("Error marshaling WebSocket message: %v", err)
 		return
 	}
+	wsm.enqueue(client, payload)
+}
 
+// enqueue đẩy message vào hàng đợi của client mà không chờ. Hàng đợi đầy nghĩa là client không đọc kịp,
+// client bị ngắt và có thể nối lại bằng last_seq.
+func (wsm *WebSocketManager) enqueue(client *wsClient, payload []byte) bool {
 	select {
-	case wsm.broadcast <- message:
+	case client.send <- payload:
+		return true
 	default:
-		log.Println("Broadcast channel is full, dropping message")
+		log.Printf("WebSocket: Hàng đợi của '%s' đầy, ngắt kết nối client chậm", client.username)
+		wsm.drop(client)
+		return false
+	}
+}
+
+// drop gỡ client khỏi hub; đóng send để writePump gửi close frame và đóng kết nối
+func (wsm *WebSocketManager) drop(client *wsClient) {
+	if _, ok := wsm.clients[client]; !ok {
+		return
+	}
+	delete(wsm.clients, client)
+	close(client.send)
+}
+
+// BroadcastGateEvent chuyển sự kiện cho hub gán seq và phân phối. Chỉ chờ khi hub đang tồn đọng quá
+// wsBroadcastBuffer sự kiện, không bỏ sự kiện.
+func (wsm *WebSocketManager) BroadcastGateEvent(event domain.GateEventNotification) {
+	wsm.broadcast <- event
+}
+
+// writePump là goroutine duy nhất ghi vào kết nối: message từ hàng đợi và ping định kỳ
+func (wsm *WebSocketManager) writePump(client *wsClient) {
+	ticker := time.NewTicker(wsm.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				log.Printf("Error writing to WebSocket client '%s': %v", client.username, err)
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
 }
 
 type WebSocketHandler struct {
-	wsManager *WebSocketManager
+	wsManager   *WebSocketManager
+	authService *service.AuthService
+	authTimeout time.Duration
 }
 
-func NewWebSocketHandler(wsManager *WebSocketManager) *WebSocketHandler {
-	return &WebSocketHandler{wsManager: wsManager}
+func NewWebSocketHandler(wsManager *WebSocketManager, authService *service.AuthService, authTimeout time.Duration) *WebSocketHandler {
+	return &WebSocketHandler{wsManager: wsManager, authService: authService, authTimeout: authTimeout}
 }
 
+// GET /ws[?last_seq=<seq>&stream_id=<id>]
+// JWT gửi qua header Sec-WebSocket-Protocol: access_token, <jwt> hoặc message đầu tiên {"type": "auth", "token": "<jwt>"};
+// không nhận token qua query để token không lọt vào access log. Sau khi xác thực, client
+// nhận mọi sự kiện của các bãi được phép cho tới khi gửi {"type": "subscribe", "lot_ids": [...], "event_types": [...]}.
+// Console nối lại gửi last_seq (qua query, message auth hoặc {"type": "resume"}) để nhận lại các sự kiện bị lỡ.
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if c.Query("token") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không nhận token qua query, hãy gửi qua Sec-WebSocket-Protocol hoặc message auth"})
+		return
+	}
+	token := tokenFromSubprotocol(c.Request)
+	var responseHeader http.Header
+	if token != "" {
+		// Trình duyệt đóng kết nối nếu server không chọn một trong các subprotocol client đề nghị
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": {wsTokenProtocol}}
+	}
+	conn, err := h.wsManager.upgrader.Upgrade(c.Writer, c.Request, responseHeader)
 	if err != nil {
 		log.Printf("Failed to upgrade to WebSocket: %v", err)
 		return
 	}
+	// Giới hạn kích thước cả message auth đầu tiên, trước khi client được xác thực
+	conn.SetReadLimit(wsMaxMessageSize)
 
-	h.wsManager.register <- conn
+	client, resume, err := h.authenticate(c, conn, token)
+	if err != nil {
+		log.Printf("WebSocket: Từ chối kết nối từ %s: %v", c.ClientIP(), err)
+		conn.WriteJSON(domain.WSServerMessage{Type: domain.WSMessageError, Error: err.Error()})
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unauthorized"))
+		conn.Close()
+		return
+	}
+
+	go h.wsManager.writePump(client)
+	h.wsManager.register <- wsRegistration{client: client, resume: resume}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if client.role != "admin" {
+		go h.refreshAssignments(ctx, client)
+	}
 
-	// Keep connection alive và handle disconnect
+	// Đọc message điều khiển; không nhận được pong trong pongWait thì coi client đã chết
 	go func() {
 		defer func() {
-			h.wsManager.unregister <- conn
+			cancel()
+			h.wsManager.unregister <- client
 		}()
 
+		conn.SetReadDeadline(time.Now().Add(h.wsManager.pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(h.wsManager.pongWait))
+		})
+
 		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
+			var message domain.WSClientMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				var syntaxErr *json.SyntaxError
+				var typeErr *json.UnmarshalTypeError
+				if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+					log.Printf("WebSocket: Message không hợp lệ từ '%s': %v", client.username, err)
+					continue
+				}
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					log.Printf("WebSocket error: %v", err)
 				}
 				break
 			}
+			switch message.Type {
+			case domain.WSMessageSubscribe, domain.WSMessageUnsubscribe, domain.WSMessageResume:
+				h.wsManager.control <- wsControl{client: client, message: message}
+			default:
+				log.Printf("WebSocket: Bỏ qua message '%s' từ '%s'", message.Type, client.username)
+			}
 		}
 	}()
 }
+
+// refreshAssignments nạp lại định kỳ các bãi được phân công cho tới khi client ngắt kết nối; hub áp dụng thay đổi.
+func (h *WebSocketHandler) refreshAssignments(ctx context.Context, client *wsClient) {
+	ticker := time.NewTicker(wsAssignmentRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lotIDs, err := h.authService.AssignedLotIDs(ctx, client.userID)
+			if err != nil {
+				log.Printf("WebSocket: Lỗi nạp lại bãi được phân công của '%s': %v", client.username, err)
+				continue
+			}
+			select {
+			case h.wsManager.assignment <- wsAssignment{client: client, lotIDs: lotIDs}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// tokenFromSubprotocol lấy JWT từ Sec-WebSocket-Protocol dạng "access_token, <jwt>"
+func tokenFromSubprotocol(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == wsTokenProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// authenticate dùng JWT từ Sec-WebSocket-Protocol hoặc message auth đầu tiên, rồi nạp các bãi được phân công cho
+// operator. Trả về thêm yêu cầu gửi lại sự kiện nếu client có last_seq.
+func (h *WebSocketHandler) authenticate(c *gin.Context, conn *websocket.Conn, token string) (*wsClient, *domain.WSClientMessage, error) {
+	auth := domain.WSClientMessage{Type: domain.WSMessageAuth, Token: token, StreamID: c.Query("stream_id")}
+	if lastSeqStr := c.Query("last_seq"); lastSeqStr != "" {
+		lastSeq, err := strconv.ParseUint(lastSeqStr, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("last_seq không hợp lệ")
+		}
+		auth.LastSeq = &lastSeq
+	}
+	if auth.Token == "" {
+		conn.SetReadDeadline(time.Now().Add(h.authTimeout))
+		if err := conn.ReadJSON(&auth); err != nil {
+			return nil, nil, fmt.Errorf("không nhận được message auth: %w", err)
+		}
+		conn.SetReadDeadline(time.Time{})
+		if auth.Type != domain.WSMessageAuth || auth.Token == "" {
+			return nil, nil, fmt.Errorf("message đầu tiên phải là auth kèm token")
+		}
+	}
+
+	_, claims, err := h.authService.ValidateToken(auth.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	userIDStr, okUserID := claims["sub"].(string)
+	role, okRole := claims["role"].(string)
+	username, okUsername := claims["username"].(string)
+	userID, errUserID := strconv.Atoi(userIDStr)
+	if !okUserID || !okRole || !okUsername || errUserID != nil {
+		return nil, nil, fmt.Errorf("thông tin người dùng trong token không hợp lệ")
+	}
+
+	client := &wsClient{conn: conn, send: make(chan []byte, h.wsManager.sendQueue), userID: userID, username: username, role: role}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		client.expiresAt = exp.Time
+	}
+
+	reply := domain.WSServerMessage{Type: domain.WSMessageAuthOK, Username: username, Role: role, StreamID: h.wsManager.streamID}
+	if role != "admin" {
+		lotIDs, err := h.authService.AssignedLotIDs(c.Request.Context(), userID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("không lấy được bãi được phân công: %w", err)
+		}
+		client.allowedLots = make(map[int]bool, len(lotIDs))
+		for _, lotID := range lotIDs {
+			client.allowedLots[lotID] = true
+		}
+		reply.AllowedLots = lotIDs
+	}
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteJSON(reply); err != nil {
+		return nil, nil, err
+	}
+
+	if auth.LastSeq == nil {
+		return client, nil, nil
+	}
+	return client, &auth, nil
+}
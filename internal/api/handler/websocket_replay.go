@@ -0,0 +1,40 @@
+package handler
+
+// wsReplayBuffer - Vòng đệm các sự kiện đã broadcast gần nhất, để console mất kết nối nhận lại những gì đã lỡ.
+// Chỉ goroutine Start của manager truy cập nên không cần khóa.
+type wsReplayBuffer struct {
+	events []wsEvent
+	start  int // Vị trí sự kiện cũ nhất
+	count  int
+}
+
+func newWSReplayBuffer(size int) *wsReplayBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &wsReplayBuffer{events: make([]wsEvent, size)}
+}
+
+func (b *wsReplayBuffer) add(event wsEvent) {
+	if len(b.events) == 0 {
+		return
+	}
+	if b.count < len(b.events) {
+		b.events[(b.start+b.count)%len(b.events)] = event
+		b.count++
+		return
+	}
+	b.events[b.start] = event
+	b.start = (b.start + 1) % len(b.events)
+}
+
+// since trả về các sự kiện còn trong buffer có seq > lastSeq, theo thứ tự seq
+func (b *wsReplayBuffer) since(lastSeq uint64) []wsEvent {
+	var events []wsEvent
+	for i := 0; i < b.count; i++ {
+		if event := b.events[(b.start+i)%len(b.events)]; event.seq > lastSeq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
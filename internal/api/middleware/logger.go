@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedQueryParams - Tham số query có thể chứa secret, không được ghi ra access log
+var redactedQueryParams = []string{"token", "access_token"}
+
+// Logger ghi access log giống gin.Logger nhưng che giá trị các tham số query chứa token
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency,
+			param.ClientIP,
+			param.Method,
+			redactPath(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// redactPath thay giá trị các tham số trong redactedQueryParams bằng REDACTED
+func redactPath(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Query không parse được thì bỏ hẳn, tránh ghi nguyên văn
+		return base + "?REDACTED"
+	}
+	redacted := false
+	for _, key := range redactedQueryParams {
+		if _, ok := query[key]; ok {
+			query.Set(key, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"smart_parking/internal/api/handler"
 	"smart_parking/internal/api/middleware"
+	"smart_parking/internal/iot"
 	"smart_parking/internal/service"
+	"time"
 	// "parking_system_go/internal/domain" // Không cần trực tiếp ở đây nữa
 
 	"github.com/gin-gonic/gin"
@@ -14,9 +16,14 @@ import (
 )
 
 func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *service.IoTService,
-	authMw *middleware.AuthMiddleware, lprService *service.LPRService, iotServiceUpdated *service.IoTService, wsManager *handler.WebSocketManager) *gin.Engine {
-	r := gin.Default()
-	r.Use(gin.Logger())
+	authMw *middleware.AuthMiddleware, lprProvider service.LPRProvider, iotServiceUpdated *service.IoTService, wsManager *handler.WebSocketManager,
+	tariffService *service.TariffService, paymentService *service.PaymentService, reservationService *service.ReservationService,
+	subscriptionService *service.SubscriptionService, watchlistService *service.WatchlistService,
+	evidenceService *service.EvidenceService, lprFeedbackService *service.LPRFeedbackService,
+	lprThresholdService *service.LPRThresholdService, wsAuthTimeout time.Duration,
+	httpEventSource *iot.HTTPEventSource, ingestToken string) *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.Logger()) // Như gin.Logger nhưng che token trong query
 	r.Use(gin.Recovery())
 
 	r.Use(func(c *gin.Context) {
@@ -31,8 +38,9 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 		c.Next()
 	})
 
-	// WebSocket endpoint (không cần auth cho real-time connection)
-	wsHandler := handler.NewWebSocketHandler(wsManager)
+	// WebSocket endpoint: JWT qua Sec-WebSocket-Protocol hoặc message auth đầu tiên (trình duyệt không gửi được
+	// header Authorization khi upgrade)
+	wsHandler := handler.NewWebSocketHandler(wsManager, as, wsAuthTimeout)
 	r.GET("/ws", wsHandler.HandleWebSocket)
 
 	authHandler := handler.NewAuthHandler(as)
@@ -42,9 +50,27 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 		authRoutes.POST("/login", authHandler.Login)
 	}
 
+	// Webhook từ cổng thanh toán (xác thực bằng chữ ký, không dùng JWT)
+	paymentH := handler.NewPaymentHandler(paymentService)
+	r.POST("/webhooks/payments/:gateway", paymentH.HandleWebhook)
+
+	// Gateway tại bãi đẩy sự kiện thiết bị qua HTTP (xác thực bằng X-Ingest-Token, chỉ khi EVENT_SOURCES có http)
+	if httpEventSource != nil {
+		ingestH := handler.NewDeviceIngestHandler(httpEventSource, ingestToken)
+		r.POST("/ingest/device-events", ingestH.IngestDeviceEvent)
+	}
+
 	v1 := r.Group("/api/v1")
 	v1.Use(authMw.Authenticate())
 	{
+		// Phân công bãi đỗ cho operator (giới hạn sự kiện WebSocket theo bãi)
+		userRoutes := v1.Group("/users")
+		userRoutes.Use(authMw.AuthorizeRole("admin"))
+		{
+			userRoutes.GET("/:id/lots", authHandler.GetUserLots)
+			userRoutes.PUT("/:id/lots", authHandler.SetUserLots)
+		}
+
 		lotH := handler.NewParkingLotHandler(ps)
 		lotRoutes := v1.Group("/parking-lots")
 		{
@@ -66,6 +92,55 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 
 			sessionH_nested := handler.NewParkingSessionHandler(ps)
 			lotRoutes.GET("/:id/active-sessions", sessionH_nested.GetActiveSessionsByLotID)
+			lotRoutes.GET("/:id/active-sessions/similar", sessionH_nested.FindSimilarActiveSessions)
+
+			tariffH := handler.NewTariffHandler(tariffService)
+			tariffRoutes := lotRoutes.Group("/:id/tariffs")
+			{
+				tariffRoutes.POST("", authMw.AuthorizeRole("admin"), tariffH.CreateTariff)
+				tariffRoutes.GET("", tariffH.GetTariffsByLotID)
+				tariffRoutes.GET("/:tariff_id", tariffH.GetTariffByID)
+				tariffRoutes.PUT("/:tariff_id", authMw.AuthorizeRole("admin"), tariffH.UpdateTariff)
+				tariffRoutes.DELETE("/:tariff_id", authMw.AuthorizeRole("admin"), tariffH.DeleteTariff)
+			}
+		}
+
+		holidayH := handler.NewHolidayHandler(tariffService)
+		holidayRoutes := v1.Group("/holidays")
+		{
+			holidayRoutes.POST("", authMw.AuthorizeRole("admin"), holidayH.CreateHoliday)
+			holidayRoutes.GET("", holidayH.GetHolidays)
+			holidayRoutes.DELETE("/:id", authMw.AuthorizeRole("admin"), holidayH.DeleteHoliday)
+		}
+
+		reservationH := handler.NewReservationHandler(reservationService)
+		reservationRoutes := v1.Group("/reservations")
+		{
+			reservationRoutes.POST("", reservationH.CreateReservation)
+			reservationRoutes.GET("", reservationH.FindReservations)
+			reservationRoutes.GET("/:id", reservationH.GetReservationByID)
+			reservationRoutes.POST("/:id/cancel", reservationH.CancelReservation)
+		}
+
+		subscriptionH := handler.NewSubscriptionHandler(subscriptionService)
+		subscriptionRoutes := v1.Group("/subscriptions")
+		{
+			subscriptionRoutes.POST("", authMw.AuthorizeRole("admin"), subscriptionH.CreateSubscription)
+			subscriptionRoutes.GET("", authMw.AuthorizeRole("admin"), subscriptionH.FindSubscriptions)
+			subscriptionRoutes.GET("/:id", authMw.AuthorizeRole("admin"), subscriptionH.GetSubscriptionByID)
+			subscriptionRoutes.PUT("/:id", authMw.AuthorizeRole("admin"), subscriptionH.UpdateSubscription)
+			subscriptionRoutes.DELETE("/:id", authMw.AuthorizeRole("admin"), subscriptionH.DeleteSubscription)
+		}
+
+		watchlistH := handler.NewWatchlistHandler(watchlistService)
+		watchlistRoutes := v1.Group("/watchlist")
+		{
+			watchlistRoutes.POST("", authMw.AuthorizeRole("admin"), watchlistH.CreateEntry)
+			watchlistRoutes.GET("", authMw.AuthorizeRole("admin"), watchlistH.FindEntries)
+			watchlistRoutes.GET("/hits", authMw.AuthorizeRole("admin"), watchlistH.FindHits)
+			watchlistRoutes.GET("/:id", authMw.AuthorizeRole("admin"), watchlistH.GetEntryByID)
+			watchlistRoutes.PUT("/:id", authMw.AuthorizeRole("admin"), watchlistH.UpdateEntry)
+			watchlistRoutes.DELETE("/:id", authMw.AuthorizeRole("admin"), watchlistH.DeleteEntry)
 		}
 
 		slotH := handler.NewParkingSlotHandler(ps)
@@ -88,10 +163,24 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 		sessionH := handler.NewParkingSessionHandler(ps) // Sử dụng handler đã tạo
 		sessionRoutes := v1.Group("/parking-sessions")
 		{
-			sessionRoutes.POST("/check-in", sessionH.VehicleCheckIn)   // API check-in
-			sessionRoutes.POST("/check-out", sessionH.VehicleCheckOut) // API check-out
-			sessionRoutes.GET("", sessionH.FindParkingSessions)        // API filter sessions
+			sessionRoutes.POST("/check-in", authMw.AuthorizeRole("admin", "operator"), sessionH.VehicleCheckIn)   // API check-in
+			sessionRoutes.POST("/check-out", authMw.AuthorizeRole("admin", "operator"), sessionH.VehicleCheckOut) // API check-out
+			sessionRoutes.GET("", sessionH.FindParkingSessions)                                                   // API filter sessions
 			sessionRoutes.GET("/:id", sessionH.GetParkingSessionByID)
+			sessionRoutes.PUT("/:id/vehicle", authMw.AuthorizeRole("admin", "operator"), sessionH.UpdateSessionVehicle)
+
+			sessionRoutes.GET("/:id/payments", paymentH.GetSessionPayments)
+			sessionRoutes.POST("/:id/payments", paymentH.StartPayment)
+			sessionRoutes.POST("/:id/payments/cash", authMw.AuthorizeRole("admin", "operator"), paymentH.RecordCashPayment)
+			sessionRoutes.POST("/:id/waive", authMw.AuthorizeRole("admin", "operator"), paymentH.WaivePayment)
+
+			exitPaymentH := handler.NewExitPaymentHandler(iotServiceUpdated)
+			sessionRoutes.POST("/:id/confirm-payment", authMw.AuthorizeRole("admin", "operator"), exitPaymentH.ConfirmExitPayment)
+		}
+
+		paymentRoutes := v1.Group("/payments")
+		{
+			paymentRoutes.POST("/:payment_id/refunds", authMw.AuthorizeRole("admin"), paymentH.RefundPayment)
 		}
 
 		// Device Monitoring Routes
@@ -103,17 +192,18 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 			deviceRoutes.GET("/:thing_name", deviceH.GetDeviceByThingName)
 		}
 
-		if is != nil {
-			iotCmdH := handler.NewIoTCommandHandler(is)
+		if iotServiceUpdated != nil {
+			iotCmdH := handler.NewIoTCommandHandler(iotServiceUpdated) // Cần gate event repo để kiểm tra pay-before-exit
 			iotRoutes := v1.Group("/iot/commands")
 			iotRoutes.Use(authMw.AuthorizeRole("admin", "operator"))
 			{
 				iotRoutes.POST("/barrier", iotCmdH.ControlBarrier)
+				iotRoutes.GET("/:request_id", iotCmdH.GetCommandStatus)
 			}
 		}
 
-		if lprService != nil { // Kiểm tra nếu lprService được truyền vào
-			lprH := handler.NewLPRHandler(lprService, ps) // Truyền cả parkingService
+		if lprProvider != nil { // Kiểm tra nếu lprProvider được truyền vào
+			lprH := handler.NewLPRHandler(lprProvider, ps) // Truyền cả parkingService
 			lprRoutes := v1.Group("/lpr")
 			// Có thể cần quyền admin hoặc operator cho API này
 			lprRoutes.Use(authMw.AuthorizeRole("admin", "operator"))
@@ -122,7 +212,30 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 			}
 		}
 
-		gateEventHandler := handler.NewGateEventHandler(iotServiceUpdated, lprService, ps)
+		if lprFeedbackService != nil {
+			feedbackH := handler.NewLPRFeedbackHandler(lprFeedbackService)
+			feedbackRoutes := v1.Group("/lpr")
+			feedbackRoutes.Use(authMw.AuthorizeRole("admin", "operator"))
+			{
+				feedbackRoutes.GET("/corrections", feedbackH.GetCorrections)
+				feedbackRoutes.GET("/accuracy", feedbackH.GetAccuracy)
+				feedbackRoutes.GET("/dataset", authMw.AuthorizeRole("admin"), feedbackH.ExportDataset)
+			}
+		}
+
+		if lprThresholdService != nil {
+			thresholdH := handler.NewLPRThresholdHandler(lprThresholdService)
+			thresholdRoutes := v1.Group("/lpr/thresholds")
+			thresholdRoutes.Use(authMw.AuthorizeRole("admin"))
+			{
+				thresholdRoutes.GET("", thresholdH.ListThresholds)
+				thresholdRoutes.PUT("", thresholdH.SetThreshold)
+				thresholdRoutes.DELETE("/:id", thresholdH.DeleteThreshold)
+				thresholdRoutes.GET("/history", thresholdH.GetHistory)
+			}
+		}
+
+		gateEventHandler := handler.NewGateEventHandler(iotServiceUpdated, lprProvider, ps)
 		gateRoutes := v1.Group("/gate-events")
 		gateRoutes.Use(authMw.AuthorizeRole("admin", "operator"))
 		{
@@ -130,6 +243,19 @@ func SetupRouter(as *service.AuthService, ps *service.ParkingService, is *servic
 			gateRoutes.POST("/create-session", gateEventHandler.CreateSessionFromEvent)
 			gateRoutes.GET("/pending", gateEventHandler.GetPendingGateEvents)
 		}
+
+		if evidenceService != nil { // nil khi EVIDENCE_STORE=none
+			evidenceH := handler.NewEvidenceHandler(evidenceService)
+			evidenceRoutes := v1.Group("/evidence")
+			evidenceRoutes.Use(authMw.AuthorizeRole("admin", "operator"))
+			{
+				evidenceRoutes.GET("/:id", evidenceH.GetEvidence)
+				evidenceRoutes.GET("/:id/image", evidenceH.GetEvidenceImage)
+				evidenceRoutes.PUT("/:id/hold", authMw.AuthorizeRole("admin"), evidenceH.SetLegalHold)
+			}
+			gateRoutes.GET("/:event_id/evidence", evidenceH.GetGateEventEvidence)
+			v1.GET("/parking-sessions/:id/evidence", authMw.AuthorizeRole("admin", "operator"), evidenceH.GetSessionEvidence)
+		}
 	}
 	return r
 }
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -22,6 +25,21 @@ type Config struct {
 	SQSEventQueueURL string
 	IoTMQTTEndpoint  string
 
+	// SQS Settings
+	SQSWorkers              int           // Số worker xử lý message SQS song song, chia theo device_id (default: 8)
+	SQSVisibilityTimeout    time.Duration // Visibility timeout khi nhận message, được gia hạn khi xử lý lâu (default: 60 giây)
+	EventSourceDrainTimeout time.Duration // Thời gian tối đa xử lý nốt sự kiện đã nhận khi tắt server (default: 20 giây)
+
+	// Command Settings
+	CommandTransport          string        // Kênh gửi lệnh xuống thiết bị: aws_iot, mqtt, loopback (default: aws_iot)
+	CommandTopicTemplate      string        // Topic lệnh rào chắn, thay {thing_name} và {barrier_type} (default: topic chung cũ smart_parking/command/barriers/{barrier_type})
+	CommandAckTimeout         time.Duration // Chờ thiết bị xác nhận trước khi gửi lại lần đầu, nhân đôi sau mỗi lần (default: 5 giây)
+	CommandRetryMaxBackoff    time.Duration // Thời gian chờ tối đa giữa hai lần gửi (default: 60 giây)
+	CommandMaxAttempts        int           // Số lần gửi tối đa trước khi lệnh timed_out / failed (default: 4)
+	CommandAlertAfterFailures int           // Báo operator qua WebSocket sau số lần gửi không được xác nhận này, 0 = chỉ khi kết thúc (default: 2)
+	CommandExecuteTimeout     time.Duration // Đã xác nhận mà rào không báo chuyển trạng thái trong khoảng này thì timed_out (default: 30 giây)
+	CommandCheckInterval      time.Duration // Chu kỳ quét lệnh cần gửi lại (default: 2 giây)
+
 	JWTSecret          string        // Secret key cho JWT
 	JWTExpirationHours time.Duration // Thời gian hết hạn của JWT
 
@@ -30,13 +48,74 @@ type Config struct {
 	GateEventCleanupInterval time.Duration // Interval cho cleanup job (default: 1 phút)
 	LPRConfidenceThreshold   float32       // Ngưỡng confidence để auto-create session (default: 0.8)
 
+	// LPR Settings
+	LPRProvider          string // "rekognition" (default) hoặc "local"
+	LPRLocalFixturesPath string // File JSON fixture cho LPR local (sha256 ảnh -> ứng viên)
+	// File JSON fixture thuộc tính xe cho LPR local (sha256 ảnh -> loại xe / màu xe / hãng xe)
+	LPRLocalVehicleFixturesPath string
+	LPRMaxCandidates            int // Số biển số ứng viên tối đa provider trả về (default: 10)
+	// Tự điều chỉnh ngưỡng confidence theo tỉ lệ operator phải sửa biển số
+	LPRAutoTuneEnabled      bool          // Bật job tự điều chỉnh cho các ngưỡng có auto_tune (default: false)
+	LPRAutoTuneInterval     time.Duration // Interval cho job tự điều chỉnh (default: 60 phút)
+	LPRAutoTuneWindow       time.Duration // Cửa sổ trượt để tính tỉ lệ sửa (default: 168 giờ)
+	LPRAutoTuneMinSamples   int           // Số biển số máy đọc tối thiểu trong cửa sổ để điều chỉnh (default: 30)
+	LPRAutoTuneTargetRate   float64       // Tỉ lệ sửa mục tiêu (default: 0.05)
+	LPRAutoTuneStep         float64       // Mức tăng / giảm ngưỡng mỗi lần điều chỉnh (default: 0.02)
+	LPRAutoTuneMinThreshold float64       // Cận dưới của ngưỡng khi tự điều chỉnh (default: 0.6)
+	LPRAutoTuneMaxThreshold float64       // Cận trên của ngưỡng khi tự điều chỉnh (default: 0.98)
+
+	// Evidence Settings
+	EvidenceStore         string        // "filesystem" (default), "s3" hoặc "none"
+	EvidenceDir           string        // Thư mục lưu ảnh cho filesystem store
+	EvidenceS3Bucket      string        // Bucket cho s3 store
+	EvidenceS3Prefix      string        // Prefix key trong bucket
+	EvidenceS3Endpoint    string        // Endpoint dịch vụ tương thích S3 (MinIO...), rỗng = AWS S3
+	EvidenceS3PathStyle   bool          // Dùng path-style URL (cần cho phần lớn dịch vụ tương thích S3)
+	EvidenceRetentionDays int           // Số ngày giữ ảnh trước khi purge, <= 0 = giữ vĩnh viễn (default: 90)
+	EvidencePurgeInterval time.Duration // Interval cho job purge ảnh (default: 24 giờ)
+
 	// WebSocket Settings
-	WebSocketReadBufferSize  int // Default: 1024
-	WebSocketWriteBufferSize int // Default: 1024
+	WebSocketReadBufferSize  int           // Default: 1024
+	WebSocketWriteBufferSize int           // Default: 1024
+	WebSocketAllowedOrigins  []string      // Origin được phép kết nối, rỗng = chỉ cùng host, "*" = mọi origin
+	WebSocketAuthTimeout     time.Duration // Thời gian chờ message auth khi không gửi token qua Sec-WebSocket-Protocol (default: 10 giây)
+	WebSocketSendQueueSize   int           // Số message chờ gửi tối đa mỗi client, đầy thì ngắt client chậm (default: 256)
+	WebSocketPongTimeout     time.Duration // Không nhận pong trong khoảng này thì coi client đã chết (default: 60 giây)
+	WebSocketReplayBuffer    int           // Số sự kiện gần nhất giữ lại để gửi lại khi client nối lại, chung cho mọi client (default: 1000); phần gửi lại vượt hàng đợi send thì chỉ báo missed
+
+	// Event Source Settings
+	EventSources        []string      // Các nguồn sự kiện thiết bị chạy song song: sqs, mqtt, http (default: sqs)
+	EventIngestToken    string        // Token gateway gửi trong header X-Ingest-Token khi POST /ingest/device-events
+	DeviceEventDedupTTL time.Duration // Thời gian giữ dedup key để bỏ sự kiện gửi lại (default: 24 giờ)
+
+	// MQTT Settings (nguồn sự kiện mqtt)
+	MQTTBrokerURL          string // tcp://host:1883, ssl://host:8883, mqtts://...
+	MQTTClientID           string // Client id duy nhất cho mỗi instance khi dùng phiên bền (default: smart-parking-backend)
+	MQTTUsername           string
+	MQTTPassword           string
+	MQTTTopics             []string      // Topic filter, phân tách bằng dấu phẩy (default: smart_parking/events/#)
+	MQTTSharedGroup        string        // Nhóm shared subscription ($share/<group>/...) khi chạy nhiều instance
+	MQTTQoS                int           // 0 hoặc 1 (default: 1)
+	MQTTKeepAlive          time.Duration // default: 30 giây
+	MQTTMaxRedelivery      int           // Số lần xử lý lỗi tối đa trước khi bỏ message, 0 = không giới hạn (default: 5)
+	MQTTCAFile             string        // CA của broker tự ký
+	MQTTCertFile           string        // Client certificate (AWS IoT Core / Mosquitto require_certificate)
+	MQTTKeyFile            string
+	MQTTInsecureSkipVerify bool
 
 	// Logging Settings
 	EnableStructuredLogging bool   // Enable structured JSON logging
 	LogLevel                string // DEBUG, INFO, WARN, ERROR
+
+	// Payment Settings
+	PaymentGateway          string // Tên cổng thanh toán, hiện hỗ trợ: "fake"
+	PaymentWebhookSecret    string // Secret để xác thực chữ ký webhook, bắt buộc
+	PaymentCheckoutBaseURL  string // URL trang thanh toán của fake gateway
+	PaymentAllowFakeGateway bool   // Chỉ bật trên máy dev: cho phép PAYMENT_GATEWAY=fake (default: false)
+
+	// Reservation Settings
+	ReservationNoShowMinutes int           // Sau start_time bao lâu mà xe chưa vào thì nhả chỗ (default: 15 phút)
+	ReservationCheckInterval time.Duration // Interval cho job giữ chỗ / nhả chỗ (default: 1 phút)
 }
 
 func Load() *Config {
@@ -53,14 +132,62 @@ func Load() *Config {
 	gateEventTimeout, _ := strconv.Atoi(getEnv("GATE_EVENT_TIMEOUT_MINUTES", "5"))
 	cleanupIntervalMin, _ := strconv.Atoi(getEnv("GATE_EVENT_CLEANUP_INTERVAL_MINUTES", "1"))
 	lprThreshold, _ := strconv.ParseFloat(getEnv("LPR_CONFIDENCE_THRESHOLD", "0.8"), 32)
+	lprMaxCandidates, _ := strconv.Atoi(getEnv("LPR_MAX_CANDIDATES", "10"))
+	lprAutoTuneEnabled, _ := strconv.ParseBool(getEnv("LPR_AUTOTUNE_ENABLED", "false"))
+	lprAutoTuneIntervalMin, _ := strconv.Atoi(getEnv("LPR_AUTOTUNE_INTERVAL_MINUTES", "60"))
+	lprAutoTuneWindowHours, _ := strconv.Atoi(getEnv("LPR_AUTOTUNE_WINDOW_HOURS", "168"))
+	lprAutoTuneMinSamples, _ := strconv.Atoi(getEnv("LPR_AUTOTUNE_MIN_SAMPLES", "30"))
+	lprAutoTuneTargetRate, _ := strconv.ParseFloat(getEnv("LPR_AUTOTUNE_TARGET_CORRECTION_RATE", "0.05"), 64)
+	lprAutoTuneStep, _ := strconv.ParseFloat(getEnv("LPR_AUTOTUNE_STEP", "0.02"), 64)
+	lprAutoTuneMin, _ := strconv.ParseFloat(getEnv("LPR_AUTOTUNE_MIN_THRESHOLD", "0.6"), 64)
+	lprAutoTuneMax, _ := strconv.ParseFloat(getEnv("LPR_AUTOTUNE_MAX_THRESHOLD", "0.98"), 64)
+
+	// Evidence Config
+	evidenceS3PathStyle, _ := strconv.ParseBool(getEnv("EVIDENCE_S3_PATH_STYLE", "false"))
+	evidenceRetentionDays, _ := strconv.Atoi(getEnv("EVIDENCE_RETENTION_DAYS", "90"))
+	evidencePurgeIntervalHours, _ := strconv.Atoi(getEnv("EVIDENCE_PURGE_INTERVAL_HOURS", "24"))
 
 	// WebSocket Config
 	wsReadBuffer, _ := strconv.Atoi(getEnv("WEBSOCKET_READ_BUFFER_SIZE", "1024"))
 	wsWriteBuffer, _ := strconv.Atoi(getEnv("WEBSOCKET_WRITE_BUFFER_SIZE", "1024"))
+	wsAuthTimeoutSec, _ := strconv.Atoi(getEnv("WEBSOCKET_AUTH_TIMEOUT_SECONDS", "10"))
+	wsSendQueue, _ := strconv.Atoi(getEnv("WEBSOCKET_SEND_QUEUE_SIZE", "256"))
+	wsPongTimeoutSec, _ := strconv.Atoi(getEnv("WEBSOCKET_PONG_TIMEOUT_SECONDS", "60"))
+	wsReplayBuffer, _ := strconv.Atoi(getEnv("WEBSOCKET_REPLAY_BUFFER_SIZE", "1000"))
+	wsAllowedOrigins := getEnvList("WEBSOCKET_ALLOWED_ORIGINS", "")
+
+	// SQS Config
+	sqsWorkers, _ := strconv.Atoi(getEnv("SQS_WORKERS", "8"))
+	sqsVisibilityTimeoutSec, _ := strconv.Atoi(getEnv("SQS_VISIBILITY_TIMEOUT_SECONDS", "60"))
+	eventSourceDrainTimeoutSec, _ := strconv.Atoi(getEnv("EVENT_SOURCE_DRAIN_TIMEOUT_SECONDS", "20"))
+
+	// Command Config
+	commandAckTimeoutSec, _ := strconv.Atoi(getEnv("COMMAND_ACK_TIMEOUT_SECONDS", "5"))
+	commandMaxBackoffSec, _ := strconv.Atoi(getEnv("COMMAND_RETRY_MAX_BACKOFF_SECONDS", "60"))
+	commandMaxAttempts, _ := strconv.Atoi(getEnv("COMMAND_MAX_ATTEMPTS", "4"))
+	commandAlertAfter, _ := strconv.Atoi(getEnv("COMMAND_ALERT_AFTER_FAILURES", "2"))
+	commandExecuteTimeoutSec, _ := strconv.Atoi(getEnv("COMMAND_EXECUTE_TIMEOUT_SECONDS", "30"))
+	commandCheckIntervalSec, _ := strconv.Atoi(getEnv("COMMAND_CHECK_INTERVAL_SECONDS", "2"))
+
+	// Event Source Config
+	deviceEventDedupTTLHours, _ := strconv.Atoi(getEnv("DEVICE_EVENT_DEDUP_TTL_HOURS", "24"))
+
+	// MQTT Config
+	mqttQoS, _ := strconv.Atoi(getEnv("MQTT_QOS", "1"))
+	mqttKeepAliveSec, _ := strconv.Atoi(getEnv("MQTT_KEEPALIVE_SECONDS", "30"))
+	mqttMaxRedelivery, _ := strconv.Atoi(getEnv("MQTT_MAX_REDELIVERY", "5"))
+	mqttInsecureSkipVerify, _ := strconv.ParseBool(getEnv("MQTT_INSECURE_SKIP_VERIFY", "false"))
 
 	// Logging Config
 	enableStructuredLogging, _ := strconv.ParseBool(getEnv("ENABLE_STRUCTURED_LOGGING", "false"))
 
+	// Payment Config
+	paymentAllowFakeGateway, _ := strconv.ParseBool(getEnv("PAYMENT_ALLOW_FAKE_GATEWAY", "false"))
+
+	// Reservation Config
+	reservationNoShow, _ := strconv.Atoi(getEnv("RESERVATION_NO_SHOW_MINUTES", "15"))
+	reservationIntervalSec, _ := strconv.Atoi(getEnv("RESERVATION_CHECK_INTERVAL_SECONDS", "60"))
+
 	return &Config{
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		DBHost:     getEnv("DB_HOST", "localhost"),
@@ -74,6 +201,21 @@ func Load() *Config {
 		SQSEventQueueURL: getEnv("SQS_EVENT_QUEUE_URL", ""),      // << ĐIỀN URL SQS QUEUE
 		IoTMQTTEndpoint:  getEnv("IOT_MQTT_ENDPOINT", ""),        // << ĐIỀN AWS IOT ENDPOINT
 
+		// SQS Settings
+		SQSWorkers:              sqsWorkers,
+		SQSVisibilityTimeout:    time.Duration(sqsVisibilityTimeoutSec) * time.Second,
+		EventSourceDrainTimeout: time.Duration(eventSourceDrainTimeoutSec) * time.Second,
+
+		// Command Settings
+		CommandTransport:          getEnv("COMMAND_TRANSPORT", "aws_iot"),
+		CommandTopicTemplate:      getEnv("COMMAND_TOPIC_TEMPLATE", "smart_parking/command/barriers/{barrier_type}"),
+		CommandAckTimeout:         time.Duration(commandAckTimeoutSec) * time.Second,
+		CommandRetryMaxBackoff:    time.Duration(commandMaxBackoffSec) * time.Second,
+		CommandMaxAttempts:        commandMaxAttempts,
+		CommandAlertAfterFailures: commandAlertAfter,
+		CommandExecuteTimeout:     time.Duration(commandExecuteTimeoutSec) * time.Second,
+		CommandCheckInterval:      time.Duration(commandCheckIntervalSec) * time.Second,
+
 		JWTSecret:          getEnv("JWT_SECRET", "your-very-secret-key-for-jwt-!@#$"), // << THAY BẰNG SECRET KEY MẠNH HƠN
 		JWTExpirationHours: time.Duration(jwtExpHours) * time.Hour,
 
@@ -82,16 +224,128 @@ func Load() *Config {
 		GateEventCleanupInterval: time.Duration(cleanupIntervalMin) * time.Minute,
 		LPRConfidenceThreshold:   float32(lprThreshold),
 
+		// LPR Settings
+		LPRProvider:                 getEnv("LPR_PROVIDER", "rekognition"),
+		LPRLocalFixturesPath:        getEnv("LPR_LOCAL_FIXTURES", ""),
+		LPRLocalVehicleFixturesPath: getEnv("LPR_LOCAL_VEHICLE_FIXTURES", ""),
+		LPRMaxCandidates:            lprMaxCandidates,
+		LPRAutoTuneEnabled:          lprAutoTuneEnabled,
+		LPRAutoTuneInterval:         time.Duration(lprAutoTuneIntervalMin) * time.Minute,
+		LPRAutoTuneWindow:           time.Duration(lprAutoTuneWindowHours) * time.Hour,
+		LPRAutoTuneMinSamples:       lprAutoTuneMinSamples,
+		LPRAutoTuneTargetRate:       lprAutoTuneTargetRate,
+		LPRAutoTuneStep:             lprAutoTuneStep,
+		LPRAutoTuneMinThreshold:     lprAutoTuneMin,
+		LPRAutoTuneMaxThreshold:     lprAutoTuneMax,
+
+		// Evidence Settings
+		EvidenceStore:         getEnv("EVIDENCE_STORE", "filesystem"),
+		EvidenceDir:           getEnv("EVIDENCE_DIR", "./data/evidence"),
+		EvidenceS3Bucket:      getEnv("EVIDENCE_S3_BUCKET", ""),
+		EvidenceS3Prefix:      getEnv("EVIDENCE_S3_PREFIX", "evidence"),
+		EvidenceS3Endpoint:    getEnv("EVIDENCE_S3_ENDPOINT", ""),
+		EvidenceS3PathStyle:   evidenceS3PathStyle,
+		EvidenceRetentionDays: evidenceRetentionDays,
+		EvidencePurgeInterval: time.Duration(evidencePurgeIntervalHours) * time.Hour,
+
 		// WebSocket Settings
 		WebSocketReadBufferSize:  wsReadBuffer,
 		WebSocketWriteBufferSize: wsWriteBuffer,
+		WebSocketAllowedOrigins:  wsAllowedOrigins,
+		WebSocketAuthTimeout:     time.Duration(wsAuthTimeoutSec) * time.Second,
+		WebSocketSendQueueSize:   wsSendQueue,
+		WebSocketPongTimeout:     time.Duration(wsPongTimeoutSec) * time.Second,
+		WebSocketReplayBuffer:    wsReplayBuffer,
+
+		// Event Source Settings
+		EventSources:        getEnvList("EVENT_SOURCES", "sqs"),
+		EventIngestToken:    getEnv("EVENT_INGEST_TOKEN", ""),
+		DeviceEventDedupTTL: time.Duration(deviceEventDedupTTLHours) * time.Hour,
+
+		// MQTT Settings
+		MQTTBrokerURL:          getEnv("MQTT_BROKER_URL", ""),
+		MQTTClientID:           getEnv("MQTT_CLIENT_ID", "smart-parking-backend"),
+		MQTTUsername:           getEnv("MQTT_USERNAME", ""),
+		MQTTPassword:           getEnv("MQTT_PASSWORD", ""),
+		MQTTTopics:             getEnvList("MQTT_TOPICS", "smart_parking/events/#"),
+		MQTTSharedGroup:        getEnv("MQTT_SHARED_GROUP", ""),
+		MQTTQoS:                mqttQoS,
+		MQTTKeepAlive:          time.Duration(mqttKeepAliveSec) * time.Second,
+		MQTTMaxRedelivery:      mqttMaxRedelivery,
+		MQTTCAFile:             getEnv("MQTT_CA_FILE", ""),
+		MQTTCertFile:           getEnv("MQTT_CERT_FILE", ""),
+		MQTTKeyFile:            getEnv("MQTT_KEY_FILE", ""),
+		MQTTInsecureSkipVerify: mqttInsecureSkipVerify,
 
 		// Logging Settings
 		EnableStructuredLogging: enableStructuredLogging,
 		LogLevel:                getEnv("LOG_LEVEL", "INFO"),
+
+		// Payment Settings
+		PaymentGateway:          getEnv("PAYMENT_GATEWAY", "fake"),
+		PaymentWebhookSecret:    getEnv("PAYMENT_WEBHOOK_SECRET", ""), // << BẮT BUỘC, ĐẶT SECRET THẬT
+		PaymentCheckoutBaseURL:  getEnv("PAYMENT_CHECKOUT_BASE_URL", "http://localhost:8080/fake-checkout"),
+		PaymentAllowFakeGateway: paymentAllowFakeGateway,
+
+		// Reservation Settings
+		ReservationNoShowMinutes: reservationNoShow,
+		ReservationCheckInterval: time.Duration(reservationIntervalSec) * time.Second,
 	}
 }
 
+// Secret mẫu từng là giá trị mặc định, không được dùng để ký webhook thật
+const placeholderPaymentWebhookSecret = "change-me-payment-webhook-secret"
+
+const minPaymentWebhookSecretLength = 16
+
+// Validate kiểm tra các cấu hình mà giá trị sai sẽ gây lỗ hổng hoặc làm server hỏng khi chạy; main dừng khởi động nếu có lỗi
+func (c *Config) Validate() error {
+	var problems []string
+	secret := strings.TrimSpace(c.PaymentWebhookSecret)
+	switch {
+	case secret == "":
+		problems = append(problems, "PAYMENT_WEBHOOK_SECRET chưa được đặt")
+	case secret == placeholderPaymentWebhookSecret:
+		problems = append(problems, "PAYMENT_WEBHOOK_SECRET vẫn là giá trị mẫu, hãy đặt secret thật")
+	case len(secret) < minPaymentWebhookSecretLength:
+		problems = append(problems, fmt.Sprintf("PAYMENT_WEBHOOK_SECRET phải dài ít nhất %d ký tự", minPaymentWebhookSecretLength))
+	}
+	if c.PaymentGateway == "fake" && !c.PaymentAllowFakeGateway {
+		problems = append(problems, "PAYMENT_GATEWAY=fake chỉ dùng cho môi trường dev, cần đặt PAYMENT_ALLOW_FAKE_GATEWAY=true")
+	}
+
+	// Reservation: giá trị không parse được được đọc thành 0; chu kỳ <= 0 làm time.NewTicker panic
+	problems = requirePositive(problems, "RESERVATION_CHECK_INTERVAL_SECONDS", c.ReservationCheckInterval)
+	if c.ReservationNoShowMinutes < 0 {
+		problems = append(problems, "RESERVATION_NO_SHOW_MINUTES không được âm")
+	}
+
+	// Evidence: job purge chỉ chạy khi có evidence store
+	if c.EvidenceStore != "" && c.EvidenceStore != "none" {
+		problems = requirePositive(problems, "EVIDENCE_PURGE_INTERVAL_HOURS", c.EvidencePurgeInterval)
+	}
+
+	if c.LPRAutoTuneEnabled {
+		problems = requirePositive(problems, "LPR_AUTOTUNE_INTERVAL_MINUTES", c.LPRAutoTuneInterval)
+	}
+
+	// Command: chu kỳ quét / thời gian chờ <= 0 làm job gửi lại panic hoặc gửi lại liên tục
+	problems = requirePositive(problems, "COMMAND_CHECK_INTERVAL_SECONDS", c.CommandCheckInterval)
+	problems = requirePositive(problems, "COMMAND_ACK_TIMEOUT_SECONDS", c.CommandAckTimeout)
+	problems = requirePositive(problems, "COMMAND_RETRY_MAX_BACKOFF_SECONDS", c.CommandRetryMaxBackoff)
+	problems = requirePositive(problems, "COMMAND_EXECUTE_TIMEOUT_SECONDS", c.CommandExecuteTimeout)
+	if c.CommandMaxAttempts <= 0 {
+		problems = append(problems, "COMMAND_MAX_ATTEMPTS phải là số nguyên dương")
+	}
+
+	problems = requirePositive(problems, "DEVICE_EVENT_DEDUP_TTL_HOURS", c.DeviceEventDedupTTL)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 func getEnv(key string, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -99,3 +353,22 @@ func getEnv(key string, fallback string) string {
 	log.Printf("Biến môi trường '%s' không được đặt, sử dụng giá trị mặc định: '%s'", key, fallback)
 	return fallback
 }
+
+// getEnvList đọc danh sách phân tách bằng dấu phẩy, bỏ phần tử rỗng
+func getEnvList(key string, fallback string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, fallback), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// requirePositive thêm lỗi nếu giá trị thời gian đọc từ env <= 0 (kể cả khi không parse được số)
+func requirePositive(problems []string, env string, value time.Duration) []string {
+	if value <= 0 {
+		problems = append(problems, fmt.Sprintf("%s phải là số nguyên dương", env))
+	}
+	return problems
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Trạng thái lệnh gửi xuống thiết bị
+const (
+	CommandStatusPending      = "pending"      // Chưa publish được tới broker, đang chờ gửi lại
+	CommandStatusSent         = "sent"         // Broker đã nhận, chờ thiết bị xác nhận
+	CommandStatusAcknowledged = "acknowledged" // Thiết bị đã xác nhận nhận lệnh
+	CommandStatusExecuted     = "executed"     // barrier_state sau đó cho thấy rào đã chuyển theo lệnh
+	CommandStatusTimedOut     = "timed_out"    // Hết số lần gửi mà thiết bị không xác nhận, hoặc xác nhận mà rào không chuyển
+	CommandStatusFailed       = "failed"       // Hết số lần gửi mà không publish được tới broker
+)
+
+// DeviceCommand - Một lệnh điều khiển rào chắn, theo dõi từ lúc gửi tới khi rào thực sự chuyển trạng thái
+type DeviceCommand struct {
+	RequestID      string          `json:"request_id"`
+	Esp32ThingName string          `json:"esp32_thing_name"`
+	BarrierType    string          `json:"barrier_type"`
+	Command        string          `json:"command"`
+	Topic          string          `json:"topic"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`     // Số lần đã publish (kể cả lần lỗi)
+	MaxAttempts    int             `json:"max_attempts"` // Hết số lần này mà chưa có xác nhận thì timed_out / failed
+	LastError      null.String     `json:"last_error"`
+	NextRetryAt    null.Time       `json:"next_retry_at"` // Hạn chờ xác nhận / thời điểm gửi lại
+	AlertSent      bool            `json:"alert_sent"`    // Đã cảnh báo operator qua WebSocket
+	RequestedBy    null.String     `json:"requested_by"`  // Operator gửi lệnh, NULL khi hệ thống tự mở rào
+	SentAt         null.Time       `json:"sent_at"`
+	AcknowledgedAt null.Time       `json:"acknowledged_at"`
+	ExecutedAt     null.Time       `json:"executed_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// IsFinal - lệnh đã kết thúc, không còn gửi lại
+func (c *DeviceCommand) IsFinal() bool {
+	switch c.Status {
+	case CommandStatusExecuted, CommandStatusTimedOut, CommandStatusFailed:
+		return true
+	}
+	return false
+}
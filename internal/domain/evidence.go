@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// EvidenceImage - Ảnh camera tại cổng được lưu làm bằng chứng cho gate event / phiên đỗ xe.
+// File ảnh nằm trong evidence store, StorageKey không trả ra ngoài API.
+type EvidenceImage struct {
+	ID            int         `json:"id"`
+	GateEventID   null.String `json:"gate_event_id"`
+	SessionID     null.Int    `json:"session_id"`
+	LotID         int         `json:"lot_id"`
+	GateDirection string      `json:"gate_direction"`
+	CameraID      null.String `json:"camera_id,omitempty"`
+	Backend       string      `json:"backend"`
+	StorageKey    string      `json:"-"`
+	SHA256        string      `json:"sha256"`
+	ContentType   string      `json:"content_type"`
+	SizeBytes     int64       `json:"size_bytes"`
+	LegalHold     bool        `json:"legal_hold"`
+	CapturedAt    time.Time   `json:"captured_at"`
+	PurgedAt      null.Time   `json:"purged_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// EvidenceHoldDTO - Bật/tắt giữ bằng chứng khi có khiếu nại (không bị purge)
+type EvidenceHoldDTO struct {
+	LegalHold *bool `json:"legal_hold" binding:"required"`
+}
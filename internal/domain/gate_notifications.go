@@ -10,6 +10,15 @@ const (
 	GateEventVehicleAtGate      GateEventType = "vehicle_at_gate"
 	GateEventVehiclePassed      GateEventType = "vehicle_passed"
 	GateEventGateTimeout        GateEventType = "gate_timeout"
+	GateEventPaymentRequired    GateEventType = "payment_required"  // Xe ở cổng ra nhưng phiên chưa thanh toán, rào được giữ
+	GateEventPaymentConfirmed   GateEventType = "payment_confirmed" // Đã thanh toán, rào ra được mở
+	GateEventPassAccepted       GateEventType = "pass_accepted"     // Xe có vé tháng hợp lệ, rào vào được mở tự động
+	GateEventBarrierOpened      GateEventType = "barrier_opened"    // Đã tạo phiên từ LPR, rào vào được mở tự động
+	GateEventExitFeeDue         GateEventType = "exit_fee_due"      // Đã kết thúc phiên tại cổng ra, báo số tiền cần thu
+	GateEventExitNoMatch        GateEventType = "exit_no_match"     // LPR cổng ra không khớp phiên nào, gợi ý biển số gần giống
+	GateEventWatchlistAlert     GateEventType = "watchlist_alert"   // Biển số bị gắn cờ (mất cắp, cấm, nợ phí), cần operator xử lý
+	GateEventVIPArrived         GateEventType = "vip_arrived"       // Xe VIP tới cổng
+	GateEventCommandFailed      GateEventType = "command_failed"    // Lệnh rào chắn không được thiết bị xác nhận / không gửi được
 )
 
 type GateDirection string
@@ -21,6 +30,7 @@ const (
 
 // GateEventNotification - Event được gửi đến frontend qua WebSocket
 type GateEventNotification struct {
+	Seq           uint64        `json:"seq,omitempty"` // Số thứ tự tăng dần do WebSocket hub gán, dùng để nối lại khi mất kết nối
 	EventID       string        `json:"event_id"`
 	LotID         int           `json:"lot_id"`
 	LotName       string        `json:"lot_name"`
@@ -37,14 +47,45 @@ type GateEventNotification struct {
 
 	// Camera info nếu cần
 	SuggestedCameraID string `json:"suggested_camera_id,omitempty"`
+
+	// Thông tin thanh toán tại cổng ra (pay-before-exit)
+	SessionID    int        `json:"session_id,omitempty"`
+	AmountDue    float64    `json:"amount_due,omitempty"`
+	ExitDeadline *time.Time `json:"exit_deadline,omitempty"`
+
+	// Biển số bị gắn cờ trong danh sách cảnh báo
+	DetectedPlate   string          `json:"detected_plate,omitempty"`
+	WatchlistReason WatchlistReason `json:"watchlist_reason,omitempty"`
+
+	// Cổng ra: các phiên active có biển số gần giống khi LPR không khớp chính xác
+	Candidates []PlateCandidate `json:"candidates,omitempty"`
+
+	// Lệnh rào chắn gặp lỗi (command_failed), chi tiết qua GET /api/v1/iot/commands/:request_id
+	CommandRequestID string `json:"command_request_id,omitempty"`
+	CommandStatus    string `json:"command_status,omitempty"`
+}
+
+// PlateCandidate - Phiên đang active có biển số gần giống biển số nhận dạng được, Score trong [0, 1]
+type PlateCandidate struct {
+	SessionID         int       `json:"session_id"`
+	VehicleIdentifier string    `json:"vehicle_identifier"`
+	EntryTime         time.Time `json:"entry_time"`
+	Distance          float64   `json:"distance"` // Khoảng cách chỉnh sửa có trọng số
+	Score             float64   `json:"score"`
 }
 
 // LPRTriggerRequest - Request từ frontend để trigger LPR
 type LPRTriggerRequest struct {
-	EventID        string `json:"event_id" binding:"required"`
-	ImageBase64    string `json:"image_base64" binding:"required"`
-	CameraID       string `json:"camera_id,omitempty"`
-	ManualOverride string `json:"manual_override,omitempty"` // Nếu user muốn nhập biển số manual
+	EventID     string `json:"event_id" binding:"required"`
+	ImageBase64 string `json:"image_base64,omitempty"`
+	// Nhiều frame chụp liên tiếp khi xe dừng ở cổng; kết quả được bỏ phiếu cùng các frame trước của event
+	ImagesBase64   []string `json:"images_base64,omitempty"`
+	CameraID       string   `json:"camera_id,omitempty"`
+	ManualOverride string   `json:"manual_override,omitempty"` // Nếu user muốn nhập biển số manual
+	// Admin chủ động cho xe trong danh sách cảnh báo qua cổng, kèm lý do (bắt buộc)
+	OverrideWatchlist bool   `json:"override_watchlist,omitempty"`
+	OverrideReason    string `json:"override_reason,omitempty"`
+	Operator          string `json:"-"` // Gán từ JWT
 }
 
 // SessionCreationRequest - Request tạo session sau khi có biển số
@@ -56,19 +97,28 @@ type SessionCreationRequest struct {
 	IsManualEntry   bool    `json:"is_manual_entry,omitempty"`
 	Esp32ThingName  string  `json:"esp32_thing_name" binding:"required"`
 	AdditionalNotes string  `json:"additional_notes,omitempty"`
+	// Operator xác nhận / sửa thuộc tính xe; bỏ trống thì dùng kết quả nhận diện từ ảnh
+	VehicleClass string `json:"vehicle_class,omitempty"`
+	VehicleColor string `json:"vehicle_color,omitempty"`
+	VehicleMake  string `json:"vehicle_make,omitempty"`
 }
 
 // GateEventStatus - Trạng thái xử lý của gate event
 type GateEventStatus string
 
 const (
-	StatusPending        GateEventStatus = "pending"         // Chờ xử lý
-	StatusAwaitingLPR    GateEventStatus = "awaiting_lpr"    // Chờ LPR
-	StatusLPRCompleted   GateEventStatus = "lpr_completed"   // LPR xong
-	StatusSessionCreated GateEventStatus = "session_created" // Đã tạo session
-	StatusTimeout        GateEventStatus = "timeout"         // Timeout
-	StatusError          GateEventStatus = "error"           // Lỗi
-	StatusManualOverride GateEventStatus = "manual_override" // Xử lý manual
+	StatusPending          GateEventStatus = "pending"           // Chờ xử lý
+	StatusAwaitingLPR      GateEventStatus = "awaiting_lpr"      // Chờ LPR
+	StatusLPRCompleted     GateEventStatus = "lpr_completed"     // LPR xong
+	StatusSessionCreated   GateEventStatus = "session_created"   // Đã tạo session
+	StatusTimeout          GateEventStatus = "timeout"           // Timeout
+	StatusError            GateEventStatus = "error"             // Lỗi
+	StatusManualOverride   GateEventStatus = "manual_override"   // Xử lý manual
+	StatusAwaitingPayment  GateEventStatus = "awaiting_payment"  // Giữ rào ra, chờ thanh toán
+	StatusExitAllowed      GateEventStatus = "exit_allowed"      // Đã thanh toán, đã gửi lệnh mở rào ra
+	StatusRequiresOverride GateEventStatus = "requires_override" // Biển số bị gắn cờ, chờ operator override
+	StatusSessionClosed    GateEventStatus = "session_closed"    // Đã kết thúc phiên tại cổng ra
+	StatusAwaitingMatch    GateEventStatus = "awaiting_match"    // Cổng ra: không khớp phiên, chờ operator chọn biển số
 )
 
 // GateEventRecord - Lưu trữ trong DB để track progress
@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+// Holiday - Ngày lễ dùng cho khung giá holiday_only. LotID NULL = áp dụng cho mọi bãi.
+type Holiday struct {
+	ID        int       `json:"id"`
+	LotID     null.Int  `json:"lot_id"`
+	Date      string    `json:"date"` // "YYYY-MM-DD"
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HolidayDTO struct {
+	LotID *int   `json:"lot_id"` // Bỏ trống để áp dụng cho toàn hệ thống
+	Date  string `json:"date" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+}
@@ -116,6 +116,8 @@ type DeviceCommandAckEvent struct {
 type BarrierControlCommandPayload struct {
 	Command   string `json:"command"`              // "open" hoặc "close"
 	RequestID string `json:"request_id,omitempty"` // ID để theo dõi lệnh (tùy chọn)
+	// Loại rào (entry / exit) để firmware subscribe một topic chung cho cả hai rào vẫn biết lệnh dành cho rào nào
+	BarrierType string `json:"barrier_type,omitempty"`
 	// BarrierTargetID string `json:"barrier_target_id,omitempty"` // Có thể không cần nếu topic đã đủ rõ ràng
 }
 
@@ -127,6 +129,17 @@ type DeviceEventLog struct {
 	MqttTopic       string          `json:"mqtt_topic"`
 	MessageType     string          `json:"message_type"`
 	Payload         json.RawMessage `json:"payload"`          // Lưu payload gốc dạng JSONB
-	ProcessedStatus string          `json:"processed_status"` // "pending", "processed", "error"
+	ProcessedStatus string          `json:"processed_status"` // Một trong các EventStatus*
 	ProcessingNotes string          `json:"processing_notes,omitempty"`
+	DedupKey        string          `json:"dedup_key,omitempty"`
 }
+
+// Kết quả xử lý sự kiện thiết bị, lưu vào device_events_log.processed_status
+const (
+	EventStatusPending   = "pending"
+	EventStatusProcessed = "processed"
+	EventStatusError     = "error"
+	EventStatusDuplicate = "duplicate" // Cùng dedup key đã được xử lý, bỏ qua
+	EventStatusStale     = "stale"     // Cũ hơn trạng thái thiết bị đã ghi nhận, bỏ qua
+	EventStatusRejected  = "rejected"  // Payload không hợp lệ (ví dụ timestamp không parse được), không xử lý lại
+)
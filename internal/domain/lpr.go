@@ -4,12 +4,58 @@ package domain
 type LPRRequestDTO struct {
 	// Frontend có thể gửi ảnh dưới dạng base64 encoded string
 	ImageBase64 string `json:"image_base64" binding:"required"`
+	// Bãi đỗ (tùy chọn): nếu có, chỉ trả các biển số đúng định dạng bãi chấp nhận
+	LotID int `json:"lot_id,omitempty"`
 	// Hoặc backend xử lý multipart/form-data nếu frontend upload file trực tiếp
 }
 
 // LPRResponseDTO trả về biển số đã nhận dạng
 type LPRResponseDTO struct {
-	DetectedPlate string  `json:"detected_plate"`
-	Confidence    float32 `json:"confidence,omitempty"` // Độ tin cậy (nếu có)
-	ErrorMessage  string  `json:"error_message,omitempty"`
+	DetectedPlate string         `json:"detected_plate"`
+	DisplayPlate  string         `json:"display_plate,omitempty"`
+	Confidence    float32        `json:"confidence,omitempty"` // Độ tin cậy (nếu có)
+	Provider      string         `json:"provider,omitempty"`
+	Candidates    []LPRCandidate `json:"candidates,omitempty"`
+	ErrorMessage  string         `json:"error_message,omitempty"`
+}
+
+// BoundingBox - Vị trí vùng văn bản trong ảnh, tính theo tỉ lệ (0..1) so với kích thước ảnh
+type BoundingBox struct {
+	Left   float32 `json:"left"`
+	Top    float32 `json:"top"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+}
+
+// LPRCharacter - Một ký tự của biển số kèm độ tin cậy riêng (0..1)
+type LPRCharacter struct {
+	Char       string  `json:"char"`
+	Confidence float32 `json:"confidence"`
+}
+
+// LPRCandidate - Một biển số ứng viên do LPR provider trả về
+type LPRCandidate struct {
+	Plate       string         `json:"plate"`   // Dạng chuẩn, ví dụ 29A12345
+	Display     string         `json:"display"` // Dạng hiển thị theo grammar, ví dụ 29A-123.45
+	Grammar     string         `json:"grammar"` // Mã định dạng biển số khớp (vn_car, uk...)
+	RegionValid bool           `json:"region_valid"`
+	Confidence  float32        `json:"confidence"` // 0..1, đã chuẩn hóa giữa các provider, gồm cả điểm cộng mã tỉnh
+	Characters  []LPRCharacter `json:"characters,omitempty"`
+	BoundingBox *BoundingBox   `json:"bounding_box,omitempty"`
+	RawText     string         `json:"raw_text,omitempty"` // Văn bản gốc trước khi chuẩn hóa
+}
+
+// LPRResult - Kết quả nhận dạng của một ảnh, Candidates xếp theo độ tin cậy giảm dần
+type LPRResult struct {
+	Provider   string         `json:"provider"`
+	Candidates []LPRCandidate `json:"candidates"`
+	RawTexts   []string       `json:"raw_texts,omitempty"` // Toàn bộ văn bản nhận dạng được, phục vụ debug
+}
+
+// Best trả về ứng viên có độ tin cậy cao nhất, nil nếu không có
+func (r *LPRResult) Best() *LPRCandidate {
+	if r == nil || len(r.Candidates) == 0 {
+		return nil
+	}
+	return &r.Candidates[0]
 }
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// LPRAttempt - Kết quả nhận dạng của một frame ảnh thuộc gate event
+type LPRAttempt struct {
+	ID            int            `json:"id"`
+	EventID       string         `json:"event_id"`
+	Provider      string         `json:"provider"`
+	CameraID      null.String    `json:"camera_id,omitempty"`
+	EvidenceID    null.Int       `json:"evidence_id"` // Ảnh đã dùng, lưu trong evidence_images
+	DetectedPlate null.String    `json:"detected_plate"`
+	DisplayPlate  null.String    `json:"display_plate,omitempty"`
+	Grammar       null.String    `json:"grammar,omitempty"`
+	Confidence    null.Float     `json:"confidence"`
+	Candidates    []LPRCandidate `json:"candidates"`
+	ErrorMessage  null.String    `json:"error_message,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// LPRFusionResult - Biển số hợp nhất từ nhiều frame bằng bỏ phiếu từng ký tự
+type LPRFusionResult struct {
+	Plate      string         `json:"plate"`
+	Display    string         `json:"display"`
+	Grammar    string         `json:"grammar,omitempty"`
+	Confidence float32        `json:"confidence"`
+	Characters []LPRCharacter `json:"characters"`
+	// Số frame có biển số tham gia bỏ phiếu / tổng số frame của gate event
+	VotingFrames int `json:"voting_frames"`
+	TotalFrames  int `json:"total_frames"`
+	// Thuộc tính xe bỏ phiếu từ các frame, nil nếu provider không nhận diện xe
+	Vehicle *VehicleAttributes `json:"vehicle,omitempty"`
+}
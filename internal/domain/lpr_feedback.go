@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Nguồn của một lần operator sửa biển số LPR
+const (
+	LPRCorrectionSourceManualOverride = "manual_override" // manual_override trong LPR trigger
+	LPRCorrectionSourceCreateSession  = "create_session"  // Operator tạo phiên từ gate event
+)
+
+// LPRCorrection - Bộ ba (ảnh, kết quả máy, biển số đúng do operator nhập) dùng làm dữ liệu gán nhãn
+type LPRCorrection struct {
+	ID                int         `json:"id"`
+	EventID           null.String `json:"event_id"`
+	LotID             int         `json:"lot_id"`
+	CameraID          null.String `json:"camera_id"`
+	EvidenceID        null.Int    `json:"evidence_id"`
+	MachinePlate      null.String `json:"machine_plate"`
+	MachineConfidence null.Float  `json:"machine_confidence"`
+	CorrectedPlate    string      `json:"corrected_plate"`
+	IsCorrect         bool        `json:"is_correct"`
+	Source            string      `json:"source"`
+	Operator          null.String `json:"operator,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+type LPRCorrectionFilterDTO struct {
+	LotID    *int       `form:"lot_id"`
+	CameraID *string    `form:"camera_id"`
+	From     *time.Time `form:"from"` // RFC3339
+	To       *time.Time `form:"to"`
+}
+
+// LPRAccuracyStats - Độ chính xác LPR trên các lần operator sửa / xác nhận
+type LPRAccuracyStats struct {
+	Total    int     `json:"total"`
+	Correct  int     `json:"correct"`
+	NoRead   int     `json:"no_read"` // Máy không đọc được biển số
+	Accuracy float64 `json:"accuracy"`
+	// Confidence trung bình khi máy đọc đúng / sai, dùng để chỉnh ngưỡng tự động tạo phiên
+	MeanConfidenceCorrect   float64 `json:"mean_confidence_correct"`
+	MeanConfidenceIncorrect float64 `json:"mean_confidence_incorrect"`
+}
+
+type LPRLotAccuracy struct {
+	LotID int `json:"lot_id"`
+	LPRAccuracyStats
+}
+
+type LPRCameraAccuracy struct {
+	CameraID string `json:"camera_id"`
+	LPRAccuracyStats
+}
+
+// LPRConfusionPair - Cặp ký tự máy hay đọc nhầm. Expected rỗng = đọc thừa, Read rỗng = đọc thiếu.
+type LPRConfusionPair struct {
+	Expected string `json:"expected"`
+	Read     string `json:"read"`
+	Count    int    `json:"count"`
+}
+
+type LPRAccuracyReport struct {
+	Overall    LPRAccuracyStats    `json:"overall"`
+	ByLot      []LPRLotAccuracy    `json:"by_lot"`
+	ByCamera   []LPRCameraAccuracy `json:"by_camera"`
+	Confusions []LPRConfusionPair  `json:"confusions"`
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Lý do thay đổi ngưỡng confidence LPR
+const (
+	LPRThresholdReasonManual   = "manual"
+	LPRThresholdReasonAutoTune = "auto_tune"
+)
+
+// LPRThreshold - Ngưỡng confidence để tự tạo phiên cho một bãi, hoặc một camera của bãi.
+// CameraID rỗng = áp dụng cho mọi camera chưa có ngưỡng riêng.
+type LPRThreshold struct {
+	ID        int       `json:"id"`
+	LotID     int       `json:"lot_id"`
+	CameraID  string    `json:"camera_id"`
+	Threshold float64   `json:"threshold"`
+	AutoTune  bool      `json:"auto_tune"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LPRThresholdDTO struct {
+	LotID     int     `json:"lot_id" binding:"required"`
+	CameraID  string  `json:"camera_id"`
+	Threshold float64 `json:"threshold" binding:"required,gt=0,lte=1"`
+	AutoTune  bool    `json:"auto_tune"`
+}
+
+// LPRThresholdChange - Một lần thay đổi ngưỡng, ghi lại để kiểm tra
+type LPRThresholdChange struct {
+	ID             int         `json:"id"`
+	LotID          int         `json:"lot_id"`
+	CameraID       string      `json:"camera_id"`
+	OldThreshold   null.Float  `json:"old_threshold"`
+	NewThreshold   null.Float  `json:"new_threshold"`
+	Reason         string      `json:"reason"`
+	ChangedBy      null.String `json:"changed_by,omitempty"`
+	CorrectionRate null.Float  `json:"correction_rate,omitempty"`
+	SampleSize     null.Int    `json:"sample_size,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// LPRCorrectionStats - Số biển số máy đọc được và số lần operator phải sửa trong một cửa sổ thời gian
+type LPRCorrectionStats struct {
+	Reads     int
+	Corrected int
+}
@@ -3,16 +3,37 @@ package domain
 import "time"
 
 type ParkingLot struct {
-	ID         int       `json:"id"`
-	Name       string    `json:"name" binding:"required"`
-	Address    string    `json:"address,omitempty"`
-	TotalSlots int       `json:"total_slots,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         int    `json:"id"`
+	Name       string `json:"name" binding:"required"`
+	Address    string `json:"address,omitempty"`
+	TotalSlots int    `json:"total_slots,omitempty"`
+
+	// Chính sách thanh toán trước khi ra: giữ rào ra đến khi phiên được thanh toán
+	RequirePaymentBeforeExit bool `json:"require_payment_before_exit"`
+	ExitGraceMinutes         int  `json:"exit_grace_minutes"` // Thời gian (phút) xe phải ra sau khi thanh toán
+
+	// Tự động mở rào vào khi phiên được tạo từ kết quả LPR tại cổng
+	AutoOpenEntryBarrier bool `json:"auto_open_entry_barrier"`
+
+	// Các định dạng biển số (mã grammar trong package plate) bãi chấp nhận; rỗng = định dạng Việt Nam mặc định
+	AcceptedPlateGrammars []string `json:"accepted_plate_grammars"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// DefaultExitGraceMinutes - Thời gian ân hạn mặc định khi DTO không chỉ định
+const DefaultExitGraceMinutes = 15
+
 type ParkingLotDTO struct {
 	Name       string `json:"name" binding:"required"`
 	Address    string `json:"address"`
 	TotalSlots int    `json:"total_slots"`
+
+	RequirePaymentBeforeExit bool `json:"require_payment_before_exit"`
+	ExitGraceMinutes         *int `json:"exit_grace_minutes" binding:"omitempty,gte=0"` // nil = giữ nguyên / mặc định
+
+	AutoOpenEntryBarrier bool `json:"auto_open_entry_barrier"`
+
+	AcceptedPlateGrammars []string `json:"accepted_plate_grammars"`
 }
@@ -14,21 +14,30 @@ const (
 )
 
 type ParkingSession struct {
-	ID                int                  `json:"id"`
-	LotID             int                  `json:"lot_id"`
-	SlotID            null.Int             `json:"slot_id"`
-	Esp32ThingName    string               `json:"esp32_thing_name"`
-	VehicleIdentifier null.String          `json:"vehicle_identifier"` // Sẽ dùng cho LPR
-	EntryTime         time.Time            `json:"entry_time"`
-	ExitTime          null.Time            `json:"exit_time"`
-	DurationMinutes   null.Int             `json:"duration_minutes"`
-	CalculatedFee     null.Float           `json:"calculated_fee"`
-	PaymentStatus     string               `json:"payment_status"` // "pending", "paid", "failed", "waived"
-	Status            ParkingSessionStatus `json:"status"`
-	EntryGateEventID  null.String          `json:"entry_gate_event_id,omitempty"`
-	ExitGateEventID   null.String          `json:"exit_gate_event_id,omitempty"`
-	CreatedAt         time.Time            `json:"created_at"`
-	UpdatedAt         time.Time            `json:"updated_at"`
+	ID                 int                  `json:"id"`
+	LotID              int                  `json:"lot_id"`
+	SlotID             null.Int             `json:"slot_id"`
+	Esp32ThingName     string               `json:"esp32_thing_name"`
+	VehicleIdentifier  null.String          `json:"vehicle_identifier"` // Sẽ dùng cho LPR
+	EntryTime          time.Time            `json:"entry_time"`
+	ExitTime           null.Time            `json:"exit_time"`
+	DurationMinutes    null.Int             `json:"duration_minutes"`
+	CalculatedFee      null.Float           `json:"calculated_fee"`
+	FeeBreakdown       *FeeBreakdown        `json:"fee_breakdown,omitempty"` // Chi tiết phí theo từng khung giá
+	PaymentStatus      string               `json:"payment_status"`          // "pending", "partially_paid", "paid", "failed", "waived"
+	Status             ParkingSessionStatus `json:"status"`
+	EntryGateEventID   null.String          `json:"entry_gate_event_id,omitempty"`
+	ExitGateEventID    null.String          `json:"exit_gate_event_id,omitempty"`
+	ExitDeadline       null.Time            `json:"exit_deadline,omitempty"`   // Hạn chót ra bãi sau khi thanh toán (pay-before-exit)
+	SubscriptionID     null.Int             `json:"subscription_id,omitempty"` // Phiên được vé tháng bao phí
+	MatchScore         *float64             `json:"match_score,omitempty"`     // Điểm khớp biển số khi check-out (không lưu DB)
+	VehicleClass       null.String          `json:"vehicle_class,omitempty"`   // motorbike, car, truck - dùng cho hệ số giá và chọn chỗ đỗ
+	VehicleColor       null.String          `json:"vehicle_color,omitempty"`
+	VehicleMake        null.String          `json:"vehicle_make,omitempty"`
+	VehicleSource      null.String          `json:"vehicle_source,omitempty"` // lpr | operator
+	VehicleCorrectedBy null.String          `json:"vehicle_corrected_by,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
 
 	ParkingLot  *ParkingLot  `json:"parking_lot,omitempty" gorm:"-"`  // Không map vào DB, dùng để trả về API
 	ParkingSlot *ParkingSlot `json:"parking_slot,omitempty" gorm:"-"` // Không map vào DB
@@ -61,6 +70,14 @@ type VehicleCheckInDTO struct {
 	Esp32ThingName    string `json:"esp32_thing_name" binding:"required"`
 	VehicleIdentifier string `json:"vehicle_identifier" binding:"required"`
 	EntryTime         string `json:"entry_time,omitempty"`
+	OverrideWatchlist bool   `json:"override_watchlist,omitempty"` // Admin cho xe trong danh sách cảnh báo vào bãi
+	OverrideReason    string `json:"override_reason,omitempty"`    // Bắt buộc khi override_watchlist, lưu vào nhật ký phát hiện
+	Operator          string `json:"-"`                            // Người thực hiện check-in, gán từ JWT
+	WatchlistScreened bool   `json:"-"`                            // Biển số đã được kiểm tra tại cổng (luồng LPR)
+	VehicleClass      string `json:"vehicle_class,omitempty"`      // motorbike, car, truck - chọn chỗ đỗ đúng khu vực
+	VehicleColor      string `json:"vehicle_color,omitempty"`
+	VehicleMake       string `json:"vehicle_make,omitempty"`
+	VehicleSource     string `json:"-"` // lpr | operator, mặc định operator khi có loại xe
 	// EntryImageBase64  string `json:"entry_image_base64,omitempty"` // Bỏ qua nếu LPR đã xử lý ở frontend hoặc 1 API riêng
 }
 
@@ -70,5 +87,7 @@ type VehicleCheckOutDTO struct {
 	Esp32ThingName    string `json:"esp32_thing_name" binding:"required"`
 	VehicleIdentifier string `json:"vehicle_identifier" binding:"required"`
 	ExitTime          string `json:"exit_time,omitempty"`
+	VehicleClass      string `json:"vehicle_class,omitempty"` // motorbike, car, truck - dùng cho hệ số giá
+	SessionID         int    `json:"session_id,omitempty"`    // Phiên operator xác nhận từ danh sách biển số gần giống
 	// ExitImageBase64   string `json:"exit_image_base64,omitempty"`
 }
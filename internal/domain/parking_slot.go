@@ -19,13 +19,15 @@ type ParkingSlot struct {
 	Status                 SlotStatus `json:"status"`
 	LastStatusUpdateSource string     `json:"last_status_update_source,omitempty"`
 	LastEventTimestamp     *time.Time `json:"last_event_timestamp,omitempty"`
+	VehicleClass           string     `json:"vehicle_class,omitempty"` // Chỗ dành riêng cho loại xe, rỗng = mọi loại xe
 	CreatedAt              time.Time  `json:"created_at"`
 	UpdatedAt              time.Time  `json:"updated_at"`
 }
 
 type ParkingSlotDTO struct {
-	LotID          int    `json:"lot_id" binding:"required"`
-	SlotIdentifier string `json:"slot_identifier" binding:"required"`
-	Esp32ThingName string `json:"esp32_thing_name"`
-	Status         string `json:"status,omitempty"`
+	LotID          int     `json:"lot_id" binding:"required"`
+	SlotIdentifier string  `json:"slot_identifier" binding:"required"`
+	Esp32ThingName string  `json:"esp32_thing_name"`
+	Status         string  `json:"status,omitempty"`
+	VehicleClass   *string `json:"vehicle_class"` // nil = giữ nguyên, "" = dùng chung cho mọi loại xe
 }
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+// Trạng thái thanh toán của phiên đỗ xe (parking_sessions.payment_status)
+const (
+	PaymentStatusPending       = "pending"
+	PaymentStatusPartiallyPaid = "partially_paid"
+	PaymentStatusPaid          = "paid"
+	PaymentStatusFailed        = "failed"
+	PaymentStatusWaived        = "waived"
+)
+
+type PaymentMethod string
+
+const (
+	PaymentMethodGateway PaymentMethod = "gateway" // Thanh toán online qua cổng thanh toán
+	PaymentMethodCash    PaymentMethod = "cash"    // Operator thu tiền mặt
+	PaymentMethodWaiver  PaymentMethod = "waiver"  // Operator miễn phí, bắt buộc có lý do
+)
+
+type PaymentRecordStatus string
+
+const (
+	PaymentRecordPending           PaymentRecordStatus = "pending"
+	PaymentRecordSucceeded         PaymentRecordStatus = "succeeded"
+	PaymentRecordFailed            PaymentRecordStatus = "failed"
+	PaymentRecordPartiallyRefunded PaymentRecordStatus = "partially_refunded"
+	PaymentRecordRefunded          PaymentRecordStatus = "refunded"
+)
+
+// Payment - Một lần thanh toán (có thể một phần) cho phiên đỗ xe
+type Payment struct {
+	ID               int                 `json:"id"`
+	SessionID        int                 `json:"session_id"`
+	Method           PaymentMethod       `json:"method"`
+	Gateway          null.String         `json:"gateway"`           // Tên cổng thanh toán (chỉ với method = gateway)
+	GatewayReference null.String         `json:"gateway_reference"` // Mã giao dịch phía cổng thanh toán
+	CheckoutURL      null.String         `json:"checkout_url,omitempty"`
+	Amount           float64             `json:"amount"`
+	RefundedAmount   float64             `json:"refunded_amount"`
+	Currency         string              `json:"currency"`
+	Status           PaymentRecordStatus `json:"status"`
+	FailureReason    null.String         `json:"failure_reason,omitempty"`
+	Note             null.String         `json:"note,omitempty"` // Ghi chú / lý do miễn phí
+	RecordedBy       null.String         `json:"recorded_by,omitempty"`
+	PaidAt           null.Time           `json:"paid_at"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+
+	Refunds []PaymentRefund `json:"refunds,omitempty"`
+}
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending" // Đã ghi nhận, đang chờ cổng thanh toán xác nhận
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// PaymentRefund - Bản ghi hoàn tiền cho một payment
+type PaymentRefund struct {
+	ID               int          `json:"id"`
+	PaymentID        int          `json:"payment_id"`
+	Amount           float64      `json:"amount"`
+	Reason           string       `json:"reason"`
+	Status           RefundStatus `json:"status"`
+	GatewayReference null.String  `json:"gateway_reference"`
+	FailureReason    null.String  `json:"failure_reason,omitempty"`
+	RecordedBy       null.String  `json:"recorded_by,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+// SessionPaymentSummary - Tổng hợp thanh toán của phiên
+type SessionPaymentSummary struct {
+	SessionID     int       `json:"session_id"`
+	PaymentStatus string    `json:"payment_status"`
+	CalculatedFee float64   `json:"calculated_fee"`
+	PaidAmount    float64   `json:"paid_amount"`    // Đã trừ hoàn tiền
+	PendingAmount float64   `json:"pending_amount"` // Giao dịch online đang chờ cổng thanh toán
+	Outstanding   float64   `json:"outstanding"`    // Số tiền còn có thể thanh toán (đã trừ giao dịch đang chờ)
+	Payments      []Payment `json:"payments"`
+}
+
+type StartPaymentDTO struct {
+	Amount float64 `json:"amount" binding:"gte=0"` // 0 = thanh toán toàn bộ số còn nợ
+}
+
+type CashPaymentDTO struct {
+	Amount float64 `json:"amount" binding:"gte=0"` // 0 = thanh toán toàn bộ số còn nợ
+	Note   string  `json:"note,omitempty"`
+}
+
+type WaivePaymentDTO struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type RefundPaymentDTO struct {
+	Amount float64 `json:"amount" binding:"gte=0"` // 0 = hoàn toàn bộ số còn lại
+	Reason string  `json:"reason" binding:"required"`
+}
+
+// ExitPaymentConfirmation - Kết quả xác nhận thanh toán để mở rào ra
+type ExitPaymentConfirmation struct {
+	SessionID     int       `json:"session_id"`
+	PaymentStatus string    `json:"payment_status"`
+	ExitDeadline  time.Time `json:"exit_deadline"`           // Xe phải ra trước thời điểm này, quá hạn sẽ tính phí thêm
+	GateEventID   string    `json:"gate_event_id,omitempty"` // Gate event đang giữ rào (nếu xe đã ở cổng ra)
+	BarrierOpened bool      `json:"barrier_opened"`          // Đã gửi lệnh mở rào ra
+	RequestID     string    `json:"request_id,omitempty"`    // RequestID của lệnh mở rào
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationScheduled ReservationStatus = "scheduled" // Chưa tới giờ, chưa giữ chỗ
+	ReservationHeld      ReservationStatus = "held"      // Đã tới giờ, chỗ đỗ đang ở trạng thái reserved
+	ReservationConsumed  ReservationStatus = "consumed"  // Xe đã check-in bằng đặt chỗ này
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationNoShow    ReservationStatus = "no_show" // Xe không đến trước no_show_at, chỗ đã được nhả
+)
+
+// Reservation - Đặt chỗ cho một biển số trong khoảng thời gian.
+// SlotID rỗng khi đặt "bất kỳ chỗ nào trong bãi"; chỗ cụ thể được gán khi bắt đầu giữ chỗ.
+type Reservation struct {
+	ID                int               `json:"id"`
+	LotID             int               `json:"lot_id"`
+	SlotID            null.Int          `json:"slot_id"`
+	AnySlot           bool              `json:"any_slot"`
+	VehicleIdentifier string            `json:"vehicle_identifier"`
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	NoShowAt          time.Time         `json:"no_show_at"` // Quá thời điểm này mà xe chưa vào thì nhả chỗ
+	Status            ReservationStatus `json:"status"`
+	SessionID         null.Int          `json:"session_id"`
+	Note              null.String       `json:"note,omitempty"`
+	CreatedBy         null.String       `json:"created_by,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+type CreateReservationDTO struct {
+	LotID             int    `json:"lot_id" binding:"required"`
+	SlotID            *int   `json:"slot_id"` // nil = bất kỳ chỗ trống nào trong bãi
+	VehicleIdentifier string `json:"vehicle_identifier" binding:"required"`
+	StartTime         string `json:"start_time" binding:"required"` // RFC3339
+	EndTime           string `json:"end_time" binding:"required"`   // RFC3339
+	Note              string `json:"note,omitempty"`
+}
+
+type ReservationFilterDTO struct {
+	LotID             *int    `form:"lot_id"`
+	Status            *string `form:"status"`
+	VehicleIdentifier *string `form:"vehicle_identifier"`
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+// SubscriptionTariffName - tên biểu phí ghi vào FeeBreakdown của phiên được vé tháng bao phí
+const SubscriptionTariffName = "subscription"
+
+// Subscription - Vé tháng / thẻ mùa: các biển số được vào các bãi chỉ định trong thời hạn và khung giờ cho phép
+type Subscription struct {
+	ID                    int         `json:"id"`
+	HolderName            string      `json:"holder_name"`
+	HolderContact         null.String `json:"holder_contact,omitempty"`
+	VehicleIdentifiers    []string    `json:"vehicle_identifiers"`
+	LotIDs                []int       `json:"lot_ids"` // Rỗng = mọi bãi
+	ValidFrom             time.Time   `json:"valid_from"`
+	ValidUntil            time.Time   `json:"valid_until"`
+	WeekdayMask           int         `json:"weekday_mask"`                 // Bit 0 = Chủ nhật ... bit 6 = Thứ bảy
+	AllowedStartTime      null.String `json:"allowed_start_time,omitempty"` // "HH:MM", rỗng = cả ngày
+	AllowedEndTime        null.String `json:"allowed_end_time,omitempty"`
+	Timezone              string      `json:"timezone"`
+	MaxConcurrentVehicles int         `json:"max_concurrent_vehicles"` // Số xe của vé được ở trong bãi cùng lúc
+	IsActive              bool        `json:"is_active"`
+	Note                  null.String `json:"note,omitempty"`
+	CreatedAt             time.Time   `json:"created_at"`
+	UpdatedAt             time.Time   `json:"updated_at"`
+}
+
+type SubscriptionDTO struct {
+	HolderName            string   `json:"holder_name" binding:"required"`
+	HolderContact         string   `json:"holder_contact,omitempty"`
+	VehicleIdentifiers    []string `json:"vehicle_identifiers" binding:"required,min=1"`
+	LotIDs                []int    `json:"lot_ids"`
+	ValidFrom             string   `json:"valid_from" binding:"required"`        // RFC3339
+	ValidUntil            string   `json:"valid_until" binding:"required"`       // RFC3339
+	WeekdayMask           int      `json:"weekday_mask" binding:"gte=0,lte=127"` // 0 = mọi ngày
+	AllowedStartTime      string   `json:"allowed_start_time,omitempty"`
+	AllowedEndTime        string   `json:"allowed_end_time,omitempty"`
+	Timezone              string   `json:"timezone,omitempty"`
+	MaxConcurrentVehicles int      `json:"max_concurrent_vehicles" binding:"gte=0"` // 0 = 1 xe
+	IsActive              *bool    `json:"is_active"`
+	Note                  string   `json:"note,omitempty"`
+}
+
+type SubscriptionFilterDTO struct {
+	LotID             *int    `form:"lot_id"`
+	VehicleIdentifier *string `form:"vehicle_identifier"`
+	ActiveOnly        bool    `form:"active_only"`
+}
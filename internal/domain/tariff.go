@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+type TariffPricingMode string
+
+const (
+	PricingPerMinute TariffPricingMode = "per_minute" // Tính theo từng phút
+	PricingHourly    TariffPricingMode = "hourly"     // Tính theo từng giờ (đã bắt đầu)
+	PricingBlock     TariffPricingMode = "block"      // Tính theo block N phút tùy cấu hình
+)
+
+// Các loại phương tiện dùng cho hệ số giá (vehicle_multipliers)
+const (
+	VehicleClassMotorbike = "motorbike"
+	VehicleClassCar       = "car"
+	VehicleClassTruck     = "truck"
+)
+
+// DefaultTariffTimezone - Múi giờ mặc định để xác định ngày/đêm khi tính phí
+const DefaultTariffTimezone = "Asia/Ho_Chi_Minh"
+
+// WeekdayMaskAll - Khung giờ áp dụng cho mọi ngày trong tuần (bit i tương ứng time.Weekday(i))
+const WeekdayMaskAll = 1<<7 - 1
+
+// Tariff - Một phiên bản biểu phí của bãi đỗ. Phiên bản có hiệu lực tại thời điểm T là phiên bản
+// có effective_from <= T gần nhất, trừ khi nó đã bị ngừng áp dụng trước T (khi đó bãi dùng biểu phí mặc định);
+// phiên đỗ xe luôn tính theo phiên bản có hiệu lực lúc xe vào.
+type Tariff struct {
+	ID                 int                `json:"id"`
+	LotID              int                `json:"lot_id"`
+	Name               string             `json:"name"`
+	IsActive           bool               `json:"is_active"`
+	Version            int                `json:"version"`
+	PreviousVersionID  null.Int           `json:"previous_version_id"` // Phiên bản mà bản này thay thế
+	EffectiveFrom      time.Time          `json:"effective_from"`
+	DeactivatedAt      null.Time          `json:"deactivated_at,omitempty"` // Thời điểm ngừng áp dụng (is_active = false)
+	PricingMode        TariffPricingMode  `json:"pricing_mode"`
+	BlockMinutes       int                `json:"block_minutes"`                 // Độ dài 1 block (per_minute = 1, hourly = 60)
+	BlockPrice         float64            `json:"block_price"`                   // Giá cho mỗi block đã bắt đầu
+	GracePeriodMinutes int                `json:"grace_period_minutes"`          // Miễn phí nếu thời gian đỗ không vượt quá N phút
+	MinimumFee         float64            `json:"minimum_fee"`                   // Phí tối thiểu khi có tính phí
+	DailyCap           float64            `json:"daily_cap,omitempty"`           // Phí tối đa mỗi ngày, 0 = không giới hạn
+	OvernightStart     string             `json:"overnight_start,omitempty"`     // "HH:MM", ví dụ "22:00"
+	OvernightEnd       string             `json:"overnight_end,omitempty"`       // "HH:MM", ví dụ "06:00"
+	OvernightFlatRate  float64            `json:"overnight_flat_rate,omitempty"` // Giá cố định cho mỗi đêm, thay cho giá theo block
+	VehicleMultipliers map[string]float64 `json:"vehicle_multipliers,omitempty"` // Ví dụ: {"motorbike": 0.5, "truck": 2}
+	Timezone           string             `json:"timezone"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+
+	Windows []TariffScheduleWindow `json:"windows,omitempty"` // Khung giờ có giá riêng, ngoài khung dùng block_price
+}
+
+// TariffScheduleWindow - Khung giờ trong ngày có giá riêng (giờ cao điểm, ban đêm, ngày lễ...)
+type TariffScheduleWindow struct {
+	ID           int     `json:"id"`
+	TariffID     int     `json:"tariff_id"`
+	Name         string  `json:"name"`
+	WeekdayMask  int     `json:"weekday_mask"` // Bit 0 = Chủ nhật ... bit 6 = Thứ bảy
+	HolidayOnly  bool    `json:"holiday_only"` // Chỉ áp dụng vào ngày lễ (ưu tiên hơn khung thường)
+	StartTime    string  `json:"start_time"`   // "HH:MM"
+	EndTime      string  `json:"end_time"`     // "HH:MM", nhỏ hơn start_time nếu khung qua nửa đêm
+	BlockMinutes int     `json:"block_minutes"`
+	BlockPrice   float64 `json:"block_price"`
+	Priority     int     `json:"priority"` // Khung có priority cao hơn thắng khi chồng lấn
+}
+
+type TariffScheduleWindowDTO struct {
+	Name         string  `json:"name" binding:"required"`
+	WeekdayMask  int     `json:"weekday_mask" binding:"gte=0,lte=127"` // 0 = mọi ngày
+	HolidayOnly  bool    `json:"holiday_only"`
+	StartTime    string  `json:"start_time" binding:"required"`
+	EndTime      string  `json:"end_time" binding:"required"`
+	BlockMinutes int     `json:"block_minutes" binding:"gte=0"` // 0 = dùng block_minutes của biểu phí
+	BlockPrice   float64 `json:"block_price" binding:"gte=0"`
+	Priority     int     `json:"priority"`
+}
+
+type TariffDTO struct {
+	Name               string             `json:"name" binding:"required"`
+	IsActive           bool               `json:"is_active"`
+	PricingMode        string             `json:"pricing_mode" binding:"required,oneof=per_minute hourly block"`
+	BlockMinutes       int                `json:"block_minutes"` // Bắt buộc khi pricing_mode = block
+	BlockPrice         float64            `json:"block_price" binding:"gte=0"`
+	GracePeriodMinutes int                `json:"grace_period_minutes" binding:"gte=0"`
+	MinimumFee         float64            `json:"minimum_fee" binding:"gte=0"`
+	DailyCap           float64            `json:"daily_cap" binding:"gte=0"`
+	OvernightStart     string             `json:"overnight_start,omitempty"`
+	OvernightEnd       string             `json:"overnight_end,omitempty"`
+	OvernightFlatRate  float64            `json:"overnight_flat_rate" binding:"gte=0"`
+	VehicleMultipliers map[string]float64 `json:"vehicle_multipliers,omitempty"`
+	Timezone           string             `json:"timezone,omitempty"`
+	EffectiveFrom      string             `json:"effective_from,omitempty"` // RFC3339, mặc định là thời điểm hiện tại
+
+	Windows []TariffScheduleWindowDTO `json:"windows,omitempty" binding:"omitempty,dive"`
+}
+
+// DefaultTariff - Biểu phí dự phòng khi bãi chưa cấu hình: 1000 VND/phút, tối thiểu 5000 VND
+func DefaultTariff(lotID int) *Tariff {
+	return &Tariff{
+		LotID:        lotID,
+		Name:         "default",
+		IsActive:     true,
+		Version:      1,
+		PricingMode:  PricingPerMinute,
+		BlockMinutes: 1,
+		BlockPrice:   1000,
+		MinimumFee:   5000,
+		Timezone:     DefaultTariffTimezone,
+	}
+}
+
+// FeeBreakdownItem - Một dòng trong chi tiết phí: số phút của phiên rơi vào một khung giá trong một ngày
+type FeeBreakdownItem struct {
+	Date      string    `json:"date"`                // Ngày (theo timezone biểu phí) mà phần phí thuộc về
+	Label     string    `json:"label"`               // "base", "overnight" hoặc tên khung giờ
+	WindowID  *int      `json:"window_id,omitempty"` // ID khung giờ nếu có
+	Holiday   string    `json:"holiday,omitempty"`   // Tên ngày lễ nếu khung áp dụng do ngày lễ
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Minutes   float64   `json:"minutes"`
+	Blocks    int       `json:"blocks"`
+	UnitPrice float64   `json:"unit_price"`
+	Amount    float64   `json:"amount"`
+}
+
+// FeeBreakdown - Chi tiết cách tính phí của một phiên, được lưu cùng ParkingSession.CalculatedFee
+type FeeBreakdown struct {
+	TariffID             int                `json:"tariff_id,omitempty"` // 0 nếu dùng biểu phí mặc định
+	TariffName           string             `json:"tariff_name"`
+	TariffVersion        int                `json:"tariff_version"`
+	Items                []FeeBreakdownItem `json:"items"`
+	Subtotal             float64            `json:"subtotal"`
+	DailyCapDiscount     float64            `json:"daily_cap_discount,omitempty"`
+	VehicleClass         string             `json:"vehicle_class,omitempty"`
+	Multiplier           float64            `json:"multiplier"`
+	MinimumFeeAdjustment float64            `json:"minimum_fee_adjustment,omitempty"`
+	GracePeriodApplied   bool               `json:"grace_period_applied,omitempty"`
+	SubscriptionID       *int               `json:"subscription_id,omitempty"` // Phiên được vé tháng bao phí, Total = 0
+	Total                float64            `json:"total"`
+}
@@ -28,3 +28,8 @@ type AuthResponseDTO struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
 }
+
+// UserLotsDTO - Admin phân công bãi đỗ cho operator
+type UserLotsDTO struct {
+	LotIDs []int `json:"lot_ids" binding:"required"`
+}
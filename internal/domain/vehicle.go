@@ -0,0 +1,44 @@
+package domain
+
+// Nguồn của thông tin loại xe trên phiên đỗ xe
+const (
+	VehicleSourceLPR      = "lpr"      // Nhận diện từ ảnh camera tại cổng
+	VehicleSourceOperator = "operator" // Operator nhập hoặc sửa
+)
+
+// VehicleClasses - Các loại xe hệ thống phân biệt khi chọn chỗ đỗ và tính phí
+var VehicleClasses = []string{VehicleClassMotorbike, VehicleClassCar, VehicleClassTruck}
+
+// IsValidVehicleClass - Rỗng được coi là hợp lệ (chưa xác định loại xe)
+func IsValidVehicleClass(class string) bool {
+	if class == "" {
+		return true
+	}
+	for _, c := range VehicleClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// VehicleAttributes - Thuộc tính xe nhận diện từ ảnh, đi kèm biển số
+type VehicleAttributes struct {
+	VehicleClass    string  `json:"vehicle_class,omitempty"`
+	ClassConfidence float32 `json:"class_confidence,omitempty"` // 0..1
+	Color           string  `json:"color,omitempty"`
+	Make            string  `json:"make,omitempty"`
+}
+
+// IsEmpty cho biết không nhận diện được thuộc tính nào
+func (a *VehicleAttributes) IsEmpty() bool {
+	return a == nil || (a.VehicleClass == "" && a.Color == "" && a.Make == "")
+}
+
+// VehicleAttributesDTO - Operator sửa loại xe / màu xe / hãng xe của phiên đỗ xe
+type VehicleAttributesDTO struct {
+	VehicleClass string `json:"vehicle_class"`
+	Color        string `json:"color"`
+	Make         string `json:"make"`
+	Operator     string `json:"-"` // Gán từ JWT
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"time"
+)
+
+type WatchlistReason string
+
+const (
+	WatchlistStolen     WatchlistReason = "stolen"      // Xe báo mất cắp
+	WatchlistBanned     WatchlistReason = "banned"      // Xe bị cấm vào bãi
+	WatchlistUnpaidDebt WatchlistReason = "unpaid_debt" // Xe còn nợ phí đỗ
+	WatchlistVIP        WatchlistReason = "vip"         // Khách VIP: vẫn cho vào, chỉ báo cho operator
+)
+
+// Kết quả xử lý khi biển số trong danh sách bị phát hiện
+const (
+	WatchlistActionDenied     = "denied"     // Không tự động tạo phiên / mở rào, chờ operator
+	WatchlistActionAllowed    = "allowed"    // VIP, xử lý bình thường
+	WatchlistActionOverridden = "overridden" // Operator chủ động cho qua
+)
+
+// Nguồn phát hiện
+const (
+	WatchlistSourceLPR     = "lpr"
+	WatchlistSourceCheckIn = "check_in"
+)
+
+// WatchlistEntry - Biển số bị gắn cờ. ExpiresAt rỗng = không hết hạn.
+type WatchlistEntry struct {
+	ID                int             `json:"id"`
+	VehicleIdentifier string          `json:"vehicle_identifier"`
+	ReasonCode        WatchlistReason `json:"reason_code"`
+	Note              null.String     `json:"note,omitempty"`
+	ExpiresAt         null.Time       `json:"expires_at"`
+	IsActive          bool            `json:"is_active"`
+	CreatedBy         null.String     `json:"created_by,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// AllowsEntry - chỉ VIP được xử lý tự động như bình thường
+func (e *WatchlistEntry) AllowsEntry() bool {
+	return e.ReasonCode == WatchlistVIP
+}
+
+type WatchlistDTO struct {
+	VehicleIdentifier string `json:"vehicle_identifier" binding:"required"`
+	ReasonCode        string `json:"reason_code" binding:"required,oneof=stolen banned unpaid_debt vip"`
+	Note              string `json:"note,omitempty"`
+	ExpiresAt         string `json:"expires_at,omitempty"` // RFC3339, rỗng = không hết hạn
+	IsActive          *bool  `json:"is_active"`
+}
+
+type WatchlistFilterDTO struct {
+	VehicleIdentifier *string `form:"vehicle_identifier"`
+	ReasonCode        *string `form:"reason_code"`
+	ActiveOnly        bool    `form:"active_only"`
+}
+
+// WatchlistHit - Nhật ký mỗi lần biển số trong danh sách bị phát hiện
+type WatchlistHit struct {
+	ID                int             `json:"id"`
+	WatchlistID       null.Int        `json:"watchlist_id"`
+	VehicleIdentifier string          `json:"vehicle_identifier"`
+	ReasonCode        WatchlistReason `json:"reason_code"`
+	LotID             int             `json:"lot_id"`
+	GateEventID       null.String     `json:"gate_event_id,omitempty"`
+	Source            string          `json:"source"`
+	Action            string          `json:"action"`
+	Operator          null.String     `json:"operator,omitempty"`
+	OverrideReason    null.String     `json:"override_reason,omitempty"` // Lý do override (action = overridden)
+	DetectedAt        time.Time       `json:"detected_at"`
+}
+
+type WatchlistHitFilterDTO struct {
+	VehicleIdentifier *string `form:"vehicle_identifier"`
+	LotID             *int    `form:"lot_id"`
+	Limit             int     `form:"limit"`
+}
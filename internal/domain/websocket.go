@@ -0,0 +1,42 @@
+package domain
+
+// Loại message điều khiển trên kết nối WebSocket
+const (
+	WSMessageAuth        = "auth"         // Client -> server: gửi JWT nếu không truyền qua Sec-WebSocket-Protocol
+	WSMessageSubscribe   = "subscribe"    // Client -> server: chỉ nhận sự kiện của các bãi / loại event đã chọn
+	WSMessageUnsubscribe = "unsubscribe"  // Client -> server: bỏ các bãi / loại event khỏi đăng ký
+	WSMessageResume      = "resume"       // Client -> server: gửi lại các sự kiện sau last_seq theo đăng ký hiện tại
+	WSMessageAuthOK      = "auth_ok"      // Server -> client: xác thực thành công
+	WSMessageSubscribed  = "subscribed"   // Server -> client: đăng ký hiện tại sau khi thay đổi
+	WSMessageReplayed    = "replayed"     // Server -> client: đã gửi lại xong các sự kiện bị lỡ
+	WSMessageError       = "error"        // Server -> client: lỗi xác thực / đăng ký
+	WSMessageLotsChanged = "lots_changed" // Server -> client: bãi được phân công thay đổi, sự kiện của bãi bị gỡ không còn được gửi
+)
+
+// WSClientMessage - Message client gửi lên qua WebSocket
+type WSClientMessage struct {
+	Type       string          `json:"type"`
+	Token      string          `json:"token,omitempty"`
+	LotIDs     []int           `json:"lot_ids,omitempty"`
+	EventTypes []GateEventType `json:"event_types,omitempty"`
+	// Nối lại sau khi mất kết nối: seq cuối cùng client đã nhận và stream_id của server lúc đó
+	LastSeq  *uint64 `json:"last_seq,omitempty"`
+	StreamID string  `json:"stream_id,omitempty"`
+}
+
+// WSServerMessage - Message điều khiển server gửi xuống. Sự kiện cổng vẫn được gửi nguyên dạng GateEventNotification.
+// LotIDs / EventTypes null nghĩa là nhận tất cả trong phạm vi được phép, [] nghĩa là không nhận gì.
+type WSServerMessage struct {
+	Type        string          `json:"type"`
+	Error       string          `json:"error,omitempty"`
+	Username    string          `json:"username,omitempty"`
+	Role        string          `json:"role,omitempty"`
+	AllowedLots []int           `json:"allowed_lots,omitempty"` // Bãi được phân công, bỏ trống với admin (mọi bãi)
+	LotIDs      []int           `json:"lot_ids"`
+	EventTypes  []GateEventType `json:"event_types"`
+	// StreamID đổi khi server khởi động lại (seq bắt đầu lại từ 1). Seq là seq mới nhất của server.
+	StreamID string `json:"stream_id,omitempty"`
+	Seq      uint64 `json:"seq,omitempty"`
+	Replayed int    `json:"replayed,omitempty"` // Số sự kiện đã gửi lại
+	Missed   bool   `json:"missed,omitempty"`   // Có sự kiện đã ra khỏi replay buffer hoặc quá nhiều để gửi lại, client cần tải lại trạng thái qua REST
+}
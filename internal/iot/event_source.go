@@ -0,0 +1,105 @@
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"smart_parking/internal/config"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// EventHandler xử lý một sự kiện thiết bị (JSON). IoTService.HandleDeviceEvent cài interface này.
+type EventHandler interface {
+	HandleDeviceEvent(ctx context.Context, body string) error
+}
+
+// EventSource - Nguồn sự kiện thiết bị (SQS, MQTT trực tiếp, HTTP ingest). Run chạy tới khi ctx bị hủy.
+// Mọi nguồn cùng ngữ nghĩa ack / nack: handler trả nil thì ack (message không được gửi lại), trả lỗi thì nack
+// (nguồn gửi lại message sau). Cách gửi lại là của từng nguồn: SQS sau visibility timeout, MQTT khi kết nối
+// lại phiên cũ, HTTP do gateway retry khi nhận 503.
+type EventSource interface {
+	Name() string
+	Run(ctx context.Context, handler EventHandler) error
+}
+
+// Message - Một sự kiện nhận được từ EventSource
+type Message interface {
+	Body() string
+	Ack(ctx context.Context) error
+	Nack(ctx context.Context, reason error) error
+}
+
+// dispatch chuyển message cho handler rồi ack / nack theo kết quả; trả về lỗi xử lý (nếu có)
+func dispatch(ctx context.Context, source string, handler EventHandler, msg Message) error {
+	processingErr := handler.HandleDeviceEvent(ctx, msg.Body())
+	if processingErr == nil {
+		if err := msg.Ack(ctx); err != nil {
+			log.Printf("%s: Lỗi ack message: %v", source, err)
+		}
+		return nil
+	}
+	if err := msg.Nack(ctx, processingErr); err != nil {
+		log.Printf("%s: Lỗi nack message: %v", source, err)
+	}
+	return processingErr
+}
+
+// enrichDeviceEvent thêm các trường IoT Rule vẫn thêm trước khi đẩy vào SQS (topic, thời điểm nhận, client id)
+// cho sự kiện tới thẳng từ MQTT / HTTP. Body không phải JSON object thì giữ nguyên để handler ghi log lỗi.
+func enrichDeviceEvent(body []byte, topic string, receivedAt time.Time) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return string(body)
+	}
+	if _, ok := fields["received_mqtt_topic"]; !ok && topic != "" {
+		fields["received_mqtt_topic"], _ = json.Marshal(topic)
+	}
+	if _, ok := fields["iot_processing_timestamp"]; !ok {
+		fields["iot_processing_timestamp"], _ = json.Marshal(receivedAt.UnixMilli())
+	}
+	if _, ok := fields["client_id_iot"]; !ok {
+		if deviceID, ok := fields["device_id"]; ok {
+			fields["client_id_iot"] = deviceID
+		}
+	}
+	enriched, err := json.Marshal(fields)
+	if err != nil {
+		return string(body)
+	}
+	return string(enriched)
+}
+
+// NewEventSources tạo các nguồn sự kiện theo EVENT_SOURCES. Nguồn HTTP được trả riêng để router gắn endpoint ingest.
+func NewEventSources(cfg *config.Config, sqsClient *sqs.Client) ([]EventSource, *HTTPEventSource, error) {
+	var sources []EventSource
+	var httpSource *HTTPEventSource
+	for _, name := range cfg.EventSources {
+		switch strings.ToLower(name) {
+		case "sqs":
+			if cfg.SQSEventQueueURL == "" {
+				log.Println("CẢNH BÁO: SQS_EVENT_QUEUE_URL chưa được cấu hình. SQS Consumer sẽ không chạy.")
+				continue
+			}
+			sources = append(sources, NewSQSConsumer(sqsClient, cfg))
+		case "mqtt":
+			source, err := NewMQTTEventSource(cfg)
+			if err != nil {
+				return nil, nil, err
+			}
+			sources = append(sources, source)
+		case "http":
+			if cfg.EventIngestToken == "" {
+				return nil, nil, fmt.Errorf("cần EVENT_INGEST_TOKEN để bật nguồn sự kiện http")
+			}
+			httpSource = NewHTTPEventSource()
+			sources = append(sources, httpSource)
+		default:
+			return nil, nil, fmt.Errorf("nguồn sự kiện không được hỗ trợ: %s", name)
+		}
+	}
+	return sources, httpSource, nil
+}
@@ -0,0 +1,59 @@
+package iot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrSourceNotRunning = errors.New("nguồn sự kiện chưa chạy")
+
+// HTTPEventSource nhận sự kiện do gateway tại bãi POST lên (POST /ingest/device-events).
+// Ack = 202 Accepted; nack = 503 để gateway giữ lại sự kiện và gửi lại sau.
+type HTTPEventSource struct {
+	mu      sync.RWMutex
+	handler EventHandler
+}
+
+func NewHTTPEventSource() *HTTPEventSource {
+	return &HTTPEventSource{}
+}
+
+func (s *HTTPEventSource) Name() string { return "http" }
+
+// Run gắn handler cho tới khi ctx bị hủy; sự kiện tới qua Deliver trong goroutine của HTTP server
+func (s *HTTPEventSource) Run(ctx context.Context, handler EventHandler) error {
+	s.mu.Lock()
+	s.handler = handler
+	s.mu.Unlock()
+	log.Println("HTTP Ingest: Sẵn sàng nhận sự kiện tại POST /ingest/device-events")
+
+	<-ctx.Done()
+	s.mu.Lock()
+	s.handler = nil
+	s.mu.Unlock()
+	return nil
+}
+
+// Deliver xử lý một sự kiện; trả lỗi nghĩa là nack. Sự kiện tới khi nguồn chưa chạy / đã dừng cũng bị nack.
+func (s *HTTPEventSource) Deliver(ctx context.Context, body []byte, topic string) error {
+	s.mu.RLock()
+	handler := s.handler
+	s.mu.RUnlock()
+	if handler == nil {
+		return ErrSourceNotRunning
+	}
+	msg := &httpMessage{body: enrichDeviceEvent(body, topic, time.Now())}
+	return dispatch(ctx, "HTTP Ingest", handler, msg)
+}
+
+// httpMessage - kết quả ack / nack được trả thẳng cho gateway qua mã HTTP
+type httpMessage struct {
+	body string
+}
+
+func (m *httpMessage) Body() string                                 { return m.body }
+func (m *httpMessage) Ack(ctx context.Context) error                { return nil }
+func (m *httpMessage) Nack(ctx context.Context, reason error) error { return nil }
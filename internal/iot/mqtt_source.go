@@ -0,0 +1,165 @@
+package iot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/config"
+	"smart_parking/internal/mqtt"
+	"time"
+)
+
+// Thời gian chờ kết nối lại tối đa khi broker không truy cập được
+const mqttMaxReconnectDelay = 30 * time.Second
+
+// MQTTEventSource nhận sự kiện thẳng từ broker MQTT (Mosquitto tại bãi, AWS IoT Core...) thay cho IoT Rule -> SQS.
+// Subscribe QoS 1 với phiên bền (clean session = false): message chỉ được PUBACK sau khi xử lý xong, nack thì
+// ngắt kết nối để broker gửi lại các message chưa ack khi kết nối lại. Khi chạy nhiều instance backend,
+// MQTT_SHARED_GROUP biến các topic thành shared subscription để broker chia message giữa các instance.
+type MQTTEventSource struct {
+	options        mqtt.Options
+	filters        []string
+	qos            byte
+	maxRedelivery  int
+	failedAttempts map[string]int // Số lần nack theo hash topic + payload, chỉ goroutine Run truy cập
+}
+
+func NewMQTTEventSource(cfg *config.Config) (*MQTTEventSource, error) {
+	if cfg.MQTTBrokerURL == "" {
+		return nil, fmt.Errorf("cần MQTT_BROKER_URL để bật nguồn sự kiện mqtt")
+	}
+	if len(cfg.MQTTTopics) == 0 {
+		return nil, fmt.Errorf("cần ít nhất một topic trong MQTT_TOPICS")
+	}
+	tlsConfig, err := mqtt.NewTLSConfig(cfg.MQTTCAFile, cfg.MQTTCertFile, cfg.MQTTKeyFile, cfg.MQTTInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]string, 0, len(cfg.MQTTTopics))
+	for _, topic := range cfg.MQTTTopics {
+		if cfg.MQTTSharedGroup != "" {
+			topic = fmt.Sprintf("$share/%s/%s", cfg.MQTTSharedGroup, topic)
+		}
+		filters = append(filters, topic)
+	}
+	qos := byte(1)
+	if cfg.MQTTQoS == 0 {
+		qos = 0
+	}
+
+	return &MQTTEventSource{
+		options: mqtt.Options{
+			BrokerURL:    cfg.MQTTBrokerURL,
+			ClientID:     cfg.MQTTClientID,
+			Username:     cfg.MQTTUsername,
+			Password:     cfg.MQTTPassword,
+			TLSConfig:    tlsConfig,
+			KeepAlive:    cfg.MQTTKeepAlive,
+			CleanSession: false,
+		},
+		filters:        filters,
+		qos:            qos,
+		maxRedelivery:  cfg.MQTTMaxRedelivery,
+		failedAttempts: make(map[string]int),
+	}, nil
+}
+
+func (s *MQTTEventSource) Name() string { return "mqtt" }
+
+func (s *MQTTEventSource) Run(ctx context.Context, handler EventHandler) error {
+	log.Printf("MQTT Source: Kết nối %s, topic %v (QoS %d)", s.options.BrokerURL, s.filters, s.qos)
+	delay := time.Second
+	for {
+		err := s.session(ctx, handler)
+		if ctx.Err() != nil {
+			log.Println("MQTT Source: context cancelled, stopping.")
+			return nil
+		}
+		log.Printf("MQTT Source: %v. Kết nối lại sau %s", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+		// Phiên vừa xử lý được message thì thử lại nhanh, broker lỗi liên tục thì giãn dần
+		if errors.Is(err, errMQTTNack) {
+			delay = time.Second
+			continue
+		}
+		delay *= 2
+		if delay > mqttMaxReconnectDelay {
+			delay = mqttMaxReconnectDelay
+		}
+	}
+}
+
+var errMQTTNack = errors.New("message bị nack, ngắt kết nối để broker gửi lại")
+
+// session chạy một kết nối: subscribe rồi xử lý tuần tự từng message theo thứ tự broker gửi.
+// Message lỗi quá maxRedelivery lần được ack để không chặn các message sau (payload đã nằm trong device_events_log).
+func (s *MQTTEventSource) session(ctx context.Context, handler EventHandler) error {
+	conn, err := mqtt.Dial(ctx, s.options)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Subscribe(ctx, s.filters, s.qos); err != nil {
+		return err
+	}
+	log.Printf("MQTT Source: Đã subscribe %v", s.filters)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-conn.Done():
+			return conn.Err()
+		case received := <-conn.Messages():
+			msg := &mqttMessage{conn: conn, message: received, body: enrichDeviceEvent(received.Payload, received.Topic, time.Now())}
+			key := mqttMessageKey(received)
+			err := dispatch(ctx, "MQTT Source", handler, msg)
+			if err == nil || received.QoS == 0 {
+				delete(s.failedAttempts, key)
+				continue
+			}
+			s.failedAttempts[key]++
+			if s.maxRedelivery > 0 && s.failedAttempts[key] >= s.maxRedelivery {
+				log.Printf("MQTT Source: Bỏ message trên topic %s sau %d lần xử lý lỗi: %v", received.Topic, s.failedAttempts[key], err)
+				delete(s.failedAttempts, key)
+				if ackErr := conn.Ack(received); ackErr != nil {
+					return ackErr
+				}
+				continue
+			}
+			return fmt.Errorf("%w (topic %s, lần %d): %v", errMQTTNack, received.Topic, s.failedAttempts[key], err)
+		}
+	}
+}
+
+func mqttMessageKey(msg mqtt.Message) string {
+	sum := sha256.Sum256(append([]byte(msg.Topic+"\x00"), msg.Payload...))
+	return hex.EncodeToString(sum[:])
+}
+
+// mqttMessage - ack = PUBACK; nack = không PUBACK, session đóng kết nối để broker gửi lại
+type mqttMessage struct {
+	conn    *mqtt.Conn
+	message mqtt.Message
+	body    string
+}
+
+func (m *mqttMessage) Body() string { return m.body }
+
+func (m *mqttMessage) Ack(ctx context.Context) error { return m.conn.Ack(m.message) }
+
+func (m *mqttMessage) Nack(ctx context.Context, reason error) error {
+	if m.message.QoS == 0 {
+		log.Printf("MQTT Source: Lỗi xử lý message QoS 0 trên topic %s, message bị bỏ: %v", m.message.Topic, reason)
+	}
+	return nil
+}
@@ -2,89 +2,360 @@ package iot
 
 import (
 	"context"
-	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"encoding/json"
+	"hash/fnv"
 	"log"
 	"smart_parking/internal/config"
-	"smart_parking/internal/service"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
+// Giới hạn của SQS: tối đa 10 message mỗi lần nhận / mỗi lệnh batch
+const sqsBatchSize = 10
+
+// Chu kỳ gom các message đã ack để xóa bằng DeleteMessageBatch
+const sqsDeleteFlushInterval = 500 * time.Millisecond
+
+// Thời gian chờ worker dừng sau khi hết drainTimeout và đã hủy context xử lý. Phải nhỏ hơn khoảng chờ thêm
+// của main khi tắt server.
+const sqsCancelGracePeriod = 3 * time.Second
+
+// SQSClient - các API SQS consumer dùng, *sqs.Client cài interface này
+type SQSClient interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageBatch(ctx context.Context, params *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	ChangeMessageVisibilityBatch(ctx context.Context, params *sqs.ChangeMessageVisibilityBatchInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+}
+
+// SQSConsumer nhận message từ SQS và xử lý song song bằng một nhóm worker. Message được chia vào worker theo
+// device_id nên sự kiện của cùng một thiết bị vẫn được xử lý theo thứ tự nhận. Message đang chờ / đang xử lý được
+// gia hạn visibility timeout định kỳ; message đã ack được xóa theo lô. Khi ctx bị hủy, consumer ngừng nhận message
+// mới và xử lý nốt các message đã nhận trong thời gian drainTimeout.
 type SQSConsumer struct {
-	sqsClient  *sqs.Client
-	queueURL   string
-	iotService *service.IoTService
+	sqsClient         SQSClient
+	queueURL          string
+	workers           int
+	visibilityTimeout time.Duration
+	drainTimeout      time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]string // MessageId -> ReceiptHandle của message đã nhận mà chưa ack / nack
+	deletes  chan types.Message
 }
 
-func NewSQSConsumer(client *sqs.Client, cfg *config.Config, iotService *service.IoTService) *SQSConsumer {
+func NewSQSConsumer(client SQSClient, cfg *config.Config) *SQSConsumer {
+	workers := cfg.SQSWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	visibilityTimeout := cfg.SQSVisibilityTimeout
+	if visibilityTimeout < 2*time.Second {
+		visibilityTimeout = 60 * time.Second
+	}
 	return &SQSConsumer{
-		sqsClient:  client,
-		queueURL:   cfg.SQSEventQueueURL,
-		iotService: iotService,
+		sqsClient:         client,
+		queueURL:          cfg.SQSEventQueueURL,
+		workers:           workers,
+		visibilityTimeout: visibilityTimeout,
+		drainTimeout:      cfg.EventSourceDrainTimeout,
+		inflight:          make(map[string]string),
 	}
 }
 
-func (c *SQSConsumer) Start(ctx context.Context) {
-	log.Printf("SQS Consumer đang bắt đầu lắng nghe queue: %s", c.queueURL)
-	for {
+func (c *SQSConsumer) Name() string { return "sqs" }
+
+func (c *SQSConsumer) Run(ctx context.Context, handler EventHandler) error {
+	log.Printf("SQS Consumer đang bắt đầu lắng nghe queue: %s (%d worker)", c.queueURL, c.workers)
+
+	// Xử lý message không dùng trực tiếp ctx để message đã nhận được xử lý xong khi drain
+	processCtx, cancelProcess := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelProcess()
+
+	c.deletes = make(chan types.Message, c.workers*sqsBatchSize)
+	deleterDone := make(chan struct{})
+	go c.runDeleter(deleterDone)
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+	go c.extendVisibility(heartbeatCtx)
+
+	var workers sync.WaitGroup
+	partitions := make([]chan *sqsMessage, c.workers)
+	for i := range partitions {
+		partitions[i] = make(chan *sqsMessage, sqsBatchSize)
+		workers.Add(1)
+		go func(queue <-chan *sqsMessage) {
+			defer workers.Done()
+			for msg := range queue {
+				dispatch(processCtx, "SQS Consumer", handler, msg)
+			}
+		}(partitions[i])
+	}
+
+	c.receive(ctx, partitions)
+
+	log.Printf("SQS Consumer: Đang drain các message đã nhận (tối đa %s)...", c.drainTimeout)
+	for _, queue := range partitions {
+		close(queue)
+	}
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(c.drainTimeout):
+		log.Println("SQS Consumer: Hết thời gian drain, hủy các message đang xử lý (sẽ được SQS gửi lại).")
+		cancelProcess()
 		select {
-		case <-ctx.Done():
-			log.Println("SQS Consumer: context cancelled, stopping.")
-			return
-		default:
-			receiveInput := &sqs.ReceiveMessageInput{
-				QueueUrl:            &c.queueURL,
-				MaxNumberOfMessages: 10,
-				WaitTimeSeconds:     20,
-				VisibilityTimeout:   60,
+		case <-drained:
+		case <-time.After(sqsCancelGracePeriod):
+			// Handler bỏ qua context: không chờ tiếp và không đóng c.deletes vì worker vẫn có thể ack.
+			// Dừng gia hạn visibility để message chưa xong được SQS gửi lại.
+			stopHeartbeat()
+			log.Printf("SQS Consumer: Worker không dừng sau %s kể từ khi hủy, dừng consumer mà không chờ.", sqsCancelGracePeriod)
+			return nil
+		}
+	}
+
+	stopHeartbeat()
+	close(c.deletes)
+	<-deleterDone
+	log.Println("SQS Consumer: context cancelled, stopping.")
+	return nil
+}
+
+// receive nhận message và chia vào worker tới khi ctx bị hủy. Partition đầy thì chờ, nên tổng số message đã nhận
+// mà chưa xử lý bị giới hạn bởi số worker.
+func (c *SQSConsumer) receive(ctx context.Context, partitions []chan *sqsMessage) {
+	for ctx.Err() == nil {
+		result, err := c.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            &c.queueURL,
+			MaxNumberOfMessages: sqsBatchSize,
+			WaitTimeSeconds:     20,
+			VisibilityTimeout:   int32(c.visibilityTimeout.Seconds()),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("SQS Consumer: Lỗi khi nhận message: %v", err)
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return
 			}
+			continue
+		}
+
+		if len(result.Messages) == 0 {
+			continue
+		}
+		log.Printf("SQS Consumer: Đã nhận %d message(s)", len(result.Messages))
 
-			result, err := c.sqsClient.ReceiveMessage(ctx, receiveInput)
-			if err != nil {
-				log.Printf("SQS Consumer: Lỗi khi nhận message: %v", err)
-				select {
-				case <-time.After(5 * time.Second):
-				case <-ctx.Done():
-					log.Println("SQS Consumer: context cancelled while waiting for retry.")
-					return
-				}
+		for i, message := range result.Messages {
+			msg := &sqsMessage{consumer: c, message: message}
+			if message.Body == nil {
+				log.Println("SQS Consumer: Nhận được message với body rỗng. Đang xóa...")
+				msg.Ack(ctx)
 				continue
 			}
+			c.track(message)
+			select {
+			case partitions[c.partition(message)] <- msg:
+			case <-ctx.Done():
+				// Chưa kịp chia cho worker: trả lại queue ngay cho instance khác thay vì chờ hết visibility timeout
+				c.release(result.Messages[i:])
+				return
+			}
+		}
+	}
+}
 
-			if len(result.Messages) == 0 {
-				continue
+// partition chọn worker theo device_id (hoặc client_id_iot) để giữ thứ tự sự kiện của từng thiết bị
+func (c *SQSConsumer) partition(message types.Message) int {
+	var device struct {
+		DeviceID string `json:"device_id"`
+		ClientID string `json:"client_id_iot"`
+	}
+	key := aws.ToString(message.MessageId)
+	if err := json.Unmarshal([]byte(aws.ToString(message.Body)), &device); err == nil {
+		if device.DeviceID != "" {
+			key = device.DeviceID
+		} else if device.ClientID != "" {
+			key = device.ClientID
+		}
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(c.workers))
+}
+
+func (c *SQSConsumer) track(message types.Message) {
+	if message.MessageId == nil || message.ReceiptHandle == nil {
+		return
+	}
+	c.mu.Lock()
+	c.inflight[*message.MessageId] = *message.ReceiptHandle
+	c.mu.Unlock()
+}
+
+func (c *SQSConsumer) forget(message types.Message) {
+	if message.MessageId == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.inflight, *message.MessageId)
+	c.mu.Unlock()
+}
+
+// extendVisibility gia hạn visibility timeout của các message chưa ack / nack sau mỗi nửa visibility timeout
+func (c *SQSConsumer) extendVisibility(ctx context.Context) {
+	ticker := time.NewTicker(c.visibilityTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			handles := make(map[string]string, len(c.inflight))
+			for id, handle := range c.inflight {
+				handles[id] = handle
+			}
+			c.mu.Unlock()
+			if len(handles) > 0 {
+				c.changeVisibility(handles, int32(c.visibilityTimeout.Seconds()))
 			}
+		}
+	}
+}
 
-			log.Printf("SQS Consumer: Đã nhận %d message(s)", len(result.Messages))
+// release trả các message chưa xử lý về queue ngay (visibility timeout = 0)
+func (c *SQSConsumer) release(messages []types.Message) {
+	handles := make(map[string]string, len(messages))
+	for _, message := range messages {
+		if message.MessageId != nil && message.ReceiptHandle != nil {
+			handles[*message.MessageId] = *message.ReceiptHandle
+		}
+		c.forget(message)
+	}
+	c.changeVisibility(handles, 0)
+}
 
-			for _, message := range result.Messages {
-				if message.Body == nil {
-					log.Println("SQS Consumer: Nhận được message với body rỗng. Đang xóa...")
-					c.deleteMessage(ctx, message.ReceiptHandle)
-					continue
-				}
+func (c *SQSConsumer) changeVisibility(handles map[string]string, timeoutSeconds int32) {
+	entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, 0, sqsBatchSize)
+	flush := func() {
+		if len(entries) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		output, err := c.sqsClient.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: &c.queueURL,
+			Entries:  entries,
+		})
+		if err != nil {
+			log.Printf("SQS Consumer: Lỗi đổi visibility timeout của %d message: %v", len(entries), err)
+		} else {
+			for _, failed := range output.Failed {
+				log.Printf("SQS Consumer: Không đổi được visibility timeout message %s: %s", aws.ToString(failed.Id), aws.ToString(failed.Message))
+			}
+		}
+		entries = entries[:0]
+	}
+	for id, handle := range handles {
+		entries = append(entries, types.ChangeMessageVisibilityBatchRequestEntry{
+			Id:                aws.String(id),
+			ReceiptHandle:     aws.String(handle),
+			VisibilityTimeout: timeoutSeconds,
+		})
+		if len(entries) == sqsBatchSize {
+			flush()
+		}
+	}
+	flush()
+}
 
-				processingErr := c.iotService.HandleDeviceEvent(ctx, *message.Body)
+// runDeleter gom các message đã ack thành lô tối đa 10 để xóa bằng DeleteMessageBatch. Chạy tới khi c.deletes bị đóng.
+func (c *SQSConsumer) runDeleter(done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(sqsDeleteFlushInterval)
+	defer ticker.Stop()
 
-				if processingErr == nil {
-					c.deleteMessage(ctx, message.ReceiptHandle)
-				} else {
-					log.Printf("SQS Consumer: Lỗi khi xử lý message ID %s: %v. Message sẽ được xử lý lại sau visibility timeout.", *message.MessageId, processingErr)
-				}
+	batch := make([]types.Message, 0, sqsBatchSize)
+	for {
+		select {
+		case message, ok := <-c.deletes:
+			if !ok {
+				c.deleteBatch(batch)
+				return
+			}
+			batch = append(batch, message)
+			if len(batch) == sqsBatchSize {
+				c.deleteBatch(batch)
+				batch = batch[:0]
 			}
+		case <-ticker.C:
+			c.deleteBatch(batch)
+			batch = batch[:0]
 		}
 	}
 }
 
-func (c *SQSConsumer) deleteMessage(ctx context.Context, receiptHandle *string) {
-	if receiptHandle == nil {
-		log.Println("SQS Consumer: Receipt handle rỗng, không thể xóa message.")
+// deleteBatch xóa các message đã xử lý. Message xóa lỗi sẽ được SQS gửi lại và bị bỏ qua nhờ dedup của IoTService.
+func (c *SQSConsumer) deleteBatch(batch []types.Message) {
+	if len(batch) == 0 {
 		return
 	}
-	_, delErr := c.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-		QueueUrl:      &c.queueURL,
-		ReceiptHandle: receiptHandle,
+	entries := make([]types.DeleteMessageBatchRequestEntry, 0, len(batch))
+	for i, message := range batch {
+		entries = append(entries, types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: message.ReceiptHandle,
+		})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := c.sqsClient.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: &c.queueURL,
+		Entries:  entries,
 	})
-	if delErr != nil {
-		log.Printf("SQS Consumer: Lỗi khi xóa message: %v", delErr)
+	if err != nil {
+		log.Printf("SQS Consumer: Lỗi khi xóa %d message: %v", len(entries), err)
+		return
 	}
+	for _, failed := range output.Failed {
+		log.Printf("SQS Consumer: Lỗi khi xóa message (entry %s): %s", aws.ToString(failed.Id), aws.ToString(failed.Message))
+	}
+}
+
+// sqsMessage - ack = xóa message khỏi queue (theo lô); nack = để nguyên, SQS gửi lại sau visibility timeout
+type sqsMessage struct {
+	consumer *SQSConsumer
+	message  types.Message
+}
+
+func (m *sqsMessage) Body() string { return *m.message.Body }
+
+func (m *sqsMessage) Ack(ctx context.Context) error {
+	m.consumer.forget(m.message)
+	if m.message.ReceiptHandle == nil {
+		log.Println("SQS Consumer: Receipt handle rỗng, không thể xóa message.")
+		return nil
+	}
+	m.consumer.deletes <- m.message
+	return nil
+}
+
+func (m *sqsMessage) Nack(ctx context.Context, reason error) error {
+	m.consumer.forget(m.message)
+	log.Printf("SQS Consumer: Lỗi khi xử lý message ID %s: %v. Message sẽ được xử lý lại sau visibility timeout.", aws.ToString(m.message.MessageId), reason)
+	return nil
 }
@@ -0,0 +1,366 @@
+// Package mqtt là client MQTT 3.1.1 tối giản cho backend: kết nối TCP/TLS (kèm client certificate), subscribe,
+// publish QoS 0/1 và ack thủ công từng message QoS 1 để message chưa xử lý xong được broker gửi lại.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrConnectionClosed = errors.New("mqtt: kết nối đã đóng")
+
+// Options - Cấu hình kết nối. BrokerURL dạng tcp://host:1883, mqtt://..., ssl://host:8883, tls://... hoặc mqtts://...
+type Options struct {
+	BrokerURL    string
+	ClientID     string
+	Username     string
+	Password     string
+	TLSConfig    *tls.Config // Dùng cho scheme ssl / tls / mqtts; nil = cấu hình TLS mặc định
+	KeepAlive    time.Duration
+	CleanSession bool // false = broker giữ subscription và message QoS 1 chưa ack khi mất kết nối
+	DialTimeout  time.Duration
+}
+
+// Message - PUBLISH nhận từ broker
+type Message struct {
+	Topic     string
+	Payload   []byte
+	QoS       byte
+	PacketID  uint16
+	Retained  bool
+	Duplicate bool // Broker gửi lại message chưa được ack
+}
+
+// Conn - Một phiên kết nối tới broker. Mất kết nối thì Done() đóng; tự kết nối lại là việc của bên dùng.
+// PUBLISH nhận được xếp vào hàng đợi và do goroutine dispatch chuyển sang Messages(), nên readLoop không bao giờ
+// bị chặn bởi bên xử lý chậm và vẫn đọc được PINGRESP / PUBACK / SUBACK.
+type Conn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	messages chan Message
+
+	queueMu sync.Mutex
+	queue   []Message
+	queued  chan struct{} // Báo dispatch có message mới trong queue
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   uint16
+	pending  map[uint16]chan *packet // SUBACK / PUBACK đang chờ theo packet id
+	lastRecv time.Time
+
+	done    chan struct{}
+	closeMu sync.Once
+	err     error
+}
+
+// Dial kết nối, gửi CONNECT và chờ CONNACK
+func Dial(ctx context.Context, opts Options) (*Conn, error) {
+	broker, err := url.Parse(opts.BrokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: broker url không hợp lệ: %w", err)
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: opts.DialTimeout}
+
+	var netConn net.Conn
+	switch broker.Scheme {
+	case "tcp", "mqtt":
+		netConn, err = dialer.DialContext(ctx, "tcp", hostWithPort(broker, "1883"))
+	case "ssl", "tls", "mqtts":
+		tlsConfig := opts.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = broker.Hostname()
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConfig}
+		netConn, err = tlsDialer.DialContext(ctx, "tcp", hostWithPort(broker, "8883"))
+	default:
+		return nil, fmt.Errorf("mqtt: scheme không được hỗ trợ: %s", broker.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: không kết nối được %s: %w", broker.Host, err)
+	}
+
+	c := &Conn{
+		conn:     netConn,
+		reader:   bufio.NewReader(netConn),
+		messages: make(chan Message),
+		queued:   make(chan struct{}, 1),
+		pending:  make(map[uint16]chan *packet),
+		done:     make(chan struct{}),
+	}
+
+	netConn.SetDeadline(time.Now().Add(opts.DialTimeout))
+	if _, err := netConn.Write(encodeConnect(opts)); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("mqtt: lỗi gửi CONNECT: %w", err)
+	}
+	ack, err := readPacket(c.reader)
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("mqtt: không nhận được CONNACK: %w", err)
+	}
+	if ack.kind != packetConnAck || len(ack.body) != 2 {
+		netConn.Close()
+		return nil, fmt.Errorf("%w: chờ CONNACK nhận packet loại %d", errMalformedPacket, ack.kind)
+	}
+	if err := connAckError(ack.body[1]); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+	c.lastRecv = time.Now()
+
+	go c.readLoop()
+	go c.dispatch()
+	if opts.KeepAlive > 0 {
+		go c.keepAlive(opts.KeepAlive)
+	}
+	return c, nil
+}
+
+func hostWithPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// Messages trả về các PUBLISH nhận được. Message QoS 1 phải được Ack sau khi xử lý xong.
+func (c *Conn) Messages() <-chan Message { return c.messages }
+
+// Done đóng khi kết nối kết thúc; Err cho biết lý do
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+func (c *Conn) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Subscribe đăng ký các topic filter (có thể là shared subscription $share/<group>/<filter>) và chờ SUBACK
+func (c *Conn) Subscribe(ctx context.Context, filters []string, qos byte) error {
+	id, wait := c.reserveID()
+	defer c.releaseID(id)
+	if err := c.write(encodeSubscribe(id, filters, qos)); err != nil {
+		return err
+	}
+	ack, err := c.await(ctx, wait)
+	if err != nil {
+		return err
+	}
+	if len(ack.body) != 2+len(filters) {
+		return errMalformedPacket
+	}
+	for i, code := range ack.body[2:] {
+		if code == subAckFailure {
+			return fmt.Errorf("mqtt: broker từ chối subscribe '%s'", filters[i])
+		}
+	}
+	return nil
+}
+
+// Publish gửi message; QoS 1 chờ PUBACK của broker
+func (c *Conn) Publish(ctx context.Context, topic string, payload []byte, qos byte) error {
+	if qos == 0 {
+		return c.write(encodePublish(0, topic, payload, 0, false))
+	}
+	id, wait := c.reserveID()
+	defer c.releaseID(id)
+	if err := c.write(encodePublish(id, topic, payload, 1, false)); err != nil {
+		return err
+	}
+	_, err := c.await(ctx, wait)
+	return err
+}
+
+// Ack gửi PUBACK cho message QoS 1 đã xử lý xong. Message không được ack sẽ được broker gửi lại ở lần kết nối sau
+// (với CleanSession = false).
+func (c *Conn) Ack(msg Message) error {
+	if msg.QoS == 0 {
+		return nil
+	}
+	return c.write(encodePubAck(msg.PacketID))
+}
+
+// Close gửi DISCONNECT và đóng kết nối
+func (c *Conn) Close() error {
+	c.write(encodePacket(packetDisconnect, 0, nil))
+	c.shutdown(ErrConnectionClosed)
+	return nil
+}
+
+func (c *Conn) write(data []byte) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(data); err != nil {
+		c.shutdown(fmt.Errorf("mqtt: lỗi ghi: %w", err))
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) reserveID() (uint16, chan *packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			continue
+		}
+		if _, busy := c.pending[c.nextID]; !busy {
+			break
+		}
+	}
+	wait := make(chan *packet, 1)
+	c.pending[c.nextID] = wait
+	return c.nextID, wait
+}
+
+func (c *Conn) releaseID(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Conn) await(ctx context.Context, wait chan *packet) (*packet, error) {
+	select {
+	case ack := <-wait:
+		return ack, nil
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Conn) readLoop() {
+	for {
+		p, err := readPacket(c.reader)
+		if err != nil {
+			c.shutdown(fmt.Errorf("mqtt: mất kết nối: %w", err))
+			return
+		}
+		c.mu.Lock()
+		c.lastRecv = time.Now()
+		c.mu.Unlock()
+
+		switch p.kind {
+		case packetPublish:
+			msg, err := decodePublish(p)
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+			c.enqueue(msg)
+		case packetSubAck, packetPubAck:
+			if len(p.body) < 2 {
+				c.shutdown(errMalformedPacket)
+				return
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			wait, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				wait <- p
+			}
+		case packetPingResp:
+		default:
+			c.shutdown(fmt.Errorf("%w: loại %d không mong đợi", errMalformedPacket, p.kind))
+			return
+		}
+	}
+}
+
+// enqueue xếp message vào hàng đợi cho dispatch, không chờ bên dùng đọc Messages(). Với QoS 1 số message chưa
+// ack bị giới hạn bởi cửa sổ in-flight của broker nên hàng đợi không tăng vô hạn.
+func (c *Conn) enqueue(msg Message) {
+	c.queueMu.Lock()
+	c.queue = append(c.queue, msg)
+	c.queueMu.Unlock()
+	select {
+	case c.queued <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch chuyển message trong hàng đợi sang Messages() theo thứ tự nhận. Mất kết nối thì bỏ phần còn lại:
+// message QoS 1 chưa ack sẽ được broker gửi lại.
+func (c *Conn) dispatch() {
+	for {
+		c.queueMu.Lock()
+		if len(c.queue) == 0 {
+			c.queueMu.Unlock()
+			select {
+			case <-c.queued:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		msg := c.queue[0]
+		c.queue[0] = Message{}
+		c.queue = c.queue[1:]
+		c.queueMu.Unlock()
+
+		select {
+		case c.messages <- msg:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// keepAlive gửi PINGREQ định kỳ; không nhận được gì từ broker trong 1.5 lần keep alive thì coi như mất kết nối
+func (c *Conn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			silent := time.Since(c.lastRecv)
+			c.mu.Unlock()
+			if silent > interval*3/2 {
+				c.shutdown(fmt.Errorf("mqtt: broker không phản hồi sau %s", silent.Round(time.Second)))
+				return
+			}
+			c.write(encodePacket(packetPingReq, 0, nil))
+		}
+	}
+}
+
+func (c *Conn) shutdown(err error) {
+	c.closeMu.Do(func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	})
+}
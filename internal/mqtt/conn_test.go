@@ -0,0 +1,154 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker chấp nhận một kết nối, trả CONNACK thành công rồi giao phần còn lại cho serve.
+// Trả về broker URL cho Dial.
+func fakeBroker(t *testing.T, serve func(conn net.Conn, reader *bufio.Reader)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("không mở được listener: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		if p, err := readPacket(reader); err != nil || p.kind != packetConnect {
+			return
+		}
+		if _, err := conn.Write(encodePacket(packetConnAck, 0, []byte{0x00, 0x00})); err != nil {
+			return
+		}
+		serve(conn, reader)
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+// answerPings trả PINGRESP cho mỗi PINGREQ, PUBACK cho mỗi PUBLISH QoS 1, bỏ qua packet khác
+func answerPings(conn net.Conn, reader *bufio.Reader) {
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetPingReq:
+			conn.Write(encodePacket(packetPingResp, 0, nil))
+		case packetPublish:
+			if msg, err := decodePublish(p); err == nil && msg.QoS > 0 {
+				conn.Write(encodePubAck(msg.PacketID))
+			}
+		}
+	}
+}
+
+func dialTest(t *testing.T, brokerURL string, keepAlive time.Duration) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, Options{BrokerURL: brokerURL, ClientID: "test", KeepAlive: keepAlive, DialTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestKeepAliveWhileMessagesUnread(t *testing.T) {
+	const keepAlive = 100 * time.Millisecond
+	url := fakeBroker(t, func(conn net.Conn, reader *bufio.Reader) {
+		// Gửi message trước, bên dùng chưa đọc Messages() trong lúc keep alive vẫn phải chạy
+		conn.Write(encodePublish(1, "smart_parking/events/esp32-01", []byte("first"), 1, false))
+		conn.Write(encodePublish(2, "smart_parking/events/esp32-01", []byte("second"), 1, false))
+		answerPings(conn, reader)
+	})
+	conn := dialTest(t, url, keepAlive)
+
+	select {
+	case <-conn.Done():
+		t.Fatalf("kết nối bị đóng khi chưa đọc message: %v", conn.Err())
+	case <-time.After(keepAlive * 6):
+	}
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case msg := <-conn.Messages():
+			if string(msg.Payload) != want {
+				t.Fatalf("payload = %q, want %q", msg.Payload, want)
+			}
+			if err := conn.Ack(msg); err != nil {
+				t.Fatalf("Ack() error = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("không nhận được message %q", want)
+		}
+	}
+}
+
+func TestPublishAckedWhileMessagesUnread(t *testing.T) {
+	url := fakeBroker(t, func(conn net.Conn, reader *bufio.Reader) {
+		conn.Write(encodePublish(1, "smart_parking/events/esp32-01", []byte("unread"), 1, false))
+		answerPings(conn, reader)
+	})
+	conn := dialTest(t, url, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := conn.Publish(ctx, "smart_parking/command/barriers/entry", []byte(`{"action":"open"}`), 1); err != nil {
+		t.Fatalf("Publish() error = %v, PUBACK phải được đọc dù Messages() chưa được đọc", err)
+	}
+}
+
+func TestKeepAliveDetectsSilentBroker(t *testing.T) {
+	const keepAlive = 100 * time.Millisecond
+	url := fakeBroker(t, func(conn net.Conn, reader *bufio.Reader) {
+		// Đọc nhưng không trả PINGRESP
+		for {
+			if _, err := readPacket(reader); err != nil {
+				return
+			}
+		}
+	})
+	conn := dialTest(t, url, keepAlive)
+
+	select {
+	case <-conn.Done():
+		if conn.Err() == nil {
+			t.Fatalf("Err() = nil sau khi mất kết nối")
+		}
+	case <-time.After(keepAlive * 10):
+		t.Fatalf("keep alive không phát hiện broker im lặng")
+	}
+}
+
+func TestSubscribeRejectedFilter(t *testing.T) {
+	url := fakeBroker(t, func(conn net.Conn, reader *bufio.Reader) {
+		p, err := readPacket(reader)
+		if err != nil || p.kind != packetSubscribe {
+			return
+		}
+		id := binary.BigEndian.Uint16(p.body)
+		conn.Write(encodePacket(packetSubAck, 0, append(binary.BigEndian.AppendUint16(nil, id), 0x01, subAckFailure)))
+		answerPings(conn, reader)
+	})
+	conn := dialTest(t, url, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := conn.Subscribe(ctx, []string{"ok/#", "denied/#"}, 1); err == nil {
+		t.Fatalf("Subscribe() error = nil, want lỗi khi broker từ chối filter")
+	}
+}
@@ -0,0 +1,195 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Loại packet MQTT 3.1.1 (4 bit cao của byte đầu)
+const (
+	packetConnect     byte = 1
+	packetConnAck     byte = 2
+	packetPublish     byte = 3
+	packetPubAck      byte = 4
+	packetSubscribe   byte = 8
+	packetSubAck      byte = 9
+	packetPingReq     byte = 12
+	packetPingResp    byte = 13
+	packetDisconnect  byte = 14
+	protocolLevel311  byte = 4
+	subAckFailure     byte = 0x80
+	maxRemainingBytes      = 4
+)
+
+// Kích thước packet tối đa nhận từ broker, tránh broker lỗi làm cạn bộ nhớ
+const maxPacketSize = 1 << 20
+
+var errMalformedPacket = errors.New("mqtt: packet không hợp lệ")
+
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readRemainingLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("mqtt: packet %d byte vượt giới hạn %d", length, maxPacketSize)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+func readRemainingLength(r io.ByteReader) (int, error) {
+	length, multiplier := 0, 1
+	for i := 0; i < maxRemainingBytes; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errMalformedPacket
+}
+
+func encodePacket(kind, flags byte, body []byte) []byte {
+	buf := []byte{kind<<4 | flags}
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, body...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func readString(body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, errMalformedPacket
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return "", nil, errMalformedPacket
+	}
+	return string(body[2 : 2+n]), body[2+n:], nil
+}
+
+func encodeConnect(opts Options) []byte {
+	var flags byte
+	if opts.CleanSession {
+		flags |= 0x02
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive.Seconds()))
+	body = appendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+		if opts.Password != "" {
+			body = appendString(body, opts.Password)
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+func encodeSubscribe(packetID uint16, filters []string, qos byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, qos)
+	}
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+func encodePublish(packetID uint16, topic string, payload []byte, qos byte, retain bool) []byte {
+	flags := qos << 1
+	if retain {
+		flags |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	return encodePacket(packetPublish, flags, append(body, payload...))
+}
+
+func encodePubAck(packetID uint16) []byte {
+	return encodePacket(packetPubAck, 0, binary.BigEndian.AppendUint16(nil, packetID))
+}
+
+func decodePublish(p *packet) (Message, error) {
+	msg := Message{
+		QoS:       (p.flags >> 1) & 0x03,
+		Retained:  p.flags&0x01 != 0,
+		Duplicate: p.flags&0x08 != 0,
+	}
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return msg, err
+	}
+	msg.Topic = topic
+	if msg.QoS > 0 {
+		if len(rest) < 2 {
+			return msg, errMalformedPacket
+		}
+		msg.PacketID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	msg.Payload = rest
+	return msg, nil
+}
+
+// connAckError diễn giải mã trả về của CONNACK
+func connAckError(code byte) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("mqtt: broker không hỗ trợ MQTT 3.1.1")
+	case 2:
+		return errors.New("mqtt: client id bị từ chối")
+	case 3:
+		return errors.New("mqtt: broker không sẵn sàng")
+	case 4:
+		return errors.New("mqtt: sai username / password")
+	case 5:
+		return errors.New("mqtt: client không được phép kết nối")
+	default:
+		return fmt.Errorf("mqtt: CONNACK trả về mã %d", code)
+	}
+}
@@ -0,0 +1,225 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemainingLengthRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		header []byte // byte đầu + remaining length
+	}{
+		{name: "empty", length: 0, header: []byte{0x30, 0x00}},
+		{name: "one_byte_max", length: 127, header: []byte{0x30, 0x7f}},
+		{name: "two_bytes_min", length: 128, header: []byte{0x30, 0x80, 0x01}},
+		{name: "two_bytes_max", length: 16383, header: []byte{0x30, 0xff, 0x7f}},
+		{name: "three_bytes_min", length: 16384, header: []byte{0x30, 0x80, 0x80, 0x01}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := bytes.Repeat([]byte{'x'}, tc.length)
+			encoded := encodePacket(packetPublish, 0, body)
+			if got := encoded[:len(tc.header)]; !bytes.Equal(got, tc.header) {
+				t.Fatalf("header = % x, want % x", got, tc.header)
+			}
+			p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+			if err != nil {
+				t.Fatalf("readPacket() error = %v", err)
+			}
+			if p.kind != packetPublish || len(p.body) != tc.length {
+				t.Errorf("readPacket() kind=%d len=%d, want kind=%d len=%d", p.kind, len(p.body), packetPublish, tc.length)
+			}
+		})
+	}
+}
+
+func TestReadPacketRejectsMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "remaining_length_too_long", data: []byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}, wantErr: errMalformedPacket},
+		{name: "truncated_body", data: []byte{0x30, 0x05, 'a', 'b'}},
+		{name: "missing_length", data: []byte{0x30}},
+		{name: "over_max_size", data: encodePacketHeaderOnly(maxPacketSize + 1)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readPacket(bufio.NewReader(bytes.NewReader(tc.data)))
+			if err == nil {
+				t.Fatalf("readPacket() error = nil, want lỗi")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("readPacket() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// encodePacketHeaderOnly trả về byte đầu + remaining length, không kèm body
+func encodePacketHeaderOnly(length int) []byte {
+	full := encodePacket(packetPublish, 0, make([]byte, length))
+	return full[:len(full)-length]
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		packetID uint16
+		topic    string
+		payload  []byte
+		qos      byte
+		retain   bool
+		want     Message
+	}{
+		{
+			name:    "qos0",
+			topic:   "smart_parking/events/esp32-01",
+			payload: []byte(`{"event":"gate"}`),
+			want:    Message{Topic: "smart_parking/events/esp32-01", Payload: []byte(`{"event":"gate"}`)},
+		},
+		{
+			name:     "qos1_with_packet_id",
+			packetID: 42,
+			topic:    "smart_parking/events/esp32-02",
+			payload:  []byte("x"),
+			qos:      1,
+			want:     Message{Topic: "smart_parking/events/esp32-02", Payload: []byte("x"), QoS: 1, PacketID: 42},
+		},
+		{
+			name:   "retained_empty_payload",
+			topic:  "smart_parking/status",
+			retain: true,
+			want:   Message{Topic: "smart_parking/status", Payload: []byte{}, Retained: true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := encodePublish(tc.packetID, tc.topic, tc.payload, tc.qos, tc.retain)
+			p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+			if err != nil {
+				t.Fatalf("readPacket() error = %v", err)
+			}
+			msg, err := decodePublish(p)
+			if err != nil {
+				t.Fatalf("decodePublish() error = %v", err)
+			}
+			if !reflect.DeepEqual(msg, tc.want) {
+				t.Errorf("decodePublish() = %+v, want %+v", msg, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodePublishDuplicateFlag(t *testing.T) {
+	encoded := encodePublish(7, "t", []byte("p"), 1, false)
+	encoded[0] |= 0x08 // DUP
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+	if err != nil {
+		t.Fatalf("readPacket() error = %v", err)
+	}
+	msg, err := decodePublish(p)
+	if err != nil {
+		t.Fatalf("decodePublish() error = %v", err)
+	}
+	if !msg.Duplicate || msg.PacketID != 7 {
+		t.Errorf("decodePublish() = %+v, want Duplicate=true PacketID=7", msg)
+	}
+}
+
+func TestDecodePublishMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *packet
+	}{
+		{name: "no_topic_length", p: &packet{kind: packetPublish, body: []byte{0x00}}},
+		{name: "topic_truncated", p: &packet{kind: packetPublish, body: []byte{0x00, 0x05, 'a'}}},
+		{name: "qos1_missing_packet_id", p: &packet{kind: packetPublish, flags: 0x02, body: []byte{0x00, 0x01, 'a', 0x00}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decodePublish(tc.p); !errors.Is(err, errMalformedPacket) {
+				t.Errorf("decodePublish() error = %v, want %v", err, errMalformedPacket)
+			}
+		})
+	}
+}
+
+func TestEncodeConnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		wantFlags byte
+		wantTail  []byte // client id, username, password sau keep alive
+	}{
+		{
+			name:      "clean_session_no_auth",
+			opts:      Options{ClientID: "c1", CleanSession: true, KeepAlive: 30 * time.Second},
+			wantFlags: 0x02,
+			wantTail:  []byte{0x00, 0x02, 'c', '1'},
+		},
+		{
+			name:      "username_and_password",
+			opts:      Options{ClientID: "c1", Username: "u", Password: "p", KeepAlive: 30 * time.Second},
+			wantFlags: 0xc0,
+			wantTail:  []byte{0x00, 0x02, 'c', '1', 0x00, 0x01, 'u', 0x00, 0x01, 'p'},
+		},
+		{
+			name:      "username_only",
+			opts:      Options{ClientID: "c1", Username: "u", KeepAlive: 30 * time.Second},
+			wantFlags: 0x80,
+			wantTail:  []byte{0x00, 0x02, 'c', '1', 0x00, 0x01, 'u'},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := readPacket(bufio.NewReader(bytes.NewReader(encodeConnect(tc.opts))))
+			if err != nil {
+				t.Fatalf("readPacket() error = %v", err)
+			}
+			if p.kind != packetConnect {
+				t.Fatalf("kind = %d, want %d", p.kind, packetConnect)
+			}
+			// "MQTT" (6 byte) + protocol level + flags + keep alive (2 byte)
+			want := append([]byte{0x00, 0x04, 'M', 'Q', 'T', 'T', protocolLevel311, tc.wantFlags, 0x00, 30}, tc.wantTail...)
+			if !bytes.Equal(p.body, want) {
+				t.Errorf("CONNECT body = % x, want % x", p.body, want)
+			}
+		})
+	}
+}
+
+func TestEncodeSubscribe(t *testing.T) {
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encodeSubscribe(5, []string{"a/#", "$share/g/b"}, 1))))
+	if err != nil {
+		t.Fatalf("readPacket() error = %v", err)
+	}
+	if p.kind != packetSubscribe || p.flags != 0x02 {
+		t.Fatalf("kind=%d flags=%d, want kind=%d flags=2", p.kind, p.flags, packetSubscribe)
+	}
+	want := []byte{0x00, 0x05, 0x00, 0x03, 'a', '/', '#', 0x01, 0x00, 0x0a, '$', 's', 'h', 'a', 'r', 'e', '/', 'g', '/', 'b', 0x01}
+	if !bytes.Equal(p.body, want) {
+		t.Errorf("SUBSCRIBE body = % x, want % x", p.body, want)
+	}
+}
+
+func TestConnAckError(t *testing.T) {
+	for code := byte(0); code <= 6; code++ {
+		err := connAckError(code)
+		if (code == 0) != (err == nil) {
+			t.Errorf("connAckError(%d) = %v", code, err)
+		}
+	}
+}
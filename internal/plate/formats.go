@@ -0,0 +1,157 @@
+package plate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mã grammar đã đăng ký sẵn
+const (
+	GrammarVNDiplomatic = "vn_diplomatic"
+	GrammarVNMilitary   = "vn_military"
+	GrammarVNCar        = "vn_car"
+	GrammarVNMotorbike  = "vn_motorbike"
+	GrammarUK           = "uk"
+	GrammarFR           = "fr"
+	GrammarDE           = "de"
+	GrammarUSCA         = "us_ca"
+	GrammarUSNY         = "us_ny"
+)
+
+// DefaultVNGrammars - Các định dạng biển số Việt Nam, dùng khi bãi không cấu hình riêng
+var DefaultVNGrammars = []string{GrammarVNDiplomatic, GrammarVNMilitary, GrammarVNCar, GrammarVNMotorbike}
+
+// vnProvinceCodes - Mã tỉnh/thành trên biển số Việt Nam (80 là cơ quan trung ương)
+var vnProvinceCodes = buildVNProvinceCodes()
+
+func buildVNProvinceCodes() map[string]bool {
+	codes := make(map[string]bool)
+	for code := 11; code <= 99; code++ {
+		switch code {
+		case 13, 42, 44, 45, 46, 87, 91, 96: // không cấp
+			continue
+		}
+		codes[fmt.Sprintf("%02d", code)] = true
+	}
+	return codes
+}
+
+// vnMilitaryPrefixes - Ký hiệu đơn vị trên biển số quân sự
+var vnMilitaryPrefixes = map[string]bool{
+	"AA": true, "AB": true, "AC": true, "AD": true, "AM": true, "AN": true, "AP": true, "AT": true, "AV": true,
+	"BB": true, "BC": true, "BH": true, "BK": true, "BL": true, "BP": true, "BS": true, "BT": true, "BV": true,
+	"HA": true, "HB": true, "HC": true, "HD": true, "HE": true, "HH": true, "HN": true, "HQ": true, "HT": true,
+	"KA": true, "KB": true, "KC": true, "KD": true, "KK": true, "KN": true, "KP": true, "KT": true, "KV": true,
+	"PA": true, "PK": true, "PM": true, "PP": true, "PQ": true, "PT": true, "PX": true, "PY": true,
+	"QA": true, "QB": true, "QH": true, "TC": true, "TH": true, "TK": true, "TM": true, "TN": true, "TT": true,
+}
+
+// vnDigits hiển thị phần số của biển Việt Nam: "123.45" cho 5 số, "1234" cho 4 số
+func vnDigits(d3, d2, d4 string) string {
+	if d4 != "" {
+		return d4
+	}
+	return d3 + "." + d2
+}
+
+func validVNProvince(parts []string) bool { return vnProvinceCodes[parts[0]] }
+
+func init() {
+	Register(Grammar{
+		Code:        GrammarVNDiplomatic,
+		Country:     "VN",
+		Description: "Biển ngoại giao / tổ chức quốc tế, ví dụ 80-NG-123-45",
+		Pattern:     regexp.MustCompile(`^(\d{2}) ?(NG|NN|QT|CV) ?(\d{3}) ?(\d{2})$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s-%s-%s-%s", p[0], p[1], p[2], p[3])
+		},
+		ValidRegion: validVNProvince,
+	})
+	Register(Grammar{
+		Code:        GrammarVNMilitary,
+		Country:     "VN",
+		Description: "Biển quân sự, ví dụ QA-12-34",
+		Pattern:     regexp.MustCompile(`^([A-Z]{2}) ?(\d{2}) ?(\d{2,3})$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s-%s-%s", p[0], p[1], p[2])
+		},
+		ValidRegion: func(p []string) bool { return vnMilitaryPrefixes[p[0]] },
+	})
+	Register(Grammar{
+		Code:        GrammarVNCar,
+		Country:     "VN",
+		Description: "Biển ô tô một dòng, ví dụ 29A-123.45, 51LD-1234",
+		Pattern:     regexp.MustCompile(`^(\d{2}) ?([A-Z]{1,2}) ?(?:(\d{3}) ?(\d{2})|(\d{4}))$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s%s-%s", p[0], p[1], vnDigits(p[2], p[3], p[4]))
+		},
+		ValidRegion: validVNProvince,
+	})
+	Register(Grammar{
+		Code:        GrammarVNMotorbike,
+		Country:     "VN",
+		Description: "Biển xe máy hai dòng, ví dụ 29-B1 123.45",
+		Pattern:     regexp.MustCompile(`^(\d{2}) ?([A-Z][A-Z0-9]) ?(?:(\d{3}) ?(\d{2})|(\d{4}))$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s-%s %s", p[0], p[1], vnDigits(p[2], p[3], p[4]))
+		},
+		ValidRegion: validVNProvince,
+	})
+	Register(Grammar{
+		Code:        GrammarUK,
+		Country:     "GB",
+		Description: "Biển Anh định dạng từ 2001, ví dụ AB12 CDE",
+		Pattern:     regexp.MustCompile(`^([A-Z]{2})(\d{2}) ?([A-Z]{3})$`),
+		Display: func(p []string) string {
+			return p[0] + p[1] + " " + p[2]
+		},
+		// Mã vùng không bắt đầu bằng I/Q/Z, mã năm không phải 00/01/50, ba chữ cuối không có I/Q
+		ValidRegion: func(p []string) bool {
+			return !strings.ContainsAny(p[0][:1], "IQZ") &&
+				p[1] != "00" && p[1] != "01" && p[1] != "50" &&
+				!strings.ContainsAny(p[2], "IQ")
+		},
+	})
+	Register(Grammar{
+		Code:        GrammarFR,
+		Country:     "FR",
+		Description: "Biển Pháp (SIV), ví dụ AB-123-CD",
+		Pattern:     regexp.MustCompile(`^([A-Z]{2}) ?(\d{3}) ?([A-Z]{2})$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s-%s-%s", p[0], p[1], p[2])
+		},
+		// SIV không dùng I/O/U, không cấp WW ở đầu, SS ở cuối và số 000
+		ValidRegion: func(p []string) bool {
+			return !strings.ContainsAny(p[0]+p[2], "IOU") && p[0] != "WW" && p[2] != "SS" && p[1] != "000"
+		},
+	})
+	Register(Grammar{
+		Code:        GrammarDE,
+		Country:     "DE",
+		Description: "Biển Đức, ví dụ B-AB 1234, M-XY 12E",
+		// Mã quận phải có dấu phân cách vì dính liền thì không tách được với ký hiệu nhận dạng
+		Pattern: regexp.MustCompile(`^([A-ZÄÖÜ]{1,3}) ([A-Z]{1,2}) ?(\d{1,4})([EH]?)$`),
+		Display: func(p []string) string {
+			return fmt.Sprintf("%s-%s %s%s", p[0], p[1], p[2], p[3])
+		},
+	})
+	Register(Grammar{
+		Code:        GrammarUSCA,
+		Country:     "US",
+		Description: "Biển California, ví dụ 1ABC234",
+		Pattern:     regexp.MustCompile(`^(\d)([A-Z]{3})(\d{3})$`),
+		Display: func(p []string) string {
+			return p[0] + p[1] + p[2]
+		},
+	})
+	Register(Grammar{
+		Code:        GrammarUSNY,
+		Country:     "US",
+		Description: "Biển New York, ví dụ ABC-1234",
+		Pattern:     regexp.MustCompile(`^([A-Z]{3}) ?(\d{4})$`),
+		Display: func(p []string) string {
+			return p[0] + "-" + p[1]
+		},
+	})
+}
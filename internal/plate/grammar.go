@@ -0,0 +1,153 @@
+package plate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RegionBonus - Độ tin cậy cộng thêm khi biển số có mã tỉnh/vùng hoặc checksum hợp lệ
+const RegionBonus float32 = 0.05
+
+// Grammar mô tả một định dạng biển số. Pattern chạy trên văn bản đã in hoa, giữ dấu phân cách
+// (gạch, chấm, khoảng trắng) và các nhóm con tách biển số thành từng phần: dạng chuẩn là các
+// phần nối liền, dạng hiển thị do Display ghép lại.
+type Grammar struct {
+	Code        string
+	Country     string
+	Description string
+
+	Pattern *regexp.Regexp
+	// Display ghép các phần thành dạng hiển thị, ví dụ ["29","A","12345"] -> "29A-123.45"
+	Display func(parts []string) string
+	// ValidRegion kiểm tra mã tỉnh/vùng hoặc checksum của biển số; nil nếu định dạng không có
+	ValidRegion func(parts []string) bool
+}
+
+// Match - Kết quả khớp một văn bản với một grammar
+type Match struct {
+	Grammar     string `json:"grammar"`
+	Canonical   string `json:"canonical"`
+	Display     string `json:"display"`
+	RegionValid bool   `json:"region_valid"`
+	// Bonus cộng vào độ tin cậy OCR, bằng RegionBonus nếu mã tỉnh/checksum hợp lệ
+	Bonus float32 `json:"bonus"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Grammar
+)
+
+// Register thêm grammar vào registry; trùng Code thì thay grammar cũ.
+func Register(g Grammar) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for i := range registry {
+		if registry[i].Code == g.Code {
+			registry[i] = g
+			return
+		}
+	}
+	registry = append(registry, g)
+}
+
+// Lookup trả về grammar theo mã
+func Lookup(code string) (Grammar, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, g := range registry {
+		if g.Code == code {
+			return g, true
+		}
+	}
+	return Grammar{}, false
+}
+
+// Grammars trả về toàn bộ grammar đã đăng ký theo thứ tự đăng ký
+func Grammars() []Grammar {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Grammar(nil), registry...)
+}
+
+// ValidateCodes trả lỗi nếu có mã grammar chưa được đăng ký
+func ValidateCodes(codes []string) error {
+	for _, code := range codes {
+		if _, ok := Lookup(code); !ok {
+			return fmt.Errorf("định dạng biển số '%s' không được hỗ trợ", code)
+		}
+	}
+	return nil
+}
+
+// ParseAll khớp văn bản với các grammar trong codes (rỗng = tất cả), trả về mọi grammar khớp
+func ParseAll(text string, codes []string) []Match {
+	normalized := normalizeSeparators(text)
+	var matches []Match
+	for _, g := range selectGrammars(codes) {
+		parts := g.Pattern.FindStringSubmatch(normalized)
+		if parts == nil {
+			continue
+		}
+		parts = parts[1:]
+		match := Match{
+			Grammar:   g.Code,
+			Canonical: strings.Join(parts, ""),
+			Display:   g.Display(parts),
+		}
+		if g.ValidRegion != nil && g.ValidRegion(parts) {
+			match.RegionValid = true
+			match.Bonus = RegionBonus
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// Parse trả về grammar khớp đầu tiên (ưu tiên mã tỉnh hợp lệ) trong codes
+func Parse(text string, codes []string) (Match, bool) {
+	matches := ParseAll(text, codes)
+	if len(matches) == 0 {
+		return Match{}, false
+	}
+	for _, m := range matches {
+		if m.RegionValid {
+			return m, true
+		}
+	}
+	return matches[0], true
+}
+
+func selectGrammars(codes []string) []Grammar {
+	if len(codes) == 0 {
+		return Grammars()
+	}
+	var selected []Grammar
+	for _, code := range codes {
+		if g, ok := Lookup(code); ok {
+			selected = append(selected, g)
+		}
+	}
+	return selected
+}
+
+// normalizeSeparators in hoa, gộp các dấu phân cách liên tiếp thành một và bỏ ở hai đầu
+func normalizeSeparators(text string) string {
+	var b strings.Builder
+	pendingSep := false
+	for _, r := range strings.ToUpper(strings.TrimSpace(text)) {
+		switch r {
+		case ' ', '\t', '\n', '-', '.', '·':
+			pendingSep = b.Len() > 0
+			continue
+		}
+		if pendingSep {
+			b.WriteByte(' ')
+			pendingSep = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+// Package plate chuẩn hóa và so khớp biển số xe, bù cho các lỗi nhận dạng ký tự thường gặp của OCR.
+package plate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// confusables - các ký tự OCR hay nhầm lẫn, gộp về cùng một ký tự đại diện
+var confusables = map[rune]rune{
+	'O': '0',
+	'D': '0',
+	'Q': '0',
+	'B': '8',
+	'I': '1',
+	'L': '1',
+	'S': '5',
+	'Z': '2',
+}
+
+// Canonical trả về dạng chuẩn của biển số: chữ in hoa, bỏ dấu gạch, dấu chấm, khoảng trắng.
+// Ví dụ: "51f-123.45" -> "51F12345"
+func Canonical(plate string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(plate) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Fold trả về dạng chuẩn sau khi gộp các ký tự dễ nhầm lẫn, dùng làm khóa so khớp thô.
+// Ví dụ: "51F-I23.4S" và "51F-123.45" cùng cho "51F12345".
+func Fold(plate string) string {
+	canonical := []rune(Canonical(plate))
+	for i, r := range canonical {
+		canonical[i] = foldRune(r)
+	}
+	return string(canonical)
+}
+
+func foldRune(r rune) rune {
+	if f, ok := confusables[r]; ok {
+		return f
+	}
+	return r
+}
+
+// Confusable cho biết hai ký tự (đã in hoa) có thuộc cùng nhóm dễ nhầm lẫn không
+func Confusable(a, b rune) bool {
+	return a != b && foldRune(a) == foldRune(b)
+}
@@ -0,0 +1,76 @@
+package plate
+
+import "testing"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		plate string
+		want  string
+	}{
+		{name: "vn_car_display", plate: "51F-123.45", want: "51F12345"},
+		{name: "lowercase", plate: "51f-123.45", want: "51F12345"},
+		{name: "spaces", plate: " 29 A 123 45 ", want: "29A12345"},
+		{name: "already_canonical", plate: "29A12345", want: "29A12345"},
+		{name: "diplomatic", plate: "80-NG-123-45", want: "80NG12345"},
+		{name: "uk", plate: "ab12 cde", want: "AB12CDE"},
+		{name: "separators_only", plate: "-. ", want: ""},
+		{name: "empty", plate: "", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Canonical(tc.plate); got != tc.want {
+				t.Errorf("Canonical(%q) = %q, want %q", tc.plate, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name  string
+		plate string
+		want  string
+	}{
+		{name: "no_confusables", plate: "51F-123.45", want: "51F12345"},
+		{name: "i_and_s", plate: "51F-I23.4S", want: "51F12345"},
+		{name: "o_d_q_to_zero", plate: "3O-D0Q", want: "30000"},
+		{name: "b_to_eight", plate: "5B8", want: "588"},
+		{name: "l_to_one", plate: "LI1", want: "111"},
+		{name: "z_to_two", plate: "Z2", want: "22"},
+		{name: "lowercase_folded", plate: "5lf-i23.4s", want: "51F12345"},
+		{name: "empty", plate: "", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Fold(tc.plate); got != tc.want {
+				t.Errorf("Fold(%q) = %q, want %q", tc.plate, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfusable(t *testing.T) {
+	tests := []struct {
+		a, b rune
+		want bool
+	}{
+		{a: 'O', b: '0', want: true},
+		{a: 'D', b: 'Q', want: true},
+		{a: 'B', b: '8', want: true},
+		{a: 'I', b: 'L', want: true},
+		{a: 'S', b: '5', want: true},
+		{a: 'Z', b: '2', want: true},
+		{a: '0', b: '0', want: false}, // cùng ký tự không tính là nhầm lẫn
+		{a: 'A', b: '4', want: false},
+		{a: 'B', b: '3', want: false},
+	}
+
+	for _, tc := range tests {
+		if got := Confusable(tc.a, tc.b); got != tc.want {
+			t.Errorf("Confusable(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
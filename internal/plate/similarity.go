@@ -0,0 +1,118 @@
+package plate
+
+// Chi phí chỉnh sửa: thay một ký tự dễ nhầm lẫn rẻ hơn nhiều so với thay ký tự bất kỳ
+const (
+	substitutionCost = 1.0
+	confusableCost   = 0.3
+	insertDeleteCost = 1.0
+)
+
+// Distance tính khoảng cách chỉnh sửa (Levenshtein) có trọng số giữa hai biển số ở dạng chuẩn.
+// Thay thế giữa các ký tự dễ nhầm lẫn (0/O/D, 8/B, 1/I, 5/S...) chỉ tốn confusableCost.
+func Distance(a, b string) float64 {
+	ra, rb := []rune(Canonical(a)), []rune(Canonical(b))
+	prev := make([]float64, len(rb)+1)
+	curr := make([]float64, len(rb)+1)
+	for j := range prev {
+		prev[j] = float64(j) * insertDeleteCost
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = float64(i) * insertDeleteCost
+		for j := 1; j <= len(rb); j++ {
+			cost := 0.0
+			if ra[i-1] != rb[j-1] {
+				cost = substitutionCost
+				if Confusable(ra[i-1], rb[j-1]) {
+					cost = confusableCost
+				}
+			}
+			curr[j] = minFloat(minFloat(prev[j]+insertDeleteCost, curr[j-1]+insertDeleteCost), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Similarity trả về điểm giống nhau trong [0, 1]: 1 = trùng khớp sau khi chuẩn hóa
+func Similarity(a, b string) float64 {
+	ca, cb := []rune(Canonical(a)), []rune(Canonical(b))
+	maxLen := len(ca)
+	if len(cb) > maxLen {
+		maxLen = len(cb)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+	score := 1 - Distance(a, b)/float64(maxLen)
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Edit - Một chỗ sai giữa biển số đọc được và biển số đúng.
+// Expected rỗng: đọc thừa ký tự Read; Read rỗng: đọc thiếu ký tự Expected.
+type Edit struct {
+	Expected string
+	Read     string
+}
+
+// Edits căn chỉnh biển số đọc được (read) với biển số đúng (expected) ở dạng chuẩn bằng Levenshtein
+// chi phí đơn vị và trả về các chỗ thay thế, thừa, thiếu ký tự. Dùng để thống kê cặp ký tự OCR hay nhầm.
+func Edits(expected, read string) []Edit {
+	re, rr := []rune(Canonical(expected)), []rune(Canonical(read))
+	dist := make([][]int, len(re)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(rr)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(re); i++ {
+		for j := 1; j <= len(rr); j++ {
+			cost := 1
+			if re[i-1] == rr[j-1] {
+				cost = 0
+			}
+			dist[i][j] = minInt(minInt(dist[i-1][j]+1, dist[i][j-1]+1), dist[i-1][j-1]+cost)
+		}
+	}
+
+	var edits []Edit
+	i, j := len(re), len(rr)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && re[i-1] == rr[j-1] && dist[i][j] == dist[i-1][j-1]:
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && dist[i][j] == dist[i-1][j-1]+1:
+			edits = append(edits, Edit{Expected: string(re[i-1]), Read: string(rr[j-1])})
+			i, j = i-1, j-1
+		case i > 0 && dist[i][j] == dist[i-1][j]+1:
+			edits = append(edits, Edit{Expected: string(re[i-1])})
+			i--
+		default:
+			edits = append(edits, Edit{Read: string(rr[j-1])})
+			j--
+		}
+	}
+	// Backtrace đi từ cuối chuỗi, đảo lại theo thứ tự ký tự
+	for l, r := 0, len(edits)-1; l < r; l, r = l+1, r-1 {
+		edits[l], edits[r] = edits[r], edits[l]
+	}
+	return edits
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,109 @@
+package plate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+const floatTolerance = 1e-9
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "identical", a: "51F12345", b: "51F12345", want: 0},
+		{name: "format_ignored", a: "51F-123.45", b: "51f12345", want: 0},
+		{name: "one_confusable", a: "51F12345", b: "51F1234S", want: confusableCost},
+		{name: "two_confusables", a: "51F12345", b: "51FI234S", want: 2 * confusableCost},
+		{name: "one_substitution", a: "51F12345", b: "51F12346", want: substitutionCost},
+		{name: "one_deletion", a: "51F12345", b: "51F1234", want: insertDeleteCost},
+		{name: "one_insertion", a: "51F1234", b: "51F12345", want: insertDeleteCost},
+		{name: "empty_vs_plate", a: "", b: "29A", want: 3 * insertDeleteCost},
+		{name: "both_empty", a: "", b: "", want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Distance(tc.a, tc.b); math.Abs(got-tc.want) > floatTolerance {
+				t.Errorf("Distance(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDistanceSymmetric(t *testing.T) {
+	pairs := [][2]string{
+		{"51F12345", "51FI234S"},
+		{"29A12345", "29A1234"},
+		{"80NG12345", "8ONG1234"},
+	}
+	for _, p := range pairs {
+		if ab, ba := Distance(p[0], p[1]), Distance(p[1], p[0]); math.Abs(ab-ba) > floatTolerance {
+			t.Errorf("Distance(%q, %q) = %v nhưng chiều ngược lại = %v", p[0], p[1], ab, ba)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "identical_after_canonical", a: "51F-123.45", b: "51F12345", want: 1},
+		{name: "one_confusable", a: "51F12345", b: "51F1234S", want: 1 - confusableCost/8},
+		{name: "one_substitution", a: "51F12345", b: "51F12346", want: 1 - substitutionCost/8},
+		{name: "one_deletion_uses_longer_length", a: "51F12345", b: "51F1234", want: 1 - insertDeleteCost/8},
+		{name: "completely_different", a: "AAA", b: "999", want: 0},
+		{name: "one_empty", a: "", b: "29A", want: 0},
+		{name: "both_empty", a: "", b: "", want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Similarity(tc.a, tc.b); math.Abs(got-tc.want) > floatTolerance {
+				t.Errorf("Similarity(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSimilarityRanksConfusableAboveSubstitution(t *testing.T) {
+	// Đọc nhầm 5 thành S phải gần biển đúng hơn đọc sai hẳn một chữ số
+	confusable := Similarity("51F12345", "51F1234S")
+	substitution := Similarity("51F12345", "51F12346")
+	if confusable <= substitution {
+		t.Fatalf("Similarity nhầm ký tự (%v) phải lớn hơn thay ký tự (%v)", confusable, substitution)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		read     string
+		want     []Edit
+	}{
+		{name: "identical", expected: "51F12345", read: "51F-123.45", want: nil},
+		{name: "substitution", expected: "51F12345", read: "51F1234S", want: []Edit{{Expected: "5", Read: "S"}}},
+		{name: "missing_char", expected: "51F12345", read: "51F1245", want: []Edit{{Expected: "3"}}},
+		{name: "extra_char", expected: "29A1234", read: "29A12344", want: []Edit{{Read: "4"}}},
+		{
+			name:     "two_substitutions_in_order",
+			expected: "51F12345",
+			read:     "5IF1234S",
+			want:     []Edit{{Expected: "1", Read: "I"}, {Expected: "5", Read: "S"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Edits(tc.expected, tc.read); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Edits(%q, %q) = %+v, want %+v", tc.expected, tc.read, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type pgDeviceCommandRepository struct {
+	db *sql.DB
+}
+
+func NewPgDeviceCommandRepository(db *sql.DB) repository.DeviceCommandRepository {
+	return &pgDeviceCommandRepository{db: db}
+}
+
+const deviceCommandColumns = `request_id, esp32_thing_name, barrier_type, command, topic, payload, status, attempts, max_attempts,
+	last_error, next_retry_at, alert_sent, requested_by, sent_at, acknowledged_at, executed_at, created_at, updated_at`
+
+func scanDeviceCommand(row rowScanner, command *domain.DeviceCommand) error {
+	var payload []byte
+	err := row.Scan(&command.RequestID, &command.Esp32ThingName, &command.BarrierType, &command.Command, &command.Topic,
+		&payload, &command.Status, &command.Attempts, &command.MaxAttempts, &command.LastError, &command.NextRetryAt,
+		&command.AlertSent, &command.RequestedBy, &command.SentAt, &command.AcknowledgedAt, &command.ExecutedAt,
+		&command.CreatedAt, &command.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	command.Payload = payload
+	command.CreatedAt = command.CreatedAt.In(time.UTC)
+	command.UpdatedAt = command.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgDeviceCommandRepository) Create(ctx context.Context, command *domain.DeviceCommand) (*domain.DeviceCommand, error) {
+	query := `INSERT INTO device_commands (request_id, esp32_thing_name, barrier_type, command, topic, payload, status,
+	           attempts, max_attempts, last_error, next_retry_at, requested_by, sent_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	           RETURNING ` + deviceCommandColumns
+	saved := &domain.DeviceCommand{}
+	err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, command.RequestID, command.Esp32ThingName, command.BarrierType,
+		command.Command, command.Topic, []byte(command.Payload), command.Status, command.Attempts, command.MaxAttempts,
+		command.LastError, command.NextRetryAt, command.RequestedBy, command.SentAt), saved)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return nil, fmt.Errorf("%w: lệnh '%s' đã tồn tại", repository.ErrDuplicateEntry, command.RequestID)
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.Create: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgDeviceCommandRepository) FindByRequestID(ctx context.Context, requestID string) (*domain.DeviceCommand, error) {
+	command := &domain.DeviceCommand{}
+	query := `SELECT ` + deviceCommandColumns + ` FROM device_commands WHERE request_id = $1`
+	if err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, requestID), command); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.FindByRequestID: %w", err)
+	}
+	return command, nil
+}
+
+func (r *pgDeviceCommandRepository) ClaimDue(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.DeviceCommand, error) {
+	query := `UPDATE device_commands SET next_retry_at = $2
+	           WHERE request_id IN (
+	               SELECT request_id FROM device_commands
+	               WHERE status IN ('pending', 'sent') AND next_retry_at <= $1
+	               ORDER BY next_retry_at LIMIT $3
+	               FOR UPDATE SKIP LOCKED)
+	           RETURNING ` + deviceCommandColumns
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DeviceCommandRepository.ClaimDue: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []domain.DeviceCommand
+	for rows.Next() {
+		var command domain.DeviceCommand
+		if err := scanDeviceCommand(rows, &command); err != nil {
+			return nil, fmt.Errorf("DeviceCommandRepository.ClaimDue (scanning row): %w", err)
+		}
+		commands = append(commands, command)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("DeviceCommandRepository.ClaimDue (rows error): %w", err)
+	}
+	return commands, nil
+}
+
+func (r *pgDeviceCommandRepository) UpdateDelivery(ctx context.Context, command *domain.DeviceCommand) (*domain.DeviceCommand, error) {
+	query := `UPDATE device_commands
+	           SET status = $2, attempts = $3, last_error = $4, next_retry_at = $5, alert_sent = $6, sent_at = $7
+	           WHERE request_id = $1 AND status IN ('pending', 'sent')
+	           RETURNING ` + deviceCommandColumns
+	saved := &domain.DeviceCommand{}
+	err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, command.RequestID, command.Status, command.Attempts,
+		command.LastError, command.NextRetryAt, command.AlertSent, command.SentAt), saved)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.UpdateDelivery: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgDeviceCommandRepository) MarkAcknowledged(ctx context.Context, requestID string, at time.Time) (*domain.DeviceCommand, error) {
+	query := `UPDATE device_commands
+	           SET status = 'acknowledged', acknowledged_at = $2, next_retry_at = NULL
+	           WHERE request_id = $1 AND status IN ('pending', 'sent', 'timed_out')
+	           RETURNING ` + deviceCommandColumns
+	saved := &domain.DeviceCommand{}
+	if err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, requestID, at), saved); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.MarkAcknowledged: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgDeviceCommandRepository) MarkFailed(ctx context.Context, requestID string, reason string) (*domain.DeviceCommand, error) {
+	query := `UPDATE device_commands
+	           SET status = 'failed', last_error = $2, next_retry_at = NULL, alert_sent = TRUE
+	           WHERE request_id = $1 AND status IN ('pending', 'sent', 'acknowledged', 'timed_out')
+	           RETURNING ` + deviceCommandColumns
+	saved := &domain.DeviceCommand{}
+	if err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, requestID, reason), saved); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.MarkFailed: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgDeviceCommandRepository) MarkExecuted(ctx context.Context, thingName string, barrierType string, command string, at time.Time) (*domain.DeviceCommand, error) {
+	// Thiết bị có thể làm mất ack nhưng vẫn chuyển rào, nên lệnh còn sent cũng được tính là đã thực thi
+	query := `UPDATE device_commands
+	           SET status = 'executed', executed_at = $4, next_retry_at = NULL
+	           WHERE request_id = (
+	               SELECT request_id FROM device_commands
+	               WHERE esp32_thing_name = $1 AND barrier_type = $2 AND command = $3 AND status IN ('sent', 'acknowledged')
+	               ORDER BY created_at DESC LIMIT 1
+	               FOR UPDATE SKIP LOCKED)
+	           RETURNING ` + deviceCommandColumns
+	saved := &domain.DeviceCommand{}
+	if err := scanDeviceCommand(r.db.QueryRowContext(ctx, query, thingName, barrierType, command, at), saved); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("DeviceCommandRepository.MarkExecuted: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgDeviceCommandRepository) ExpireUnexecuted(ctx context.Context, ackedBefore time.Time, note string) (int64, error) {
+	query := `UPDATE device_commands SET status = 'timed_out', last_error = $2
+	           WHERE status = 'acknowledged' AND acknowledged_at < $1`
+	result, err := r.db.ExecContext(ctx, query, ackedBefore, note)
+	if err != nil {
+		return 0, fmt.Errorf("DeviceCommandRepository.ExpireUnexecuted: %w", err)
+	}
+	return result.RowsAffected()
+}
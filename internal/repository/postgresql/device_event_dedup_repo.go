@@ -0,0 +1,71 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgDeviceEventDedupRepository struct {
+	db *sql.DB
+}
+
+func NewPgDeviceEventDedupRepository(db *sql.DB) repository.DeviceEventDedupRepository {
+	return &pgDeviceEventDedupRepository{db: db}
+}
+
+func (r *pgDeviceEventDedupRepository) Reserve(ctx context.Context, key string, deviceID string, messageType string, leaseUntil time.Time) (bool, bool, error) {
+	query := `INSERT INTO device_event_dedup (dedup_key, device_id, message_type, expires_at)
+	           VALUES ($1, $2, $3, $4)
+	           ON CONFLICT (dedup_key) DO UPDATE
+	               SET first_seen_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at, completed_at = NULL
+	               WHERE device_event_dedup.expires_at <= CURRENT_TIMESTAMP`
+	result, err := r.db.ExecContext(ctx, query, key, deviceID, messageType, leaseUntil)
+	if err != nil {
+		return false, false, fmt.Errorf("DeviceEventDedupRepository.Reserve: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, false, fmt.Errorf("DeviceEventDedupRepository.Reserve (checking rows affected): %w", err)
+	}
+	if rowsAffected > 0 {
+		return true, false, nil
+	}
+
+	var completed bool
+	err = r.db.QueryRowContext(ctx, `SELECT completed_at IS NOT NULL FROM device_event_dedup WHERE dedup_key = $1`, key).Scan(&completed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Key vừa bị nhả / xóa: coi như đang được xử lý để lần gửi lại giữ key
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("DeviceEventDedupRepository.Reserve (checking state): %w", err)
+	}
+	return false, completed, nil
+}
+
+func (r *pgDeviceEventDedupRepository) Complete(ctx context.Context, key string, expiresAt time.Time) error {
+	query := `UPDATE device_event_dedup SET completed_at = CURRENT_TIMESTAMP, expires_at = $2 WHERE dedup_key = $1`
+	if _, err := r.db.ExecContext(ctx, query, key, expiresAt); err != nil {
+		return fmt.Errorf("DeviceEventDedupRepository.Complete: %w", err)
+	}
+	return nil
+}
+
+func (r *pgDeviceEventDedupRepository) Release(ctx context.Context, key string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM device_event_dedup WHERE dedup_key = $1`, key); err != nil {
+		return fmt.Errorf("DeviceEventDedupRepository.Release: %w", err)
+	}
+	return nil
+}
+
+func (r *pgDeviceEventDedupRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM device_event_dedup WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("DeviceEventDedupRepository.PurgeExpired: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -18,8 +18,8 @@ func NewPgDeviceEventsLogRepository(db *sql.DB) repository.DeviceEventsLogReposi
 
 func (r *pgDeviceEventsLogRepository) Create(ctx context.Context, event *domain.DeviceEventLog) error {
 	query := `INSERT INTO device_events_log 
-                (received_at, esp32_thing_name, mqtt_topic, message_type, payload, processed_status, processing_notes) 
-               VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+                (received_at, esp32_thing_name, mqtt_topic, message_type, payload, processed_status, processing_notes, dedup_key) 
+               VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
 
 	var payloadToStore []byte
 	if event.Payload != nil {
@@ -35,6 +35,7 @@ func (r *pgDeviceEventsLogRepository) Create(ctx context.Context, event *domain.
 		payloadToStore,
 		sql.NullString{String: event.ProcessedStatus, Valid: event.ProcessedStatus != ""},
 		sql.NullString{String: event.ProcessingNotes, Valid: event.ProcessingNotes != ""},
+		sql.NullString{String: event.DedupKey, Valid: event.DedupKey != ""},
 	).Scan(&id) // Scan ID trả về
 
 	if err != nil {
@@ -44,3 +45,12 @@ func (r *pgDeviceEventsLogRepository) Create(ctx context.Context, event *domain.
 	// log.Printf("Sự kiện từ thiết bị đã được ghi log với ID: %d", event.ID)
 	return nil
 }
+
+func (r *pgDeviceEventsLogRepository) UpdateStatus(ctx context.Context, id int64, status string, notes string) error {
+	query := `UPDATE device_events_log SET processed_status = $1, processing_notes = $2 WHERE id = $3`
+	_, err := r.db.ExecContext(ctx, query, status, sql.NullString{String: notes, Valid: notes != ""}, id)
+	if err != nil {
+		return fmt.Errorf("DeviceEventsLogRepository.UpdateStatus: %w", err)
+	}
+	return nil
+}
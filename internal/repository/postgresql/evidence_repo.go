@@ -0,0 +1,157 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type pgEvidenceRepository struct {
+	db *sql.DB
+}
+
+func NewPgEvidenceRepository(db *sql.DB) repository.EvidenceRepository {
+	return &pgEvidenceRepository{db: db}
+}
+
+const evidenceColumns = `id, gate_event_id, session_id, lot_id, gate_direction, camera_id, backend, storage_key, sha256,
+	content_type, size_bytes, legal_hold, captured_at, purged_at, created_at, updated_at`
+
+func scanEvidence(row rowScanner, evidence *domain.EvidenceImage) error {
+	err := row.Scan(
+		&evidence.ID, &evidence.GateEventID, &evidence.SessionID, &evidence.LotID, &evidence.GateDirection,
+		&evidence.CameraID, &evidence.Backend, &evidence.StorageKey, &evidence.SHA256, &evidence.ContentType,
+		&evidence.SizeBytes, &evidence.LegalHold, &evidence.CapturedAt, &evidence.PurgedAt,
+		&evidence.CreatedAt, &evidence.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if evidence.PurgedAt.Valid {
+		evidence.PurgedAt.Time = evidence.PurgedAt.Time.In(time.UTC)
+	}
+	evidence.CapturedAt = evidence.CapturedAt.In(time.UTC)
+	evidence.CreatedAt = evidence.CreatedAt.In(time.UTC)
+	evidence.UpdatedAt = evidence.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgEvidenceRepository) Create(ctx context.Context, evidence *domain.EvidenceImage) (*domain.EvidenceImage, error) {
+	query := `INSERT INTO evidence_images
+	           (gate_event_id, session_id, lot_id, gate_direction, camera_id, backend, storage_key, sha256,
+	            content_type, size_bytes, captured_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	           RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query,
+		evidence.GateEventID, evidence.SessionID, evidence.LotID, evidence.GateDirection, evidence.CameraID,
+		evidence.Backend, evidence.StorageKey, evidence.SHA256, evidence.ContentType, evidence.SizeBytes,
+		evidence.CapturedAt,
+	).Scan(&evidence.ID, &evidence.CreatedAt, &evidence.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			return nil, fmt.Errorf("%w: ảnh %s đã được lưu cho gate event", repository.ErrDuplicateEntry, evidence.SHA256)
+		}
+		return nil, fmt.Errorf("EvidenceRepository.Create: %w", err)
+	}
+	evidence.CreatedAt = evidence.CreatedAt.In(time.UTC)
+	evidence.UpdatedAt = evidence.UpdatedAt.In(time.UTC)
+	return evidence, nil
+}
+
+func (r *pgEvidenceRepository) FindByID(ctx context.Context, id int) (*domain.EvidenceImage, error) {
+	evidence := &domain.EvidenceImage{}
+	query := `SELECT ` + evidenceColumns + ` FROM evidence_images WHERE id = $1`
+	if err := scanEvidence(r.db.QueryRowContext(ctx, query, id), evidence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("EvidenceRepository.FindByID: %w", err)
+	}
+	return evidence, nil
+}
+
+func (r *pgEvidenceRepository) FindByGateEventAndHash(ctx context.Context, gateEventID string, sha256 string) (*domain.EvidenceImage, error) {
+	evidence := &domain.EvidenceImage{}
+	query := `SELECT ` + evidenceColumns + ` FROM evidence_images WHERE gate_event_id = $1 AND sha256 = $2`
+	if err := scanEvidence(r.db.QueryRowContext(ctx, query, gateEventID, sha256), evidence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("EvidenceRepository.FindByGateEventAndHash: %w", err)
+	}
+	return evidence, nil
+}
+
+func (r *pgEvidenceRepository) FindByGateEvent(ctx context.Context, gateEventID string) ([]domain.EvidenceImage, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence_images WHERE gate_event_id = $1 ORDER BY captured_at, id`
+	return r.query(ctx, "FindByGateEvent", query, gateEventID)
+}
+
+func (r *pgEvidenceRepository) FindBySession(ctx context.Context, sessionID int, gateEventIDs []string) ([]domain.EvidenceImage, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence_images
+	           WHERE session_id = $1 OR gate_event_id = ANY($2)
+	           ORDER BY captured_at, id`
+	return r.query(ctx, "FindBySession", query, sessionID, pq.Array(gateEventIDs))
+}
+
+func (r *pgEvidenceRepository) FindPurgeable(ctx context.Context, before time.Time, limit int) ([]domain.EvidenceImage, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence_images
+	           WHERE captured_at < $1 AND purged_at IS NULL AND NOT legal_hold
+	           ORDER BY captured_at LIMIT $2`
+	return r.query(ctx, "FindPurgeable", query, before, limit)
+}
+
+func (r *pgEvidenceRepository) query(ctx context.Context, method string, query string, args ...interface{}) ([]domain.EvidenceImage, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("EvidenceRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var images []domain.EvidenceImage
+	for rows.Next() {
+		var evidence domain.EvidenceImage
+		if err := scanEvidence(rows, &evidence); err != nil {
+			return nil, fmt.Errorf("EvidenceRepository.%s (scanning row): %w", method, err)
+		}
+		images = append(images, evidence)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("EvidenceRepository.%s (rows error): %w", method, err)
+	}
+	return images, nil
+}
+
+func (r *pgEvidenceRepository) MarkPurged(ctx context.Context, id int, purgedAt time.Time) error {
+	query := `UPDATE evidence_images SET purged_at = $1 WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, purgedAt, id)
+	if err != nil {
+		return fmt.Errorf("EvidenceRepository.MarkPurged: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("EvidenceRepository.MarkPurged (checking rows): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgEvidenceRepository) SetLegalHold(ctx context.Context, id int, hold bool) (*domain.EvidenceImage, error) {
+	evidence := &domain.EvidenceImage{}
+	query := `UPDATE evidence_images SET legal_hold = $1 WHERE id = $2 RETURNING ` + evidenceColumns
+	if err := scanEvidence(r.db.QueryRowContext(ctx, query, hold, id), evidence); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("EvidenceRepository.SetLegalHold: %w", err)
+	}
+	return evidence, nil
+}
@@ -264,3 +264,158 @@ func (r *pgGateEventRepository) CleanupExpiredEvents(ctx context.Context) (int,
 
 	return count, nil
 }
+
+const gateEventColumns = `id, event_id, lot_id, device_id, gate_direction, event_type, status,
+	sensor_id, detected_plate, lpr_confidence, is_manual_entry, session_id,
+	processing_notes, assigned_operator, created_at, updated_at, expires_at, completed_at`
+
+// scanGateEvent scan một dòng theo thứ tự gateEventColumns
+func scanGateEvent(row rowScanner, event *domain.GateEventRecord) error {
+	var sensorID, detectedPlate, processingNotes, assignedOperator sql.NullString
+	var lprConfidence sql.NullFloat64
+	var isManualEntry sql.NullBool
+	var sessionID sql.NullInt64
+	var expiresAt, completedAt sql.NullTime
+
+	err := row.Scan(
+		&event.ID, &event.EventID, &event.LotID, &event.DeviceID, &event.GateDirection,
+		&event.EventType, &event.Status, &sensorID, &detectedPlate, &lprConfidence,
+		&isManualEntry, &sessionID, &processingNotes, &assignedOperator,
+		&event.CreatedAt, &event.UpdatedAt, &expiresAt, &completedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	event.SensorID = sensorID.String
+	event.DetectedPlate = detectedPlate.String
+	event.ProcessingNotes = processingNotes.String
+	event.AssignedOperator = assignedOperator.String
+	event.IsManualEntry = isManualEntry.Bool
+	if lprConfidence.Valid {
+		conf := float32(lprConfidence.Float64)
+		event.LPRConfidence = &conf
+	}
+	if sessionID.Valid {
+		sid := int(sessionID.Int64)
+		event.SessionID = &sid
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time.In(time.UTC)
+		event.ExpiresAt = &t
+	}
+	if completedAt.Valid {
+		t := completedAt.Time.In(time.UTC)
+		event.CompletedAt = &t
+	}
+	event.CreatedAt = event.CreatedAt.In(time.UTC)
+	event.UpdatedAt = event.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgGateEventRepository) UpdateSessionStatus(ctx context.Context, eventID string, sessionID int, status domain.GateEventStatus, notes string) error {
+	query := `UPDATE gate_events
+		SET session_id = $1, status = $2, processing_notes = COALESCE(processing_notes, '') || $3,
+		    completed_at = CASE WHEN $2 IN ('exit_allowed', 'session_created') THEN CURRENT_TIMESTAMP ELSE completed_at END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE event_id = $4`
+
+	notesToAppend := ""
+	if notes != "" {
+		notesToAppend = fmt.Sprintf("; %s", notes)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, sessionID, status, notesToAppend, eventID)
+	if err != nil {
+		return fmt.Errorf("GateEventRepository.UpdateSessionStatus: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("GateEventRepository.UpdateSessionStatus (checking rows): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgGateEventRepository) FindLatestBySessionAndStatus(ctx context.Context, sessionID int, status domain.GateEventStatus) (*domain.GateEventRecord, error) {
+	event := &domain.GateEventRecord{}
+	query := `SELECT ` + gateEventColumns + ` FROM gate_events
+		WHERE session_id = $1 AND status = $2
+		ORDER BY created_at DESC LIMIT 1`
+
+	if err := scanGateEvent(r.db.QueryRowContext(ctx, query, sessionID, status), event); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("GateEventRepository.FindLatestBySessionAndStatus: %w", err)
+	}
+	return event, nil
+}
+
+func (r *pgGateEventRepository) FindByDeviceAndStatus(ctx context.Context, deviceID string, direction domain.GateDirection, status domain.GateEventStatus) ([]domain.GateEventRecord, error) {
+	query := `SELECT ` + gateEventColumns + ` FROM gate_events
+		WHERE device_id = $1 AND gate_direction = $2 AND status = $3
+		ORDER BY created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, deviceID, direction, status)
+	if err != nil {
+		return nil, fmt.Errorf("GateEventRepository.FindByDeviceAndStatus: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.GateEventRecord
+	for rows.Next() {
+		var event domain.GateEventRecord
+		if err := scanGateEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("GateEventRepository.FindByDeviceAndStatus (scanning row): %w", err)
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("GateEventRepository.FindByDeviceAndStatus (rows error): %w", err)
+	}
+	return events, nil
+}
+
+func (r *pgGateEventRepository) UpdateVehicleAttributes(ctx context.Context, eventID string, attrs domain.VehicleAttributes) error {
+	query := `UPDATE gate_events
+		SET vehicle_class = $1, vehicle_class_confidence = $2, vehicle_color = $3, vehicle_make = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE event_id = $5`
+
+	confidence := sql.NullFloat64{Float64: float64(attrs.ClassConfidence), Valid: attrs.VehicleClass != ""}
+	result, err := r.db.ExecContext(ctx, query,
+		sql.NullString{String: attrs.VehicleClass, Valid: attrs.VehicleClass != ""}, confidence,
+		sql.NullString{String: attrs.Color, Valid: attrs.Color != ""},
+		sql.NullString{String: attrs.Make, Valid: attrs.Make != ""}, eventID)
+	if err != nil {
+		return fmt.Errorf("GateEventRepository.UpdateVehicleAttributes: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("GateEventRepository.UpdateVehicleAttributes (checking rows): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgGateEventRepository) FindVehicleAttributes(ctx context.Context, eventID string) (*domain.VehicleAttributes, error) {
+	query := `SELECT vehicle_class, vehicle_class_confidence, vehicle_color, vehicle_make FROM gate_events WHERE event_id = $1`
+	var vehicleClass, vehicleColor, vehicleMake sql.NullString
+	var confidence sql.NullFloat64
+	if err := r.db.QueryRowContext(ctx, query, eventID).Scan(&vehicleClass, &confidence, &vehicleColor, &vehicleMake); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("GateEventRepository.FindVehicleAttributes: %w", err)
+	}
+	return &domain.VehicleAttributes{
+		VehicleClass:    vehicleClass.String,
+		ClassConfidence: float32(confidence.Float64),
+		Color:           vehicleColor.String,
+		Make:            vehicleMake.String,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgHolidayRepository struct {
+	db *sql.DB
+}
+
+func NewPgHolidayRepository(db *sql.DB) repository.HolidayRepository {
+	return &pgHolidayRepository{db: db}
+}
+
+const holidayColumns = `id, lot_id, to_char(holiday_date, 'YYYY-MM-DD'), name, created_at`
+
+func scanHoliday(row rowScanner, holiday *domain.Holiday) error {
+	if err := row.Scan(&holiday.ID, &holiday.LotID, &holiday.Date, &holiday.Name, &holiday.CreatedAt); err != nil {
+		return err
+	}
+	holiday.CreatedAt = holiday.CreatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgHolidayRepository) Create(ctx context.Context, holiday *domain.Holiday) (*domain.Holiday, error) {
+	var lotIDVal sql.NullInt64
+	if holiday.LotID.Valid {
+		lotIDVal = sql.NullInt64{Int64: holiday.LotID.Int64, Valid: true}
+	}
+
+	query := `INSERT INTO holidays (lot_id, holiday_date, name, created_at)
+	           VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query, lotIDVal, holiday.Date, holiday.Name).Scan(&holiday.ID, &holiday.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code.Name() == "unique_violation" {
+				return nil, fmt.Errorf("%w: ngày lễ %s đã tồn tại", repository.ErrDuplicateEntry, holiday.Date)
+			}
+		}
+		return nil, fmt.Errorf("HolidayRepository.Create: %w", err)
+	}
+	holiday.CreatedAt = holiday.CreatedAt.In(time.UTC)
+	return holiday, nil
+}
+
+func (r *pgHolidayRepository) FindByID(ctx context.Context, id int) (*domain.Holiday, error) {
+	holiday := &domain.Holiday{}
+	query := `SELECT ` + holidayColumns + ` FROM holidays WHERE id = $1`
+	if err := scanHoliday(r.db.QueryRowContext(ctx, query, id), holiday); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("HolidayRepository.FindByID: %w", err)
+	}
+	return holiday, nil
+}
+
+func (r *pgHolidayRepository) FindByLotID(ctx context.Context, lotID *int) ([]domain.Holiday, error) {
+	query := `SELECT ` + holidayColumns + ` FROM holidays`
+	var args []interface{}
+	if lotID != nil {
+		query += ` WHERE lot_id = $1 OR lot_id IS NULL`
+		args = append(args, *lotID)
+	}
+	query += ` ORDER BY holiday_date ASC`
+	return r.queryHolidays(ctx, "FindByLotID", query, args...)
+}
+
+func (r *pgHolidayRepository) FindInRange(ctx context.Context, lotID int, from, to time.Time) ([]domain.Holiday, error) {
+	query := `SELECT ` + holidayColumns + ` FROM holidays
+	           WHERE (lot_id = $1 OR lot_id IS NULL) AND holiday_date BETWEEN $2::date AND $3::date
+	           ORDER BY holiday_date ASC`
+	return r.queryHolidays(ctx, "FindInRange", query, lotID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+}
+
+func (r *pgHolidayRepository) queryHolidays(ctx context.Context, method string, query string, args ...interface{}) ([]domain.Holiday, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("HolidayRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var holidays []domain.Holiday
+	for rows.Next() {
+		var holiday domain.Holiday
+		if err := scanHoliday(rows, &holiday); err != nil {
+			return nil, fmt.Errorf("HolidayRepository.%s (scanning row): %w", method, err)
+		}
+		holidays = append(holidays, holiday)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("HolidayRepository.%s (rows error): %w", method, err)
+	}
+	return holidays, nil
+}
+
+func (r *pgHolidayRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM holidays WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("HolidayRepository.Delete: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("HolidayRepository.Delete (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgLPRAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewPgLPRAttemptRepository(db *sql.DB) repository.LPRAttemptRepository {
+	return &pgLPRAttemptRepository{db: db}
+}
+
+const lprAttemptColumns = `id, event_id, provider, camera_id, evidence_id, detected_plate, display_plate, grammar, confidence,
+	candidates, error_message, created_at`
+
+func scanLPRAttempt(row rowScanner, attempt *domain.LPRAttempt) error {
+	var candidates []byte
+	err := row.Scan(
+		&attempt.ID, &attempt.EventID, &attempt.Provider, &attempt.CameraID, &attempt.EvidenceID, &attempt.DetectedPlate,
+		&attempt.DisplayPlate, &attempt.Grammar, &attempt.Confidence, &candidates, &attempt.ErrorMessage, &attempt.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if len(candidates) > 0 {
+		if err := json.Unmarshal(candidates, &attempt.Candidates); err != nil {
+			return fmt.Errorf("unmarshal candidates: %w", err)
+		}
+	}
+	attempt.CreatedAt = attempt.CreatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgLPRAttemptRepository) Create(ctx context.Context, attempt *domain.LPRAttempt) (*domain.LPRAttempt, error) {
+	candidates := attempt.Candidates
+	if candidates == nil {
+		candidates = []domain.LPRCandidate{}
+	}
+	candidatesVal, err := json.Marshal(candidates)
+	if err != nil {
+		return nil, fmt.Errorf("LPRAttemptRepository.Create (marshal candidates): %w", err)
+	}
+
+	query := `INSERT INTO lpr_attempts
+	           (event_id, provider, camera_id, evidence_id, detected_plate, display_plate, grammar, confidence,
+	            candidates, error_message)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	           RETURNING id, created_at`
+	err = r.db.QueryRowContext(ctx, query,
+		attempt.EventID, attempt.Provider, attempt.CameraID, attempt.EvidenceID, attempt.DetectedPlate, attempt.DisplayPlate,
+		attempt.Grammar, attempt.Confidence, candidatesVal, attempt.ErrorMessage,
+	).Scan(&attempt.ID, &attempt.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("LPRAttemptRepository.Create: %w", err)
+	}
+	attempt.CreatedAt = attempt.CreatedAt.In(time.UTC)
+	return attempt, nil
+}
+
+func (r *pgLPRAttemptRepository) FindByEventID(ctx context.Context, eventID string) ([]domain.LPRAttempt, error) {
+	query := `SELECT ` + lprAttemptColumns + ` FROM lpr_attempts WHERE event_id = $1 ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("LPRAttemptRepository.FindByEventID: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []domain.LPRAttempt
+	for rows.Next() {
+		var attempt domain.LPRAttempt
+		if err := scanLPRAttempt(rows, &attempt); err != nil {
+			return nil, fmt.Errorf("LPRAttemptRepository.FindByEventID (scanning row): %w", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("LPRAttemptRepository.FindByEventID (rows error): %w", err)
+	}
+	return attempts, nil
+}
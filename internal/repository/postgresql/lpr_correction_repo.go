@@ -0,0 +1,115 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+)
+
+type pgLPRCorrectionRepository struct {
+	db *sql.DB
+}
+
+func NewPgLPRCorrectionRepository(db *sql.DB) repository.LPRCorrectionRepository {
+	return &pgLPRCorrectionRepository{db: db}
+}
+
+const lprCorrectionColumns = `id, event_id, lot_id, camera_id, evidence_id, machine_plate, machine_confidence,
+	corrected_plate, is_correct, source, operator, created_at`
+
+func scanLPRCorrection(row rowScanner, correction *domain.LPRCorrection) error {
+	err := row.Scan(
+		&correction.ID, &correction.EventID, &correction.LotID, &correction.CameraID, &correction.EvidenceID,
+		&correction.MachinePlate, &correction.MachineConfidence, &correction.CorrectedPlate, &correction.IsCorrect,
+		&correction.Source, &correction.Operator, &correction.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	correction.CreatedAt = correction.CreatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgLPRCorrectionRepository) Create(ctx context.Context, correction *domain.LPRCorrection) (*domain.LPRCorrection, error) {
+	query := `INSERT INTO lpr_corrections
+	           (event_id, lot_id, camera_id, evidence_id, machine_plate, machine_confidence, corrected_plate,
+	            is_correct, source, operator)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	           RETURNING id, created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		correction.EventID, correction.LotID, correction.CameraID, correction.EvidenceID, correction.MachinePlate,
+		correction.MachineConfidence, correction.CorrectedPlate, correction.IsCorrect, correction.Source, correction.Operator,
+	).Scan(&correction.ID, &correction.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("LPRCorrectionRepository.Create: %w", err)
+	}
+	correction.CreatedAt = correction.CreatedAt.In(time.UTC)
+	return correction, nil
+}
+
+func (r *pgLPRCorrectionRepository) Find(ctx context.Context, filter domain.LPRCorrectionFilterDTO) ([]domain.LPRCorrection, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.LotID != nil {
+		args = append(args, *filter.LotID)
+		conditions = append(conditions, fmt.Sprintf("lot_id = $%d", len(args)))
+	}
+	if filter.CameraID != nil && *filter.CameraID != "" {
+		args = append(args, *filter.CameraID)
+		conditions = append(conditions, fmt.Sprintf("camera_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := `SELECT ` + lprCorrectionColumns + ` FROM lpr_corrections`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("LPRCorrectionRepository.Find: %w", err)
+	}
+	defer rows.Close()
+
+	var corrections []domain.LPRCorrection
+	for rows.Next() {
+		var correction domain.LPRCorrection
+		if err := scanLPRCorrection(rows, &correction); err != nil {
+			return nil, fmt.Errorf("LPRCorrectionRepository.Find (scanning row): %w", err)
+		}
+		corrections = append(corrections, correction)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("LPRCorrectionRepository.Find (rows error): %w", err)
+	}
+	return corrections, nil
+}
+
+func (r *pgLPRCorrectionRepository) Stats(ctx context.Context, lotID int, cameraID string, since time.Time) (domain.LPRCorrectionStats, error) {
+	var stats domain.LPRCorrectionStats
+	query := `SELECT
+	            (SELECT COUNT(DISTINCT a.event_id)
+	               FROM lpr_attempts a JOIN gate_events ge ON ge.event_id = a.event_id
+	              WHERE ge.lot_id = $1 AND ($2 = '' OR a.camera_id = $2)
+	                AND a.detected_plate IS NOT NULL AND a.created_at >= $3),
+	            (SELECT COUNT(*)
+	               FROM lpr_corrections
+	              WHERE lot_id = $1 AND ($2 = '' OR camera_id = $2)
+	                AND machine_plate IS NOT NULL AND NOT is_correct AND created_at >= $3)`
+	if err := r.db.QueryRowContext(ctx, query, lotID, cameraID, since).Scan(&stats.Reads, &stats.Corrected); err != nil {
+		return stats, fmt.Errorf("LPRCorrectionRepository.Stats: %w", err)
+	}
+	return stats, nil
+}
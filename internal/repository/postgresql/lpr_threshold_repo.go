@@ -0,0 +1,216 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type pgLPRThresholdRepository struct {
+	db *sql.DB
+}
+
+func NewPgLPRThresholdRepository(db *sql.DB) repository.LPRThresholdRepository {
+	return &pgLPRThresholdRepository{db: db}
+}
+
+const lprThresholdColumns = `id, lot_id, camera_id, threshold, auto_tune, created_at, updated_at`
+
+func scanLPRThreshold(row rowScanner, threshold *domain.LPRThreshold) error {
+	err := row.Scan(&threshold.ID, &threshold.LotID, &threshold.CameraID, &threshold.Threshold, &threshold.AutoTune,
+		&threshold.CreatedAt, &threshold.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	threshold.CreatedAt = threshold.CreatedAt.In(time.UTC)
+	threshold.UpdatedAt = threshold.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgLPRThresholdRepository) Find(ctx context.Context, lotID *int) ([]domain.LPRThreshold, error) {
+	query := `SELECT ` + lprThresholdColumns + ` FROM lpr_thresholds`
+	var args []interface{}
+	if lotID != nil {
+		query += ` WHERE lot_id = $1`
+		args = append(args, *lotID)
+	}
+	query += ` ORDER BY lot_id, camera_id`
+	return r.query(ctx, "Find", query, args...)
+}
+
+func (r *pgLPRThresholdRepository) FindAutoTune(ctx context.Context) ([]domain.LPRThreshold, error) {
+	query := `SELECT ` + lprThresholdColumns + ` FROM lpr_thresholds WHERE auto_tune ORDER BY lot_id, camera_id`
+	return r.query(ctx, "FindAutoTune", query)
+}
+
+func (r *pgLPRThresholdRepository) query(ctx context.Context, method string, query string, args ...interface{}) ([]domain.LPRThreshold, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var thresholds []domain.LPRThreshold
+	for rows.Next() {
+		var threshold domain.LPRThreshold
+		if err := scanLPRThreshold(rows, &threshold); err != nil {
+			return nil, fmt.Errorf("LPRThresholdRepository.%s (scanning row): %w", method, err)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.%s (rows error): %w", method, err)
+	}
+	return thresholds, nil
+}
+
+func (r *pgLPRThresholdRepository) FindByID(ctx context.Context, id int) (*domain.LPRThreshold, error) {
+	threshold := &domain.LPRThreshold{}
+	query := `SELECT ` + lprThresholdColumns + ` FROM lpr_thresholds WHERE id = $1`
+	if err := scanLPRThreshold(r.db.QueryRowContext(ctx, query, id), threshold); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("LPRThresholdRepository.FindByID: %w", err)
+	}
+	return threshold, nil
+}
+
+func (r *pgLPRThresholdRepository) FindForCamera(ctx context.Context, lotID int, cameraID string) (*domain.LPRThreshold, error) {
+	threshold := &domain.LPRThreshold{}
+	// camera_id rỗng xếp trước mọi camera_id khác nên DESC lấy ngưỡng riêng của camera trước
+	query := `SELECT ` + lprThresholdColumns + ` FROM lpr_thresholds
+	           WHERE lot_id = $1 AND camera_id IN ($2, '')
+	           ORDER BY camera_id DESC LIMIT 1`
+	if err := scanLPRThreshold(r.db.QueryRowContext(ctx, query, lotID, cameraID), threshold); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("LPRThresholdRepository.FindForCamera: %w", err)
+	}
+	return threshold, nil
+}
+
+func (r *pgLPRThresholdRepository) Upsert(ctx context.Context, threshold *domain.LPRThreshold, change domain.LPRThresholdChange) (*domain.LPRThreshold, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.Upsert (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	var old null.Float
+	err = tx.QueryRowContext(ctx, `SELECT threshold FROM lpr_thresholds WHERE lot_id = $1 AND camera_id = $2 FOR UPDATE`,
+		threshold.LotID, threshold.CameraID).Scan(&old)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("LPRThresholdRepository.Upsert (current threshold): %w", err)
+	}
+
+	query := `INSERT INTO lpr_thresholds (lot_id, camera_id, threshold, auto_tune)
+	           VALUES ($1, $2, $3, $4)
+	           ON CONFLICT (lot_id, camera_id) DO UPDATE SET threshold = EXCLUDED.threshold, auto_tune = EXCLUDED.auto_tune
+	           RETURNING ` + lprThresholdColumns
+	saved := &domain.LPRThreshold{}
+	err = scanLPRThreshold(tx.QueryRowContext(ctx, query, threshold.LotID, threshold.CameraID, threshold.Threshold, threshold.AutoTune), saved)
+	if err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.Upsert: %w", err)
+	}
+
+	if !old.Valid || old.Float64 != saved.Threshold {
+		change.LotID, change.CameraID = saved.LotID, saved.CameraID
+		change.OldThreshold, change.NewThreshold = old, null.FloatFrom(saved.Threshold)
+		if err := insertLPRThresholdChange(ctx, tx, change); err != nil {
+			return nil, fmt.Errorf("LPRThresholdRepository.Upsert (change): %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.Upsert (commit): %w", err)
+	}
+	return saved, nil
+}
+
+func (r *pgLPRThresholdRepository) Delete(ctx context.Context, id int, change domain.LPRThresholdChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("LPRThresholdRepository.Delete (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	var old null.Float
+	err = tx.QueryRowContext(ctx, `DELETE FROM lpr_thresholds WHERE id = $1 RETURNING lot_id, camera_id, threshold`, id).
+		Scan(&change.LotID, &change.CameraID, &old)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("LPRThresholdRepository.Delete: %w", err)
+	}
+	change.OldThreshold, change.NewThreshold = old, null.Float{}
+	if err := insertLPRThresholdChange(ctx, tx, change); err != nil {
+		return fmt.Errorf("LPRThresholdRepository.Delete (change): %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("LPRThresholdRepository.Delete (commit): %w", err)
+	}
+	return nil
+}
+
+func insertLPRThresholdChange(ctx context.Context, tx *sql.Tx, change domain.LPRThresholdChange) error {
+	query := `INSERT INTO lpr_threshold_changes
+	           (lot_id, camera_id, old_threshold, new_threshold, reason, changed_by, correction_rate, sample_size)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.ExecContext(ctx, query, change.LotID, change.CameraID, change.OldThreshold, change.NewThreshold,
+		change.Reason, change.ChangedBy, change.CorrectionRate, change.SampleSize)
+	return err
+}
+
+func (r *pgLPRThresholdRepository) FindChanges(ctx context.Context, lotID *int, cameraID *string, limit int) ([]domain.LPRThresholdChange, error) {
+	var conditions []string
+	var args []interface{}
+	if lotID != nil {
+		args = append(args, *lotID)
+		conditions = append(conditions, fmt.Sprintf("lot_id = $%d", len(args)))
+	}
+	if cameraID != nil {
+		args = append(args, *cameraID)
+		conditions = append(conditions, fmt.Sprintf("camera_id = $%d", len(args)))
+	}
+
+	query := `SELECT id, lot_id, camera_id, old_threshold, new_threshold, reason, changed_by, correction_rate, sample_size, created_at
+	           FROM lpr_threshold_changes`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.FindChanges: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []domain.LPRThresholdChange
+	for rows.Next() {
+		var change domain.LPRThresholdChange
+		err := rows.Scan(&change.ID, &change.LotID, &change.CameraID, &change.OldThreshold, &change.NewThreshold,
+			&change.Reason, &change.ChangedBy, &change.CorrectionRate, &change.SampleSize, &change.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("LPRThresholdRepository.FindChanges (scanning row): %w", err)
+		}
+		change.CreatedAt = change.CreatedAt.In(time.UTC)
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("LPRThresholdRepository.FindChanges (rows error): %w", err)
+	}
+	return changes, nil
+}
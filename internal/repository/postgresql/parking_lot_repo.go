@@ -20,9 +20,35 @@ func NewPgParkingLotRepository(db *sql.DB) repository.ParkingLotRepository {
 	return &pgParkingLotRepository{db: db}
 }
 
+const lotColumns = `id, name, address, total_slots, require_payment_before_exit, exit_grace_minutes, auto_open_entry_barrier,
+	                        accepted_plate_grammars, created_at, updated_at`
+
+func scanParkingLot(row rowScanner, lot *domain.ParkingLot) error {
+	if err := row.Scan(&lot.ID, &lot.Name, &lot.Address, &lot.TotalSlots,
+		&lot.RequirePaymentBeforeExit, &lot.ExitGraceMinutes, &lot.AutoOpenEntryBarrier, pq.Array(&lot.AcceptedPlateGrammars),
+		&lot.CreatedAt, &lot.UpdatedAt); err != nil {
+		return err
+	}
+	lot.CreatedAt = lot.CreatedAt.In(time.UTC)
+	lot.UpdatedAt = lot.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+// plateGrammarsOrEmpty tránh ghi NULL vào cột NOT NULL khi bãi không cấu hình grammar
+func plateGrammarsOrEmpty(grammars []string) []string {
+	if grammars == nil {
+		return []string{}
+	}
+	return grammars
+}
+
 func (r *pgParkingLotRepository) Create(ctx context.Context, lot *domain.ParkingLot) (*domain.ParkingLot, error) {
-	query := `INSERT INTO parking_lots (name, address, total_slots) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
-	err := r.db.QueryRowContext(ctx, query, lot.Name, lot.Address, lot.TotalSlots).Scan(&lot.ID, &lot.CreatedAt, &lot.UpdatedAt)
+	query := `INSERT INTO parking_lots (name, address, total_slots, require_payment_before_exit, exit_grace_minutes,
+	           auto_open_entry_barrier, accepted_plate_grammars)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, lot.Name, lot.Address, lot.TotalSlots,
+		lot.RequirePaymentBeforeExit, lot.ExitGraceMinutes, lot.AutoOpenEntryBarrier,
+		pq.Array(plateGrammarsOrEmpty(lot.AcceptedPlateGrammars))).Scan(&lot.ID, &lot.CreatedAt, &lot.UpdatedAt)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			if pqErr.Code.Name() == "unique_violation" {
@@ -38,21 +64,19 @@ func (r *pgParkingLotRepository) Create(ctx context.Context, lot *domain.Parking
 
 func (r *pgParkingLotRepository) FindByID(ctx context.Context, id int) (*domain.ParkingLot, error) {
 	lot := &domain.ParkingLot{}
-	query := `SELECT id, name, address, total_slots, created_at, updated_at FROM parking_lots WHERE id = $1`
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&lot.ID, &lot.Name, &lot.Address, &lot.TotalSlots, &lot.CreatedAt, &lot.UpdatedAt)
+	query := `SELECT ` + lotColumns + ` FROM parking_lots WHERE id = $1`
+	err := scanParkingLot(r.db.QueryRowContext(ctx, query, id), lot)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound
 		}
 		return nil, fmt.Errorf("ParkingLotRepository.FindByID: %w", err)
 	}
-	lot.CreatedAt = lot.CreatedAt.In(time.UTC)
-	lot.UpdatedAt = lot.UpdatedAt.In(time.UTC)
 	return lot, nil
 }
 
 func (r *pgParkingLotRepository) FindAll(ctx context.Context) ([]domain.ParkingLot, error) {
-	query := `SELECT id, name, address, total_slots, created_at, updated_at FROM parking_lots ORDER BY name`
+	query := `SELECT ` + lotColumns + ` FROM parking_lots ORDER BY name`
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("ParkingLotRepository.FindAll: %w", err)
@@ -62,11 +86,9 @@ func (r *pgParkingLotRepository) FindAll(ctx context.Context) ([]domain.ParkingL
 	var lots []domain.ParkingLot
 	for rows.Next() {
 		var lot domain.ParkingLot
-		if err := rows.Scan(&lot.ID, &lot.Name, &lot.Address, &lot.TotalSlots, &lot.CreatedAt, &lot.UpdatedAt); err != nil {
+		if err := scanParkingLot(rows, &lot); err != nil {
 			return nil, fmt.Errorf("ParkingLotRepository.FindAll (scanning row): %w", err)
 		}
-		lot.CreatedAt = lot.CreatedAt.In(time.UTC)
-		lot.UpdatedAt = lot.UpdatedAt.In(time.UTC)
 		lots = append(lots, lot)
 	}
 	if err = rows.Err(); err != nil {
@@ -76,8 +98,13 @@ func (r *pgParkingLotRepository) FindAll(ctx context.Context) ([]domain.ParkingL
 }
 
 func (r *pgParkingLotRepository) Update(ctx context.Context, lot *domain.ParkingLot) (*domain.ParkingLot, error) {
-	query := `UPDATE parking_lots SET name = $1, address = $2, total_slots = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4 RETURNING updated_at`
-	err := r.db.QueryRowContext(ctx, query, lot.Name, lot.Address, lot.TotalSlots, lot.ID).Scan(&lot.UpdatedAt)
+	query := `UPDATE parking_lots SET name = $1, address = $2, total_slots = $3, require_payment_before_exit = $4,
+	           exit_grace_minutes = $5, auto_open_entry_barrier = $6,
+	           accepted_plate_grammars = $7, updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $8 RETURNING updated_at`
+	err := r.db.QueryRowContext(ctx, query, lot.Name, lot.Address, lot.TotalSlots,
+		lot.RequirePaymentBeforeExit, lot.ExitGraceMinutes, lot.AutoOpenEntryBarrier,
+		pq.Array(plateGrammarsOrEmpty(lot.AcceptedPlateGrammars)), lot.ID).Scan(&lot.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) { // Nên là errors.Is
 			return nil, repository.ErrNotFound
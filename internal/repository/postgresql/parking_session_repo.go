@@ -3,6 +3,7 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"smart_parking/internal/domain"
@@ -19,10 +20,48 @@ func NewPgParkingSessionRepository(db *sql.DB) repository.ParkingSessionReposito
 	return &pgParkingSessionRepository{db: db}
 }
 
+const sessionColumns = `id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time,
+	                 duration_minutes, calculated_fee, fee_breakdown, payment_status, status,
+	                 entry_gate_event_id, exit_gate_event_id, exit_deadline, subscription_id,
+	                 vehicle_class, vehicle_color, vehicle_make, vehicle_source, vehicle_corrected_by, created_at, updated_at`
+
+// scanParkingSession scan một dòng theo thứ tự sessionColumns và chuẩn hóa thời gian về UTC
+func scanParkingSession(row rowScanner, session *domain.ParkingSession) error {
+	var feeBreakdown []byte
+	err := row.Scan(
+		&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
+		&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee, &feeBreakdown,
+		&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
+		&session.ExitDeadline, &session.SubscriptionID,
+		&session.VehicleClass, &session.VehicleColor, &session.VehicleMake, &session.VehicleSource, &session.VehicleCorrectedBy,
+		&session.CreatedAt, &session.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if len(feeBreakdown) > 0 {
+		session.FeeBreakdown = &domain.FeeBreakdown{}
+		if err := json.Unmarshal(feeBreakdown, session.FeeBreakdown); err != nil {
+			return fmt.Errorf("lỗi parse fee_breakdown: %w", err)
+		}
+	}
+	session.EntryTime = session.EntryTime.In(time.UTC)
+	if session.ExitTime.Valid {
+		session.ExitTime.Time = session.ExitTime.Time.In(time.UTC)
+	}
+	if session.ExitDeadline.Valid {
+		session.ExitDeadline.Time = session.ExitDeadline.Time.In(time.UTC)
+	}
+	session.CreatedAt = session.CreatedAt.In(time.UTC)
+	session.UpdatedAt = session.UpdatedAt.In(time.UTC)
+	return nil
+}
+
 func (r *pgParkingSessionRepository) Create(ctx context.Context, session *domain.ParkingSession) (*domain.ParkingSession, error) {
 	query := `INSERT INTO parking_sessions 
-	           (lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, payment_status, status, entry_gate_event_id, created_at, updated_at) 
-	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) 
+	           (lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, payment_status, status, entry_gate_event_id, subscription_id,
+	            vehicle_class, vehicle_color, vehicle_make, vehicle_source, vehicle_corrected_by, created_at, updated_at) 
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) 
 	           RETURNING id, created_at, updated_at`
 
 	var slotIDVal sql.NullInt64
@@ -37,10 +76,15 @@ func (r *pgParkingSessionRepository) Create(ctx context.Context, session *domain
 	if session.EntryGateEventID.Valid {
 		entryGateEventIDVal = sql.NullString{String: session.EntryGateEventID.String, Valid: true}
 	}
+	var subscriptionIDVal sql.NullInt64
+	if session.SubscriptionID.Valid {
+		subscriptionIDVal = sql.NullInt64{Int64: session.SubscriptionID.Int64, Valid: true}
+	}
 
 	err := r.db.QueryRowContext(ctx, query,
 		session.LotID, slotIDVal, session.Esp32ThingName, vehicleIDVal, session.EntryTime,
-		session.PaymentStatus, session.Status, entryGateEventIDVal,
+		session.PaymentStatus, session.Status, entryGateEventIDVal, subscriptionIDVal,
+		session.VehicleClass, session.VehicleColor, session.VehicleMake, session.VehicleSource, session.VehicleCorrectedBy,
 	).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
 
 	if err != nil {
@@ -53,111 +97,69 @@ func (r *pgParkingSessionRepository) Create(ctx context.Context, session *domain
 
 func (r *pgParkingSessionRepository) FindByID(ctx context.Context, id int) (*domain.ParkingSession, error) {
 	session := &domain.ParkingSession{}
-	query := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-	                 duration_minutes, calculated_fee, payment_status, status, 
-	                 entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	query := `SELECT ` + sessionColumns + `
 	           FROM parking_sessions WHERE id = $1`
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-		&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-		&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+	err := scanParkingSession(r.db.QueryRowContext(ctx, query, id), session)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound
 		}
 		return nil, fmt.Errorf("ParkingSessionRepository.FindByID: %w", err)
 	}
-	session.EntryTime = session.EntryTime.In(time.UTC)
-	if session.ExitTime.Valid {
-		session.ExitTime.Time = session.ExitTime.Time.In(time.UTC)
-	}
-	session.CreatedAt = session.CreatedAt.In(time.UTC)
-	session.UpdatedAt = session.UpdatedAt.In(time.UTC)
 	return session, nil
 }
 
 func (r *pgParkingSessionRepository) FindActiveBySlotID(ctx context.Context, slotID int) (*domain.ParkingSession, error) {
 	session := &domain.ParkingSession{}
-	query := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-	                 duration_minutes, calculated_fee, payment_status, status, 
-	                 entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	query := `SELECT ` + sessionColumns + `
 	           FROM parking_sessions 
 	           WHERE slot_id = $1 AND status = $2 
 	           ORDER BY entry_time DESC LIMIT 1`
 
-	err := r.db.QueryRowContext(ctx, query, slotID, domain.SessionActive).Scan(
-		&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-		&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-		&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+	err := scanParkingSession(r.db.QueryRowContext(ctx, query, slotID, domain.SessionActive), session)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNoActiveSession
 		}
 		return nil, fmt.Errorf("ParkingSessionRepository.FindActiveBySlotID: %w", err)
 	}
-	// ... (chuẩn hóa thời gian) ...
 	return session, nil
 }
 
 func (r *pgParkingSessionRepository) FindActiveByVehicleIdentifier(ctx context.Context, lotID int, vehicleID string) (*domain.ParkingSession, error) {
 	session := &domain.ParkingSession{}
-	query := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-	                 duration_minutes, calculated_fee, payment_status, status, 
-	                 entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	query := `SELECT ` + sessionColumns + `
 	           FROM parking_sessions 
 	           WHERE lot_id = $1 AND vehicle_identifier = $2 AND status = $3 
 	           ORDER BY entry_time DESC LIMIT 1`
 
-	err := r.db.QueryRowContext(ctx, query, lotID, vehicleID, domain.SessionActive).Scan(
-		&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-		&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-		&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+	err := scanParkingSession(r.db.QueryRowContext(ctx, query, lotID, vehicleID, domain.SessionActive), session)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNoActiveSession
 		}
 		return nil, fmt.Errorf("ParkingSessionRepository.FindActiveByVehicleIdentifier: %w", err)
 	}
-	// ... (chuẩn hóa thời gian) ...
 	return session, nil
 }
 
 func (r *pgParkingSessionRepository) FindLatestActiveByThingName(ctx context.Context, esp32ThingName string) (*domain.ParkingSession, error) {
 	session := &domain.ParkingSession{}
-	query := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-                     duration_minutes, calculated_fee, payment_status, status, 
-                     entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	query := `SELECT ` + sessionColumns + `
                FROM parking_sessions 
                WHERE esp32_thing_name = $1 
                  AND status = $2 
                  AND exit_time IS NULL 
                ORDER BY entry_time DESC LIMIT 1`
 
-	err := r.db.QueryRowContext(ctx, query, esp32ThingName, domain.SessionActive).Scan(
-		&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-		&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-		&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-		&session.CreatedAt, &session.UpdatedAt,
-	)
+	err := scanParkingSession(r.db.QueryRowContext(ctx, query, esp32ThingName, domain.SessionActive), session)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNoActiveSession
 		}
 		return nil, fmt.Errorf("ParkingSessionRepository.FindLatestActiveByThingName: %w", err)
 	}
-	session.EntryTime = session.EntryTime.In(time.UTC)
-	if session.ExitTime.Valid {
-		session.ExitTime.Time = session.ExitTime.Time.In(time.UTC)
-	}
-	session.CreatedAt = session.CreatedAt.In(time.UTC)
-	session.UpdatedAt = session.UpdatedAt.In(time.UTC)
 	return session, nil
 }
 
@@ -166,8 +168,9 @@ func (r *pgParkingSessionRepository) Update(ctx context.Context, session *domain
 	           SET lot_id = $1, slot_id = $2, esp32_thing_name = $3, vehicle_identifier = $4, 
 	               entry_time = $5, exit_time = $6, duration_minutes = $7, calculated_fee = $8, 
 	               payment_status = $9, status = $10, entry_gate_event_id = $11, exit_gate_event_id = $12, 
-	               updated_at = CURRENT_TIMESTAMP 
-	           WHERE id = $13 
+	               fee_breakdown = $13, exit_deadline = $14, subscription_id = $15, vehicle_class = $16, vehicle_color = $17,
+	               vehicle_make = $18, vehicle_source = $19, vehicle_corrected_by = $20, updated_at = CURRENT_TIMESTAMP 
+	           WHERE id = $21 
 	           RETURNING updated_at`
 
 	var slotIDVal sql.NullInt64
@@ -194,15 +197,33 @@ func (r *pgParkingSessionRepository) Update(ctx context.Context, session *domain
 	if session.EntryGateEventID.Valid {
 		entryGateEventIDVal = sql.NullString{String: session.EntryGateEventID.String, Valid: true}
 	}
+	var feeBreakdownVal []byte
+	if session.FeeBreakdown != nil {
+		b, err := json.Marshal(session.FeeBreakdown)
+		if err != nil {
+			return nil, fmt.Errorf("ParkingSessionRepository.Update (marshal fee_breakdown): %w", err)
+		}
+		feeBreakdownVal = b
+	}
 	var exitGateEventIDVal sql.NullString
 	if session.ExitGateEventID.Valid {
 		exitGateEventIDVal = sql.NullString{String: session.ExitGateEventID.String, Valid: true}
 	}
+	var exitDeadlineVal sql.NullTime
+	if session.ExitDeadline.Valid {
+		exitDeadlineVal = sql.NullTime{Time: session.ExitDeadline.Time, Valid: true}
+	}
+	var subscriptionIDVal sql.NullInt64
+	if session.SubscriptionID.Valid {
+		subscriptionIDVal = sql.NullInt64{Int64: session.SubscriptionID.Int64, Valid: true}
+	}
 
 	err := r.db.QueryRowContext(ctx, query,
 		session.LotID, slotIDVal, session.Esp32ThingName, vehicleIDVal,
 		session.EntryTime, exitTimeVal, durationVal, feeVal,
 		session.PaymentStatus, session.Status, entryGateEventIDVal, exitGateEventIDVal,
+		feeBreakdownVal, exitDeadlineVal, subscriptionIDVal,
+		session.VehicleClass, session.VehicleColor, session.VehicleMake, session.VehicleSource, session.VehicleCorrectedBy,
 		session.ID,
 	).Scan(&session.UpdatedAt)
 
@@ -216,10 +237,33 @@ func (r *pgParkingSessionRepository) Update(ctx context.Context, session *domain
 	return session, nil
 }
 
+func (r *pgParkingSessionRepository) UpdatePaymentStatus(ctx context.Context, id int, paymentStatus string) error {
+	query := `UPDATE parking_sessions SET payment_status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	result, err := r.db.ExecContext(ctx, query, paymentStatus, id)
+	if err != nil {
+		return fmt.Errorf("ParkingSessionRepository.UpdatePaymentStatus: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ParkingSessionRepository.UpdatePaymentStatus (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgParkingSessionRepository) CountActiveBySubscription(ctx context.Context, subscriptionID int) (int, error) {
+	query := `SELECT COUNT(*) FROM parking_sessions WHERE subscription_id = $1 AND status = $2`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, subscriptionID, domain.SessionActive).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ParkingSessionRepository.CountActiveBySubscription: %w", err)
+	}
+	return count, nil
+}
+
 func (r *pgParkingSessionRepository) GetActiveSessionsByLot(ctx context.Context, lotID int) ([]domain.ParkingSession, error) {
-	query := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-	                 duration_minutes, calculated_fee, payment_status, status, 
-	                 entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	query := `SELECT ` + sessionColumns + `
 	           FROM parking_sessions 
 	           WHERE lot_id = $1 AND status = $2 
 	           ORDER BY entry_time DESC`
@@ -232,20 +276,9 @@ func (r *pgParkingSessionRepository) GetActiveSessionsByLot(ctx context.Context,
 	var sessions []domain.ParkingSession
 	for rows.Next() {
 		var session domain.ParkingSession
-		if err := rows.Scan(
-			&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-			&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-			&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-			&session.CreatedAt, &session.UpdatedAt,
-		); err != nil {
+		if err := scanParkingSession(rows, &session); err != nil {
 			return nil, fmt.Errorf("ParkingSessionRepository.GetActiveSessionsByLot (scanning row): %w", err)
 		}
-		session.EntryTime = session.EntryTime.In(time.UTC)
-		if session.ExitTime.Valid {
-			session.ExitTime.Time = session.ExitTime.Time.In(time.UTC)
-		}
-		session.CreatedAt = session.CreatedAt.In(time.UTC)
-		session.UpdatedAt = session.UpdatedAt.In(time.UTC)
 		sessions = append(sessions, session)
 	}
 	if err = rows.Err(); err != nil {
@@ -255,9 +288,7 @@ func (r *pgParkingSessionRepository) GetActiveSessionsByLot(ctx context.Context,
 }
 
 func (r *pgParkingSessionRepository) Find(ctx context.Context, filter domain.ParkingSessionFilterDTO) ([]domain.ParkingSession, error) {
-	baseQuery := `SELECT id, lot_id, slot_id, esp32_thing_name, vehicle_identifier, entry_time, exit_time, 
-                         duration_minutes, calculated_fee, payment_status, status, 
-                         entry_gate_event_id, exit_gate_event_id, created_at, updated_at 
+	baseQuery := `SELECT ` + sessionColumns + `
                    FROM parking_sessions`
 
 	var conditions []string
@@ -291,21 +322,9 @@ func (r *pgParkingSessionRepository) Find(ctx context.Context, filter domain.Par
 	var sessions []domain.ParkingSession
 	for rows.Next() {
 		var session domain.ParkingSession
-		// ... (scan tương tự như các hàm Find khác) ...
-		if err := rows.Scan(
-			&session.ID, &session.LotID, &session.SlotID, &session.Esp32ThingName, &session.VehicleIdentifier,
-			&session.EntryTime, &session.ExitTime, &session.DurationMinutes, &session.CalculatedFee,
-			&session.PaymentStatus, &session.Status, &session.EntryGateEventID, &session.ExitGateEventID,
-			&session.CreatedAt, &session.UpdatedAt,
-		); err != nil {
+		if err := scanParkingSession(rows, &session); err != nil {
 			return nil, fmt.Errorf("ParkingSessionRepository.Find (scanning row): %w", err)
 		}
-		session.EntryTime = session.EntryTime.In(time.UTC)
-		if session.ExitTime.Valid {
-			session.ExitTime.Time = session.ExitTime.Time.In(time.UTC)
-		}
-		session.CreatedAt = session.CreatedAt.In(time.UTC)
-		session.UpdatedAt = session.UpdatedAt.In(time.UTC)
 		sessions = append(sessions, session)
 	}
 	if err = rows.Err(); err != nil {
@@ -20,13 +20,40 @@ func NewPgParkingSlotRepository(db *sql.DB) repository.ParkingSlotRepository {
 	return &pgParkingSlotRepository{db: db}
 }
 
+const slotColumns = `id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp,
+	           vehicle_class, created_at, updated_at`
+
+// scanParkingSlot scan một dòng theo thứ tự slotColumns và chuẩn hóa thời gian về UTC
+func scanParkingSlot(row rowScanner, slot *domain.ParkingSlot) error {
+	var esp32ThingName, lastStatusSource, vehicleClass sql.NullString
+	var lastEventTime sql.NullTime
+	err := row.Scan(
+		&slot.ID, &slot.LotID, &slot.SlotIdentifier, &esp32ThingName, &slot.Status,
+		&lastStatusSource, &lastEventTime, &vehicleClass, &slot.CreatedAt, &slot.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	slot.Esp32ThingName = esp32ThingName.String
+	slot.LastStatusUpdateSource = lastStatusSource.String
+	slot.VehicleClass = vehicleClass.String
+	if lastEventTime.Valid {
+		t := lastEventTime.Time.In(time.UTC)
+		slot.LastEventTimestamp = &t
+	}
+	slot.CreatedAt = slot.CreatedAt.In(time.UTC)
+	slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
+	return nil
+}
+
 func (r *pgParkingSlotRepository) Create(ctx context.Context, slot *domain.ParkingSlot) (*domain.ParkingSlot, error) {
-	query := `INSERT INTO parking_slots (lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, created_at, updated_at) 
-	           VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) 
+	query := `INSERT INTO parking_slots (lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, vehicle_class, created_at, updated_at) 
+	           VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP) 
 	           RETURNING id, created_at, updated_at`
 	err := r.db.QueryRowContext(ctx, query,
 		slot.LotID, slot.SlotIdentifier, sql.NullString{String: slot.Esp32ThingName, Valid: slot.Esp32ThingName != ""},
 		slot.Status, sql.NullString{String: slot.LastStatusUpdateSource, Valid: slot.LastStatusUpdateSource != ""},
+		sql.NullString{String: slot.VehicleClass, Valid: slot.VehicleClass != ""},
 	).Scan(&slot.ID, &slot.CreatedAt, &slot.UpdatedAt)
 
 	if err != nil {
@@ -52,39 +79,18 @@ func (r *pgParkingSlotRepository) Create(ctx context.Context, slot *domain.Parki
 
 func (r *pgParkingSlotRepository) FindByID(ctx context.Context, id int) (*domain.ParkingSlot, error) {
 	slot := &domain.ParkingSlot{}
-	query := `SELECT id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp, created_at, updated_at 
-	           FROM parking_slots WHERE id = $1`
-	var esp32ThingName, lastStatusSource sql.NullString
-	var lastEventTime sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&slot.ID, &slot.LotID, &slot.SlotIdentifier, &esp32ThingName, &slot.Status,
-		&lastStatusSource, &lastEventTime, &slot.CreatedAt, &slot.UpdatedAt,
-	)
-	if err != nil {
+	query := `SELECT ` + slotColumns + ` FROM parking_slots WHERE id = $1`
+	if err := scanParkingSlot(r.db.QueryRowContext(ctx, query, id), slot); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound
 		}
 		return nil, fmt.Errorf("ParkingSlotRepository.FindByID: %w", err)
 	}
-	if esp32ThingName.Valid {
-		slot.Esp32ThingName = esp32ThingName.String
-	}
-	if lastStatusSource.Valid {
-		slot.LastStatusUpdateSource = lastStatusSource.String
-	}
-	if lastEventTime.Valid {
-		t := lastEventTime.Time.In(time.UTC)
-		slot.LastEventTimestamp = &t
-	}
-	slot.CreatedAt = slot.CreatedAt.In(time.UTC)
-	slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
 	return slot, nil
 }
 
 func (r *pgParkingSlotRepository) FindByLotID(ctx context.Context, lotID int) ([]domain.ParkingSlot, error) {
-	query := `SELECT id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp, created_at, updated_at 
-	           FROM parking_slots WHERE lot_id = $1 ORDER BY slot_identifier`
+	query := `SELECT ` + slotColumns + ` FROM parking_slots WHERE lot_id = $1 ORDER BY slot_identifier`
 	rows, err := r.db.QueryContext(ctx, query, lotID)
 	if err != nil {
 		return nil, fmt.Errorf("ParkingSlotRepository.FindByLotID: %w", err)
@@ -94,26 +100,9 @@ func (r *pgParkingSlotRepository) FindByLotID(ctx context.Context, lotID int) ([
 	var slots []domain.ParkingSlot
 	for rows.Next() {
 		var slot domain.ParkingSlot
-		var esp32ThingName, lastStatusSource sql.NullString
-		var lastEventTime sql.NullTime
-		if err := rows.Scan(
-			&slot.ID, &slot.LotID, &slot.SlotIdentifier, &esp32ThingName, &slot.Status,
-			&lastStatusSource, &lastEventTime, &slot.CreatedAt, &slot.UpdatedAt,
-		); err != nil {
+		if err := scanParkingSlot(rows, &slot); err != nil {
 			return nil, fmt.Errorf("ParkingSlotRepository.FindByLotID (scanning row): %w", err)
 		}
-		if esp32ThingName.Valid {
-			slot.Esp32ThingName = esp32ThingName.String
-		}
-		if lastStatusSource.Valid {
-			slot.LastStatusUpdateSource = lastStatusSource.String
-		}
-		if lastEventTime.Valid {
-			t := lastEventTime.Time.In(time.UTC)
-			slot.LastEventTimestamp = &t
-		}
-		slot.CreatedAt = slot.CreatedAt.In(time.UTC)
-		slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
 		slots = append(slots, slot)
 	}
 	if err = rows.Err(); err != nil {
@@ -124,68 +113,25 @@ func (r *pgParkingSlotRepository) FindByLotID(ctx context.Context, lotID int) ([
 
 func (r *pgParkingSlotRepository) FindByLotIDAndSlotIdentifier(ctx context.Context, lotID int, slotIdentifier string) (*domain.ParkingSlot, error) {
 	slot := &domain.ParkingSlot{}
-	query := `SELECT id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp, created_at, updated_at 
-	           FROM parking_slots 
-	           WHERE lot_id = $1 AND slot_identifier = $2`
-	var esp32ThingName, lastStatusSource sql.NullString
-	var lastEventTime sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, lotID, slotIdentifier).Scan(
-		&slot.ID, &slot.LotID, &slot.SlotIdentifier, &esp32ThingName, &slot.Status,
-		&lastStatusSource, &lastEventTime, &slot.CreatedAt, &slot.UpdatedAt,
-	)
-	if err != nil {
+	query := `SELECT ` + slotColumns + ` FROM parking_slots WHERE lot_id = $1 AND slot_identifier = $2`
+	if err := scanParkingSlot(r.db.QueryRowContext(ctx, query, lotID, slotIdentifier), slot); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound
 		}
 		return nil, fmt.Errorf("ParkingSlotRepository.FindByLotIDAndSlotIdentifier: %w", err)
 	}
-	if esp32ThingName.Valid {
-		slot.Esp32ThingName = esp32ThingName.String
-	}
-	if lastStatusSource.Valid {
-		slot.LastStatusUpdateSource = lastStatusSource.String
-	}
-	if lastEventTime.Valid {
-		t := lastEventTime.Time.In(time.UTC)
-		slot.LastEventTimestamp = &t
-	}
-	slot.CreatedAt = slot.CreatedAt.In(time.UTC)
-	slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
 	return slot, nil
 }
 
 func (r *pgParkingSlotRepository) FindByThingAndSlotIdentifier(ctx context.Context, esp32ThingName string, slotIdentifier string) (*domain.ParkingSlot, error) {
 	slot := &domain.ParkingSlot{}
-	query := `SELECT id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp, created_at, updated_at 
-	           FROM parking_slots 
-	           WHERE esp32_thing_name = $1 AND slot_identifier = $2`
-	var lastEventTime sql.NullTime
-	var lastStatusSource sql.NullString
-	var dbEsp32ThingName sql.NullString
-
-	err := r.db.QueryRowContext(ctx, query, esp32ThingName, slotIdentifier).Scan(
-		&slot.ID, &slot.LotID, &slot.SlotIdentifier, &dbEsp32ThingName, &slot.Status,
-		&lastStatusSource, &lastEventTime, &slot.CreatedAt, &slot.UpdatedAt,
-	)
-	if err != nil {
+	query := `SELECT ` + slotColumns + ` FROM parking_slots WHERE esp32_thing_name = $1 AND slot_identifier = $2`
+	if err := scanParkingSlot(r.db.QueryRowContext(ctx, query, esp32ThingName, slotIdentifier), slot); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound
 		}
 		return nil, fmt.Errorf("ParkingSlotRepository.FindByThingAndSlotIdentifier: %w", err)
 	}
-	if dbEsp32ThingName.Valid {
-		slot.Esp32ThingName = dbEsp32ThingName.String
-	}
-	if lastEventTime.Valid {
-		t := lastEventTime.Time.In(time.UTC)
-		slot.LastEventTimestamp = &t
-	}
-	if lastStatusSource.Valid {
-		slot.LastStatusUpdateSource = lastStatusSource.String
-	}
-	slot.CreatedAt = slot.CreatedAt.In(time.UTC)
-	slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
 	return slot, nil
 }
 
@@ -216,11 +162,28 @@ func (r *pgParkingSlotRepository) UpdateStatus(ctx context.Context, id int, stat
 	return nil
 }
 
+func (r *pgParkingSlotRepository) UpdateStatusFromDevice(ctx context.Context, id int, status domain.SlotStatus, eventTime time.Time) (bool, error) {
+	// Chỉ so thứ tự với sự kiện thiết bị trước đó: cập nhật từ phiên / đặt chỗ dùng giờ server nên không so được
+	query := `UPDATE parking_slots
+	           SET status = $1, last_event_timestamp = $2, last_status_update_source = 'device', updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $3 AND (last_status_update_source IS DISTINCT FROM 'device' OR last_event_timestamp IS NULL
+	                              OR last_event_timestamp < $2)`
+	result, err := r.db.ExecContext(ctx, query, status, eventTime, id)
+	if err != nil {
+		return false, fmt.Errorf("ParkingSlotRepository.UpdateStatusFromDevice: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ParkingSlotRepository.UpdateStatusFromDevice (checking rows affected): %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
 func (r *pgParkingSlotRepository) Update(ctx context.Context, slot *domain.ParkingSlot) (*domain.ParkingSlot, error) {
 	query := `UPDATE parking_slots 
                SET lot_id = $1, slot_identifier = $2, esp32_thing_name = $3, status = $4, 
-                   last_status_update_source = $5, last_event_timestamp = $6, updated_at = CURRENT_TIMESTAMP 
-               WHERE id = $7 
+                   last_status_update_source = $5, last_event_timestamp = $6, vehicle_class = $7, updated_at = CURRENT_TIMESTAMP 
+               WHERE id = $8 
                RETURNING updated_at`
 
 	var esp32ThingName sql.NullString
@@ -238,7 +201,7 @@ func (r *pgParkingSlotRepository) Update(ctx context.Context, slot *domain.Parki
 
 	err := r.db.QueryRowContext(ctx, query,
 		slot.LotID, slot.SlotIdentifier, esp32ThingName, slot.Status,
-		lastStatusSource, lastEventTime, slot.ID,
+		lastStatusSource, lastEventTime, sql.NullString{String: slot.VehicleClass, Valid: slot.VehicleClass != ""}, slot.ID,
 	).Scan(&slot.UpdatedAt)
 
 	if err != nil {
@@ -258,37 +221,27 @@ func (r *pgParkingSlotRepository) Update(ctx context.Context, slot *domain.Parki
 	return slot, nil
 }
 
-func (r *pgParkingSlotRepository) FindFirstAvailableByLotID(ctx context.Context, lotID int) (*domain.ParkingSlot, error) {
+func (r *pgParkingSlotRepository) FindFirstAvailableByLotID(ctx context.Context, lotID int, vehicleClass string) (*domain.ParkingSlot, error) {
 	slot := &domain.ParkingSlot{}
-	query := `SELECT id, lot_id, slot_identifier, esp32_thing_name, status, last_status_update_source, last_event_timestamp, created_at, updated_at 
+	query := `SELECT ` + slotColumns + `
 	           FROM parking_slots 
 	           WHERE lot_id = $1 AND status = $2 
-	           ORDER BY slot_identifier ASC LIMIT 1` // Lấy slot trống đầu tiên theo thứ tự slot_identifier
-	var esp32ThingName, lastStatusSource sql.NullString
-	var lastEventTime sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, lotID, domain.StatusVacant).Scan(
-		&slot.ID, &slot.LotID, &slot.SlotIdentifier, &esp32ThingName, &slot.Status,
-		&lastStatusSource, &lastEventTime, &slot.CreatedAt, &slot.UpdatedAt,
-	)
-	if err != nil {
+	             AND NOT EXISTS (
+	                 -- Bỏ qua chỗ đang được giữ cho đặt chỗ khác (kể cả khi cảm biến đã báo trống)
+	                 SELECT 1 FROM reservations r
+	                 WHERE r.slot_id = parking_slots.id
+	                   AND (r.status = 'held' OR (r.status = 'scheduled' AND r.start_time <= CURRENT_TIMESTAMP))
+	             )
+	             AND ($3 = '' OR vehicle_class IS NULL OR vehicle_class = $3)
+	           ORDER BY CASE WHEN vehicle_class = $3 THEN 0 WHEN vehicle_class IS NULL THEN 1 ELSE 2 END,
+	                    slot_identifier ASC
+	           LIMIT 1` // Lấy slot trống đầu tiên theo khu vực loại xe, rồi theo thứ tự slot_identifier
+	if err := scanParkingSlot(r.db.QueryRowContext(ctx, query, lotID, domain.StatusVacant, vehicleClass), slot); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, repository.ErrNotFound // Không có slot nào trống
 		}
 		return nil, fmt.Errorf("ParkingSlotRepository.FindFirstAvailableByLotID: %w", err)
 	}
-	if esp32ThingName.Valid {
-		slot.Esp32ThingName = esp32ThingName.String
-	}
-	if lastStatusSource.Valid {
-		slot.LastStatusUpdateSource = lastStatusSource.String
-	}
-	if lastEventTime.Valid {
-		t := lastEventTime.Time.In(time.UTC)
-		slot.LastEventTimestamp = &t
-	}
-	slot.CreatedAt = slot.CreatedAt.In(time.UTC)
-	slot.UpdatedAt = slot.UpdatedAt.In(time.UTC)
 	return slot, nil
 }
 
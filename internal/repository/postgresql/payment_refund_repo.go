@@ -0,0 +1,134 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgPaymentRefundRepository struct {
+	db *sql.DB
+}
+
+func NewPgPaymentRefundRepository(db *sql.DB) repository.PaymentRefundRepository {
+	return &pgPaymentRefundRepository{db: db}
+}
+
+// CreatePending lưu yêu cầu hoàn tiền ở trạng thái pending trước khi gọi cổng thanh toán. Payment được khóa
+// trong transaction; tổng đã hoàn và đang chờ hoàn không được vượt quá số tiền của payment.
+func (r *pgPaymentRefundRepository) CreatePending(ctx context.Context, refund *domain.PaymentRefund) (*domain.PaymentRefund, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRefundRepository.CreatePending (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	var available float64
+	err = tx.QueryRowContext(ctx, `SELECT p.amount - p.refunded_amount - COALESCE(
+	                                  (SELECT SUM(amount) FROM payment_refunds WHERE payment_id = p.id AND status = $2), 0)
+	                               FROM payments p
+	                               WHERE p.id = $1 AND p.status IN ('succeeded', 'partially_refunded')
+	                               FOR UPDATE`, refund.PaymentID, domain.RefundPending).Scan(&available)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("PaymentRefundRepository.CreatePending (lock payment): %w", err)
+	}
+	if refund.Amount > available+0.005 {
+		return nil, repository.ErrNotFound
+	}
+
+	refund.Status = domain.RefundPending
+	query := `INSERT INTO payment_refunds
+	           (payment_id, amount, reason, status, gateway_reference, failure_reason, recorded_by, created_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query,
+		refund.PaymentID, refund.Amount, refund.Reason, refund.Status, refund.GatewayReference,
+		refund.FailureReason, refund.RecordedBy,
+	).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRefundRepository.CreatePending: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("PaymentRefundRepository.CreatePending (commit): %w", err)
+	}
+	refund.CreatedAt = refund.CreatedAt.In(time.UTC)
+	return refund, nil
+}
+
+// Complete chuyển refund pending sang succeeded và cộng vào refunded_amount của payment trong cùng transaction
+func (r *pgPaymentRefundRepository) Complete(ctx context.Context, refund *domain.PaymentRefund) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("PaymentRefundRepository.Complete (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE payment_refunds SET status = $1, gateway_reference = $2
+	                                    WHERE id = $3 AND status = $4`,
+		domain.RefundSucceeded, refund.GatewayReference, refund.ID, domain.RefundPending)
+	if err != nil {
+		return fmt.Errorf("PaymentRefundRepository.Complete: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("PaymentRefundRepository.Complete (checking rows affected): %w", err)
+	} else if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE payments
+	           SET refunded_amount = refunded_amount + $1,
+	               status = CASE WHEN refunded_amount + $1 >= amount THEN 'refunded' ELSE 'partially_refunded' END,
+	               updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $2`, refund.Amount, refund.PaymentID)
+	if err != nil {
+		return fmt.Errorf("PaymentRefundRepository.Complete (payment): %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("PaymentRefundRepository.Complete (commit): %w", err)
+	}
+	refund.Status = domain.RefundSucceeded
+	return nil
+}
+
+func (r *pgPaymentRefundRepository) MarkFailed(ctx context.Context, refund *domain.PaymentRefund) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE payment_refunds SET status = $1, failure_reason = $2
+	                                 WHERE id = $3 AND status = $4`,
+		domain.RefundFailed, refund.FailureReason, refund.ID, domain.RefundPending)
+	if err != nil {
+		return fmt.Errorf("PaymentRefundRepository.MarkFailed: %w", err)
+	}
+	refund.Status = domain.RefundFailed
+	return nil
+}
+
+func (r *pgPaymentRefundRepository) FindByPaymentID(ctx context.Context, paymentID int) ([]domain.PaymentRefund, error) {
+	query := `SELECT id, payment_id, amount, reason, status, gateway_reference, failure_reason, recorded_by, created_at
+	           FROM payment_refunds WHERE payment_id = $1 ORDER BY created_at ASC, id ASC`
+	rows, err := r.db.QueryContext(ctx, query, paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRefundRepository.FindByPaymentID: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []domain.PaymentRefund
+	for rows.Next() {
+		var refund domain.PaymentRefund
+		if err := rows.Scan(&refund.ID, &refund.PaymentID, &refund.Amount, &refund.Reason, &refund.Status,
+			&refund.GatewayReference, &refund.FailureReason, &refund.RecordedBy, &refund.CreatedAt); err != nil {
+			return nil, fmt.Errorf("PaymentRefundRepository.FindByPaymentID (scanning row): %w", err)
+		}
+		refund.CreatedAt = refund.CreatedAt.In(time.UTC)
+		refunds = append(refunds, refund)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("PaymentRefundRepository.FindByPaymentID (rows error): %w", err)
+	}
+	return refunds, nil
+}
@@ -0,0 +1,139 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgPaymentRepository struct {
+	db *sql.DB
+}
+
+func NewPgPaymentRepository(db *sql.DB) repository.PaymentRepository {
+	return &pgPaymentRepository{db: db}
+}
+
+const paymentColumns = `id, session_id, method, gateway, gateway_reference, checkout_url, amount, refunded_amount, currency,
+	                 status, failure_reason, note, recorded_by, paid_at, created_at, updated_at`
+
+func scanPayment(row rowScanner, p *domain.Payment) error {
+	err := row.Scan(
+		&p.ID, &p.SessionID, &p.Method, &p.Gateway, &p.GatewayReference, &p.CheckoutURL, &p.Amount, &p.RefundedAmount,
+		&p.Currency, &p.Status, &p.FailureReason, &p.Note, &p.RecordedBy, &p.PaidAt, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if p.PaidAt.Valid {
+		p.PaidAt.Time = p.PaidAt.Time.In(time.UTC)
+	}
+	p.CreatedAt = p.CreatedAt.In(time.UTC)
+	p.UpdatedAt = p.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgPaymentRepository) Create(ctx context.Context, p *domain.Payment) (*domain.Payment, error) {
+	query := `INSERT INTO payments
+	           (session_id, method, gateway, gateway_reference, checkout_url, amount, currency, status,
+	            failure_reason, note, recorded_by, paid_at, created_at, updated_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at, updated_at`
+
+	var paidAtVal sql.NullTime
+	if p.PaidAt.Valid {
+		paidAtVal = sql.NullTime{Time: p.PaidAt.Time, Valid: true}
+	}
+
+	err := r.db.QueryRowContext(ctx, query,
+		p.SessionID, p.Method, p.Gateway, p.GatewayReference, p.CheckoutURL, p.Amount, p.Currency, p.Status,
+		p.FailureReason, p.Note, p.RecordedBy, paidAtVal,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code.Name() == "unique_violation" {
+				return nil, fmt.Errorf("%w: mã giao dịch '%s' đã tồn tại", repository.ErrDuplicateEntry, p.GatewayReference.String)
+			}
+		}
+		return nil, fmt.Errorf("PaymentRepository.Create: %w", err)
+	}
+	p.CreatedAt = p.CreatedAt.In(time.UTC)
+	p.UpdatedAt = p.UpdatedAt.In(time.UTC)
+	return p, nil
+}
+
+func (r *pgPaymentRepository) FindByID(ctx context.Context, id int) (*domain.Payment, error) {
+	p := &domain.Payment{}
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE id = $1`
+	if err := scanPayment(r.db.QueryRowContext(ctx, query, id), p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("PaymentRepository.FindByID: %w", err)
+	}
+	return p, nil
+}
+
+func (r *pgPaymentRepository) FindByGatewayReference(ctx context.Context, gateway string, reference string) (*domain.Payment, error) {
+	p := &domain.Payment{}
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE gateway = $1 AND gateway_reference = $2`
+	if err := scanPayment(r.db.QueryRowContext(ctx, query, gateway, reference), p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("PaymentRepository.FindByGatewayReference: %w", err)
+	}
+	return p, nil
+}
+
+func (r *pgPaymentRepository) FindBySessionID(ctx context.Context, sessionID int) ([]domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE session_id = $1 ORDER BY created_at ASC, id ASC`
+	rows, err := r.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRepository.FindBySessionID: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []domain.Payment
+	for rows.Next() {
+		var p domain.Payment
+		if err := scanPayment(rows, &p); err != nil {
+			return nil, fmt.Errorf("PaymentRepository.FindBySessionID (scanning row): %w", err)
+		}
+		payments = append(payments, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("PaymentRepository.FindBySessionID (rows error): %w", err)
+	}
+	return payments, nil
+}
+
+// UpdateStatus chỉ cập nhật khi payment đang ở trạng thái from, tránh xử lý trùng webhook
+func (r *pgPaymentRepository) UpdateStatus(ctx context.Context, id int, from, to domain.PaymentRecordStatus,
+	failureReason string, paidAt *time.Time) error {
+	var paidAtVal sql.NullTime
+	if paidAt != nil {
+		paidAtVal = sql.NullTime{Time: *paidAt, Valid: true}
+	}
+	query := `UPDATE payments
+	           SET status = $1, failure_reason = $2, paid_at = COALESCE($3, paid_at), updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $4 AND status = $5`
+	result, err := r.db.ExecContext(ctx, query, to,
+		sql.NullString{String: failureReason, Valid: failureReason != ""}, paidAtVal, id, from)
+	if err != nil {
+		return fmt.Errorf("PaymentRepository.UpdateStatus: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("PaymentRepository.UpdateStatus (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+)
+
+type pgReservationRepository struct {
+	db *sql.DB
+}
+
+func NewPgReservationRepository(db *sql.DB) repository.ReservationRepository {
+	return &pgReservationRepository{db: db}
+}
+
+const reservationColumns = `id, lot_id, slot_id, any_slot, vehicle_identifier, start_time, end_time, no_show_at,
+	                 status, session_id, note, created_by, created_at, updated_at`
+
+func scanReservation(row rowScanner, reservation *domain.Reservation) error {
+	err := row.Scan(
+		&reservation.ID, &reservation.LotID, &reservation.SlotID, &reservation.AnySlot, &reservation.VehicleIdentifier,
+		&reservation.StartTime, &reservation.EndTime, &reservation.NoShowAt, &reservation.Status,
+		&reservation.SessionID, &reservation.Note, &reservation.CreatedBy, &reservation.CreatedAt, &reservation.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	reservation.StartTime = reservation.StartTime.In(time.UTC)
+	reservation.EndTime = reservation.EndTime.In(time.UTC)
+	reservation.NoShowAt = reservation.NoShowAt.In(time.UTC)
+	reservation.CreatedAt = reservation.CreatedAt.In(time.UTC)
+	reservation.UpdatedAt = reservation.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgReservationRepository) Create(ctx context.Context, reservation *domain.Reservation) (*domain.Reservation, error) {
+	query := `INSERT INTO reservations
+	           (lot_id, slot_id, any_slot, vehicle_identifier, start_time, end_time, no_show_at, status, note, created_by, created_at, updated_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at, updated_at`
+
+	var slotIDVal sql.NullInt64
+	if reservation.SlotID.Valid {
+		slotIDVal = sql.NullInt64{Int64: reservation.SlotID.Int64, Valid: true}
+	}
+	err := r.db.QueryRowContext(ctx, query,
+		reservation.LotID, slotIDVal, reservation.AnySlot, reservation.VehicleIdentifier,
+		reservation.StartTime, reservation.EndTime, reservation.NoShowAt, reservation.Status,
+		sql.NullString{String: reservation.Note.String, Valid: reservation.Note.Valid},
+		sql.NullString{String: reservation.CreatedBy.String, Valid: reservation.CreatedBy.Valid},
+	).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code.Name() == "foreign_key_violation" {
+				return nil, fmt.Errorf("%w: bãi đỗ %d hoặc chỗ đỗ không tồn tại", repository.ErrNotFound, reservation.LotID)
+			}
+		}
+		return nil, fmt.Errorf("ReservationRepository.Create: %w", err)
+	}
+	reservation.CreatedAt = reservation.CreatedAt.In(time.UTC)
+	reservation.UpdatedAt = reservation.UpdatedAt.In(time.UTC)
+	return reservation, nil
+}
+
+func (r *pgReservationRepository) FindByID(ctx context.Context, id int) (*domain.Reservation, error) {
+	reservation := &domain.Reservation{}
+	query := `SELECT ` + reservationColumns + ` FROM reservations WHERE id = $1`
+	if err := scanReservation(r.db.QueryRowContext(ctx, query, id), reservation); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("ReservationRepository.FindByID: %w", err)
+	}
+	return reservation, nil
+}
+
+func (r *pgReservationRepository) Find(ctx context.Context, filter domain.ReservationFilterDTO) ([]domain.Reservation, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.LotID != nil {
+		args = append(args, *filter.LotID)
+		conditions = append(conditions, fmt.Sprintf("lot_id = $%d", len(args)))
+	}
+	if filter.Status != nil && *filter.Status != "" {
+		args = append(args, *filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.VehicleIdentifier != nil && *filter.VehicleIdentifier != "" {
+		args = append(args, *filter.VehicleIdentifier)
+		conditions = append(conditions, fmt.Sprintf("vehicle_identifier = $%d", len(args)))
+	}
+
+	query := `SELECT ` + reservationColumns + ` FROM reservations`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY start_time DESC"
+	return r.queryReservations(ctx, "Find", query, args...)
+}
+
+func (r *pgReservationRepository) FindOpenForVehicle(ctx context.Context, lotID int, vehicleID string, from, to time.Time) (*domain.Reservation, error) {
+	reservation := &domain.Reservation{}
+	query := `SELECT ` + reservationColumns + ` FROM reservations
+	           WHERE lot_id = $1 AND vehicle_identifier = $2 AND status IN ('scheduled', 'held')
+	             AND start_time <= $3 AND no_show_at >= $4
+	           ORDER BY start_time ASC LIMIT 1`
+	err := scanReservation(r.db.QueryRowContext(ctx, query, lotID, vehicleID, to, from), reservation)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("ReservationRepository.FindOpenForVehicle: %w", err)
+	}
+	return reservation, nil
+}
+
+func (r *pgReservationRepository) CountOverlapping(ctx context.Context, lotID int, slotID *int, start, end time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM reservations
+	           WHERE lot_id = $1 AND status IN ('scheduled', 'held') AND start_time < $2 AND end_time > $3`
+	args := []interface{}{lotID, end, start}
+	if slotID != nil {
+		query += ` AND slot_id = $4`
+		args = append(args, *slotID)
+	}
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ReservationRepository.CountOverlapping: %w", err)
+	}
+	return count, nil
+}
+
+func (r *pgReservationRepository) FindDueForHold(ctx context.Context, at time.Time) ([]domain.Reservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM reservations
+	           WHERE status = 'scheduled' AND start_time <= $1 AND no_show_at >= $1
+	           ORDER BY start_time ASC`
+	return r.queryReservations(ctx, "FindDueForHold", query, at)
+}
+
+func (r *pgReservationRepository) FindNoShows(ctx context.Context, at time.Time) ([]domain.Reservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM reservations
+	           WHERE status IN ('scheduled', 'held') AND no_show_at < $1
+	           ORDER BY no_show_at ASC`
+	return r.queryReservations(ctx, "FindNoShows", query, at)
+}
+
+func (r *pgReservationRepository) queryReservations(ctx context.Context, method string, query string, args ...interface{}) ([]domain.Reservation, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ReservationRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var reservations []domain.Reservation
+	for rows.Next() {
+		var reservation domain.Reservation
+		if err := scanReservation(rows, &reservation); err != nil {
+			return nil, fmt.Errorf("ReservationRepository.%s (scanning row): %w", method, err)
+		}
+		reservations = append(reservations, reservation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("ReservationRepository.%s (rows error): %w", method, err)
+	}
+	return reservations, nil
+}
+
+func (r *pgReservationRepository) Hold(ctx context.Context, id int, slotID int) error {
+	query := `UPDATE reservations SET slot_id = $1, status = 'held', updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $2 AND status = 'scheduled'`
+	return r.execTransition(ctx, "Hold", query, slotID, id)
+}
+
+func (r *pgReservationRepository) UpdateStatus(ctx context.Context, id int, from []domain.ReservationStatus, to domain.ReservationStatus) error {
+	fromValues := make([]string, len(from))
+	for i, status := range from {
+		fromValues[i] = string(status)
+	}
+	query := `UPDATE reservations SET status = $1, updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $2 AND status = ANY($3)`
+	return r.execTransition(ctx, "UpdateStatus", query, to, id, pq.Array(fromValues))
+}
+
+func (r *pgReservationRepository) Consume(ctx context.Context, id int, sessionID int) error {
+	query := `UPDATE reservations SET status = 'consumed', session_id = $1, updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $2 AND status IN ('scheduled', 'held')`
+	return r.execTransition(ctx, "Consume", query, sessionID, id)
+}
+
+// execTransition trả về ErrNotFound nếu đặt chỗ không còn ở trạng thái cho phép chuyển
+func (r *pgReservationRepository) execTransition(ctx context.Context, method string, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ReservationRepository.%s: %w", method, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ReservationRepository.%s (checking rows affected): %w", method, err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
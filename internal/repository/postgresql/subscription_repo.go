@@ -0,0 +1,179 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+)
+
+type pgSubscriptionRepository struct {
+	db *sql.DB
+}
+
+func NewPgSubscriptionRepository(db *sql.DB) repository.SubscriptionRepository {
+	return &pgSubscriptionRepository{db: db}
+}
+
+const subscriptionColumns = `id, holder_name, holder_contact, vehicle_identifiers, lot_ids, valid_from, valid_until,
+	                 weekday_mask, allowed_start_time, allowed_end_time, timezone, max_concurrent_vehicles,
+	                 is_active, note, created_at, updated_at`
+
+func scanSubscription(row rowScanner, subscription *domain.Subscription) error {
+	var lotIDs pq.Int64Array
+	err := row.Scan(
+		&subscription.ID, &subscription.HolderName, &subscription.HolderContact,
+		pq.Array(&subscription.VehicleIdentifiers), &lotIDs, &subscription.ValidFrom, &subscription.ValidUntil,
+		&subscription.WeekdayMask, &subscription.AllowedStartTime, &subscription.AllowedEndTime, &subscription.Timezone,
+		&subscription.MaxConcurrentVehicles, &subscription.IsActive, &subscription.Note,
+		&subscription.CreatedAt, &subscription.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	subscription.LotIDs = make([]int, len(lotIDs))
+	for i, id := range lotIDs {
+		subscription.LotIDs[i] = int(id)
+	}
+	subscription.ValidFrom = subscription.ValidFrom.In(time.UTC)
+	subscription.ValidUntil = subscription.ValidUntil.In(time.UTC)
+	subscription.CreatedAt = subscription.CreatedAt.In(time.UTC)
+	subscription.UpdatedAt = subscription.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func lotIDsArray(lotIDs []int) pq.Int64Array {
+	arr := make(pq.Int64Array, len(lotIDs))
+	for i, id := range lotIDs {
+		arr[i] = int64(id)
+	}
+	return arr
+}
+
+func (r *pgSubscriptionRepository) Create(ctx context.Context, subscription *domain.Subscription) (*domain.Subscription, error) {
+	query := `INSERT INTO subscriptions
+	           (holder_name, holder_contact, vehicle_identifiers, lot_ids, valid_from, valid_until, weekday_mask,
+	            allowed_start_time, allowed_end_time, timezone, max_concurrent_vehicles, is_active, note, created_at, updated_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query,
+		subscription.HolderName, subscription.HolderContact, pq.Array(subscription.VehicleIdentifiers),
+		lotIDsArray(subscription.LotIDs), subscription.ValidFrom, subscription.ValidUntil, subscription.WeekdayMask,
+		subscription.AllowedStartTime, subscription.AllowedEndTime, subscription.Timezone,
+		subscription.MaxConcurrentVehicles, subscription.IsActive, subscription.Note,
+	).Scan(&subscription.ID, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionRepository.Create: %w", err)
+	}
+	subscription.CreatedAt = subscription.CreatedAt.In(time.UTC)
+	subscription.UpdatedAt = subscription.UpdatedAt.In(time.UTC)
+	return subscription, nil
+}
+
+func (r *pgSubscriptionRepository) FindByID(ctx context.Context, id int) (*domain.Subscription, error) {
+	subscription := &domain.Subscription{}
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+	if err := scanSubscription(r.db.QueryRowContext(ctx, query, id), subscription); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("SubscriptionRepository.FindByID: %w", err)
+	}
+	return subscription, nil
+}
+
+func (r *pgSubscriptionRepository) Find(ctx context.Context, filter domain.SubscriptionFilterDTO) ([]domain.Subscription, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.LotID != nil {
+		args = append(args, *filter.LotID)
+		conditions = append(conditions, fmt.Sprintf("(cardinality(lot_ids) = 0 OR $%d = ANY(lot_ids))", len(args)))
+	}
+	if filter.VehicleIdentifier != nil && *filter.VehicleIdentifier != "" {
+		args = append(args, *filter.VehicleIdentifier)
+		conditions = append(conditions, fmt.Sprintf("$%d = ANY(vehicle_identifiers)", len(args)))
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, "is_active AND valid_until > CURRENT_TIMESTAMP")
+	}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY valid_until DESC"
+	return r.querySubscriptions(ctx, "Find", query, args...)
+}
+
+func (r *pgSubscriptionRepository) FindValidForVehicle(ctx context.Context, lotID int, vehicleID string, at time.Time) ([]domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions
+	           WHERE is_active AND $1 = ANY(vehicle_identifiers)
+	             AND (cardinality(lot_ids) = 0 OR $2 = ANY(lot_ids))
+	             AND valid_from <= $3 AND valid_until > $3
+	           ORDER BY valid_until ASC`
+	return r.querySubscriptions(ctx, "FindValidForVehicle", query, vehicleID, lotID, at)
+}
+
+func (r *pgSubscriptionRepository) querySubscriptions(ctx context.Context, method string, query string, args ...interface{}) ([]domain.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var subscriptions []domain.Subscription
+	for rows.Next() {
+		var subscription domain.Subscription
+		if err := scanSubscription(rows, &subscription); err != nil {
+			return nil, fmt.Errorf("SubscriptionRepository.%s (scanning row): %w", method, err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("SubscriptionRepository.%s (rows error): %w", method, err)
+	}
+	return subscriptions, nil
+}
+
+func (r *pgSubscriptionRepository) Update(ctx context.Context, subscription *domain.Subscription) (*domain.Subscription, error) {
+	query := `UPDATE subscriptions
+	           SET holder_name = $1, holder_contact = $2, vehicle_identifiers = $3, lot_ids = $4, valid_from = $5,
+	               valid_until = $6, weekday_mask = $7, allowed_start_time = $8, allowed_end_time = $9, timezone = $10,
+	               max_concurrent_vehicles = $11, is_active = $12, note = $13, updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $14
+	           RETURNING updated_at`
+	err := r.db.QueryRowContext(ctx, query,
+		subscription.HolderName, subscription.HolderContact, pq.Array(subscription.VehicleIdentifiers),
+		lotIDsArray(subscription.LotIDs), subscription.ValidFrom, subscription.ValidUntil, subscription.WeekdayMask,
+		subscription.AllowedStartTime, subscription.AllowedEndTime, subscription.Timezone,
+		subscription.MaxConcurrentVehicles, subscription.IsActive, subscription.Note, subscription.ID,
+	).Scan(&subscription.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("SubscriptionRepository.Update: %w", err)
+	}
+	subscription.UpdatedAt = subscription.UpdatedAt.In(time.UTC)
+	return subscription, nil
+}
+
+func (r *pgSubscriptionRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("SubscriptionRepository.Delete: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("SubscriptionRepository.Delete (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
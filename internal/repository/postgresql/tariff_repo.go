@@ -0,0 +1,315 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+type pgTariffRepository struct {
+	db *sql.DB
+}
+
+func NewPgTariffRepository(db *sql.DB) repository.TariffRepository {
+	return &pgTariffRepository{db: db}
+}
+
+const tariffColumns = `id, lot_id, name, is_active, version, previous_version_id, effective_from, pricing_mode,
+	                 block_minutes, block_price, grace_period_minutes, minimum_fee, daily_cap, overnight_start, overnight_end,
+	                 overnight_flat_rate, vehicle_multipliers, timezone, created_at, updated_at, deactivated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTariff(row rowScanner, tariff *domain.Tariff) error {
+	var overnightStart, overnightEnd sql.NullString
+	var multipliers []byte
+	err := row.Scan(
+		&tariff.ID, &tariff.LotID, &tariff.Name, &tariff.IsActive, &tariff.Version, &tariff.PreviousVersionID,
+		&tariff.EffectiveFrom, &tariff.PricingMode, &tariff.BlockMinutes, &tariff.BlockPrice, &tariff.GracePeriodMinutes,
+		&tariff.MinimumFee, &tariff.DailyCap, &overnightStart, &overnightEnd, &tariff.OvernightFlatRate, &multipliers,
+		&tariff.Timezone, &tariff.CreatedAt, &tariff.UpdatedAt, &tariff.DeactivatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if overnightStart.Valid {
+		tariff.OvernightStart = overnightStart.String
+	}
+	if overnightEnd.Valid {
+		tariff.OvernightEnd = overnightEnd.String
+	}
+	if len(multipliers) > 0 {
+		if err := json.Unmarshal(multipliers, &tariff.VehicleMultipliers); err != nil {
+			return fmt.Errorf("lỗi parse vehicle_multipliers: %w", err)
+		}
+	}
+	tariff.EffectiveFrom = tariff.EffectiveFrom.In(time.UTC)
+	tariff.CreatedAt = tariff.CreatedAt.In(time.UTC)
+	tariff.UpdatedAt = tariff.UpdatedAt.In(time.UTC)
+	if tariff.DeactivatedAt.Valid {
+		tariff.DeactivatedAt.Time = tariff.DeactivatedAt.Time.In(time.UTC)
+	}
+	return nil
+}
+
+func marshalMultipliers(m map[string]float64) ([]byte, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+func duplicateTariffErr(err error, tariff *domain.Tariff) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+		return fmt.Errorf("%w: bãi %d đã có biểu phí active hiệu lực từ %s", repository.ErrDuplicateEntry,
+			tariff.LotID, tariff.EffectiveFrom.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// replaceTariffWindows ghi lại toàn bộ khung giờ của biểu phí trong cùng transaction
+func replaceTariffWindows(ctx context.Context, tx *sql.Tx, tariff *domain.Tariff) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tariff_schedule_windows WHERE tariff_id = $1`, tariff.ID); err != nil {
+		return err
+	}
+	query := `INSERT INTO tariff_schedule_windows
+	           (tariff_id, name, weekday_mask, holiday_only, start_time, end_time, block_minutes, block_price, priority)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	           RETURNING id`
+	for i := range tariff.Windows {
+		w := &tariff.Windows[i]
+		w.TariffID = tariff.ID
+		err := tx.QueryRowContext(ctx, query,
+			w.TariffID, w.Name, w.WeekdayMask, w.HolidayOnly, w.StartTime, w.EndTime, w.BlockMinutes, w.BlockPrice, w.Priority,
+		).Scan(&w.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *pgTariffRepository) loadWindows(ctx context.Context, tariff *domain.Tariff) error {
+	query := `SELECT id, tariff_id, name, weekday_mask, holiday_only, start_time, end_time, block_minutes, block_price, priority
+	           FROM tariff_schedule_windows WHERE tariff_id = $1 ORDER BY priority DESC, id ASC`
+	rows, err := r.db.QueryContext(ctx, query, tariff.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tariff.Windows = nil
+	for rows.Next() {
+		var w domain.TariffScheduleWindow
+		if err := rows.Scan(&w.ID, &w.TariffID, &w.Name, &w.WeekdayMask, &w.HolidayOnly, &w.StartTime, &w.EndTime,
+			&w.BlockMinutes, &w.BlockPrice, &w.Priority); err != nil {
+			return err
+		}
+		tariff.Windows = append(tariff.Windows, w)
+	}
+	return rows.Err()
+}
+
+func (r *pgTariffRepository) Create(ctx context.Context, tariff *domain.Tariff) (*domain.Tariff, error) {
+	multipliers, err := marshalMultipliers(tariff.VehicleMultipliers)
+	if err != nil {
+		return nil, fmt.Errorf("TariffRepository.Create (marshal multipliers): %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("TariffRepository.Create (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousVersionID sql.NullInt64
+	if tariff.PreviousVersionID.Valid {
+		previousVersionID = sql.NullInt64{Int64: tariff.PreviousVersionID.Int64, Valid: true}
+	}
+
+	query := `INSERT INTO tariffs
+	           (lot_id, name, is_active, version, previous_version_id, effective_from, pricing_mode, block_minutes, block_price,
+	            grace_period_minutes, minimum_fee, daily_cap, overnight_start, overnight_end, overnight_flat_rate,
+	            vehicle_multipliers, timezone, created_at, updated_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query,
+		tariff.LotID, tariff.Name, tariff.IsActive, tariff.Version, previousVersionID, tariff.EffectiveFrom,
+		tariff.PricingMode, tariff.BlockMinutes, tariff.BlockPrice, tariff.GracePeriodMinutes, tariff.MinimumFee, tariff.DailyCap,
+		sql.NullString{String: tariff.OvernightStart, Valid: tariff.OvernightStart != ""},
+		sql.NullString{String: tariff.OvernightEnd, Valid: tariff.OvernightEnd != ""},
+		tariff.OvernightFlatRate, multipliers, tariff.Timezone,
+	).Scan(&tariff.ID, &tariff.CreatedAt, &tariff.UpdatedAt)
+	if err != nil {
+		if dupErr := duplicateTariffErr(err, tariff); dupErr != nil {
+			return nil, dupErr
+		}
+		return nil, fmt.Errorf("TariffRepository.Create: %w", err)
+	}
+
+	if err := replaceTariffWindows(ctx, tx, tariff); err != nil {
+		return nil, fmt.Errorf("TariffRepository.Create (windows): %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("TariffRepository.Create (commit): %w", err)
+	}
+	tariff.CreatedAt = tariff.CreatedAt.In(time.UTC)
+	tariff.UpdatedAt = tariff.UpdatedAt.In(time.UTC)
+	return tariff, nil
+}
+
+func (r *pgTariffRepository) FindByID(ctx context.Context, id int) (*domain.Tariff, error) {
+	tariff := &domain.Tariff{}
+	query := `SELECT ` + tariffColumns + ` FROM tariffs WHERE id = $1`
+	if err := scanTariff(r.db.QueryRowContext(ctx, query, id), tariff); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("TariffRepository.FindByID: %w", err)
+	}
+	if err := r.loadWindows(ctx, tariff); err != nil {
+		return nil, fmt.Errorf("TariffRepository.FindByID (windows): %w", err)
+	}
+	return tariff, nil
+}
+
+func (r *pgTariffRepository) FindByLotID(ctx context.Context, lotID int) ([]domain.Tariff, error) {
+	query := `SELECT ` + tariffColumns + ` FROM tariffs WHERE lot_id = $1 ORDER BY effective_from DESC, id DESC`
+	rows, err := r.db.QueryContext(ctx, query, lotID)
+	if err != nil {
+		return nil, fmt.Errorf("TariffRepository.FindByLotID: %w", err)
+	}
+	defer rows.Close()
+
+	var tariffs []domain.Tariff
+	for rows.Next() {
+		var tariff domain.Tariff
+		if err := scanTariff(rows, &tariff); err != nil {
+			return nil, fmt.Errorf("TariffRepository.FindByLotID (scanning row): %w", err)
+		}
+		tariffs = append(tariffs, tariff)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("TariffRepository.FindByLotID (rows error): %w", err)
+	}
+
+	for i := range tariffs {
+		if err := r.loadWindows(ctx, &tariffs[i]); err != nil {
+			return nil, fmt.Errorf("TariffRepository.FindByLotID (windows): %w", err)
+		}
+	}
+	return tariffs, nil
+}
+
+// FindEffectiveByLotID không lọc theo is_active: phiên bản bị ngừng áp dụng sau khi có hiệu lực vẫn là giá của
+// các phiên vào trước lúc ngừng. Phiên bản bị hủy trước khi có hiệu lực (deactivated_at <= effective_from, hoặc
+// is_active = false từ đầu) bị bỏ qua. Nếu phiên bản mới nhất đã ngừng trước thời điểm at thì trả về ErrNotFound
+// thay vì quay lại phiên bản cũ hơn mà nó đã thay thế.
+func (r *pgTariffRepository) FindEffectiveByLotID(ctx context.Context, lotID int, at time.Time) (*domain.Tariff, error) {
+	tariff := &domain.Tariff{}
+	query := `SELECT ` + tariffColumns + ` FROM tariffs
+	           WHERE lot_id = $1 AND effective_from <= $2 AND (is_active OR deactivated_at > effective_from)
+	           ORDER BY effective_from DESC, id DESC LIMIT 1`
+	if err := scanTariff(r.db.QueryRowContext(ctx, query, lotID, at), tariff); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("TariffRepository.FindEffectiveByLotID: %w", err)
+	}
+	if tariff.DeactivatedAt.Valid && !tariff.DeactivatedAt.Time.After(at) {
+		return nil, repository.ErrNotFound
+	}
+	if err := r.loadWindows(ctx, tariff); err != nil {
+		return nil, fmt.Errorf("TariffRepository.FindEffectiveByLotID (windows): %w", err)
+	}
+	return tariff, nil
+}
+
+func (r *pgTariffRepository) Update(ctx context.Context, tariff *domain.Tariff) (*domain.Tariff, error) {
+	multipliers, err := marshalMultipliers(tariff.VehicleMultipliers)
+	if err != nil {
+		return nil, fmt.Errorf("TariffRepository.Update (marshal multipliers): %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("TariffRepository.Update (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE tariffs
+	           SET name = $1, is_active = $2, effective_from = $3, pricing_mode = $4, block_minutes = $5, block_price = $6,
+	               grace_period_minutes = $7, minimum_fee = $8, daily_cap = $9, overnight_start = $10, overnight_end = $11,
+	               overnight_flat_rate = $12, vehicle_multipliers = $13, timezone = $14, updated_at = CURRENT_TIMESTAMP,
+	               deactivated_at = CASE WHEN $2 THEN NULL ELSE COALESCE(deactivated_at, CURRENT_TIMESTAMP) END
+	           WHERE id = $15
+	           RETURNING updated_at, deactivated_at`
+	err = tx.QueryRowContext(ctx, query,
+		tariff.Name, tariff.IsActive, tariff.EffectiveFrom, tariff.PricingMode, tariff.BlockMinutes, tariff.BlockPrice,
+		tariff.GracePeriodMinutes, tariff.MinimumFee, tariff.DailyCap,
+		sql.NullString{String: tariff.OvernightStart, Valid: tariff.OvernightStart != ""},
+		sql.NullString{String: tariff.OvernightEnd, Valid: tariff.OvernightEnd != ""},
+		tariff.OvernightFlatRate, multipliers, tariff.Timezone,
+		tariff.ID,
+	).Scan(&tariff.UpdatedAt, &tariff.DeactivatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		if dupErr := duplicateTariffErr(err, tariff); dupErr != nil {
+			return nil, dupErr
+		}
+		return nil, fmt.Errorf("TariffRepository.Update: %w", err)
+	}
+
+	if err := replaceTariffWindows(ctx, tx, tariff); err != nil {
+		return nil, fmt.Errorf("TariffRepository.Update (windows): %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("TariffRepository.Update (commit): %w", err)
+	}
+	tariff.UpdatedAt = tariff.UpdatedAt.In(time.UTC)
+	if tariff.DeactivatedAt.Valid {
+		tariff.DeactivatedAt.Time = tariff.DeactivatedAt.Time.In(time.UTC)
+	}
+	return tariff, nil
+}
+
+func (r *pgTariffRepository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM tariffs WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("TariffRepository.Delete: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("TariffRepository.Delete (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgTariffRepository) HasSessions(ctx context.Context, id int) (bool, error) {
+	query := `SELECT EXISTS (
+	             SELECT 1 FROM parking_sessions s JOIN tariffs t ON t.id = $1
+	             WHERE s.fee_breakdown ->> 'tariff_id' = t.id::text
+	                OR (t.is_active AND s.lot_id = t.lot_id AND s.status = $2 AND s.entry_time >= t.effective_from)
+	           )`
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, id, domain.SessionActive).Scan(&exists); err != nil {
+		return false, fmt.Errorf("TariffRepository.HasSessions: %w", err)
+	}
+	return exists, nil
+}
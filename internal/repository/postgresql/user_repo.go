@@ -68,3 +68,55 @@ func (r *pgUserRepository) FindByID(ctx context.Context, id int) (*domain.User,
 	user.UpdatedAt = user.UpdatedAt.In(time.UTC)
 	return user, nil
 }
+
+func (r *pgUserRepository) FindLotIDs(ctx context.Context, userID int) ([]int, error) {
+	query := `SELECT lot_id FROM user_lot_assignments WHERE user_id = $1 ORDER BY lot_id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UserRepository.FindLotIDs: %w", err)
+	}
+	defer rows.Close()
+
+	lotIDs := []int{}
+	for rows.Next() {
+		var lotID int
+		if err := rows.Scan(&lotID); err != nil {
+			return nil, fmt.Errorf("UserRepository.FindLotIDs (scan): %w", err)
+		}
+		lotIDs = append(lotIDs, lotID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserRepository.FindLotIDs (rows): %w", err)
+	}
+	return lotIDs, nil
+}
+
+// SetLotIDs thay toàn bộ danh sách bãi được phân công của người dùng
+func (r *pgUserRepository) SetLotIDs(ctx context.Context, userID int, lotIDs []int, assignedBy string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("UserRepository.SetLotIDs (begin tx): %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_lot_assignments WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("UserRepository.SetLotIDs (delete): %w", err)
+	}
+	if len(lotIDs) > 0 {
+		query := `INSERT INTO user_lot_assignments (user_id, lot_id, assigned_by)
+		           SELECT $1, lot_id, $3 FROM unnest($2::int[]) AS lot_id
+		           ON CONFLICT (user_id, lot_id) DO NOTHING`
+		assigned := sql.NullString{String: assignedBy, Valid: assignedBy != ""}
+		if _, err := tx.ExecContext(ctx, query, userID, pq.Array(lotIDs), assigned); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+				return fmt.Errorf("%w: bãi đỗ hoặc người dùng không tồn tại", repository.ErrNotFound)
+			}
+			return fmt.Errorf("UserRepository.SetLotIDs (insert): %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("UserRepository.SetLotIDs (commit): %w", err)
+	}
+	return nil
+}
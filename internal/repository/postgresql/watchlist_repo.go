@@ -0,0 +1,216 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+)
+
+type pgWatchlistRepository struct {
+	db *sql.DB
+}
+
+func NewPgWatchlistRepository(db *sql.DB) repository.WatchlistRepository {
+	return &pgWatchlistRepository{db: db}
+}
+
+const watchlistColumns = `id, vehicle_identifier, reason_code, note, expires_at, is_active, created_by, created_at, updated_at`
+
+func scanWatchlistEntry(row rowScanner, entry *domain.WatchlistEntry) error {
+	err := row.Scan(
+		&entry.ID, &entry.VehicleIdentifier, &entry.ReasonCode, &entry.Note, &entry.ExpiresAt,
+		&entry.IsActive, &entry.CreatedBy, &entry.CreatedAt, &entry.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if entry.ExpiresAt.Valid {
+		entry.ExpiresAt.Time = entry.ExpiresAt.Time.In(time.UTC)
+	}
+	entry.CreatedAt = entry.CreatedAt.In(time.UTC)
+	entry.UpdatedAt = entry.UpdatedAt.In(time.UTC)
+	return nil
+}
+
+func (r *pgWatchlistRepository) Create(ctx context.Context, entry *domain.WatchlistEntry) (*domain.WatchlistEntry, error) {
+	query := `INSERT INTO plate_watchlist
+	           (vehicle_identifier, reason_code, note, expires_at, is_active, created_by, created_at, updated_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	           RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query,
+		entry.VehicleIdentifier, entry.ReasonCode, entry.Note, entry.ExpiresAt, entry.IsActive, entry.CreatedBy,
+	).Scan(&entry.ID, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.Create: %w", err)
+	}
+	entry.CreatedAt = entry.CreatedAt.In(time.UTC)
+	entry.UpdatedAt = entry.UpdatedAt.In(time.UTC)
+	return entry, nil
+}
+
+func (r *pgWatchlistRepository) FindByID(ctx context.Context, id int) (*domain.WatchlistEntry, error) {
+	entry := &domain.WatchlistEntry{}
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist WHERE id = $1`
+	if err := scanWatchlistEntry(r.db.QueryRowContext(ctx, query, id), entry); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("WatchlistRepository.FindByID: %w", err)
+	}
+	return entry, nil
+}
+
+func (r *pgWatchlistRepository) Find(ctx context.Context, filter domain.WatchlistFilterDTO) ([]domain.WatchlistEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.VehicleIdentifier != nil && *filter.VehicleIdentifier != "" {
+		args = append(args, *filter.VehicleIdentifier)
+		conditions = append(conditions, fmt.Sprintf("vehicle_identifier = $%d", len(args)))
+	}
+	if filter.ReasonCode != nil && *filter.ReasonCode != "" {
+		args = append(args, *filter.ReasonCode)
+		conditions = append(conditions, fmt.Sprintf("reason_code = $%d", len(args)))
+	}
+	if filter.ActiveOnly {
+		conditions = append(conditions, "is_active AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)")
+	}
+
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC"
+	return r.queryEntries(ctx, "Find", query, args...)
+}
+
+// FindActiveByVehicle trả về các mục cấm trước, VIP sau cùng
+func (r *pgWatchlistRepository) FindActiveByVehicle(ctx context.Context, vehicleID string, at time.Time) ([]domain.WatchlistEntry, error) {
+	query := `SELECT ` + watchlistColumns + ` FROM plate_watchlist
+	           WHERE vehicle_identifier = $1 AND is_active AND (expires_at IS NULL OR expires_at > $2)
+	           ORDER BY (reason_code = 'vip'), created_at DESC`
+	return r.queryEntries(ctx, "FindActiveByVehicle", query, vehicleID, at)
+}
+
+func (r *pgWatchlistRepository) queryEntries(ctx context.Context, method string, query string, args ...interface{}) ([]domain.WatchlistEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.%s: %w", method, err)
+	}
+	defer rows.Close()
+
+	var entries []domain.WatchlistEntry
+	for rows.Next() {
+		var entry domain.WatchlistEntry
+		if err := scanWatchlistEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("WatchlistRepository.%s (scanning row): %w", method, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.%s (rows error): %w", method, err)
+	}
+	return entries, nil
+}
+
+func (r *pgWatchlistRepository) Update(ctx context.Context, entry *domain.WatchlistEntry) (*domain.WatchlistEntry, error) {
+	query := `UPDATE plate_watchlist
+	           SET vehicle_identifier = $1, reason_code = $2, note = $3, expires_at = $4, is_active = $5,
+	               updated_at = CURRENT_TIMESTAMP
+	           WHERE id = $6
+	           RETURNING updated_at`
+	err := r.db.QueryRowContext(ctx, query,
+		entry.VehicleIdentifier, entry.ReasonCode, entry.Note, entry.ExpiresAt, entry.IsActive, entry.ID,
+	).Scan(&entry.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, fmt.Errorf("WatchlistRepository.Update: %w", err)
+	}
+	entry.UpdatedAt = entry.UpdatedAt.In(time.UTC)
+	return entry, nil
+}
+
+func (r *pgWatchlistRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM plate_watchlist WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("WatchlistRepository.Delete: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("WatchlistRepository.Delete (checking rows affected): %w", err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgWatchlistRepository) CreateHit(ctx context.Context, hit *domain.WatchlistHit) (*domain.WatchlistHit, error) {
+	query := `INSERT INTO watchlist_hits
+	           (watchlist_id, vehicle_identifier, reason_code, lot_id, gate_event_id, source, action, operator,
+	            override_reason, detected_at)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP)
+	           RETURNING id, detected_at`
+	err := r.db.QueryRowContext(ctx, query,
+		hit.WatchlistID, hit.VehicleIdentifier, hit.ReasonCode, hit.LotID, hit.GateEventID,
+		hit.Source, hit.Action, hit.Operator, hit.OverrideReason,
+	).Scan(&hit.ID, &hit.DetectedAt)
+	if err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.CreateHit: %w", err)
+	}
+	hit.DetectedAt = hit.DetectedAt.In(time.UTC)
+	return hit, nil
+}
+
+func (r *pgWatchlistRepository) FindHits(ctx context.Context, filter domain.WatchlistHitFilterDTO) ([]domain.WatchlistHit, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.VehicleIdentifier != nil && *filter.VehicleIdentifier != "" {
+		args = append(args, *filter.VehicleIdentifier)
+		conditions = append(conditions, fmt.Sprintf("vehicle_identifier = $%d", len(args)))
+	}
+	if filter.LotID != nil {
+		args = append(args, *filter.LotID)
+		conditions = append(conditions, fmt.Sprintf("lot_id = $%d", len(args)))
+	}
+
+	query := `SELECT id, watchlist_id, vehicle_identifier, reason_code, lot_id, gate_event_id, source, action, operator,
+	                  override_reason, detected_at
+	           FROM watchlist_hits`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY detected_at DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.FindHits: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []domain.WatchlistHit
+	for rows.Next() {
+		var hit domain.WatchlistHit
+		if err := rows.Scan(&hit.ID, &hit.WatchlistID, &hit.VehicleIdentifier, &hit.ReasonCode, &hit.LotID,
+			&hit.GateEventID, &hit.Source, &hit.Action, &hit.Operator, &hit.OverrideReason, &hit.DetectedAt); err != nil {
+			return nil, fmt.Errorf("WatchlistRepository.FindHits (scanning row): %w", err)
+		}
+		hit.DetectedAt = hit.DetectedAt.In(time.UTC)
+		hits = append(hits, hit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("WatchlistRepository.FindHits (rows error): %w", err)
+	}
+	return hits, nil
+}
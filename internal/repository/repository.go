@@ -15,6 +15,9 @@ type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) (*domain.User, error)
 	FindByUsername(ctx context.Context, username string) (*domain.User, error)
 	FindByID(ctx context.Context, id int) (*domain.User, error)
+	// Bãi đỗ được phân công cho người dùng (operator)
+	FindLotIDs(ctx context.Context, userID int) ([]int, error)
+	SetLotIDs(ctx context.Context, userID int, lotIDs []int, assignedBy string) error
 }
 
 type ParkingLotRepository interface {
@@ -32,9 +35,13 @@ type ParkingSlotRepository interface {
 	FindByLotIDAndSlotIdentifier(ctx context.Context, lotID int, slotIdentifier string) (*domain.ParkingSlot, error)
 	FindByThingAndSlotIdentifier(ctx context.Context, esp32ThingName string, slotIdentifier string) (*domain.ParkingSlot, error)
 	UpdateStatus(ctx context.Context, id int, status domain.SlotStatus, lastEventTime *time.Time, source string) error
+	// Cập nhật theo sự kiện cảm biến; false nếu sự kiện không mới hơn sự kiện thiết bị đã ghi nhận cuối cùng
+	UpdateStatusFromDevice(ctx context.Context, id int, status domain.SlotStatus, eventTime time.Time) (bool, error)
 	Update(ctx context.Context, slot *domain.ParkingSlot) (*domain.ParkingSlot, error)
 	Delete(ctx context.Context, id int) error
-	FindFirstAvailableByLotID(ctx context.Context, lotID int) (*domain.ParkingSlot, error)
+	// Chỗ trống đầu tiên cho loại xe: ưu tiên chỗ dành riêng cho loại xe, sau đó chỗ dùng chung.
+	// vehicleClass rỗng chỉ lấy chỗ dùng chung, không có thì lấy bất kỳ chỗ trống nào.
+	FindFirstAvailableByLotID(ctx context.Context, lotID int, vehicleClass string) (*domain.ParkingSlot, error)
 }
 
 type BarrierRepository interface {
@@ -51,6 +58,18 @@ type BarrierRepository interface {
 
 type DeviceEventsLogRepository interface {
 	Create(ctx context.Context, event *domain.DeviceEventLog) error
+	UpdateStatus(ctx context.Context, id int64, status string, notes string) error
+}
+
+type DeviceEventDedupRepository interface {
+	// Giữ dedup key tới leaseUntil trong lúc xử lý. reserved = false nếu key đang được giữ (sự kiện trùng), khi đó
+	// completed cho biết sự kiện kia đã xử lý xong hay còn đang xử lý. Key hết hạn được giữ lại như mới.
+	Reserve(ctx context.Context, key string, deviceID string, messageType string, leaseUntil time.Time) (reserved bool, completed bool, err error)
+	// Đánh dấu đã xử lý xong và giữ key tới expiresAt
+	Complete(ctx context.Context, key string, expiresAt time.Time) error
+	// Nhả key khi xử lý lỗi để lần gửi lại được xử lý
+	Release(ctx context.Context, key string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
 }
 
 type ParkingSessionRepository interface {
@@ -64,6 +83,9 @@ type ParkingSessionRepository interface {
 	GetActiveSessionsByLot(ctx context.Context, lotID int) ([]domain.ParkingSession, error)
 	// Thêm các hàm tìm kiếm khác nếu cần (ví dụ: theo khoảng thời gian, theo trạng thái)
 	Find(ctx context.Context, filter domain.ParkingSessionFilterDTO) ([]domain.ParkingSession, error)
+	UpdatePaymentStatus(ctx context.Context, id int, paymentStatus string) error
+	// Số phiên đang active dùng vé tháng (giới hạn số xe đồng thời)
+	CountActiveBySubscription(ctx context.Context, subscriptionID int) (int, error)
 }
 
 // Thêm DeviceRepository interface
@@ -81,7 +103,150 @@ type GateEventRepository interface {
 	UpdateStatus(ctx context.Context, eventID string, status domain.GateEventStatus, notes string) error
 	UpdateLPRResult(ctx context.Context, eventID string, plate string, confidence float32) error
 	UpdateWithSession(ctx context.Context, eventID string, sessionID int) error
+	// Gắn phiên và chuyển trạng thái (ví dụ awaiting_payment, exit_allowed cho cổng ra)
+	UpdateSessionStatus(ctx context.Context, eventID string, sessionID int, status domain.GateEventStatus, notes string) error
+	FindLatestBySessionAndStatus(ctx context.Context, sessionID int, status domain.GateEventStatus) (*domain.GateEventRecord, error)
+	FindByDeviceAndStatus(ctx context.Context, deviceID string, direction domain.GateDirection, status domain.GateEventStatus) ([]domain.GateEventRecord, error)
 	FindPendingEvents(ctx context.Context, limit int) ([]domain.GateEventRecord, error)
 	FindExpiredEvents(ctx context.Context) ([]domain.GateEventRecord, error)
 	CleanupExpiredEvents(ctx context.Context) (int, error)
+	// Thuộc tính xe nhận diện từ ảnh hoặc operator sửa
+	UpdateVehicleAttributes(ctx context.Context, eventID string, attrs domain.VehicleAttributes) error
+	FindVehicleAttributes(ctx context.Context, eventID string) (*domain.VehicleAttributes, error)
+}
+
+type TariffRepository interface {
+	Create(ctx context.Context, tariff *domain.Tariff) (*domain.Tariff, error)
+	FindByID(ctx context.Context, id int) (*domain.Tariff, error)
+	FindByLotID(ctx context.Context, lotID int) ([]domain.Tariff, error)
+	// Phiên bản active có effective_from <= at gần nhất
+	FindEffectiveByLotID(ctx context.Context, lotID int, at time.Time) (*domain.Tariff, error)
+	Update(ctx context.Context, tariff *domain.Tariff) (*domain.Tariff, error)
+	Delete(ctx context.Context, id int) error
+	// Có phiên đã tính phí theo phiên bản này, hoặc phiên đang đỗ có thể được tính theo phiên bản này
+	HasSessions(ctx context.Context, id int) (bool, error)
+}
+
+type HolidayRepository interface {
+	Create(ctx context.Context, holiday *domain.Holiday) (*domain.Holiday, error)
+	FindByID(ctx context.Context, id int) (*domain.Holiday, error)
+	FindByLotID(ctx context.Context, lotID *int) ([]domain.Holiday, error) // nil = tất cả
+	// Ngày lễ của bãi (kể cả ngày lễ chung) trong khoảng [from, to]
+	FindInRange(ctx context.Context, lotID int, from, to time.Time) ([]domain.Holiday, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *domain.Payment) (*domain.Payment, error)
+	FindByID(ctx context.Context, id int) (*domain.Payment, error)
+	FindByGatewayReference(ctx context.Context, gateway string, reference string) (*domain.Payment, error)
+	FindBySessionID(ctx context.Context, sessionID int) ([]domain.Payment, error)
+	// Trả về ErrNotFound nếu payment không còn ở trạng thái from (đã được xử lý trước đó)
+	UpdateStatus(ctx context.Context, id int, from, to domain.PaymentRecordStatus, failureReason string, paidAt *time.Time) error
+}
+
+type PaymentRefundRepository interface {
+	// Trả về ErrNotFound nếu payment không thể hoàn thêm số tiền refund.Amount
+	CreatePending(ctx context.Context, refund *domain.PaymentRefund) (*domain.PaymentRefund, error)
+	// Trả về ErrNotFound nếu refund không còn ở trạng thái pending
+	Complete(ctx context.Context, refund *domain.PaymentRefund) error
+	MarkFailed(ctx context.Context, refund *domain.PaymentRefund) error
+	FindByPaymentID(ctx context.Context, paymentID int) ([]domain.PaymentRefund, error)
+}
+
+type ReservationRepository interface {
+	Create(ctx context.Context, reservation *domain.Reservation) (*domain.Reservation, error)
+	FindByID(ctx context.Context, id int) (*domain.Reservation, error)
+	Find(ctx context.Context, filter domain.ReservationFilterDTO) ([]domain.Reservation, error)
+	// Đặt chỗ đang mở của biển số có start_time <= to và no_show_at >= from
+	FindOpenForVehicle(ctx context.Context, lotID int, vehicleID string, from, to time.Time) (*domain.Reservation, error)
+	// Số đặt chỗ đang mở chồng lấn [start, end); slotID nil = toàn bãi
+	CountOverlapping(ctx context.Context, lotID int, slotID *int, start, end time.Time) (int, error)
+	FindDueForHold(ctx context.Context, at time.Time) ([]domain.Reservation, error)
+	FindNoShows(ctx context.Context, at time.Time) ([]domain.Reservation, error)
+	// Các hàm chuyển trạng thái trả về ErrNotFound nếu đặt chỗ không còn ở trạng thái phù hợp
+	Hold(ctx context.Context, id int, slotID int) error
+	UpdateStatus(ctx context.Context, id int, from []domain.ReservationStatus, to domain.ReservationStatus) error
+	Consume(ctx context.Context, id int, sessionID int) error
+}
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, subscription *domain.Subscription) (*domain.Subscription, error)
+	FindByID(ctx context.Context, id int) (*domain.Subscription, error)
+	Find(ctx context.Context, filter domain.SubscriptionFilterDTO) ([]domain.Subscription, error)
+	// Vé đang active, còn hạn tại thời điểm at, có biển số và áp dụng cho bãi lotID
+	FindValidForVehicle(ctx context.Context, lotID int, vehicleID string, at time.Time) ([]domain.Subscription, error)
+	Update(ctx context.Context, subscription *domain.Subscription) (*domain.Subscription, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type WatchlistRepository interface {
+	Create(ctx context.Context, entry *domain.WatchlistEntry) (*domain.WatchlistEntry, error)
+	FindByID(ctx context.Context, id int) (*domain.WatchlistEntry, error)
+	Find(ctx context.Context, filter domain.WatchlistFilterDTO) ([]domain.WatchlistEntry, error)
+	// Các mục đang active và chưa hết hạn tại thời điểm at của biển số
+	FindActiveByVehicle(ctx context.Context, vehicleID string, at time.Time) ([]domain.WatchlistEntry, error)
+	Update(ctx context.Context, entry *domain.WatchlistEntry) (*domain.WatchlistEntry, error)
+	Delete(ctx context.Context, id int) error
+	CreateHit(ctx context.Context, hit *domain.WatchlistHit) (*domain.WatchlistHit, error)
+	FindHits(ctx context.Context, filter domain.WatchlistHitFilterDTO) ([]domain.WatchlistHit, error)
+}
+
+type LPRAttemptRepository interface {
+	Create(ctx context.Context, attempt *domain.LPRAttempt) (*domain.LPRAttempt, error)
+	// Các lần nhận dạng của gate event, cũ nhất trước
+	FindByEventID(ctx context.Context, eventID string) ([]domain.LPRAttempt, error)
+}
+
+type EvidenceRepository interface {
+	// Trả về ErrDuplicateEntry nếu ảnh (cùng hash) đã được lưu cho gate event
+	Create(ctx context.Context, evidence *domain.EvidenceImage) (*domain.EvidenceImage, error)
+	FindByID(ctx context.Context, id int) (*domain.EvidenceImage, error)
+	FindByGateEventAndHash(ctx context.Context, gateEventID string, sha256 string) (*domain.EvidenceImage, error)
+	FindByGateEvent(ctx context.Context, gateEventID string) ([]domain.EvidenceImage, error)
+	// Ảnh gắn trực tiếp với phiên hoặc với gate event vào/ra của phiên
+	FindBySession(ctx context.Context, sessionID int, gateEventIDs []string) ([]domain.EvidenceImage, error)
+	// Ảnh chụp trước before, chưa purge và không bị giữ do khiếu nại
+	FindPurgeable(ctx context.Context, before time.Time, limit int) ([]domain.EvidenceImage, error)
+	MarkPurged(ctx context.Context, id int, purgedAt time.Time) error
+	SetLegalHold(ctx context.Context, id int, hold bool) (*domain.EvidenceImage, error)
+}
+
+type LPRCorrectionRepository interface {
+	Create(ctx context.Context, correction *domain.LPRCorrection) (*domain.LPRCorrection, error)
+	// Các lần sửa theo bộ lọc, cũ nhất trước
+	Find(ctx context.Context, filter domain.LPRCorrectionFilterDTO) ([]domain.LPRCorrection, error)
+	// Số gate event máy đọc được biển số và số lần operator phải sửa từ since. cameraID rỗng = cả bãi.
+	Stats(ctx context.Context, lotID int, cameraID string, since time.Time) (domain.LPRCorrectionStats, error)
+}
+
+type LPRThresholdRepository interface {
+	Find(ctx context.Context, lotID *int) ([]domain.LPRThreshold, error)
+	FindByID(ctx context.Context, id int) (*domain.LPRThreshold, error)
+	// Ngưỡng riêng của camera, không có thì ngưỡng chung của bãi
+	FindForCamera(ctx context.Context, lotID int, cameraID string) (*domain.LPRThreshold, error)
+	FindAutoTune(ctx context.Context) ([]domain.LPRThreshold, error)
+	// Tạo hoặc cập nhật ngưỡng và ghi lịch sử trong cùng transaction; giá trị không đổi thì không ghi lịch sử
+	Upsert(ctx context.Context, threshold *domain.LPRThreshold, change domain.LPRThresholdChange) (*domain.LPRThreshold, error)
+	Delete(ctx context.Context, id int, change domain.LPRThresholdChange) error
+	FindChanges(ctx context.Context, lotID *int, cameraID *string, limit int) ([]domain.LPRThresholdChange, error)
+}
+
+type DeviceCommandRepository interface {
+	Create(ctx context.Context, command *domain.DeviceCommand) (*domain.DeviceCommand, error)
+	FindByRequestID(ctx context.Context, requestID string) (*domain.DeviceCommand, error)
+	// Nhận xử lý các lệnh pending / sent có next_retry_at <= now (cũ nhất trước): next_retry_at được dời tới leaseUntil
+	// để backend khác chạy song song không lấy trùng; lệnh đang bị khóa bởi backend khác được bỏ qua.
+	ClaimDue(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.DeviceCommand, error)
+	// Cập nhật status, attempts, last_error, next_retry_at, alert_sent, sent_at khi lệnh còn pending / sent.
+	// Trả về ErrNotFound nếu lệnh đã được xác nhận / kết thúc trong lúc đó.
+	UpdateDelivery(ctx context.Context, command *domain.DeviceCommand) (*domain.DeviceCommand, error)
+	// Thiết bị xác nhận lệnh (kể cả xác nhận muộn sau timed_out). ErrNotFound nếu không có lệnh chờ xác nhận.
+	MarkAcknowledged(ctx context.Context, requestID string, at time.Time) (*domain.DeviceCommand, error)
+	// Thiết bị từ chối / báo lỗi lệnh -> failed. ErrNotFound nếu lệnh không còn chờ xác nhận / thực thi.
+	MarkFailed(ctx context.Context, requestID string, reason string) (*domain.DeviceCommand, error)
+	// Đánh dấu executed lệnh gần nhất của rào đang sent / acknowledged với đúng command. ErrNotFound nếu không có.
+	MarkExecuted(ctx context.Context, thingName string, barrierType string, command string, at time.Time) (*domain.DeviceCommand, error)
+	// Lệnh đã xác nhận trước ackedBefore mà rào chưa chuyển trạng thái -> timed_out
+	ExpireUnexecuted(ctx context.Context, ackedBefore time.Time, note string) (int64, error)
 }
@@ -137,3 +137,19 @@ func (s *AuthService) ValidateToken(tokenString string) (*jwt.Token, jwt.MapClai
 	}
 	return token, claims, nil
 }
+
+// AssignedLotIDs trả về các bãi được phân công cho người dùng
+func (s *AuthService) AssignedLotIDs(ctx context.Context, userID int) ([]int, error) {
+	return s.userRepo.FindLotIDs(ctx, userID)
+}
+
+// SetAssignedLots thay danh sách bãi được phân công cho người dùng
+func (s *AuthService) SetAssignedLots(ctx context.Context, userID int, lotIDs []int, assignedBy string) ([]int, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetLotIDs(ctx, userID, lotIDs, assignedBy); err != nil {
+		return nil, err
+	}
+	return s.userRepo.FindLotIDs(ctx, userID)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/config"
+	"smart_parking/internal/mqtt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+)
+
+var ErrInvalidCommandTarget = errors.New("thing name hoặc loại rào chắn không hợp lệ cho topic lệnh")
+
+// CommandPublisher - Kênh gửi lệnh xuống thiết bị. IoTService chỉ phụ thuộc vào interface này
+// để điều khiển rào chắn qua AWS IoT Core, broker MQTT tại bãi hoặc loopback khi chạy trên máy dev.
+type CommandPublisher interface {
+	Name() string
+	// Publish gửi payload tới topic với QoS 1; trả về khi broker đã nhận lệnh
+	Publish(ctx context.Context, topic string, payload []byte) error
+}
+
+// NewCommandPublisher chọn kênh gửi lệnh theo cấu hình COMMAND_TRANSPORT
+func NewCommandPublisher(cfg *config.Config, iotDataClient *iotdataplane.Client) (CommandPublisher, error) {
+	if !strings.Contains(cfg.CommandTopicTemplate, "{thing_name}") {
+		log.Printf("CẢNH BÁO: COMMAND_TOPIC_TEMPLATE '%s' không có {thing_name}, mọi ESP32 subscribe topic này đều nhận lệnh.", cfg.CommandTopicTemplate)
+	}
+	switch cfg.CommandTransport {
+	case "aws_iot", "":
+		return NewAWSIoTCommandPublisher(iotDataClient), nil
+	case "mqtt":
+		return NewMQTTCommandPublisher(cfg)
+	case "loopback":
+		return NewLoopbackCommandPublisher(100), nil
+	default:
+		return nil, fmt.Errorf("kênh gửi lệnh không được hỗ trợ: %s", cfg.CommandTransport)
+	}
+}
+
+// barrierCommandTopic dựng topic lệnh từ template với {thing_name} và {barrier_type}.
+// Không chấp nhận ký tự wildcard / phân cấp MQTT trong giá trị để một lệnh không rơi vào topic của thiết bị khác.
+func barrierCommandTopic(template, thingName, barrierType string) (string, error) {
+	for _, value := range []string{thingName, barrierType} {
+		if value == "" || strings.ContainsAny(value, "/+#") {
+			return "", fmt.Errorf("%w: '%s'", ErrInvalidCommandTarget, value)
+		}
+	}
+	return strings.NewReplacer("{thing_name}", thingName, "{barrier_type}", barrierType).Replace(template), nil
+}
+
+// AWSIoTCommandPublisher gửi lệnh qua AWS IoT Data Plane (HTTPS Publish)
+type AWSIoTCommandPublisher struct {
+	client *iotdataplane.Client
+}
+
+func NewAWSIoTCommandPublisher(client *iotdataplane.Client) *AWSIoTCommandPublisher {
+	return &AWSIoTCommandPublisher{client: client}
+}
+
+func (p *AWSIoTCommandPublisher) Name() string { return "aws_iot" }
+
+func (p *AWSIoTCommandPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	_, err := p.client.Publish(ctx, &iotdataplane.PublishInput{
+		Topic:   aws.String(topic),
+		Qos:     1,
+		Payload: payload,
+	})
+	return err
+}
+
+// MQTTCommandPublisher gửi lệnh thẳng tới broker MQTT (Mosquitto tại bãi...). Kết nối được mở khi gửi lệnh
+// đầu tiên và mở lại nếu đã mất, dùng client id riêng để không đá phiên subscribe của nguồn sự kiện mqtt.
+type MQTTCommandPublisher struct {
+	options mqtt.Options
+
+	mu   sync.Mutex
+	conn *mqtt.Conn
+}
+
+func NewMQTTCommandPublisher(cfg *config.Config) (*MQTTCommandPublisher, error) {
+	if cfg.MQTTBrokerURL == "" {
+		return nil, fmt.Errorf("cần MQTT_BROKER_URL để gửi lệnh qua mqtt")
+	}
+	tlsConfig, err := mqtt.NewTLSConfig(cfg.MQTTCAFile, cfg.MQTTCertFile, cfg.MQTTKeyFile, cfg.MQTTInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	return &MQTTCommandPublisher{
+		options: mqtt.Options{
+			BrokerURL:    cfg.MQTTBrokerURL,
+			ClientID:     cfg.MQTTClientID + "-commands",
+			Username:     cfg.MQTTUsername,
+			Password:     cfg.MQTTPassword,
+			TLSConfig:    tlsConfig,
+			KeepAlive:    cfg.MQTTKeepAlive,
+			CleanSession: true,
+		},
+	}, nil
+}
+
+func (p *MQTTCommandPublisher) Name() string { return "mqtt" }
+
+func (p *MQTTCommandPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	conn, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+	if err := conn.Publish(ctx, topic, payload, 1); err != nil {
+		return fmt.Errorf("lỗi publish lệnh tới broker: %w", err)
+	}
+	return nil
+}
+
+func (p *MQTTCommandPublisher) connection(ctx context.Context) (*mqtt.Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		select {
+		case <-p.conn.Done():
+			log.Printf("MQTTCommandPublisher: Mất kết nối broker (%v), kết nối lại", p.conn.Err())
+			p.conn = nil
+		default:
+			return p.conn, nil
+		}
+	}
+	conn, err := mqtt.Dial(ctx, p.options)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// Close đóng kết nối tới broker (nếu đang mở)
+func (p *MQTTCommandPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// PublishedCommand - Lệnh LoopbackCommandPublisher đã nhận
+type PublishedCommand struct {
+	Topic       string
+	Payload     []byte
+	PublishedAt time.Time
+}
+
+// LoopbackCommandPublisher không gửi lệnh đi đâu: ghi log và giữ lại các lệnh gần nhất trong bộ nhớ
+// để chạy luồng điều khiển rào chắn trên máy dev mà không cần AWS IoT hay broker.
+type LoopbackCommandPublisher struct {
+	mu       sync.Mutex
+	limit    int
+	commands []PublishedCommand
+}
+
+func NewLoopbackCommandPublisher(limit int) *LoopbackCommandPublisher {
+	return &LoopbackCommandPublisher{limit: limit}
+}
+
+func (p *LoopbackCommandPublisher) Name() string { return "loopback" }
+
+func (p *LoopbackCommandPublisher) Publish(ctx context.Context, topic string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, PublishedCommand{Topic: topic, Payload: append([]byte(nil), payload...), PublishedAt: time.Now()})
+	if p.limit > 0 && len(p.commands) > p.limit {
+		p.commands = p.commands[len(p.commands)-p.limit:]
+	}
+	log.Printf("LoopbackCommandPublisher: %s <- %s", topic, payload)
+	return nil
+}
+
+// Published trả về bản sao các lệnh đã nhận, cũ nhất trước
+func (p *LoopbackCommandPublisher) Published() []PublishedCommand {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PublishedCommand(nil), p.commands...)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/config"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// ErrCommandRetryScheduled - publish lần đầu lỗi nhưng lệnh đã được lưu, CommandTracker sẽ tự gửi lại
+var ErrCommandRetryScheduled = errors.New("chưa gửi được lệnh, hệ thống sẽ tự gửi lại")
+
+var ErrCommandTrackingDisabled = errors.New("chưa bật theo dõi lệnh thiết bị")
+
+// Số lệnh tối đa xử lý mỗi lần quét
+const commandRetryBatchSize = 100
+
+// Lệnh đã được một backend nhận xử lý sẽ không được backend khác lấy lại trong khoảng này (nếu backend đó dừng giữa chừng)
+const commandClaimLease = time.Minute
+
+// CommandTracker ghi lại mỗi lệnh rào chắn theo request_id và theo dõi tới khi rào thực sự chuyển trạng thái:
+// sent -> acknowledged (command_acknowledgement) -> executed (barrier_state khớp lệnh). Lệnh chưa được xác nhận
+// được gửi lại với backoff; hết số lần gửi thì timed_out (hoặc failed nếu chưa từng tới được broker).
+type CommandTracker struct {
+	repo       repository.DeviceCommandRepository
+	deviceRepo repository.DeviceRepository
+	publisher  CommandPublisher
+	notifier   WebSocketManager
+
+	ackTimeout     time.Duration
+	maxBackoff     time.Duration
+	executeTimeout time.Duration
+	maxAttempts    int
+	alertAfter     int
+}
+
+func NewCommandTracker(repo repository.DeviceCommandRepository, deviceRepo repository.DeviceRepository,
+	publisher CommandPublisher, notifier WebSocketManager, cfg *config.Config) *CommandTracker {
+	maxAttempts := cfg.CommandMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &CommandTracker{
+		repo:           repo,
+		deviceRepo:     deviceRepo,
+		publisher:      publisher,
+		notifier:       notifier,
+		ackTimeout:     cfg.CommandAckTimeout,
+		maxBackoff:     cfg.CommandRetryMaxBackoff,
+		executeTimeout: cfg.CommandExecuteTimeout,
+		maxAttempts:    maxAttempts,
+		alertAfter:     cfg.CommandAlertAfterFailures,
+	}
+}
+
+// Send lưu lệnh rồi publish lần đầu. Publish lỗi trả về ErrCommandRetryScheduled, lệnh vẫn được gửi lại sau.
+func (t *CommandTracker) Send(ctx context.Context, command *domain.DeviceCommand) error {
+	command.Status = domain.CommandStatusPending
+	command.Attempts = 0
+	command.MaxAttempts = t.maxAttempts
+	saved, err := t.repo.Create(ctx, command)
+	if err != nil {
+		return fmt.Errorf("lỗi lưu lệnh %s: %w", command.RequestID, err)
+	}
+	if err := t.deliver(ctx, saved, time.Now().UTC()); err != nil {
+		return fmt.Errorf("%w: %v", ErrCommandRetryScheduled, err)
+	}
+	return nil
+}
+
+func (t *CommandTracker) Get(ctx context.Context, requestID string) (*domain.DeviceCommand, error) {
+	return t.repo.FindByRequestID(ctx, requestID)
+}
+
+// backoff - thời gian chờ xác nhận sau lần gửi thứ attempt: ackTimeout, 2x, 4x... tối đa maxBackoff
+func (t *CommandTracker) backoff(attempt int) time.Duration {
+	delay := t.ackTimeout
+	for i := 1; i < attempt && delay < t.maxBackoff; i++ {
+		delay *= 2
+	}
+	if t.maxBackoff > 0 && delay > t.maxBackoff {
+		delay = t.maxBackoff
+	}
+	return delay
+}
+
+// deliver publish lệnh một lần và hẹn lần kiểm tra xác nhận tiếp theo; trả về lỗi publish (nếu có)
+func (t *CommandTracker) deliver(ctx context.Context, command *domain.DeviceCommand, now time.Time) error {
+	command.Attempts++
+	command.NextRetryAt = null.TimeFrom(now.Add(t.backoff(command.Attempts)))
+	publishErr := t.publisher.Publish(ctx, command.Topic, command.Payload)
+	if publishErr != nil {
+		command.LastError = null.StringFrom(publishErr.Error())
+	} else {
+		command.Status = domain.CommandStatusSent
+		command.SentAt = null.TimeFrom(now)
+	}
+	if _, err := t.repo.UpdateDelivery(ctx, command); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Printf("CommandTracker: Lỗi cập nhật lệnh %s sau lần gửi %d: %v", command.RequestID, command.Attempts, err)
+	}
+	return publishErr
+}
+
+// ProcessDueCommands gửi lại các lệnh quá hạn chờ xác nhận và kết thúc các lệnh đã hết số lần gửi
+func (t *CommandTracker) ProcessDueCommands(ctx context.Context, now time.Time) (retried int, expired int, err error) {
+	commands, err := t.repo.ClaimDue(ctx, now, now.Add(commandClaimLease), commandRetryBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range commands {
+		command := &commands[i]
+		failures := command.Attempts // Mọi lần gửi trước đều chưa được xác nhận
+		if failures >= command.MaxAttempts {
+			t.expire(ctx, command)
+			expired++
+			continue
+		}
+		if t.alertAfter > 0 && failures >= t.alertAfter && !command.AlertSent {
+			command.AlertSent = true
+			message := fmt.Sprintf("ESP32 %s chưa xác nhận lệnh '%s' rào %s sau %d lần gửi, đang gửi lại.",
+				command.Esp32ThingName, command.Command, command.BarrierType, failures)
+			if command.Status == domain.CommandStatusPending {
+				message = fmt.Sprintf("Chưa gửi được lệnh '%s' tới ESP32 %s sau %d lần (%s), đang thử lại.",
+					command.Command, command.Esp32ThingName, failures, command.LastError.String)
+			}
+			t.alert(ctx, command, message)
+		}
+		if err := t.deliver(ctx, command, now); err != nil {
+			log.Printf("CommandTracker: Gửi lại lệnh %s (lần %d) lỗi: %v", command.RequestID, command.Attempts, err)
+		}
+		retried++
+	}
+
+	if t.executeTimeout > 0 {
+		count, err := t.repo.ExpireUnexecuted(ctx, now.Add(-t.executeTimeout), "thiết bị đã xác nhận nhưng không báo rào chuyển trạng thái")
+		if err != nil {
+			return retried, expired, err
+		}
+		expired += int(count)
+	}
+	return retried, expired, nil
+}
+
+func (t *CommandTracker) expire(ctx context.Context, command *domain.DeviceCommand) {
+	var message string
+	if command.Status == domain.CommandStatusSent {
+		command.Status = domain.CommandStatusTimedOut
+		message = fmt.Sprintf("ESP32 %s không xác nhận lệnh '%s' rào %s sau %d lần gửi. Kiểm tra thiết bị và điều khiển rào thủ công.",
+			command.Esp32ThingName, command.Command, command.BarrierType, command.Attempts)
+	} else {
+		command.Status = domain.CommandStatusFailed
+		message = fmt.Sprintf("Không gửi được lệnh '%s' tới ESP32 %s sau %d lần (%s).",
+			command.Command, command.Esp32ThingName, command.Attempts, command.LastError.String)
+	}
+	// Luôn báo khi lệnh kết thúc, kể cả đã cảnh báo lúc đang gửi lại
+	command.NextRetryAt = null.Time{}
+	command.AlertSent = true
+	t.alert(ctx, command, message)
+	if _, err := t.repo.UpdateDelivery(ctx, command); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Printf("CommandTracker: Lỗi kết thúc lệnh %s: %v", command.RequestID, err)
+	}
+	log.Printf("CommandTracker: Lệnh %s kết thúc với trạng thái %s: %s", command.RequestID, command.Status, message)
+}
+
+// alert báo operator qua WebSocket; lot lấy theo thiết bị để operator của bãi nhận được
+func (t *CommandTracker) alert(ctx context.Context, command *domain.DeviceCommand, message string) {
+	if t.notifier == nil {
+		return
+	}
+	notification := domain.GateEventNotification{
+		EventID:           command.RequestID,
+		DeviceID:          command.Esp32ThingName,
+		GateDirection:     domain.GateDirection(command.BarrierType),
+		EventType:         domain.GateEventCommandFailed,
+		Timestamp:         time.Now().UTC(),
+		RequiresUserInput: true,
+		Message:           message,
+		CommandRequestID:  command.RequestID,
+		CommandStatus:     command.Status,
+	}
+	if device, err := t.deviceRepo.FindByThingName(ctx, command.Esp32ThingName); err == nil && device.LotID.Valid {
+		notification.LotID = int(device.LotID.Int64)
+	}
+	t.notifier.BroadcastGateEvent(notification)
+}
+
+// HandleAck ghi nhận command_acknowledgement của thiết bị theo request_id
+func (t *CommandTracker) HandleAck(ctx context.Context, event domain.DeviceCommandAckEvent) error {
+	if event.RequestID == "" {
+		return nil
+	}
+	if event.Status != "" && event.Status != "acknowledged" {
+		return t.handleRejected(ctx, event)
+	}
+	command, err := t.repo.MarkAcknowledged(ctx, event.RequestID, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Ack trùng (lệnh được gửi lại nhiều lần) hoặc lệnh không do backend này theo dõi
+			return nil
+		}
+		return err
+	}
+	log.Printf("CommandTracker: ESP32 %s đã xác nhận lệnh %s (%s) sau %d lần gửi", command.Esp32ThingName, command.RequestID, command.Command, command.Attempts)
+	return nil
+}
+
+// handleRejected: thiết bị từ chối / không thực hiện được lệnh -> failed và báo operator
+func (t *CommandTracker) handleRejected(ctx context.Context, event domain.DeviceCommandAckEvent) error {
+	reason := fmt.Sprintf("ESP32 trả về trạng thái '%s'", event.Status)
+	command, err := t.repo.MarkFailed(ctx, event.RequestID, reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Printf("CommandTracker: ESP32 %s trả về trạng thái '%s' cho lệnh %s không còn chờ xác nhận", event.DeviceID, event.Status, event.RequestID)
+			return nil
+		}
+		return err
+	}
+	t.alert(ctx, command, fmt.Sprintf("ESP32 %s không thực hiện lệnh '%s' rào %s (trạng thái '%s'). Kiểm tra thiết bị và điều khiển rào thủ công.",
+		command.Esp32ThingName, command.Command, command.BarrierType, event.Status))
+	log.Printf("CommandTracker: Lệnh %s thất bại: %s", command.RequestID, reason)
+	return nil
+}
+
+// HandleBarrierState đánh dấu executed lệnh gần nhất của rào khi trạng thái báo về khớp lệnh (opened_command <- open)
+func (t *CommandTracker) HandleBarrierState(ctx context.Context, event domain.DeviceBarrierStateEvent) error {
+	state := string(event.BarrierState)
+	if event.BarrierType == "" || !strings.HasSuffix(state, "_command") {
+		return nil
+	}
+	var command string
+	switch strings.TrimSuffix(state, "_command") {
+	case "opened":
+		command = "open"
+	case "closed":
+		command = "close"
+	default:
+		return nil
+	}
+	executed, err := t.repo.MarkExecuted(ctx, event.DeviceID, event.BarrierType, command, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	log.Printf("CommandTracker: Rào %s của ESP32 %s đã thực hiện lệnh %s (%s)", executed.BarrierType, executed.Esp32ThingName, executed.RequestID, executed.Command)
+	return nil
+}
+
+// GetDeviceCommand trả về trạng thái lệnh theo request_id
+func (s *IoTService) GetDeviceCommand(ctx context.Context, requestID string) (*domain.DeviceCommand, error) {
+	if s.commandTracker == nil {
+		return nil, ErrCommandTrackingDisabled
+	}
+	return s.commandTracker.Get(ctx, requestID)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"smart_parking/internal/domain"
+	"strings"
+	"time"
+)
+
+// ErrStaleDeviceEvent - sự kiện cũ hơn trạng thái thiết bị đã ghi nhận, được bỏ qua (không phải lỗi xử lý)
+var ErrStaleDeviceEvent = errors.New("sự kiện thiết bị cũ hơn trạng thái hiện tại")
+
+// ErrInvalidDeviceEvent - payload sự kiện không hợp lệ, gửi lại cũng không xử lý được nên sự kiện bị bỏ
+var ErrInvalidDeviceEvent = errors.New("sự kiện thiết bị không hợp lệ")
+
+// ErrDeviceEventInProgress - sự kiện cùng dedup key đang được xử lý ở nơi khác; trả lỗi để nguồn sự kiện gửi lại sau
+var ErrDeviceEventInProgress = errors.New("sự kiện thiết bị trùng đang được xử lý")
+
+// Dedup key được giữ trong thời gian này khi đang xử lý; xử lý xong mới gia hạn tới DeviceEventDedupTTL.
+// Backend dừng giữa chừng thì key hết hạn sau khoảng này và lần gửi lại được xử lý.
+const deviceEventProcessingLease = 2 * time.Minute
+
+// deviceEventIdentity - các trường định danh một sự kiện trong payload, tùy loại message
+type deviceEventIdentity struct {
+	EventID   string `json:"event_id"`
+	SlotID    string `json:"slot_id"`
+	BarrierID string `json:"barrier_id"`
+	RequestID string `json:"request_id"`
+	ErrorID   string `json:"error_id"`
+}
+
+// deviceEventDedupKey tạo key device_id|message_type|định danh|timestamp cho sự kiện. event_id của ESP32 là millis()
+// nên khởi động lại sẽ lặp, vì vậy luôn kèm timestamp thiết bị. Không có định danh lẫn timestamp thì trả về "" (không dedup).
+func deviceEventDedupKey(event domain.GenericIoTEvent) string {
+	var identity deviceEventIdentity
+	if err := json.Unmarshal(event.RawPayload, &identity); err != nil {
+		return ""
+	}
+	ids := []string{identity.EventID, identity.SlotID, identity.BarrierID, identity.RequestID, identity.ErrorID}
+	if event.Timestamp == "" && strings.Join(ids, "") == "" {
+		return ""
+	}
+	deviceID := event.DeviceID
+	if deviceID == "" {
+		deviceID = event.ClientIDFromIoT
+	}
+	if deviceID == "" || event.MessageType == "" {
+		return ""
+	}
+	return strings.Join(append([]string{deviceID, event.MessageType}, append(ids, event.Timestamp)...), "|")
+}
+
+// reserveDeviceEvent giữ dedup key của sự kiện trong thời gian xử lý; false nếu sự kiện đã được xử lý xong,
+// ErrDeviceEventInProgress nếu đang được xử lý. Lỗi dedup store không chặn việc xử lý, sự kiện được xử lý như không có dedup.
+func (s *IoTService) reserveDeviceEvent(ctx context.Context, key string, event domain.GenericIoTEvent) (bool, error) {
+	if s.eventDedupRepo == nil || key == "" {
+		return true, nil
+	}
+	deviceID := event.DeviceID
+	if deviceID == "" {
+		deviceID = event.ClientIDFromIoT
+	}
+	reserved, completed, err := s.eventDedupRepo.Reserve(ctx, key, deviceID, event.MessageType, time.Now().UTC().Add(deviceEventProcessingLease))
+	if err != nil {
+		log.Printf("IoTService: Lỗi dedup store, xử lý sự kiện không qua dedup (key %s): %v", key, err)
+		return true, nil
+	}
+	if !reserved && !completed {
+		return false, ErrDeviceEventInProgress
+	}
+	return reserved, nil
+}
+
+// finishDeviceEvent ghi quyết định xử lý vào device_events_log. Xử lý xong thì gia hạn dedup key tới DeviceEventDedupTTL,
+// lỗi xử lý thì nhả key để lần gửi lại được xử lý.
+func (s *IoTService) finishDeviceEvent(logEntry *domain.DeviceEventLog, status string, notes string) {
+	if s.eventDedupRepo != nil && logEntry.DedupKey != "" {
+		switch status {
+		case domain.EventStatusError:
+			if err := s.eventDedupRepo.Release(context.Background(), logEntry.DedupKey); err != nil {
+				log.Printf("IoTService: Lỗi nhả dedup key %s: %v", logEntry.DedupKey, err)
+			}
+		case domain.EventStatusProcessed, domain.EventStatusStale, domain.EventStatusRejected:
+			expiresAt := time.Now().UTC().Add(s.cfg.DeviceEventDedupTTL)
+			if err := s.eventDedupRepo.Complete(context.Background(), logEntry.DedupKey, expiresAt); err != nil {
+				log.Printf("IoTService: Lỗi gia hạn dedup key %s: %v", logEntry.DedupKey, err)
+			}
+		}
+	}
+	if s.eventLogRepo == nil || logEntry.ID == 0 {
+		return
+	}
+	if err := s.eventLogRepo.UpdateStatus(context.Background(), logEntry.ID, status, notes); err != nil {
+		log.Printf("Lỗi khi cập nhật trạng thái log sự kiện %d (%s): %v", logEntry.ID, status, err)
+	}
+}
+
+// PurgeDeviceEventDedup xóa các dedup key đã hết hạn
+func (s *IoTService) PurgeDeviceEventDedup(ctx context.Context, now time.Time) (int64, error) {
+	if s.eventDedupRepo == nil {
+		return 0, nil
+	}
+	return s.eventDedupRepo.PurgeExpired(ctx, now)
+}
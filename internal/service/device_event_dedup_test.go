@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"smart_parking/internal/domain"
+)
+
+func TestDeviceEventDedupKey(t *testing.T) {
+	event := func(deviceID, clientID, messageType, timestamp, payload string) domain.GenericIoTEvent {
+		return domain.GenericIoTEvent{
+			DeviceID:        deviceID,
+			ClientIDFromIoT: clientID,
+			MessageType:     messageType,
+			Timestamp:       timestamp,
+			RawPayload:      json.RawMessage(payload),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event domain.GenericIoTEvent
+		want  string
+	}{
+		{
+			name:  "gate_event_id_and_timestamp",
+			event: event("esp32-01", "", "gate_event", "2025-03-03T10:00:00Z", `{"event_id":"1234"}`),
+			want:  "esp32-01|gate_event|1234|||||2025-03-03T10:00:00Z",
+		},
+		{
+			name:  "slot_status_uses_slot_id",
+			event: event("esp32-01", "", "slot_status", "2025-03-03T10:00:00Z", `{"slot_id":"A1"}`),
+			want:  "esp32-01|slot_status||A1||||2025-03-03T10:00:00Z",
+		},
+		{
+			name:  "command_ack_uses_request_id",
+			event: event("esp32-01", "", "command_acknowledgement", "", `{"request_id":"exit-42@abc"}`),
+			want:  "esp32-01|command_acknowledgement||||exit-42@abc||",
+		},
+		{
+			name:  "falls_back_to_client_id",
+			event: event("", "esp32-02", "barrier_state", "2025-03-03T10:00:00Z", `{"barrier_id":"B1"}`),
+			want:  "esp32-02|barrier_state|||B1|||2025-03-03T10:00:00Z",
+		},
+		{
+			name:  "timestamp_only",
+			event: event("esp32-01", "", "system_status", "2025-03-03T10:00:00Z", `{}`),
+			want:  "esp32-01|system_status||||||2025-03-03T10:00:00Z",
+		},
+		{
+			name:  "no_identity_no_timestamp",
+			event: event("esp32-01", "", "system_status", "", `{}`),
+			want:  "",
+		},
+		{
+			name:  "no_device",
+			event: event("", "", "gate_event", "2025-03-03T10:00:00Z", `{"event_id":"1234"}`),
+			want:  "",
+		},
+		{
+			name:  "no_message_type",
+			event: event("esp32-01", "", "", "2025-03-03T10:00:00Z", `{"event_id":"1234"}`),
+			want:  "",
+		},
+		{
+			name:  "invalid_payload",
+			event: event("esp32-01", "", "gate_event", "2025-03-03T10:00:00Z", `not json`),
+			want:  "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := deviceEventDedupKey(tc.event); got != tc.want {
+				t.Errorf("deviceEventDedupKey() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeviceEventDedupKeyDistinguishesRestarts(t *testing.T) {
+	// event_id của ESP32 là millis(), lặp lại sau khi khởi động lại; timestamp khác thì key phải khác
+	payload := json.RawMessage(`{"event_id":"1000"}`)
+	before := domain.GenericIoTEvent{DeviceID: "esp32-01", MessageType: "gate_event", Timestamp: "2025-03-03T10:00:00Z", RawPayload: payload}
+	after := before
+	after.Timestamp = "2025-03-03T11:30:00Z"
+
+	if deviceEventDedupKey(before) == deviceEventDedupKey(after) {
+		t.Fatalf("cùng event_id khác timestamp phải cho key khác nhau")
+	}
+}
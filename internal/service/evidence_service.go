@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+var (
+	ErrEvidencePurged    = errors.New("ảnh bằng chứng đã bị xóa theo chính sách lưu giữ")
+	ErrEvidenceCorrupted = errors.New("ảnh bằng chứng không khớp hash đã lưu")
+)
+
+// Số ảnh purge tối đa mỗi lượt truy vấn
+const evidencePurgeBatchSize = 200
+
+type EvidenceService struct {
+	evidenceRepo repository.EvidenceRepository
+	sessionRepo  repository.ParkingSessionRepository
+	store        EvidenceStore
+	retention    time.Duration // <= 0: giữ vĩnh viễn
+}
+
+func NewEvidenceService(evidenceRepo repository.EvidenceRepository, sessionRepo repository.ParkingSessionRepository,
+	store EvidenceStore, retention time.Duration) *EvidenceService {
+	return &EvidenceService{evidenceRepo: evidenceRepo, sessionRepo: sessionRepo, store: store, retention: retention}
+}
+
+// SaveGateImage lưu ảnh camera của gate event vào evidence store kèm hash SHA-256.
+// Cùng một ảnh gửi lại cho cùng gate event thì trả về bản ghi đã có.
+func (s *EvidenceService) SaveGateImage(ctx context.Context, gateEvent *domain.GateEventRecord, cameraID string, data []byte) (*domain.EvidenceImage, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if existing, err := s.evidenceRepo.FindByGateEventAndHash(ctx, gateEvent.EventID, hash); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	capturedAt := time.Now().UTC()
+	contentType := http.DetectContentType(data)
+	key := fmt.Sprintf("lot-%d/%s/%s/%s%s", gateEvent.LotID, capturedAt.Format("2006/01/02"),
+		gateEvent.EventID, hash, evidenceExtension(contentType))
+	if err := s.store.Put(ctx, key, data, contentType); err != nil {
+		return nil, fmt.Errorf("lỗi lưu ảnh bằng chứng: %w", err)
+	}
+
+	evidence := &domain.EvidenceImage{
+		GateEventID:   null.StringFrom(gateEvent.EventID),
+		LotID:         gateEvent.LotID,
+		GateDirection: string(gateEvent.GateDirection),
+		CameraID:      null.NewString(cameraID, cameraID != ""),
+		Backend:       s.store.Name(),
+		StorageKey:    key,
+		SHA256:        hash,
+		ContentType:   contentType,
+		SizeBytes:     int64(len(data)),
+		CapturedAt:    capturedAt,
+	}
+	if gateEvent.SessionID != nil {
+		evidence.SessionID = null.IntFrom(int64(*gateEvent.SessionID))
+	}
+
+	created, err := s.evidenceRepo.Create(ctx, evidence)
+	if errors.Is(err, repository.ErrDuplicateEntry) {
+		// Request song song đã lưu cùng ảnh; file ghi đè cùng key nên không cần dọn
+		return s.evidenceRepo.FindByGateEventAndHash(ctx, gateEvent.EventID, hash)
+	}
+	return created, err
+}
+
+func evidenceExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".bin"
+	}
+}
+
+func (s *EvidenceService) GetEvidence(ctx context.Context, id int) (*domain.EvidenceImage, error) {
+	return s.evidenceRepo.FindByID(ctx, id)
+}
+
+// OpenEvidence đọc file ảnh và kiểm tra lại hash trước khi trả ra, để ảnh dùng cho khiếu nại không bị sửa
+func (s *EvidenceService) OpenEvidence(ctx context.Context, id int) (*domain.EvidenceImage, []byte, error) {
+	evidence, err := s.evidenceRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if evidence.PurgedAt.Valid {
+		return evidence, nil, ErrEvidencePurged
+	}
+
+	data, err := s.store.Get(ctx, evidence.StorageKey)
+	if err != nil {
+		return evidence, nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != evidence.SHA256 {
+		log.Printf("EvidenceService: Ảnh bằng chứng %d (%s) không khớp hash đã lưu", evidence.ID, evidence.StorageKey)
+		return evidence, nil, ErrEvidenceCorrupted
+	}
+	return evidence, data, nil
+}
+
+func (s *EvidenceService) FindByGateEvent(ctx context.Context, gateEventID string) ([]domain.EvidenceImage, error) {
+	return s.evidenceRepo.FindByGateEvent(ctx, gateEventID)
+}
+
+// FindBySession trả về ảnh của phiên, gồm cả ảnh tại gate event vào/ra chụp trước khi phiên được tạo
+func (s *EvidenceService) FindBySession(ctx context.Context, sessionID int) ([]domain.EvidenceImage, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var gateEventIDs []string
+	if session.EntryGateEventID.Valid {
+		gateEventIDs = append(gateEventIDs, session.EntryGateEventID.String)
+	}
+	if session.ExitGateEventID.Valid {
+		gateEventIDs = append(gateEventIDs, session.ExitGateEventID.String)
+	}
+	return s.evidenceRepo.FindBySession(ctx, session.ID, gateEventIDs)
+}
+
+func (s *EvidenceService) SetLegalHold(ctx context.Context, id int, hold bool) (*domain.EvidenceImage, error) {
+	return s.evidenceRepo.SetLegalHold(ctx, id, hold)
+}
+
+// PurgeExpired xóa file của các ảnh quá thời gian lưu giữ (trừ ảnh đang giữ do khiếu nại),
+// giữ lại bản ghi và hash. Trả về số ảnh đã purge.
+func (s *EvidenceService) PurgeExpired(ctx context.Context, now time.Time) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.retention)
+
+	purged := 0
+	for {
+		batch, err := s.evidenceRepo.FindPurgeable(ctx, cutoff, evidencePurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, evidence := range batch {
+			if err := s.store.Delete(ctx, evidence.StorageKey); err != nil {
+				// Dừng lượt này để không lặp lại mãi cùng ảnh lỗi; lượt sau thử lại
+				return purged, fmt.Errorf("lỗi xóa ảnh bằng chứng %d: %w", evidence.ID, err)
+			}
+			if err := s.evidenceRepo.MarkPurged(ctx, evidence.ID, now); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(batch) < evidencePurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
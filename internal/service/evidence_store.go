@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smart_parking/internal/config"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var ErrEvidenceNotFound = errors.New("không tìm thấy ảnh bằng chứng trong evidence store")
+
+// EvidenceStore - Interface lưu trữ file ảnh bằng chứng. EvidenceService chỉ phụ thuộc vào interface này
+// để có thể lưu trên ổ đĩa tại bãi hoặc trên S3 / dịch vụ tương thích S3 (MinIO...).
+type EvidenceStore interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get trả về ErrEvidenceNotFound nếu key không tồn tại
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete không báo lỗi nếu key đã bị xóa
+	Delete(ctx context.Context, key string) error
+}
+
+// NewEvidenceStore chọn evidence store theo cấu hình EVIDENCE_STORE; "none" tắt việc lưu ảnh (trả về nil)
+func NewEvidenceStore(cfg *config.Config, awsCfg aws.Config) (EvidenceStore, error) {
+	switch cfg.EvidenceStore {
+	case "none", "":
+		return nil, nil
+	case "filesystem":
+		return NewFilesystemEvidenceStore(cfg.EvidenceDir)
+	case "s3":
+		return NewS3EvidenceStore(awsCfg, cfg.EvidenceS3Bucket, cfg.EvidenceS3Prefix, cfg.EvidenceS3Endpoint, cfg.EvidenceS3PathStyle)
+	default:
+		return nil, fmt.Errorf("evidence store không được hỗ trợ: %s", cfg.EvidenceStore)
+	}
+}
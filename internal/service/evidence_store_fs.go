@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FilesystemEvidenceStore lưu ảnh bằng chứng dưới thư mục gốc trên ổ đĩa, key là đường dẫn tương đối
+type FilesystemEvidenceStore struct {
+	rootDir string
+}
+
+func NewFilesystemEvidenceStore(rootDir string) (*FilesystemEvidenceStore, error) {
+	if rootDir == "" {
+		return nil, fmt.Errorf("chưa cấu hình thư mục lưu ảnh bằng chứng (EVIDENCE_DIR)")
+	}
+	if err := os.MkdirAll(rootDir, 0o750); err != nil {
+		return nil, fmt.Errorf("không tạo được thư mục lưu ảnh bằng chứng %s: %w", rootDir, err)
+	}
+	return &FilesystemEvidenceStore{rootDir: rootDir}, nil
+}
+
+func (s *FilesystemEvidenceStore) Name() string { return "filesystem" }
+
+// path chuyển key thành đường dẫn tuyệt đối, chặn key thoát ra ngoài thư mục gốc
+func (s *FilesystemEvidenceStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("key ảnh bằng chứng không hợp lệ: %q", key)
+	}
+	return filepath.Join(s.rootDir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *FilesystemEvidenceStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("FilesystemEvidenceStore.Put (mkdir): %w", err)
+	}
+	// Ghi ra file tạm rồi rename để không để lại file ảnh dở dang khi lỗi giữa chừng
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".evidence-*")
+	if err != nil {
+		return fmt.Errorf("FilesystemEvidenceStore.Put (tạo file tạm): %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("FilesystemEvidenceStore.Put (ghi file): %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("FilesystemEvidenceStore.Put (đóng file): %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("FilesystemEvidenceStore.Put (rename): %w", err)
+	}
+	return nil
+}
+
+func (s *FilesystemEvidenceStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrEvidenceNotFound
+		}
+		return nil, fmt.Errorf("FilesystemEvidenceStore.Get: %w", err)
+	}
+	return data, nil
+}
+
+func (s *FilesystemEvidenceStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("FilesystemEvidenceStore.Delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3EvidenceStore lưu ảnh bằng chứng trên S3 hoặc dịch vụ tương thích S3 (MinIO, Ceph...) qua endpoint riêng
+type S3EvidenceStore struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func NewS3EvidenceStore(awsCfg aws.Config, bucket, prefix, endpoint string, usePathStyle bool) (*S3EvidenceStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("chưa cấu hình bucket lưu ảnh bằng chứng (EVIDENCE_S3_BUCKET)")
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = usePathStyle
+	})
+	return &S3EvidenceStore{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+}
+
+func (s *S3EvidenceStore) Name() string { return "s3" }
+
+func (s *S3EvidenceStore) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
+
+func (s *S3EvidenceStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(s.objectKey(key)),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("S3EvidenceStore.Put: %w", err)
+	}
+	return nil
+}
+
+func (s *S3EvidenceStore) Get(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrEvidenceNotFound
+		}
+		return nil, fmt.Errorf("S3EvidenceStore.Get: %w", err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("S3EvidenceStore.Get (đọc body): %w", err)
+	}
+	return data, nil
+}
+
+func (s *S3EvidenceStore) Delete(ctx context.Context, key string) error {
+	// S3 DeleteObject không báo lỗi khi key không tồn tại
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("S3EvidenceStore.Delete: %w", err)
+	}
+	return nil
+}
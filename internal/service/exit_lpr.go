@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+// Số biển số gần giống tối đa gợi ý cho operator khi LPR cổng ra không khớp phiên nào
+const exitCandidateLimit = 5
+
+// processExitLPR xử lý biển số nhận dạng được tại cổng ra: tìm phiên active khớp chính xác biển số
+// (không khớp thì gợi ý biển gần giống cho operator xác nhận), rồi
+// áp dụng pay-before-exit (nếu bãi bật) hoặc kết thúc phiên ngay và báo số tiền cần thu cho operator.
+func (s *IoTService) processExitLPR(ctx context.Context, gateEvent *domain.GateEventRecord, plate string) error {
+	lot, err := s.parkingService.GetParkingLotByID(ctx, gateEvent.LotID)
+	if err != nil {
+		return fmt.Errorf("không tìm thấy bãi đỗ %d: %w", gateEvent.LotID, err)
+	}
+
+	session, err := s.parkingService.findActiveSessionForExit(ctx, gateEvent.LotID, plate)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoActiveSession) || errors.Is(err, repository.ErrNotFound) {
+			return s.suggestExitCandidates(ctx, gateEvent, lot, plate)
+		}
+		return fmt.Errorf("lỗi tìm phiên đỗ xe đang hoạt động: %w", err)
+	}
+
+	if lot.RequirePaymentBeforeExit && s.paymentService != nil {
+		return s.enforceExitPayment(ctx, gateEvent, lot, session, plate)
+	}
+
+	closed, err := s.parkingService.completeSession(ctx, session, time.Now().UTC(), "", gateEvent.EventID)
+	if err != nil {
+		s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusError, err.Error())
+		return err
+	}
+
+	amountDue := closed.CalculatedFee.Float64
+	if s.paymentService != nil {
+		if summary, err := s.paymentService.SyncSessionPaymentStatus(ctx, closed.ID); err != nil {
+			log.Printf("Lỗi kiểm tra thanh toán của phiên %d: %v", closed.ID, err)
+		} else {
+			amountDue = summary.Outstanding
+		}
+	}
+
+	notes := fmt.Sprintf("Đã kết thúc phiên %d tại cổng ra, phí %.2f, cần thu %.2f", closed.ID, closed.CalculatedFee.Float64, amountDue)
+	if err := s.gateEventRepo.UpdateSessionStatus(ctx, gateEvent.EventID, closed.ID, domain.StatusSessionClosed, notes); err != nil {
+		log.Printf("Lỗi cập nhật gate event %s sang session_closed: %v", gateEvent.EventID, err)
+	}
+
+	if s.webSocketManager != nil {
+		s.webSocketManager.BroadcastGateEvent(domain.GateEventNotification{
+			EventID:           gateEvent.EventID,
+			LotID:             gateEvent.LotID,
+			LotName:           lot.Name,
+			DeviceID:          gateEvent.DeviceID,
+			GateDirection:     domain.GateDirectionExit,
+			EventType:         domain.GateEventExitFeeDue,
+			Timestamp:         time.Now(),
+			SensorID:          gateEvent.SensorID,
+			RequiresUserInput: amountDue > 0,
+			Message: fmt.Sprintf("Xe %s ra khỏi bãi %s sau %d phút. Số tiền cần thu: %.0f %s.",
+				plate, lot.Name, closed.DurationMinutes.Int64, amountDue, paymentCurrency),
+			SessionID:     closed.ID,
+			AmountDue:     amountDue,
+			DetectedPlate: plate,
+		})
+	}
+
+	log.Printf("Cổng ra: Đã kết thúc phiên %d cho xe '%s', cần thu %.2f, EventID=%s",
+		closed.ID, plate, amountDue, gateEvent.EventID)
+	return nil
+}
+
+// suggestExitCandidates báo operator danh sách biển số đang active gần giống để chọn lại.
+// Operator gửi lại biển số đúng qua manual_override của LPR trigger.
+func (s *IoTService) suggestExitCandidates(ctx context.Context, gateEvent *domain.GateEventRecord, lot *domain.ParkingLot, plate string) error {
+	candidates, err := s.parkingService.FindSimilarActiveSessions(ctx, gateEvent.LotID, plate, exitCandidateLimit)
+	if err != nil {
+		log.Printf("Lỗi tìm biển số gần giống '%s' tại bãi %d: %v", plate, gateEvent.LotID, err)
+	}
+
+	notes := fmt.Sprintf("Không có phiên active cho xe %s, %d biển số gần giống", plate, len(candidates))
+	if err := s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusAwaitingMatch, notes); err != nil {
+		log.Printf("Lỗi cập nhật gate event %s sang awaiting_match: %v", gateEvent.EventID, err)
+	}
+
+	if s.webSocketManager != nil {
+		message := fmt.Sprintf("Không tìm thấy phiên đỗ của xe %s tại bãi %s. Vui lòng chọn biển số đúng.", plate, lot.Name)
+		if len(candidates) == 0 {
+			message = fmt.Sprintf("Không tìm thấy phiên đỗ của xe %s tại bãi %s. Vui lòng nhập biển số thủ công.", plate, lot.Name)
+		}
+		s.webSocketManager.BroadcastGateEvent(domain.GateEventNotification{
+			EventID:           gateEvent.EventID,
+			LotID:             gateEvent.LotID,
+			LotName:           lot.Name,
+			DeviceID:          gateEvent.DeviceID,
+			GateDirection:     domain.GateDirectionExit,
+			EventType:         domain.GateEventExitNoMatch,
+			Timestamp:         time.Now(),
+			SensorID:          gateEvent.SensorID,
+			RequiresUserInput: true,
+			Message:           message,
+			DetectedPlate:     plate,
+			Candidates:        candidates,
+		})
+	}
+
+	log.Printf("Cổng ra: Không có phiên active cho xe '%s' tại bãi %d, gợi ý %d biển số, EventID=%s",
+		plate, gateEvent.LotID, len(candidates), gateEvent.EventID)
+	return nil
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPaymentOutstanding = errors.New("phiên đỗ xe chưa được thanh toán đủ")
+	ErrExitPaymentHold    = errors.New("rào ra đang được giữ chờ thanh toán")
+)
+
+// enforceExitPayment áp dụng chính sách pay-before-exit cho xe đã nhận dạng biển số tại cổng ra:
+// phiên còn nợ thì giữ rào và báo frontend, đã thanh toán (hoặc phí bằng 0) thì mở rào và kết thúc phiên.
+func (s *IoTService) enforceExitPayment(ctx context.Context, gateEvent *domain.GateEventRecord, lot *domain.ParkingLot,
+	session *domain.ParkingSession, plate string) error {
+	now := time.Now().UTC()
+	paidInGrace := session.ExitDeadline.Valid && !now.After(session.ExitDeadline.Time) &&
+		(session.PaymentStatus == domain.PaymentStatusPaid || session.PaymentStatus == domain.PaymentStatusWaived)
+	if paidInGrace {
+		log.Printf("Phiên %d đã thanh toán, xe ra trong thời gian ân hạn. Mở rào ra.", session.ID)
+		_, err := s.allowExit(ctx, gateEvent, session.ID, lot.Name, session.ExitDeadline.Time)
+		return err
+	}
+
+	// Chưa thanh toán hoặc đã quá hạn ân hạn: tính phí đến hiện tại và đối chiếu với số đã thu
+	session, err := s.parkingService.QuoteExitFee(ctx, session, now)
+	if err != nil {
+		s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusError, err.Error())
+		return err
+	}
+	summary, err := s.paymentService.SyncSessionPaymentStatus(ctx, session.ID)
+	if err != nil {
+		return fmt.Errorf("lỗi kiểm tra thanh toán của phiên %d: %w", session.ID, err)
+	}
+
+	if summary.Outstanding <= 0 {
+		updated, err := s.parkingService.GrantExitWindow(ctx, session.ID, lot.ExitGraceMinutes)
+		if err != nil {
+			return err
+		}
+		_, err = s.allowExit(ctx, gateEvent, session.ID, lot.Name, updated.ExitDeadline.Time)
+		return err
+	}
+
+	// Giữ rào: không gửi lệnh mở, chờ xác nhận thanh toán
+	notes := fmt.Sprintf("Giữ rào ra, chờ thanh toán %.2f cho phiên %d", summary.Outstanding, session.ID)
+	if err := s.gateEventRepo.UpdateSessionStatus(ctx, gateEvent.EventID, session.ID, domain.StatusAwaitingPayment, notes); err != nil {
+		return fmt.Errorf("lỗi cập nhật gate event %s: %w", gateEvent.EventID, err)
+	}
+
+	if s.webSocketManager != nil {
+		s.webSocketManager.BroadcastGateEvent(domain.GateEventNotification{
+			EventID:           gateEvent.EventID,
+			LotID:             gateEvent.LotID,
+			LotName:           lot.Name,
+			DeviceID:          gateEvent.DeviceID,
+			GateDirection:     domain.GateDirectionExit,
+			EventType:         domain.GateEventPaymentRequired,
+			Timestamp:         now,
+			SensorID:          gateEvent.SensorID,
+			RequiresUserInput: true,
+			Message:           fmt.Sprintf("Xe %s cần thanh toán %.0f %s trước khi ra khỏi bãi %s.", plate, summary.Outstanding, paymentCurrency, lot.Name),
+			SessionID:         session.ID,
+			AmountDue:         summary.Outstanding,
+		})
+	}
+
+	log.Printf("Pay-before-exit: Giữ rào ra cho xe '%s' (phiên %d, còn nợ %.2f), EventID=%s",
+		plate, session.ID, summary.Outstanding, gateEvent.EventID)
+	return nil
+}
+
+// ConfirmExitPayment xác nhận phiên đã được thanh toán (hoặc miễn phí), cấp thời gian ân hạn để ra bãi
+// và mở rào ra nếu xe đang bị giữ tại cổng.
+func (s *IoTService) ConfirmExitPayment(ctx context.Context, sessionID int, confirmedBy string) (*domain.ExitPaymentConfirmation, error) {
+	if s.paymentService == nil || s.gateEventRepo == nil {
+		return nil, fmt.Errorf("pay-before-exit chưa được cấu hình")
+	}
+
+	session, err := s.parkingService.GetParkingSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.paymentService.SyncSessionPaymentStatus(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.CalculatedFee.Valid ||
+		(summary.PaymentStatus != domain.PaymentStatusPaid && summary.PaymentStatus != domain.PaymentStatusWaived) {
+		return nil, fmt.Errorf("%w: phiên %d còn nợ %.2f (trạng thái: %s)", ErrPaymentOutstanding, sessionID, summary.Outstanding, summary.PaymentStatus)
+	}
+
+	lot, err := s.parkingService.GetParkingLotByID(ctx, session.LotID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy bãi đỗ %d: %w", session.LotID, err)
+	}
+	updated, err := s.parkingService.GrantExitWindow(ctx, sessionID, lot.ExitGraceMinutes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ExitPaymentConfirmation{
+		SessionID:     sessionID,
+		PaymentStatus: summary.PaymentStatus,
+		ExitDeadline:  updated.ExitDeadline.Time,
+	}
+
+	held, err := s.gateEventRepo.FindLatestBySessionAndStatus(ctx, sessionID, domain.StatusAwaitingPayment)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Xe chưa tới cổng ra: rào sẽ tự mở khi xe tới trong thời gian ân hạn
+			log.Printf("Pay-before-exit: Phiên %d đã thanh toán (xác nhận bởi '%s'), chưa có xe chờ ở cổng ra", sessionID, confirmedBy)
+			return result, nil
+		}
+		return nil, fmt.Errorf("lỗi tìm gate event đang giữ rào: %w", err)
+	}
+
+	requestID, err := s.allowExit(ctx, held, sessionID, lot.Name, result.ExitDeadline)
+	if err != nil {
+		return nil, err
+	}
+	result.GateEventID = held.EventID
+	result.BarrierOpened = true
+	result.RequestID = requestID
+	log.Printf("Pay-before-exit: Phiên %d đã thanh toán (xác nhận bởi '%s'), mở rào ra cho EventID=%s", sessionID, confirmedBy, held.EventID)
+	return result, nil
+}
+
+// FindExitPaymentHolds trả về các gate event đang giữ rào ra của ESP32 chờ thanh toán
+func (s *IoTService) FindExitPaymentHolds(ctx context.Context, esp32ThingName string) ([]domain.GateEventRecord, error) {
+	if s.gateEventRepo == nil {
+		return nil, nil
+	}
+	return s.gateEventRepo.FindByDeviceAndStatus(ctx, esp32ThingName, domain.GateDirectionExit, domain.StatusAwaitingPayment)
+}
+
+// ReleaseExitPaymentHolds ghi nhận operator mở rào ra thủ công khi xe chưa thanh toán
+func (s *IoTService) ReleaseExitPaymentHolds(ctx context.Context, holds []domain.GateEventRecord, operator string, reason string) {
+	for _, hold := range holds {
+		notes := fmt.Sprintf("Mở rào ra thủ công khi chưa thanh toán bởi '%s': %s", operator, reason)
+		if err := s.gateEventRepo.UpdateStatus(ctx, hold.EventID, domain.StatusManualOverride, notes); err != nil {
+			log.Printf("Lỗi cập nhật gate event %s sang manual_override: %v", hold.EventID, err)
+		}
+	}
+}
+
+// confirmSettledExit được PaymentService gọi khi phiên vừa hết nợ: phiên còn active tại bãi bật pay-before-exit
+// được xác nhận như operator bấm confirm-payment (cấp thời gian ân hạn, mở rào nếu xe đang bị giữ).
+func (s *IoTService) confirmSettledExit(ctx context.Context, sessionID int, settledBy string) {
+	session, err := s.parkingService.GetParkingSessionByID(ctx, sessionID)
+	if err != nil {
+		log.Printf("Pay-before-exit: Lỗi lấy phiên %d sau khi thanh toán: %v", sessionID, err)
+		return
+	}
+	if session.Status != domain.SessionActive {
+		return
+	}
+	lot, err := s.parkingService.GetParkingLotByID(ctx, session.LotID)
+	if err != nil {
+		log.Printf("Pay-before-exit: Không tìm thấy bãi đỗ %d của phiên %d: %v", session.LotID, sessionID, err)
+		return
+	}
+	if !lot.RequirePaymentBeforeExit {
+		return
+	}
+	if _, err := s.ConfirmExitPayment(ctx, sessionID, settledBy); err != nil {
+		log.Printf("Pay-before-exit: Lỗi mở rào ra sau khi phiên %d được thanh toán bởi '%s': %v", sessionID, settledBy, err)
+	}
+}
+
+// ExitBarrierRequiresPayment cho biết rào ra của ESP32 thuộc bãi bật pay-before-exit, khi đó mở rào ra thủ công
+// luôn cần force + lý do kể cả khi chưa có xe bị giữ tại cổng.
+func (s *IoTService) ExitBarrierRequiresPayment(ctx context.Context, esp32ThingName string) (bool, error) {
+	barriers, err := s.parkingService.barrierRepo.FindByThingName(ctx, esp32ThingName)
+	if err != nil {
+		return false, err
+	}
+	for _, barrier := range barriers {
+		if barrier.BarrierType != string(domain.GateDirectionExit) {
+			continue
+		}
+		lot, err := s.parkingService.GetParkingLotByID(ctx, barrier.LotID)
+		if err != nil {
+			return false, fmt.Errorf("không tìm thấy bãi đỗ %d: %w", barrier.LotID, err)
+		}
+		if lot.RequirePaymentBeforeExit {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// allowExit gửi lệnh mở rào ra cho gate event và thông báo frontend. RequestID gắn với gate event để dễ truy vết.
+// Phiên chưa được kết thúc ở đây: completeAllowedExits kết thúc phiên khi ESP32 xác nhận lệnh mở hoặc báo xe đã qua rào.
+func (s *IoTService) allowExit(ctx context.Context, gateEvent *domain.GateEventRecord, sessionID int, lotName string, exitDeadline time.Time) (string, error) {
+	requestID := gateRequestID(exitRequestID(gateEvent.EventID))
+	notes := fmt.Sprintf("Đã thanh toán, mở rào ra (hạn chót %s, ReqID=%s)", exitDeadline.Format(time.RFC3339), requestID)
+	if err := s.SendBarrierControlCommand(ctx, gateEvent.DeviceID, string(domain.GateDirectionExit), "open", requestID, ""); err != nil {
+		if !errors.Is(err, ErrCommandRetryScheduled) {
+			s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusError, err.Error())
+			return "", fmt.Errorf("lỗi gửi lệnh mở rào ra: %w", err)
+		}
+		// Lệnh đã được lưu và sẽ tự gửi lại: rào vẫn được coi là đang mở cho xe
+		log.Printf("Lệnh mở rào ra %s chưa gửi được, CommandTracker sẽ gửi lại: %v", requestID, err)
+		notes += ", lệnh đang được gửi lại"
+	}
+
+	if err := s.gateEventRepo.UpdateSessionStatus(ctx, gateEvent.EventID, sessionID, domain.StatusExitAllowed, notes); err != nil {
+		log.Printf("Lỗi cập nhật gate event %s sang exit_allowed: %v", gateEvent.EventID, err)
+	}
+
+	if s.webSocketManager != nil {
+		s.webSocketManager.BroadcastGateEvent(domain.GateEventNotification{
+			EventID:       gateEvent.EventID,
+			LotID:         gateEvent.LotID,
+			LotName:       lotName,
+			DeviceID:      gateEvent.DeviceID,
+			GateDirection: domain.GateDirectionExit,
+			EventType:     domain.GateEventPaymentConfirmed,
+			Timestamp:     time.Now(),
+			SensorID:      gateEvent.SensorID,
+			Message:       fmt.Sprintf("Phiên %d đã thanh toán. Đã gửi lệnh mở rào ra bãi %s.", sessionID, lotName),
+			SessionID:     sessionID,
+			ExitDeadline:  timePtr(exitDeadline),
+		})
+	}
+	return requestID, nil
+}
+
+// exitRequestID - tiền tố RequestID của lệnh mở rào ra cho gate event
+func exitRequestID(eventID string) string {
+	return fmt.Sprintf("exit-%s", eventID)
+}
+
+// gateRequestID thêm hậu tố theo thời điểm gửi vào tiền tố RequestID của gate event: mỗi lần mở lại rào cho cùng
+// gate event (ví dụ xác nhận thanh toán lần hai) là một lệnh mới, không trùng request_id đã lưu.
+func gateRequestID(prefix string) string {
+	return fmt.Sprintf("%s@%s", prefix, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// matchesGateRequestID kiểm tra requestID là lệnh được gửi với tiền tố prefix
+func matchesGateRequestID(requestID string, prefix string) bool {
+	return requestID == prefix || strings.HasPrefix(requestID, prefix+"@")
+}
+
+// completeAllowedExits kết thúc phiên của các gate event exit_allowed của ESP32: khi ESP32 xác nhận lệnh mở rào ra
+// (requestID khác rỗng, chỉ gate event của lệnh đó) hoặc khi cảm biến báo xe đã qua rào ra (requestID rỗng).
+// Phí đã thu được giữ nguyên nếu xe ra trong thời gian ân hạn.
+func (s *IoTService) completeAllowedExits(ctx context.Context, esp32ThingName string, requestID string) error {
+	if s.gateEventRepo == nil {
+		return nil
+	}
+	allowed, err := s.gateEventRepo.FindByDeviceAndStatus(ctx, esp32ThingName, domain.GateDirectionExit, domain.StatusExitAllowed)
+	if err != nil {
+		return fmt.Errorf("lỗi tìm gate event đã mở rào ra: %w", err)
+	}
+	for i := range allowed {
+		gateEvent := &allowed[i]
+		if requestID != "" && !matchesGateRequestID(requestID, exitRequestID(gateEvent.EventID)) {
+			continue
+		}
+		if gateEvent.SessionID == nil {
+			continue
+		}
+		sessionID := *gateEvent.SessionID
+		session, err := s.parkingService.GetParkingSessionByID(ctx, sessionID)
+		if err != nil {
+			log.Printf("Lỗi lấy phiên %d để kết thúc tại cổng ra: %v", sessionID, err)
+			continue
+		}
+		if session.Status == domain.SessionActive {
+			if _, err := s.parkingService.completeSession(ctx, session, time.Now().UTC(), "", gateEvent.EventID); err != nil {
+				log.Printf("Lỗi kết thúc phiên %d tại cổng ra: %v", sessionID, err)
+				continue
+			}
+		}
+		notes := fmt.Sprintf("Xe đã ra, kết thúc phiên %d", sessionID)
+		if err := s.gateEventRepo.UpdateSessionStatus(ctx, gateEvent.EventID, sessionID, domain.StatusSessionClosed, notes); err != nil {
+			log.Printf("Lỗi cập nhật gate event %s sang session_closed: %v", gateEvent.EventID, err)
+		}
+		log.Printf("Pay-before-exit: Đã kết thúc phiên %d tại cổng ra, EventID=%s", sessionID, gateEvent.EventID)
+	}
+	return nil
+}
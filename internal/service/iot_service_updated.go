@@ -4,15 +4,16 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"smart_parking/internal/config"
 	"smart_parking/internal/domain"
 	"smart_parking/internal/repository"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/iotdataplane"
+	"gopkg.in/guregu/null.v4"
 )
 
 // Interface cho WebSocket Manager để tránh circular dependency
@@ -22,22 +23,29 @@ type WebSocketManager interface {
 
 type IoTService struct {
 	parkingService   *ParkingService
-	iotDataClient    *iotdataplane.Client
+	commands         CommandPublisher
 	cfg              *config.Config
 	eventLogRepo     repository.DeviceEventsLogRepository
-	gateEventRepo    repository.GateEventRepository // NEW
-	webSocketManager WebSocketManager               // NEW
+	gateEventRepo    repository.GateEventRepository        // NEW
+	webSocketManager WebSocketManager                      // NEW
+	paymentService   *PaymentService                       // Pay-before-exit tại cổng ra
+	lprAttemptRepo   repository.LPRAttemptRepository       // Các lần nhận dạng từng frame của gate event
+	evidenceService  *EvidenceService                      // Lưu ảnh camera tại cổng làm bằng chứng (nil = tắt)
+	lprFeedback      *LPRFeedbackService                   // Ghi lại biển số operator sửa làm dữ liệu gán nhãn
+	lprThresholds    *LPRThresholdService                  // Ngưỡng confidence theo bãi / camera
+	commandTracker   *CommandTracker                       // Theo dõi xác nhận / thực thi lệnh rào chắn (nil = chỉ publish)
+	eventDedupRepo   repository.DeviceEventDedupRepository // Chống xử lý trùng sự kiện gửi lại (nil = tắt)
 }
 
 func NewIoTService(
 	ps *ParkingService,
-	iotDataClient *iotdataplane.Client,
+	commands CommandPublisher,
 	cfg *config.Config,
 	eventLogRepo repository.DeviceEventsLogRepository,
 ) *IoTService {
 	return &IoTService{
 		parkingService: ps,
-		iotDataClient:  iotDataClient,
+		commands:       commands,
 		cfg:            cfg,
 		eventLogRepo:   eventLogRepo,
 	}
@@ -46,20 +54,39 @@ func NewIoTService(
 // NEW: Constructor với Gate Event support
 func NewIoTServiceUpdated(
 	ps *ParkingService,
-	iotDataClient *iotdataplane.Client,
+	commands CommandPublisher,
 	cfg *config.Config,
 	eventLogRepo repository.DeviceEventsLogRepository,
 	gateEventRepo repository.GateEventRepository,
 	wsManager WebSocketManager,
+	paymentService *PaymentService,
+	lprAttemptRepo repository.LPRAttemptRepository,
+	evidenceService *EvidenceService,
+	lprFeedback *LPRFeedbackService,
+	lprThresholds *LPRThresholdService,
+	commandTracker *CommandTracker,
+	eventDedupRepo repository.DeviceEventDedupRepository,
 ) *IoTService {
-	return &IoTService{
+	s := &IoTService{
 		parkingService:   ps,
-		iotDataClient:    iotDataClient,
+		commands:         commands,
 		cfg:              cfg,
 		eventLogRepo:     eventLogRepo,
 		gateEventRepo:    gateEventRepo,
 		webSocketManager: wsManager,
-	}
+		paymentService:   paymentService,
+		lprAttemptRepo:   lprAttemptRepo,
+		evidenceService:  evidenceService,
+		lprFeedback:      lprFeedback,
+		lprThresholds:    lprThresholds,
+		commandTracker:   commandTracker,
+		eventDedupRepo:   eventDedupRepo,
+	}
+	if paymentService != nil {
+		// Thanh toán qua webhook / tiền mặt / miễn phí làm phiên hết nợ thì mở rào ra nếu xe đang bị giữ
+		paymentService.onSettled = s.confirmSettledExit
+	}
+	return s
 }
 
 func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody string) error {
@@ -72,7 +99,7 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 			logEntry := &domain.DeviceEventLog{
 				ReceivedAt:      time.Now().UTC(),
 				Payload:         json.RawMessage(sqsMessageBody),
-				ProcessedStatus: "error",
+				ProcessedStatus: domain.EventStatusError,
 				ProcessingNotes: fmt.Sprintf("Failed to unmarshal raw payload: %v", err),
 			}
 			s.eventLogRepo.Create(context.Background(), logEntry)
@@ -89,7 +116,7 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 				Esp32ThingName:  genericEvent.ClientIDFromIoT,
 				MqttTopic:       genericEvent.ReceivedMqttTopic,
 				Payload:         rawPayload,
-				ProcessedStatus: "error",
+				ProcessedStatus: domain.EventStatusError,
 				ProcessingNotes: fmt.Sprintf("Failed to unmarshal generic event: %v", err),
 			}
 			s.eventLogRepo.Create(context.Background(), logEntry)
@@ -104,7 +131,8 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 		MqttTopic:       genericEvent.ReceivedMqttTopic,
 		MessageType:     genericEvent.MessageType,
 		Payload:         genericEvent.RawPayload,
-		ProcessedStatus: "pending",
+		ProcessedStatus: domain.EventStatusPending,
+		DedupKey:        deviceEventDedupKey(genericEvent),
 	}
 	if s.eventLogRepo != nil {
 		if err := s.eventLogRepo.Create(context.Background(), logEntry); err != nil {
@@ -112,6 +140,20 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 		}
 	}
 
+	// SQS / MQTT gửi lại ít nhất một lần: sự kiện cùng dedup key đã được xử lý thì chỉ ack,
+	// đang được xử lý thì trả lỗi để nguồn sự kiện gửi lại sau (lần xử lý kia có thể thất bại)
+	reserved, err := s.reserveDeviceEvent(ctx, logEntry.DedupKey, genericEvent)
+	if err != nil {
+		log.Printf("IoTService: Sự kiện '%s' (key %s) đang được xử lý, chờ gửi lại", genericEvent.MessageType, logEntry.DedupKey)
+		s.finishDeviceEvent(logEntry, domain.EventStatusDuplicate, err.Error())
+		return err
+	}
+	if !reserved {
+		log.Printf("IoTService: Bỏ qua sự kiện trùng '%s' (key %s)", genericEvent.MessageType, logEntry.DedupKey)
+		s.finishDeviceEvent(logEntry, domain.EventStatusDuplicate, "Sự kiện trùng dedup key đã được xử lý")
+		return nil
+	}
+
 	var processingError error
 
 	switch genericEvent.MessageType {
@@ -129,6 +171,9 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 		if err := json.Unmarshal(genericEvent.RawPayload, &event); err == nil {
 			event.GenericIoTEvent = genericEvent
 			processingError = s.parkingService.UpdateBarrierStateFromDevice(ctx, event)
+			if processingError == nil && s.commandTracker != nil {
+				processingError = s.commandTracker.HandleBarrierState(ctx, event)
+			}
 		} else {
 			processingError = fmt.Errorf("lỗi unmarshal barrier_state event: %w", err)
 		}
@@ -183,6 +228,14 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 		if err := json.Unmarshal(genericEvent.RawPayload, &event); err == nil {
 			event.GenericIoTEvent = genericEvent
 			processingError = s.parkingService.HandleCommandAck(ctx, event)
+			if processingError == nil && s.commandTracker != nil {
+				processingError = s.commandTracker.HandleAck(ctx, event)
+			}
+			// ESP32 xác nhận lệnh mở rào ra pay-before-exit: xe được ra, kết thúc phiên
+			if processingError == nil && (event.Status == "" || event.Status == "acknowledged") &&
+				strings.HasPrefix(event.RequestID, exitRequestID("")) {
+				processingError = s.completeAllowedExits(ctx, event.DeviceID, event.RequestID)
+			}
 		} else {
 			processingError = fmt.Errorf("lỗi unmarshal command_ack event: %w", err)
 		}
@@ -192,9 +245,21 @@ func (s *IoTService) HandleDeviceEvent(ctx context.Context, sqsMessageBody strin
 		processingError = nil // Không coi là lỗi, chỉ log
 	}
 
-	if processingError != nil {
+	switch {
+	case errors.Is(processingError, ErrInvalidDeviceEvent):
+		log.Printf("IoTService: Bỏ sự kiện '%s' không hợp lệ (Device: %s): %v", genericEvent.MessageType, genericEvent.ClientIDFromIoT, processingError)
+		s.finishDeviceEvent(logEntry, domain.EventStatusRejected, processingError.Error())
+		return nil
+	case errors.Is(processingError, ErrStaleDeviceEvent):
+		log.Printf("IoTService: Bỏ qua sự kiện '%s' cũ (Device: %s): %v", genericEvent.MessageType, genericEvent.ClientIDFromIoT, processingError)
+		s.finishDeviceEvent(logEntry, domain.EventStatusStale, processingError.Error())
+		return nil
+	case processingError != nil:
 		log.Printf("Lỗi khi xử lý sự kiện loại '%s' (Device: %s, Topic: %s): %v",
 			genericEvent.MessageType, genericEvent.ClientIDFromIoT, genericEvent.ReceivedMqttTopic, processingError)
+		s.finishDeviceEvent(logEntry, domain.EventStatusError, processingError.Error())
+	default:
+		s.finishDeviceEvent(logEntry, domain.EventStatusProcessed, "")
 	}
 
 	return processingError
@@ -211,6 +276,13 @@ func (s *IoTService) handleGateEventEnhanced(ctx context.Context, event domain.D
 		log.Printf("Lỗi trong RecordGateSensorEvent: %v", err)
 	}
 
+	// Xe đã qua rào ra: kết thúc phiên đã được mở rào (pay-before-exit) nếu chưa nhận được ack của lệnh mở
+	if event.EventType == "vehicle_passed" && s.determineGateDirection(event) == domain.GateDirectionExit {
+		if err := s.completeAllowedExits(ctx, event.DeviceID, ""); err != nil {
+			log.Printf("Lỗi kết thúc phiên khi xe qua rào ra: %v", err)
+		}
+	}
+
 	// NEW: Enhanced processing với WebSocket notification
 	if s.gateEventRepo != nil && s.webSocketManager != nil {
 		return s.processGateEventWithNotification(ctx, event)
@@ -235,23 +307,21 @@ func (s *IoTService) processGateEventWithNotification(ctx context.Context, event
 
 	var lotID int
 	var lotName string
+	var lot *domain.ParkingLot
 	if device.LotID.Valid {
 		lotID = int(device.LotID.Int64)
-		lot, err := s.parkingService.GetParkingLotByID(ctx, lotID)
-		if err == nil {
-			lotName = lot.Name
-		}
+		lot, _ = s.parkingService.GetParkingLotByID(ctx, lotID)
 	} else {
 		// Fallback: tìm từ barriers
 		barriers, _ := s.parkingService.barrierRepo.FindByThingName(ctx, event.DeviceID)
 		if len(barriers) > 0 {
 			lotID = barriers[0].LotID
-			lot, _ := s.parkingService.GetParkingLotByID(ctx, lotID)
-			if lot != nil {
-				lotName = lot.Name
-			}
+			lot, _ = s.parkingService.GetParkingLotByID(ctx, lotID)
 		}
 	}
+	if lot != nil {
+		lotName = lot.Name
+	}
 
 	if lotID == 0 {
 		return fmt.Errorf("không thể xác định lot_id cho device %s", event.DeviceID)
@@ -332,7 +402,11 @@ func (s *IoTService) mapEventType(event domain.DeviceGateSensorEvent) domain.Gat
 }
 
 func (s *IoTService) requiresLPR(event domain.DeviceGateSensorEvent) bool {
-	return event.IsEntryArea || event.GateArea == "entry_approach"
+	if event.IsEntryArea || event.GateArea == "entry_approach" {
+		return true
+	}
+	// Cổng ra: nhận dạng biển số để kết thúc phiên, trừ sự kiện xe đã qua rào
+	return s.determineGateDirection(event) == domain.GateDirectionExit && event.GateArea != "exit_passed"
 }
 
 func (s *IoTService) requiresUserInput(event domain.DeviceGateSensorEvent) bool {
@@ -343,7 +417,7 @@ func (s *IoTService) generateUserMessage(event domain.DeviceGateSensorEvent, lot
 	if event.IsEntryArea {
 		return fmt.Sprintf("Xe đang đến cổng vào bãi %s. Vui lòng chụp ảnh biển số.", lotName)
 	}
-	return fmt.Sprintf("Xe đang đến cổng ra bãi %s.", lotName)
+	return fmt.Sprintf("Xe đang đến cổng ra bãi %s. Vui lòng chụp ảnh biển số.", lotName)
 }
 
 func (s *IoTService) getSuggestedCameraID(event domain.DeviceGateSensorEvent) string {
@@ -362,6 +436,12 @@ func (s *IoTService) ProcessLPRResult(ctx context.Context, request domain.LPRTri
 	log.Printf("IoTService: Xử lý kết quả LPR cho EventID=%s, Plate=%s, Confidence=%.2f",
 		request.EventID, detectedPlate, confidence)
 
+	// Ghi lại kết quả máy trước khi gate event bị ghi đè bằng biển số operator nhập
+	if request.ManualOverride != "" {
+		s.RecordLPRCorrection(ctx, request.EventID, request.ManualOverride, request.CameraID,
+			domain.LPRCorrectionSourceManualOverride, request.Operator)
+	}
+
 	// Cập nhật gate event record
 	err := s.gateEventRepo.UpdateLPRResult(ctx, request.EventID, detectedPlate, confidence)
 	if err != nil {
@@ -369,14 +449,43 @@ func (s *IoTService) ProcessLPRResult(ctx context.Context, request domain.LPRTri
 	}
 
 	// Tự động tạo session nếu confidence đủ cao hoặc có manual override
-	if confidence >= s.cfg.LPRConfidenceThreshold || request.ManualOverride != "" {
+	threshold := s.LPRConfidenceThreshold(ctx, request.EventID, request.CameraID)
+	if confidence >= threshold || request.ManualOverride != "" {
+		// Biển số bị gắn cờ thì dừng lại chờ operator
+		proceed, err := s.screenGateWatchlist(ctx, request, detectedPlate)
+		if err != nil || !proceed {
+			return err
+		}
 		return s.autoCreateSession(ctx, request.EventID, detectedPlate, request.ManualOverride != "")
 	}
 
-	log.Printf("Confidence thấp (%.2f), chờ manual confirmation", confidence)
+	log.Printf("Confidence thấp (%.2f < %.2f), chờ manual confirmation", confidence, threshold)
 	return nil
 }
 
+// LPRConfidenceThreshold trả về ngưỡng tự tạo phiên cho camera tại bãi của gate event
+func (s *IoTService) LPRConfidenceThreshold(ctx context.Context, eventID string, cameraID string) float32 {
+	if s.lprThresholds == nil || s.gateEventRepo == nil {
+		return s.cfg.LPRConfidenceThreshold
+	}
+	gateEvent, err := s.gateEventRepo.FindByEventID(ctx, eventID)
+	if err != nil {
+		return s.cfg.LPRConfidenceThreshold
+	}
+	return s.lprThresholds.Resolve(ctx, gateEvent.LotID, cameraID)
+}
+
+// RecordLPRCorrection ghi biển số operator nhập làm dữ liệu gán nhãn. Lỗi chỉ được log,
+// không chặn luồng xử lý xe qua cổng.
+func (s *IoTService) RecordLPRCorrection(ctx context.Context, eventID string, correctedPlate string, cameraID string, source string, operator string) {
+	if s.lprFeedback == nil {
+		return
+	}
+	if _, err := s.lprFeedback.RecordCorrection(ctx, eventID, correctedPlate, cameraID, source, operator); err != nil {
+		log.Printf("LPR: Lỗi ghi nhận sửa biển số cho EventID=%s: %v", eventID, err)
+	}
+}
+
 func (s *IoTService) autoCreateSession(ctx context.Context, eventID string, plate string, isManual bool) error {
 	// Lấy gate event record
 	gateEvent, err := s.gateEventRepo.FindByEventID(ctx, eventID)
@@ -384,10 +493,9 @@ func (s *IoTService) autoCreateSession(ctx context.Context, eventID string, plat
 		return fmt.Errorf("không tìm thấy gate event: %w", err)
 	}
 
-	// Chỉ tạo session cho entry events
+	// Chỉ tạo session cho entry events; exit events kết thúc phiên của xe
 	if gateEvent.GateDirection != domain.GateDirectionEntry {
-		log.Printf("Không tạo session cho exit event: %s", eventID)
-		return nil
+		return s.processExitLPR(ctx, gateEvent, plate)
 	}
 
 	// Tạo parking session
@@ -396,6 +504,14 @@ func (s *IoTService) autoCreateSession(ctx context.Context, eventID string, plat
 		Esp32ThingName:    gateEvent.DeviceID,
 		VehicleIdentifier: plate,
 		EntryTime:         time.Now().Format(time.RFC3339),
+		WatchlistScreened: true, // Đã kiểm tra trong ProcessLPRResult
+	}
+	// Loại xe nhận diện từ ảnh dùng để chọn chỗ đỗ và tính phí
+	if vehicle := s.GateEventVehicle(ctx, eventID); vehicle != nil {
+		sessionDTO.VehicleClass = vehicle.VehicleClass
+		sessionDTO.VehicleColor = vehicle.Color
+		sessionDTO.VehicleMake = vehicle.Make
+		sessionDTO.VehicleSource = domain.VehicleSourceLPR
 	}
 
 	session, err := s.parkingService.VehicleCheckIn(ctx, sessionDTO)
@@ -411,29 +527,124 @@ func (s *IoTService) autoCreateSession(ctx context.Context, eventID string, plat
 	}
 
 	log.Printf("Đã tạo parking session ID=%d cho gate event=%s", session.ID, eventID)
+
+	// Mở rào vào: xe có vé tháng hợp lệ luôn được mở, các xe khác theo cấu hình của bãi
+	lot, err := s.parkingService.GetParkingLotByID(ctx, gateEvent.LotID)
+	if err != nil {
+		log.Printf("Không lấy được cấu hình bãi %d, không tự động mở rào vào: %v", gateEvent.LotID, err)
+		return nil
+	}
+	if session.SubscriptionID.Valid || lot.AutoOpenEntryBarrier {
+		return s.openEntryBarrier(ctx, gateEvent, session, lot, plate)
+	}
 	return nil
 }
 
-// Existing barrier control method
-func (s *IoTService) SendBarrierControlCommand(ctx context.Context, esp32ControllerID string, barrierType string, command string, requestID string) error {
-	topic := fmt.Sprintf("smart_parking/command/barriers/%s", barrierType)
+// openEntryBarrier gửi lệnh mở rào vào cho gate event vừa tạo phiên, ghi nhận lệnh lên rào chắn và thông báo frontend.
+// RequestID gắn với gate event để đối chiếu với command ack của ESP32.
+func (s *IoTService) openEntryBarrier(ctx context.Context, gateEvent *domain.GateEventRecord, session *domain.ParkingSession,
+	lot *domain.ParkingLot, plate string) error {
+	barrier, err := s.findGateBarrier(ctx, gateEvent.LotID, gateEvent.DeviceID, gateEvent.GateDirection)
+	if err != nil {
+		// Phiên đã được tạo, operator vẫn có thể mở rào thủ công
+		log.Printf("Không tự động mở rào vào cho gate event %s: %v", gateEvent.EventID, err)
+		return nil
+	}
+
+	requestID := gateRequestID(fmt.Sprintf("entry-%s", gateEvent.EventID))
+	notes := fmt.Sprintf("Tự động mở rào vào %s (ReqID=%s)", barrier.BarrierIdentifier, requestID)
+	if err := s.SendBarrierControlCommand(ctx, barrier.Esp32ThingName, barrier.BarrierType, "open", requestID, ""); err != nil {
+		if !errors.Is(err, ErrCommandRetryScheduled) {
+			s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusError, err.Error())
+			return fmt.Errorf("lỗi gửi lệnh mở rào vào: %w", err)
+		}
+		// Lệnh đã được lưu và sẽ tự gửi lại, không coi là lỗi của gate event
+		log.Printf("Lệnh mở rào vào %s chưa gửi được, CommandTracker sẽ gửi lại: %v", requestID, err)
+		notes += ", lệnh đang được gửi lại"
+	}
+
+	now := time.Now().UTC()
+	if err := s.parkingService.barrierRepo.UpdateState(ctx, barrier.ID, domain.StateOpenedCommand, "open", &now, "lpr_auto_open"); err != nil {
+		log.Printf("Lỗi ghi nhận lệnh mở cho rào chắn %s: %v", barrier.BarrierIdentifier, err)
+	}
+	if err := s.gateEventRepo.UpdateSessionStatus(ctx, gateEvent.EventID, session.ID, domain.StatusSessionCreated, notes); err != nil {
+		log.Printf("Lỗi cập nhật gate event %s: %v", gateEvent.EventID, err)
+	}
+
+	if s.webSocketManager != nil {
+		notification := domain.GateEventNotification{
+			EventID:       gateEvent.EventID,
+			LotID:         gateEvent.LotID,
+			LotName:       lot.Name,
+			DeviceID:      gateEvent.DeviceID,
+			GateDirection: domain.GateDirectionEntry,
+			EventType:     domain.GateEventBarrierOpened,
+			Timestamp:     now,
+			SensorID:      gateEvent.SensorID,
+			Message:       fmt.Sprintf("Đã tạo phiên cho xe %s. Rào vào bãi %s đã mở.", plate, lot.Name),
+			SessionID:     session.ID,
+			DetectedPlate: plate,
+		}
+		if session.SubscriptionID.Valid {
+			notification.EventType = domain.GateEventPassAccepted
+			notification.Message = fmt.Sprintf("Xe %s có vé tháng hợp lệ (vé %d). Rào vào đã mở.", plate, session.SubscriptionID.Int64)
+		}
+		s.webSocketManager.BroadcastGateEvent(notification)
+	}
+
+	log.Printf("Tự động mở rào vào %s cho xe '%s' (phiên %d), ReqID=%s", barrier.BarrierIdentifier, plate, session.ID, requestID)
+	return nil
+}
+
+// findGateBarrier tìm rào chắn theo hướng cổng do ESP32 điều khiển trong bãi
+func (s *IoTService) findGateBarrier(ctx context.Context, lotID int, esp32ThingName string, direction domain.GateDirection) (*domain.Barrier, error) {
+	barriers, err := s.parkingService.barrierRepo.FindByLotIDAndThingName(ctx, lotID, esp32ThingName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range barriers {
+		if barriers[i].BarrierType == string(direction) {
+			return &barriers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: không có rào %s của ESP32 %s tại bãi %d", repository.ErrNotFound, direction, esp32ThingName, lotID)
+}
+
+// SendBarrierControlCommand gửi lệnh rào chắn; có CommandTracker thì lệnh được lưu theo requestID và tự gửi lại
+// tới khi thiết bị xác nhận. requestedBy rỗng = hệ thống tự gửi.
+func (s *IoTService) SendBarrierControlCommand(ctx context.Context, esp32ControllerID string, barrierType string, command string, requestID string, requestedBy string) error {
+	topic, err := barrierCommandTopic(s.cfg.CommandTopicTemplate, esp32ControllerID, barrierType)
+	if err != nil {
+		return err
+	}
 
 	payload := domain.BarrierControlCommandPayload{
-		Command:   command,
-		RequestID: requestID,
+		Command:     command,
+		RequestID:   requestID,
+		BarrierType: barrierType,
 	}
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("lỗi marshal payload lệnh rào chắn: %w", err)
 	}
 
-	log.Printf("IoTService: Đang publish lệnh '%s' (ReqID: %s) tới topic %s cho ESP32 %s", command, requestID, topic, esp32ControllerID)
-	_, err = s.iotDataClient.Publish(ctx, &iotdataplane.PublishInput{
-		Topic:   aws.String(topic),
-		Qos:     1,
-		Payload: payloadBytes,
-	})
-	if err != nil {
+	log.Printf("IoTService: Đang publish lệnh '%s' (ReqID: %s) tới topic %s cho ESP32 %s qua %s", command, requestID, topic, esp32ControllerID, s.commands.Name())
+	if s.commandTracker != nil {
+		tracked := &domain.DeviceCommand{
+			RequestID:      requestID,
+			Esp32ThingName: esp32ControllerID,
+			BarrierType:    barrierType,
+			Command:        command,
+			Topic:          topic,
+			Payload:        payloadBytes,
+		}
+		if requestedBy != "" {
+			tracked.RequestedBy = null.StringFrom(requestedBy)
+		}
+		if err := s.commandTracker.Send(ctx, tracked); err != nil {
+			return err
+		}
+	} else if err := s.commands.Publish(ctx, topic, payloadBytes); err != nil {
 		return fmt.Errorf("lỗi publish lệnh MQTT: %w", err)
 	}
 
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+	"smart_parking/internal/repository"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+var ErrInvalidCorrectionSource = errors.New("nguồn sửa biển số không hợp lệ")
+
+// LPRFeedbackService ghi lại các lần operator sửa / xác nhận biển số làm dữ liệu gán nhãn,
+// tính độ chính xác LPR và xuất bộ dữ liệu để huấn luyện lại
+type LPRFeedbackService struct {
+	correctionRepo  repository.LPRCorrectionRepository
+	lprAttemptRepo  repository.LPRAttemptRepository
+	gateEventRepo   repository.GateEventRepository
+	evidenceService *EvidenceService // nil = không xuất được ảnh
+}
+
+func NewLPRFeedbackService(correctionRepo repository.LPRCorrectionRepository, lprAttemptRepo repository.LPRAttemptRepository,
+	gateEventRepo repository.GateEventRepository, evidenceService *EvidenceService) *LPRFeedbackService {
+	return &LPRFeedbackService{
+		correctionRepo:  correctionRepo,
+		lprAttemptRepo:  lprAttemptRepo,
+		gateEventRepo:   gateEventRepo,
+		evidenceService: evidenceService,
+	}
+}
+
+// RecordCorrection lưu biển số operator nhập cho gate event cùng kết quả máy đã đọc trước đó.
+// Kết quả máy là biển số hợp nhất từ các frame đã lưu; gate event chưa có frame nào thì lấy biển số
+// đang ghi trên gate event, nên phải gọi trước khi gate event bị ghi đè bằng biển số của operator.
+func (s *LPRFeedbackService) RecordCorrection(ctx context.Context, eventID string, correctedPlate string,
+	cameraID string, source string, operator string) (*domain.LPRCorrection, error) {
+	if source != domain.LPRCorrectionSourceManualOverride && source != domain.LPRCorrectionSourceCreateSession {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCorrectionSource, source)
+	}
+	corrected := plate.Canonical(correctedPlate)
+	if corrected == "" {
+		return nil, fmt.Errorf("biển số sửa không được rỗng")
+	}
+
+	gateEvent, err := s.gateEventRepo.FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy gate event: %w", err)
+	}
+	attempts, err := s.lprAttemptRepo.FindByEventID(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lấy các lần nhận dạng của gate event: %w", err)
+	}
+
+	correction := &domain.LPRCorrection{
+		EventID:        null.StringFrom(eventID),
+		LotID:          gateEvent.LotID,
+		CameraID:       null.NewString(cameraID, cameraID != ""),
+		CorrectedPlate: corrected,
+		Source:         source,
+		Operator:       null.NewString(operator, operator != ""),
+	}
+
+	var bests []domain.LPRCandidate
+	for _, attempt := range attempts {
+		if attempt.DetectedPlate.Valid && len(attempt.Candidates) > 0 {
+			bests = append(bests, attempt.Candidates[0])
+		}
+	}
+	if fused := fuseLPRCandidates(bests, nil); fused != nil {
+		correction.MachinePlate = null.StringFrom(fused.Plate)
+		correction.MachineConfidence = null.FloatFrom(float64(fused.Confidence))
+	} else if gateEvent.DetectedPlate != "" && plate.Canonical(gateEvent.DetectedPlate) != "" {
+		correction.MachinePlate = null.StringFrom(plate.Canonical(gateEvent.DetectedPlate))
+		if gateEvent.LPRConfidence != nil {
+			correction.MachineConfidence = null.FloatFrom(float64(*gateEvent.LPRConfidence))
+		}
+	}
+	correction.IsCorrect = correction.MachinePlate.Valid && correction.MachinePlate.String == corrected
+
+	// Ảnh gán nhãn: frame đọc ra đúng biển số máy chọn với confidence cao nhất, không có thì frame đầu có ảnh
+	if attempt := labelAttempt(attempts, correction.MachinePlate.String); attempt != nil {
+		correction.EvidenceID = attempt.EvidenceID
+		if !correction.CameraID.Valid {
+			correction.CameraID = attempt.CameraID
+		}
+	}
+
+	created, err := s.correctionRepo.Create(ctx, correction)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lưu lần sửa biển số: %w", err)
+	}
+	log.Printf("LPR: Ghi nhận sửa biển số EventID=%s: máy '%s' -> operator '%s' (đúng=%t)",
+		eventID, created.MachinePlate.String, created.CorrectedPlate, created.IsCorrect)
+	return created, nil
+}
+
+func labelAttempt(attempts []domain.LPRAttempt, machinePlate string) *domain.LPRAttempt {
+	var best, first *domain.LPRAttempt
+	for i := range attempts {
+		attempt := &attempts[i]
+		if !attempt.EvidenceID.Valid {
+			continue
+		}
+		if first == nil {
+			first = attempt
+		}
+		if machinePlate != "" && attempt.DetectedPlate.String == machinePlate &&
+			(best == nil || attempt.Confidence.Float64 > best.Confidence.Float64) {
+			best = attempt
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return first
+}
+
+func (s *LPRFeedbackService) FindCorrections(ctx context.Context, filter domain.LPRCorrectionFilterDTO) ([]domain.LPRCorrection, error) {
+	return s.correctionRepo.Find(ctx, filter)
+}
+
+// AccuracyReport tính độ chính xác theo bãi, theo camera và thống kê cặp ký tự hay đọc nhầm
+func (s *LPRFeedbackService) AccuracyReport(ctx context.Context, filter domain.LPRCorrectionFilterDTO) (*domain.LPRAccuracyReport, error) {
+	corrections, err := s.correctionRepo.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	overall := &lprAccuracyAccumulator{}
+	byLot := make(map[int]*lprAccuracyAccumulator)
+	byCamera := make(map[string]*lprAccuracyAccumulator)
+	confusions := make(map[plate.Edit]int)
+	for _, c := range corrections {
+		overall.add(c)
+		if byLot[c.LotID] == nil {
+			byLot[c.LotID] = &lprAccuracyAccumulator{}
+		}
+		byLot[c.LotID].add(c)
+		if c.CameraID.Valid {
+			if byCamera[c.CameraID.String] == nil {
+				byCamera[c.CameraID.String] = &lprAccuracyAccumulator{}
+			}
+			byCamera[c.CameraID.String].add(c)
+		}
+		// Không đọc được thì không có ký tự nào để so
+		if c.MachinePlate.Valid && !c.IsCorrect {
+			for _, edit := range plate.Edits(c.CorrectedPlate, c.MachinePlate.String) {
+				confusions[edit]++
+			}
+		}
+	}
+
+	report := &domain.LPRAccuracyReport{
+		Overall:    overall.stats(),
+		ByLot:      []domain.LPRLotAccuracy{},
+		ByCamera:   []domain.LPRCameraAccuracy{},
+		Confusions: []domain.LPRConfusionPair{},
+	}
+	for lotID, acc := range byLot {
+		report.ByLot = append(report.ByLot, domain.LPRLotAccuracy{LotID: lotID, LPRAccuracyStats: acc.stats()})
+	}
+	sort.Slice(report.ByLot, func(i, j int) bool { return report.ByLot[i].LotID < report.ByLot[j].LotID })
+	for cameraID, acc := range byCamera {
+		report.ByCamera = append(report.ByCamera, domain.LPRCameraAccuracy{CameraID: cameraID, LPRAccuracyStats: acc.stats()})
+	}
+	sort.Slice(report.ByCamera, func(i, j int) bool { return report.ByCamera[i].CameraID < report.ByCamera[j].CameraID })
+	for edit, count := range confusions {
+		report.Confusions = append(report.Confusions, domain.LPRConfusionPair{Expected: edit.Expected, Read: edit.Read, Count: count})
+	}
+	sort.Slice(report.Confusions, func(i, j int) bool {
+		a, b := report.Confusions[i], report.Confusions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Expected != b.Expected {
+			return a.Expected < b.Expected
+		}
+		return a.Read < b.Read
+	})
+	return report, nil
+}
+
+type lprAccuracyAccumulator struct {
+	total, correct, noRead                 int
+	correctConfidence, incorrectConfidence float64
+	correctScored, incorrectScored         int
+}
+
+func (a *lprAccuracyAccumulator) add(c domain.LPRCorrection) {
+	a.total++
+	if !c.MachinePlate.Valid {
+		a.noRead++
+		return
+	}
+	if c.IsCorrect {
+		a.correct++
+		if c.MachineConfidence.Valid {
+			a.correctConfidence += c.MachineConfidence.Float64
+			a.correctScored++
+		}
+		return
+	}
+	if c.MachineConfidence.Valid {
+		a.incorrectConfidence += c.MachineConfidence.Float64
+		a.incorrectScored++
+	}
+}
+
+func (a *lprAccuracyAccumulator) stats() domain.LPRAccuracyStats {
+	stats := domain.LPRAccuracyStats{Total: a.total, Correct: a.correct, NoRead: a.noRead}
+	if a.total > 0 {
+		stats.Accuracy = float64(a.correct) / float64(a.total)
+	}
+	if a.correctScored > 0 {
+		stats.MeanConfidenceCorrect = a.correctConfidence / float64(a.correctScored)
+	}
+	if a.incorrectScored > 0 {
+		stats.MeanConfidenceIncorrect = a.incorrectConfidence / float64(a.incorrectScored)
+	}
+	return stats
+}
+
+// ExportDataset ghi bộ dữ liệu gán nhãn dạng zip: ảnh trong images/ và labels.csv.
+// Lần sửa không có ảnh, ảnh đã purge hoặc sai hash bị bỏ qua. Trả về số mẫu đã xuất và số mẫu bỏ qua.
+func (s *LPRFeedbackService) ExportDataset(ctx context.Context, corrections []domain.LPRCorrection, w io.Writer) (int, int, error) {
+	archive := zip.NewWriter(w)
+	var labels strings.Builder
+	labelWriter := csv.NewWriter(&labels)
+	_ = labelWriter.Write([]string{"correction_id", "image", "label", "machine_plate", "machine_confidence",
+		"is_correct", "lot_id", "camera_id", "sha256", "source", "created_at"})
+
+	exported, skipped := 0, 0
+	for _, c := range corrections {
+		if s.evidenceService == nil || !c.EvidenceID.Valid {
+			skipped++
+			continue
+		}
+		evidence, data, err := s.evidenceService.OpenEvidence(ctx, int(c.EvidenceID.Int64))
+		if err != nil {
+			if errors.Is(err, ErrEvidencePurged) || errors.Is(err, ErrEvidenceCorrupted) ||
+				errors.Is(err, ErrEvidenceNotFound) || errors.Is(err, repository.ErrNotFound) {
+				skipped++
+				continue
+			}
+			return exported, skipped, fmt.Errorf("lỗi đọc ảnh bằng chứng %d: %w", c.EvidenceID.Int64, err)
+		}
+
+		name := fmt.Sprintf("images/%d%s", c.ID, evidenceExtension(evidence.ContentType))
+		header := &zip.FileHeader{Name: name, Method: zip.Store, Modified: evidence.CapturedAt} // Ảnh đã nén sẵn
+		fw, err := archive.CreateHeader(header)
+		if err != nil {
+			return exported, skipped, fmt.Errorf("lỗi ghi archive: %w", err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			return exported, skipped, fmt.Errorf("lỗi ghi archive: %w", err)
+		}
+
+		confidence := ""
+		if c.MachineConfidence.Valid {
+			confidence = strconv.FormatFloat(c.MachineConfidence.Float64, 'f', 4, 64)
+		}
+		_ = labelWriter.Write([]string{strconv.Itoa(c.ID), name, c.CorrectedPlate, c.MachinePlate.String, confidence,
+			strconv.FormatBool(c.IsCorrect), strconv.Itoa(c.LotID), c.CameraID.String, evidence.SHA256, c.Source,
+			c.CreatedAt.Format(time.RFC3339)})
+		exported++
+	}
+	labelWriter.Flush()
+
+	fw, err := archive.Create("labels.csv")
+	if err != nil {
+		return exported, skipped, fmt.Errorf("lỗi ghi archive: %w", err)
+	}
+	if _, err := io.WriteString(fw, labels.String()); err != nil {
+		return exported, skipped, fmt.Errorf("lỗi ghi archive: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return exported, skipped, fmt.Errorf("lỗi ghi archive: %w", err)
+	}
+	return exported, skipped, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"smart_parking/internal/domain"
+)
+
+// Khóa fixture dùng cho mọi ảnh không có trong file
+const lprFixtureDefaultKey = "default"
+
+// LocalLPRProvider - LPR offline dựa trên file fixture, dùng khi chạy tại bãi không có AWS hoặc khi kiểm thử.
+// File fixture là JSON map từ SHA-256 (hex) của ảnh sang danh sách ứng viên, ví dụ:
+//
+//	{
+//	  "3a7bd3e2...": [{"plate": "29A12345", "confidence": 0.97, "bounding_box": {"left": 0.3, "top": 0.6, "width": 0.4, "height": 0.1}}],
+//	  "default": []
+//	}
+//
+// File fixture thuộc tính xe (tùy chọn) cùng cấu trúc khóa, giá trị là thuộc tính xe:
+//
+//	{"3a7bd3e2...": {"vehicle_class": "car", "class_confidence": 0.93, "color": "white"}}
+type LocalLPRProvider struct {
+	fixtures        map[string][]domain.LPRCandidate
+	vehicleFixtures map[string]domain.VehicleAttributes
+	maxCandidates   int
+}
+
+func NewLocalLPRProvider(fixturesPath, vehicleFixturesPath string, maxCandidates int) (*LocalLPRProvider, error) {
+	provider := &LocalLPRProvider{
+		fixtures:        make(map[string][]domain.LPRCandidate),
+		vehicleFixtures: make(map[string]domain.VehicleAttributes),
+		maxCandidates:   maxCandidates,
+	}
+	if vehicleFixturesPath != "" {
+		data, err := os.ReadFile(vehicleFixturesPath)
+		if err != nil {
+			return nil, fmt.Errorf("không đọc được file fixture thuộc tính xe %s: %w", vehicleFixturesPath, err)
+		}
+		if err := json.Unmarshal(data, &provider.vehicleFixtures); err != nil {
+			return nil, fmt.Errorf("file fixture thuộc tính xe %s không hợp lệ: %w", vehicleFixturesPath, err)
+		}
+		log.Printf("LocalLPRProvider: Đã nạp %d fixture thuộc tính xe từ %s", len(provider.vehicleFixtures), vehicleFixturesPath)
+	}
+	if fixturesPath == "" {
+		log.Println("LocalLPRProvider: Không có file fixture (LPR_LOCAL_FIXTURES), mọi ảnh sẽ không nhận dạng được biển số")
+		return provider, nil
+	}
+
+	data, err := os.ReadFile(fixturesPath)
+	if err != nil {
+		return nil, fmt.Errorf("không đọc được file fixture LPR %s: %w", fixturesPath, err)
+	}
+	if err := json.Unmarshal(data, &provider.fixtures); err != nil {
+		return nil, fmt.Errorf("file fixture LPR %s không hợp lệ: %w", fixturesPath, err)
+	}
+	log.Printf("LocalLPRProvider: Đã nạp %d fixture từ %s", len(provider.fixtures), fixturesPath)
+	return provider, nil
+}
+
+func (p *LocalLPRProvider) Name() string { return "local" }
+
+func (p *LocalLPRProvider) Recognize(ctx context.Context, imageBytes []byte) (*domain.LPRResult, error) {
+	sum := sha256.Sum256(imageBytes)
+	imageHash := hex.EncodeToString(sum[:])
+
+	fixture, ok := p.fixtures[imageHash]
+	if !ok {
+		fixture = p.fixtures[lprFixtureDefaultKey]
+	}
+
+	result := &domain.LPRResult{Provider: p.Name()}
+	var candidates []domain.LPRCandidate
+	for _, c := range fixture {
+		result.RawTexts = append(result.RawTexts, c.Plate)
+		matched := newLPRCandidates(c.Plate, c.Confidence, fixtureCharConfidences(c), c.BoundingBox)
+		candidates = append(candidates, matched...)
+	}
+
+	result.Candidates = sortLPRCandidates(candidates, p.maxCandidates)
+	if best := result.Best(); best != nil {
+		log.Printf("LocalLPRProvider: Ảnh %s… nhận dạng '%s' (%s, %.2f)", imageHash[:12], best.Display, best.Grammar, best.Confidence)
+		return result, nil
+	}
+	return result, fmt.Errorf("%w (ảnh %s không có fixture phù hợp)", ErrNoPlateDetected, imageHash)
+}
+
+// ClassifyVehicle trả về thuộc tính xe từ fixture; ảnh không có fixture thì không nhận diện được gì
+func (p *LocalLPRProvider) ClassifyVehicle(ctx context.Context, imageBytes []byte) (*domain.VehicleAttributes, error) {
+	sum := sha256.Sum256(imageBytes)
+	attrs, ok := p.vehicleFixtures[hex.EncodeToString(sum[:])]
+	if !ok {
+		attrs = p.vehicleFixtures[lprFixtureDefaultKey]
+	}
+	return &attrs, nil
+}
+
+// fixtureCharConfidences lấy confidence từng ký tự nếu fixture khai báo sẵn
+func fixtureCharConfidences(c domain.LPRCandidate) []float32 {
+	var confidences []float32
+	for _, ch := range c.Characters {
+		confidences = append(confidences, ch.Confidence)
+	}
+	return confidences
+}
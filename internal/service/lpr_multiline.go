@@ -0,0 +1,103 @@
+package service
+
+import (
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+)
+
+// Ngưỡng hình học để coi hai dòng văn bản là hai dòng của cùng một biển số (biển xe máy, biển ô tô vuông).
+// Tọa độ Rekognition tính theo tỉ lệ ảnh, gốc ở góc trên bên trái.
+const (
+	// Khoảng cách dọc tối đa giữa đáy dòng trên và đỉnh dòng dưới, tính theo chiều cao dòng
+	stackedLineMaxGap = 1.0
+	// Cho phép hai dòng chồng lấn theo chiều dọc tối đa nửa chiều cao dòng
+	stackedLineMaxOverlap = 0.5
+	// Tỉ lệ chiều cao hai dòng phải nằm trong khoảng [1/ratio, ratio]
+	stackedLineMaxHeightRatio = 2.0
+	// Phần chồng lấn theo chiều ngang tối thiểu so với dòng hẹp hơn
+	stackedLineMinHorizontalOverlap = 0.5
+)
+
+// ocrLine - Một dòng văn bản OCR kèm vị trí, không phụ thuộc provider
+type ocrLine struct {
+	Text       string
+	Confidence float32
+	Box        domain.BoundingBox
+}
+
+// stackedLineCandidates ghép từng cặp dòng xếp chồng theo chiều dọc thành ứng viên biển hai dòng.
+// Confidence của ứng viên ghép là confidence thấp hơn của hai dòng, khung bao là hợp của hai khung.
+func stackedLineCandidates(lines []ocrLine) []domain.LPRCandidate {
+	var candidates []domain.LPRCandidate
+	for i := range lines {
+		for j := range lines {
+			if i == j || !isStackedBelow(lines[i].Box, lines[j].Box) {
+				continue
+			}
+			upper, lower := lines[i], lines[j]
+			confidence := upper.Confidence
+			if lower.Confidence < confidence {
+				confidence = lower.Confidence
+			}
+
+			charConfidences := make([]float32, 0, len(upper.Text)+len(lower.Text))
+			for range plate.Canonical(upper.Text) {
+				charConfidences = append(charConfidences, upper.Confidence)
+			}
+			for range plate.Canonical(lower.Text) {
+				charConfidences = append(charConfidences, lower.Confidence)
+			}
+
+			box := unionBoundingBox(upper.Box, lower.Box)
+			candidates = append(candidates, newLPRCandidates(upper.Text+" "+lower.Text, confidence, charConfidences, &box)...)
+		}
+	}
+	return candidates
+}
+
+// isStackedBelow cho biết lower có nằm ngay dưới upper, cùng cột và cùng cỡ chữ hay không
+func isStackedBelow(upper, lower domain.BoundingBox) bool {
+	if upper.Height <= 0 || lower.Height <= 0 || lower.Top <= upper.Top {
+		return false
+	}
+
+	lineHeight := upper.Height
+	if lower.Height > lineHeight {
+		lineHeight = lower.Height
+	}
+	heightRatio := upper.Height / lower.Height
+	if heightRatio > stackedLineMaxHeightRatio || heightRatio < 1/stackedLineMaxHeightRatio {
+		return false
+	}
+
+	gap := lower.Top - (upper.Top + upper.Height)
+	if gap > stackedLineMaxGap*lineHeight || gap < -stackedLineMaxOverlap*lineHeight {
+		return false
+	}
+
+	overlap := minFloat32(upper.Left+upper.Width, lower.Left+lower.Width) - maxFloat32(upper.Left, lower.Left)
+	narrower := minFloat32(upper.Width, lower.Width)
+	return narrower > 0 && overlap >= stackedLineMinHorizontalOverlap*narrower
+}
+
+func unionBoundingBox(a, b domain.BoundingBox) domain.BoundingBox {
+	left := minFloat32(a.Left, b.Left)
+	top := minFloat32(a.Top, b.Top)
+	right := maxFloat32(a.Left+a.Width, b.Left+b.Width)
+	bottom := maxFloat32(a.Top+a.Height, b.Top+b.Height)
+	return domain.BoundingBox{Left: left, Top: top, Width: right - left, Height: bottom - top}
+}
+
+func minFloat32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smart_parking/internal/config"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+)
+
+var ErrNoPlateDetected = errors.New("không nhận dạng được biển số từ ảnh")
+
+// LPRProvider - Interface cho bộ nhận dạng biển số. Handler chỉ phụ thuộc vào interface này
+// để có thể chạy LPR trên AWS Rekognition hoặc offline tại bãi mà không sửa logic nghiệp vụ.
+type LPRProvider interface {
+	Name() string
+	// Recognize trả về các biển số ứng viên (confidence 0..1, giảm dần) theo mọi grammar đã đăng ký;
+	// việc lọc theo định dạng bãi chấp nhận do service làm. Trả về ErrNoPlateDetected nếu ảnh
+	// không có văn bản nào giống biển số.
+	Recognize(ctx context.Context, imageBytes []byte) (*domain.LPRResult, error)
+}
+
+// NewLPRProvider chọn LPR provider theo cấu hình LPR_PROVIDER
+func NewLPRProvider(cfg *config.Config, rekClient *rekognition.Client) (LPRProvider, error) {
+	switch cfg.LPRProvider {
+	case "rekognition":
+		return NewRekognitionLPRProvider(rekClient, cfg.LPRMaxCandidates), nil
+	case "local":
+		return NewLocalLPRProvider(cfg.LPRLocalFixturesPath, cfg.LPRLocalVehicleFixturesPath, cfg.LPRMaxCandidates)
+	default:
+		return nil, fmt.Errorf("LPR provider không được hỗ trợ: %s", cfg.LPRProvider)
+	}
+}
+
+// newLPRCandidates tạo ứng viên cho mỗi grammar khớp với văn bản OCR. Điểm cộng mã tỉnh/checksum
+// được cộng vào confidence; mỗi ký tự nhận confidence riêng nếu provider có, ngược lại dùng
+// confidence của cả khối văn bản.
+func newLPRCandidates(rawText string, confidence float32, charConfidences []float32, bbox *domain.BoundingBox) []domain.LPRCandidate {
+	var candidates []domain.LPRCandidate
+	for _, match := range plate.ParseAll(rawText, nil) {
+		boosted := confidence + match.Bonus
+		if boosted > 1 {
+			boosted = 1
+		}
+		candidate := domain.LPRCandidate{
+			Plate:       match.Canonical,
+			Display:     match.Display,
+			Grammar:     match.Grammar,
+			RegionValid: match.RegionValid,
+			Confidence:  boosted,
+			BoundingBox: bbox,
+			RawText:     rawText,
+		}
+		for i, r := range []rune(match.Canonical) {
+			charConfidence := confidence
+			if i < len(charConfidences) {
+				charConfidence = charConfidences[i]
+			}
+			candidate.Characters = append(candidate.Characters, domain.LPRCharacter{Char: string(r), Confidence: charConfidence})
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// sortLPRCandidates gộp các ứng viên trùng biển số và grammar (giữ confidence cao nhất), xếp giảm dần
+// theo confidence và giữ tối đa limit ứng viên. Cùng confidence thì giữ thứ tự đăng ký grammar.
+func sortLPRCandidates(candidates []domain.LPRCandidate, limit int) []domain.LPRCandidate {
+	best := make(map[string]int)
+	var unique []domain.LPRCandidate
+	for _, candidate := range candidates {
+		key := candidate.Grammar + ":" + candidate.Plate
+		if idx, ok := best[key]; ok {
+			if candidate.Confidence > unique[idx].Confidence {
+				unique[idx] = candidate
+			}
+			continue
+		}
+		best[key] = len(unique)
+		unique = append(unique, candidate)
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return unique[i].Confidence > unique[j].Confidence
+	})
+	if limit > 0 && len(unique) > limit {
+		unique = unique[:limit]
+	}
+	return unique
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition"
+	"github.com/aws/aws-sdk-go-v2/service/rekognition/types"
+)
+
+// RekognitionLPRProvider nhận dạng biển số bằng AWS Rekognition DetectText
+type RekognitionLPRProvider struct {
+	rekognitionClient *rekognition.Client
+	maxCandidates     int // Số ứng viên tối đa trả về, <= 0 = không giới hạn
+}
+
+func NewRekognitionLPRProvider(rekClient *rekognition.Client, maxCandidates int) *RekognitionLPRProvider {
+	return &RekognitionLPRProvider{rekognitionClient: rekClient, maxCandidates: maxCandidates}
+}
+
+func (p *RekognitionLPRProvider) Name() string { return "rekognition" }
+
+// Recognize nhận ảnh dưới dạng bytes, gọi Rekognition và trích xuất các biển số ứng viên.
+// Mỗi dòng/từ được thử riêng, sau đó các dòng xếp chồng được ghép lại cho biển hai dòng.
+func (p *RekognitionLPRProvider) Recognize(ctx context.Context, imageBytes []byte) (*domain.LPRResult, error) {
+	if p.rekognitionClient == nil {
+		return nil, fmt.Errorf("Rekognition client chưa được khởi tạo")
+	}
+
+	input := &rekognition.DetectTextInput{
+		Image: &types.Image{
+			Bytes: imageBytes,
+		},
+	}
+
+	log.Println("RekognitionLPRProvider: Đang gọi Rekognition DetectText...")
+	output, err := p.rekognitionClient.DetectText(ctx, input)
+	if err != nil {
+		log.Printf("RekognitionLPRProvider: Lỗi khi gọi Rekognition DetectText: %v", err)
+		return nil, fmt.Errorf("lỗi Rekognition: %w", err)
+	}
+
+	log.Printf("RekognitionLPRProvider: Rekognition trả về %d khối văn bản.", len(output.TextDetections))
+	result := &domain.LPRResult{Provider: p.Name()}
+	var candidates []domain.LPRCandidate
+	var lines []ocrLine
+
+	for _, textDetection := range output.TextDetections {
+		if textDetection.Type != types.TextTypesLine && textDetection.Type != types.TextTypesWord {
+			continue
+		}
+		if textDetection.DetectedText == nil || textDetection.Confidence == nil {
+			continue
+		}
+		// Rekognition trả confidence 0..100, chuẩn hóa về 0..1 để so với LPR_CONFIDENCE_THRESHOLD
+		confidence := *textDetection.Confidence / 100
+
+		log.Printf("RekognitionLPRProvider: Text: '%s', Confidence: %.2f", *textDetection.DetectedText, confidence)
+		result.RawTexts = append(result.RawTexts, *textDetection.DetectedText)
+
+		// DetectText không có confidence từng ký tự, dùng confidence của cả khối
+		bbox := rekognitionBoundingBox(textDetection.Geometry)
+		candidates = append(candidates, newLPRCandidates(*textDetection.DetectedText, confidence, nil, bbox)...)
+		if textDetection.Type == types.TextTypesLine && bbox != nil {
+			lines = append(lines, ocrLine{Text: *textDetection.DetectedText, Confidence: confidence, Box: *bbox})
+		}
+	}
+	candidates = append(candidates, stackedLineCandidates(lines)...)
+
+	result.Candidates = sortLPRCandidates(candidates, p.maxCandidates)
+	if best := result.Best(); best != nil {
+		log.Printf("RekognitionLPRProvider: Biển số được chọn: '%s' (%s) với độ tin cậy: %.2f (%d ứng viên)",
+			best.Display, best.Grammar, best.Confidence, len(result.Candidates))
+		return result, nil
+	}
+
+	log.Printf("RekognitionLPRProvider: Không có văn bản nào khớp định dạng biển số. Tất cả văn bản nhận dạng: %s", strings.Join(result.RawTexts, ", "))
+	return result, fmt.Errorf("%w (Văn bản: %s)", ErrNoPlateDetected, strings.Join(result.RawTexts, ", "))
+}
+
+// Ngưỡng confidence (0..100) của nhãn DetectLabels khi nhận diện loại xe
+const rekognitionVehicleMinConfidence = 60
+
+// ClassifyVehicle nhận diện loại xe và màu xe bằng Rekognition DetectLabels. Hãng xe DetectLabels không trả về.
+func (p *RekognitionLPRProvider) ClassifyVehicle(ctx context.Context, imageBytes []byte) (*domain.VehicleAttributes, error) {
+	if p.rekognitionClient == nil {
+		return nil, fmt.Errorf("Rekognition client chưa được khởi tạo")
+	}
+
+	output, err := p.rekognitionClient.DetectLabels(ctx, &rekognition.DetectLabelsInput{
+		Image:         &types.Image{Bytes: imageBytes},
+		Features:      []types.DetectLabelsFeatureName{types.DetectLabelsFeatureNameGeneralLabels, types.DetectLabelsFeatureNameImageProperties},
+		MinConfidence: aws.Float32(rekognitionVehicleMinConfidence),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lỗi Rekognition DetectLabels: %w", err)
+	}
+
+	attrs := &domain.VehicleAttributes{}
+	var best *types.Label
+	for i, label := range output.Labels {
+		if label.Name == nil || label.Confidence == nil {
+			continue
+		}
+		class, ok := rekognitionVehicleLabels[*label.Name]
+		if !ok {
+			continue
+		}
+		if best == nil || *label.Confidence > *best.Confidence {
+			best = &output.Labels[i]
+			attrs.VehicleClass = class
+			attrs.ClassConfidence = *label.Confidence / 100
+		}
+	}
+	if best != nil {
+		attrs.Color = rekognitionVehicleColor(best.Instances)
+		log.Printf("RekognitionLPRProvider: Nhận diện xe '%s' -> %s (%.2f), màu '%s'", *best.Name, attrs.VehicleClass, attrs.ClassConfidence, attrs.Color)
+	}
+	return attrs, nil
+}
+
+// rekognitionVehicleColor lấy màu đơn giản chiếm nhiều điểm ảnh nhất của instance có confidence cao nhất
+func rekognitionVehicleColor(instances []types.Instance) string {
+	var instance *types.Instance
+	for i := range instances {
+		if instances[i].Confidence == nil {
+			continue
+		}
+		if instance == nil || *instances[i].Confidence > *instance.Confidence {
+			instance = &instances[i]
+		}
+	}
+	if instance == nil {
+		return ""
+	}
+
+	color, percent := "", float32(0)
+	for _, dominant := range instance.DominantColors {
+		if dominant.SimplifiedColor == nil || dominant.PixelPercent == nil {
+			continue
+		}
+		if *dominant.PixelPercent > percent {
+			color, percent = strings.ToLower(*dominant.SimplifiedColor), *dominant.PixelPercent
+		}
+	}
+	return color
+}
+
+func rekognitionBoundingBox(geometry *types.Geometry) *domain.BoundingBox {
+	if geometry == nil || geometry.BoundingBox == nil {
+		return nil
+	}
+	box := geometry.BoundingBox
+	bbox := &domain.BoundingBox{}
+	if box.Left != nil {
+		bbox.Left = *box.Left
+	}
+	if box.Top != nil {
+		bbox.Top = *box.Top
+	}
+	if box.Width != nil {
+		bbox.Width = *box.Width
+	}
+	if box.Height != nil {
+		bbox.Height = *box.Height
+	}
+	return bbox
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"smart_parking/internal/config"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Số bản ghi lịch sử tối đa mỗi lần xem
+const maxLPRThresholdChanges = 500
+
+// LPRThresholdService quản lý ngưỡng confidence tự tạo phiên theo bãi / camera.
+// Thứ tự ưu tiên: ngưỡng riêng của camera, ngưỡng chung của bãi, rồi LPR_CONFIDENCE_THRESHOLD.
+type LPRThresholdService struct {
+	thresholdRepo  repository.LPRThresholdRepository
+	correctionRepo repository.LPRCorrectionRepository
+	cfg            *config.Config
+}
+
+func NewLPRThresholdService(thresholdRepo repository.LPRThresholdRepository, correctionRepo repository.LPRCorrectionRepository,
+	cfg *config.Config) *LPRThresholdService {
+	return &LPRThresholdService{
+		thresholdRepo:  thresholdRepo,
+		correctionRepo: correctionRepo,
+		cfg:            cfg,
+	}
+}
+
+// Resolve trả về ngưỡng áp dụng cho camera của bãi. Lỗi đọc DB thì dùng ngưỡng mặc định để không chặn cổng.
+func (s *LPRThresholdService) Resolve(ctx context.Context, lotID int, cameraID string) float32 {
+	threshold, err := s.thresholdRepo.FindForCamera(ctx, lotID, cameraID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("LPRThresholdService: Lỗi lấy ngưỡng cho bãi %d, camera '%s': %v", lotID, cameraID, err)
+		}
+		return s.cfg.LPRConfidenceThreshold
+	}
+	return float32(threshold.Threshold)
+}
+
+func (s *LPRThresholdService) List(ctx context.Context, lotID *int) ([]domain.LPRThreshold, error) {
+	return s.thresholdRepo.Find(ctx, lotID)
+}
+
+func (s *LPRThresholdService) Set(ctx context.Context, dto domain.LPRThresholdDTO, operator string) (*domain.LPRThreshold, error) {
+	threshold := &domain.LPRThreshold{
+		LotID:     dto.LotID,
+		CameraID:  dto.CameraID,
+		Threshold: roundThreshold(dto.Threshold),
+		AutoTune:  dto.AutoTune,
+	}
+	change := domain.LPRThresholdChange{
+		Reason:    domain.LPRThresholdReasonManual,
+		ChangedBy: null.NewString(operator, operator != ""),
+	}
+	saved, err := s.thresholdRepo.Upsert(ctx, threshold, change)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("LPRThresholdService: %s đặt ngưỡng bãi %d, camera '%s' = %.4f (auto_tune=%t)",
+		operator, saved.LotID, saved.CameraID, saved.Threshold, saved.AutoTune)
+	return saved, nil
+}
+
+func (s *LPRThresholdService) Delete(ctx context.Context, id int, operator string) error {
+	change := domain.LPRThresholdChange{
+		Reason:    domain.LPRThresholdReasonManual,
+		ChangedBy: null.NewString(operator, operator != ""),
+	}
+	return s.thresholdRepo.Delete(ctx, id, change)
+}
+
+func (s *LPRThresholdService) History(ctx context.Context, lotID *int, cameraID *string) ([]domain.LPRThresholdChange, error) {
+	return s.thresholdRepo.FindChanges(ctx, lotID, cameraID, maxLPRThresholdChanges)
+}
+
+// AutoTune điều chỉnh các ngưỡng bật auto_tune theo tỉ lệ operator phải sửa biển số máy đọc trong cửa sổ trượt:
+// sửa nhiều hơn mục tiêu thì tăng ngưỡng để nhiều xe được operator xác nhận hơn, sửa ít hơn một nửa mục tiêu
+// thì giảm ngưỡng để bớt việc xác nhận. Ngưỡng luôn nằm trong cận cấu hình. Trả về số ngưỡng đã thay đổi.
+func (s *LPRThresholdService) AutoTune(ctx context.Context, now time.Time) (int, error) {
+	thresholds, err := s.thresholdRepo.FindAutoTune(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	since := now.Add(-s.cfg.LPRAutoTuneWindow)
+	for _, threshold := range thresholds {
+		stats, err := s.correctionRepo.Stats(ctx, threshold.LotID, threshold.CameraID, since)
+		if err != nil {
+			return changed, fmt.Errorf("lỗi tính tỉ lệ sửa cho bãi %d, camera '%s': %w", threshold.LotID, threshold.CameraID, err)
+		}
+		if stats.Reads < s.cfg.LPRAutoTuneMinSamples {
+			continue
+		}
+
+		rate := float64(stats.Corrected) / float64(stats.Reads)
+		next := threshold.Threshold
+		switch {
+		case rate > s.cfg.LPRAutoTuneTargetRate:
+			next += s.cfg.LPRAutoTuneStep
+		case rate < s.cfg.LPRAutoTuneTargetRate/2:
+			next -= s.cfg.LPRAutoTuneStep
+		}
+		next = roundThreshold(math.Max(s.cfg.LPRAutoTuneMinThreshold, math.Min(s.cfg.LPRAutoTuneMaxThreshold, next)))
+		if next == threshold.Threshold {
+			continue
+		}
+
+		change := domain.LPRThresholdChange{
+			Reason:         domain.LPRThresholdReasonAutoTune,
+			CorrectionRate: null.FloatFrom(roundThreshold(rate)),
+			SampleSize:     null.IntFrom(int64(stats.Reads)),
+		}
+		updated := threshold
+		updated.Threshold = next
+		if _, err := s.thresholdRepo.Upsert(ctx, &updated, change); err != nil {
+			return changed, fmt.Errorf("lỗi cập nhật ngưỡng bãi %d, camera '%s': %w", threshold.LotID, threshold.CameraID, err)
+		}
+		log.Printf("LPRThresholdService: Tự điều chỉnh ngưỡng bãi %d, camera '%s': %.4f -> %.4f (sửa %d/%d = %.2f%%)",
+			threshold.LotID, threshold.CameraID, threshold.Threshold, next, stats.Corrected, stats.Reads, rate*100)
+		changed++
+	}
+	return changed, nil
+}
+
+// roundThreshold làm tròn theo độ chính xác cột DECIMAL(5, 4)
+func roundThreshold(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
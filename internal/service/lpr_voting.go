@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// RecognizeGateFrames nhận dạng từng frame ảnh của gate event, lưu ảnh làm bằng chứng và mỗi lần nhận dạng
+// vào lpr_attempts, rồi bỏ phiếu trên toàn bộ frame đã lưu của event (gồm cả frame từ các lần trigger trước).
+// Chỉ kết quả hợp nhất mới được dùng để xử lý tiếp gate event.
+func (s *IoTService) RecognizeGateFrames(ctx context.Context, provider LPRProvider, request domain.LPRTriggerRequest, frames [][]byte) (*domain.LPRFusionResult, error) {
+	gateEvent, err := s.gateEventRepo.FindByEventID(ctx, request.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy gate event: %w", err)
+	}
+	lot, err := s.parkingService.GetParkingLotByID(ctx, gateEvent.LotID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy bãi đỗ %d: %w", gateEvent.LotID, err)
+	}
+
+	var providerErr error
+	for i, frame := range frames {
+		attempt := &domain.LPRAttempt{
+			EventID:  request.EventID,
+			Provider: provider.Name(),
+			CameraID: null.NewString(request.CameraID, request.CameraID != ""),
+		}
+		if s.evidenceService != nil {
+			if evidence, err := s.evidenceService.SaveGateImage(ctx, gateEvent, request.CameraID, frame); err != nil {
+				log.Printf("LPR: Lỗi lưu ảnh bằng chứng frame %d của EventID=%s: %v", i+1, request.EventID, err)
+			} else {
+				attempt.EvidenceID = null.IntFrom(int64(evidence.ID))
+			}
+		}
+
+		result, err := provider.Recognize(ctx, frame)
+		if err == nil {
+			// Chỉ giữ các biển số đúng định dạng bãi chấp nhận
+			result.Candidates, err = s.parkingService.FilterLPRCandidatesForLot(ctx, lot.ID, result.Candidates)
+			if err == nil && len(result.Candidates) == 0 {
+				err = fmt.Errorf("%w: không có ứng viên nào đúng định dạng bãi %d chấp nhận", ErrNoPlateDetected, lot.ID)
+			}
+		}
+		if result != nil {
+			attempt.Candidates = result.Candidates
+		}
+		if err != nil {
+			if !errors.Is(err, ErrNoPlateDetected) {
+				providerErr = err
+			}
+			attempt.ErrorMessage = null.StringFrom(err.Error())
+			log.Printf("LPR: Frame %d/%d của EventID=%s không nhận dạng được: %v", i+1, len(frames), request.EventID, err)
+		} else {
+			best := result.Best()
+			attempt.DetectedPlate = null.StringFrom(best.Plate)
+			attempt.DisplayPlate = null.StringFrom(best.Display)
+			attempt.Grammar = null.StringFrom(best.Grammar)
+			attempt.Confidence = null.FloatFrom(float64(best.Confidence))
+		}
+
+		if s.lprAttemptRepo == nil {
+			continue
+		}
+		if _, err := s.lprAttemptRepo.Create(ctx, attempt); err != nil {
+			log.Printf("LPR: Lỗi lưu lần nhận dạng của EventID=%s: %v", request.EventID, err)
+		}
+	}
+
+	// Loại xe / màu xe lưu vào gate event kể cả khi không đọc được biển số, để operator tham khảo khi nhập tay
+	var vehicle *domain.VehicleAttributes
+	if classifier, ok := provider.(VehicleClassifier); ok {
+		vehicle = classifyGateFrames(ctx, classifier, request.EventID, frames)
+		if vehicle != nil {
+			if err := s.gateEventRepo.UpdateVehicleAttributes(ctx, request.EventID, *vehicle); err != nil {
+				log.Printf("LPR: Lỗi lưu thuộc tính xe của EventID=%s: %v", request.EventID, err)
+			}
+		}
+	}
+
+	var attempts []domain.LPRAttempt
+	if s.lprAttemptRepo != nil {
+		attempts, err = s.lprAttemptRepo.FindByEventID(ctx, request.EventID)
+		if err != nil {
+			return nil, fmt.Errorf("lỗi lấy các lần nhận dạng của gate event: %w", err)
+		}
+	}
+
+	var bests []domain.LPRCandidate
+	for _, attempt := range attempts {
+		if attempt.DetectedPlate.Valid && len(attempt.Candidates) > 0 {
+			bests = append(bests, attempt.Candidates[0])
+		}
+	}
+
+	fused := fuseLPRCandidates(bests, lotPlateGrammars(lot))
+	if fused == nil {
+		if providerErr != nil {
+			return nil, providerErr
+		}
+		return nil, fmt.Errorf("%w: %d frame đều không có biển số hợp lệ", ErrNoPlateDetected, len(attempts))
+	}
+	fused.TotalFrames = len(attempts)
+	fused.Vehicle = vehicle
+
+	log.Printf("LPR: EventID=%s hợp nhất %d/%d frame -> '%s' (%.2f)",
+		request.EventID, fused.VotingFrames, fused.TotalFrames, fused.Display, fused.Confidence)
+	return fused, nil
+}
+
+// fuseLPRCandidates bỏ phiếu từng ký tự giữa các biển số tốt nhất của mỗi frame, trọng số là confidence
+// của ký tự. Chỉ các frame có độ dài biển số chiếm trọng số lớn nhất mới tham gia bỏ phiếu.
+// Confidence của mỗi ký tự là tổng trọng số của ký tự thắng chia cho số frame bỏ phiếu, nên các frame
+// đọc khác nhau sẽ kéo confidence xuống.
+func fuseLPRCandidates(candidates []domain.LPRCandidate, grammars []string) *domain.LPRFusionResult {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	lengthWeights := make(map[int]float32)
+	for _, c := range candidates {
+		lengthWeights[len([]rune(c.Plate))] += c.Confidence
+	}
+	plateLength, bestWeight := 0, float32(-1)
+	for _, c := range candidates { // Duyệt theo thứ tự frame để hòa thì chọn độ dài xuất hiện trước
+		length := len([]rune(c.Plate))
+		if lengthWeights[length] > bestWeight {
+			plateLength, bestWeight = length, lengthWeights[length]
+		}
+	}
+
+	var voters []domain.LPRCandidate
+	for _, c := range candidates {
+		if len([]rune(c.Plate)) == plateLength {
+			voters = append(voters, c)
+		}
+	}
+
+	fused := &domain.LPRFusionResult{VotingFrames: len(voters)}
+	fusedPlate := make([]rune, plateLength)
+	var totalConfidence float32
+	for pos := 0; pos < plateLength; pos++ {
+		votes := make(map[rune]float32)
+		var order []rune
+		for _, c := range voters {
+			r := []rune(c.Plate)[pos]
+			if _, ok := votes[r]; !ok {
+				order = append(order, r)
+			}
+			votes[r] += lprCharConfidence(c, pos)
+		}
+		winner := order[0]
+		for _, r := range order[1:] {
+			if votes[r] > votes[winner] {
+				winner = r
+			}
+		}
+		charConfidence := votes[winner] / float32(len(voters))
+		fusedPlate[pos] = winner
+		fused.Characters = append(fused.Characters, domain.LPRCharacter{Char: string(winner), Confidence: charConfidence})
+		totalConfidence += charConfidence
+	}
+
+	fused.Plate = string(fusedPlate)
+	fused.Display = fused.Plate
+	if plateLength > 0 {
+		fused.Confidence = totalConfidence / float32(plateLength)
+	}
+	if match, ok := plate.Parse(fused.Plate, grammars); ok {
+		fused.Display = match.Display
+		fused.Grammar = match.Grammar
+		fused.Confidence += match.Bonus
+		if fused.Confidence > 1 {
+			fused.Confidence = 1
+		}
+	}
+	return fused
+}
+
+// lprCharConfidence trả về confidence của ký tự tại vị trí pos, mặc định là confidence của cả biển
+func lprCharConfidence(c domain.LPRCandidate, pos int) float32 {
+	if pos < len(c.Characters) {
+		return c.Characters[pos].Confidence
+	}
+	return c.Confidence
+}
@@ -15,12 +15,21 @@ import (
 )
 
 type ParkingService struct {
-	lotRepo      repository.ParkingLotRepository
-	slotRepo     repository.ParkingSlotRepository
-	barrierRepo  repository.BarrierRepository
-	sessionRepo  repository.ParkingSessionRepository
-	deviceRepo   repository.DeviceRepository // Thêm deviceRepo
-	eventLogRepo repository.DeviceEventsLogRepository
+	lotRepo       repository.ParkingLotRepository
+	slotRepo      repository.ParkingSlotRepository
+	barrierRepo   repository.BarrierRepository
+	sessionRepo   repository.ParkingSessionRepository
+	deviceRepo    repository.DeviceRepository // Thêm deviceRepo
+	eventLogRepo  repository.DeviceEventsLogRepository
+	tariffService *TariffService
+	// Đặt chỗ: dùng khi check-in để lấy đúng chỗ đã giữ cho biển số
+	reservationService *ReservationService
+	// Vé tháng: phiên của xe có vé hợp lệ được gắn subscription_id và miễn phí
+	subscriptionService *SubscriptionService
+	// Danh sách cảnh báo biển số: chặn check-in của xe bị gắn cờ
+	watchlistService *WatchlistService
+	// Pay-before-exit: chặn kết thúc phiên còn nợ tại bãi bật chính sách này
+	paymentService *PaymentService
 }
 
 func NewParkingService(
@@ -30,23 +39,44 @@ func NewParkingService(
 	sessionRepo repository.ParkingSessionRepository,
 	deviceRepo repository.DeviceRepository, // Thêm vào constructor
 	eventLogRepo repository.DeviceEventsLogRepository,
+	tariffService *TariffService,
+	reservationService *ReservationService,
+	subscriptionService *SubscriptionService,
+	watchlistService *WatchlistService,
+	paymentService *PaymentService,
 ) *ParkingService {
 	return &ParkingService{
-		lotRepo:      lotRepo,
-		slotRepo:     slotRepo,
-		barrierRepo:  barrierRepo,
-		sessionRepo:  sessionRepo,
-		deviceRepo:   deviceRepo, // Gán
-		eventLogRepo: eventLogRepo,
+		lotRepo:       lotRepo,
+		slotRepo:      slotRepo,
+		barrierRepo:   barrierRepo,
+		sessionRepo:   sessionRepo,
+		deviceRepo:    deviceRepo, // Gán
+		eventLogRepo:  eventLogRepo,
+		tariffService: tariffService,
+
+		reservationService:  reservationService,
+		subscriptionService: subscriptionService,
+		watchlistService:    watchlistService,
+		paymentService:      paymentService,
 	}
 }
 
 // --- ParkingLot ---
 func (s *ParkingService) CreateParkingLot(ctx context.Context, dto domain.ParkingLotDTO) (*domain.ParkingLot, error) {
+	if err := validatePlateGrammars(dto.AcceptedPlateGrammars); err != nil {
+		return nil, err
+	}
 	lot := &domain.ParkingLot{
-		Name:       dto.Name,
-		Address:    dto.Address,
-		TotalSlots: dto.TotalSlots,
+		Name:                     dto.Name,
+		Address:                  dto.Address,
+		TotalSlots:               dto.TotalSlots,
+		RequirePaymentBeforeExit: dto.RequirePaymentBeforeExit,
+		ExitGraceMinutes:         domain.DefaultExitGraceMinutes,
+		AutoOpenEntryBarrier:     dto.AutoOpenEntryBarrier,
+		AcceptedPlateGrammars:    dto.AcceptedPlateGrammars,
+	}
+	if dto.ExitGraceMinutes != nil {
+		lot.ExitGraceMinutes = *dto.ExitGraceMinutes
 	}
 	return s.lotRepo.Create(ctx, lot)
 }
@@ -60,6 +90,9 @@ func (s *ParkingService) GetAllParkingLots(ctx context.Context) ([]domain.Parkin
 }
 
 func (s *ParkingService) UpdateParkingLot(ctx context.Context, id int, dto domain.ParkingLotDTO) (*domain.ParkingLot, error) {
+	if err := validatePlateGrammars(dto.AcceptedPlateGrammars); err != nil {
+		return nil, err
+	}
 	lot, err := s.lotRepo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -67,6 +100,12 @@ func (s *ParkingService) UpdateParkingLot(ctx context.Context, id int, dto domai
 	lot.Name = dto.Name
 	lot.Address = dto.Address
 	lot.TotalSlots = dto.TotalSlots
+	lot.RequirePaymentBeforeExit = dto.RequirePaymentBeforeExit
+	lot.AutoOpenEntryBarrier = dto.AutoOpenEntryBarrier
+	lot.AcceptedPlateGrammars = dto.AcceptedPlateGrammars
+	if dto.ExitGraceMinutes != nil {
+		lot.ExitGraceMinutes = *dto.ExitGraceMinutes
+	}
 	return s.lotRepo.Update(ctx, lot)
 }
 
@@ -122,6 +161,12 @@ func (s *ParkingService) CreateParkingSlot(ctx context.Context, dto domain.Parki
 		Status:                 domain.StatusVacant, // Mặc định
 		LastStatusUpdateSource: "admin_creation",    // Hoặc "api_creation"
 	}
+	if dto.VehicleClass != nil {
+		if !domain.IsValidVehicleClass(*dto.VehicleClass) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVehicleClass, *dto.VehicleClass)
+		}
+		slot.VehicleClass = *dto.VehicleClass
+	}
 	return s.slotRepo.Create(ctx, slot)
 }
 
@@ -175,6 +220,12 @@ func (s *ParkingService) UpdateParkingSlot(ctx context.Context, slotID int, dto
 		}
 		slot.Status = domain.SlotStatus(dto.Status)
 	}
+	if dto.VehicleClass != nil {
+		if !domain.IsValidVehicleClass(*dto.VehicleClass) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVehicleClass, *dto.VehicleClass)
+		}
+		slot.VehicleClass = *dto.VehicleClass
+	}
 	slot.LastStatusUpdateSource = "admin_update" // Hoặc "api_update"
 
 	return s.slotRepo.Update(ctx, slot)
@@ -205,22 +256,28 @@ func (s *ParkingService) UpdateParkingSlotStatusFromDevice(ctx context.Context,
 		return fmt.Errorf("lỗi tìm slot: %w", err)
 	}
 
-	// Chuyển đổi timestamp từ string sang time.Time
-	parsedTime := time.Now()
+	// Chỗ đang được giữ cho đặt chỗ: cảm biến báo trống không được xóa trạng thái reserved
+	if status == domain.StatusVacant && slot.Status == domain.StatusReserved {
+		status = domain.StatusReserved
+	}
 
-	// Chỉ cập nhật nếu trạng thái thực sự thay đổi HOẶC nếu sự kiện này mới hơn sự kiện đã lưu cuối cùng
-	// Điều này giúp xử lý các message đến không theo thứ tự hoặc message trùng lặp.
-	if slot.Status != status || slot.LastEventTimestamp == nil || (slot.LastEventTimestamp != nil && parsedTime.After(*slot.LastEventTimestamp)) {
-		err = s.slotRepo.UpdateStatus(ctx, slot.ID, status, &parsedTime, "device")
-		if err != nil {
-			log.Printf("Lỗi khi cập nhật trạng thái slot ID %d: %v", slot.ID, err)
-			return fmt.Errorf("lỗi cập nhật trạng thái slot: %w", err)
-		}
-		log.Printf("Đã cập nhật trạng thái slot ID %d (Identifier: %s, LotID: %d) thành %s", slot.ID, slot.SlotIdentifier, slot.LotID, status)
-	} else {
-		log.Printf("Trạng thái slot ID %d (Identifier: %s) không thay đổi (%s) hoặc sự kiện cũ hơn (DB: %v, Event: %v). Bỏ qua cập nhật.",
-			slot.ID, slot.SlotIdentifier, status, slot.LastEventTimestamp, parsedTime)
+	// Dùng timestamp của ESP32 để message đến không theo thứ tự không ghi đè trạng thái mới hơn
+	// Không parse được timestamp thì không thể so thứ tự với trạng thái hiện tại: bỏ sự kiện thay vì coi là mới nhất
+	eventTime, err := time.Parse(time.RFC3339Nano, event.Timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: timestamp '%s' của sự kiện slot %s: %v", ErrInvalidDeviceEvent, event.Timestamp, event.SlotID, err)
+	}
+
+	applied, err := s.slotRepo.UpdateStatusFromDevice(ctx, slot.ID, status, eventTime)
+	if err != nil {
+		log.Printf("Lỗi khi cập nhật trạng thái slot ID %d: %v", slot.ID, err)
+		return fmt.Errorf("lỗi cập nhật trạng thái slot: %w", err)
 	}
+	if !applied {
+		return fmt.Errorf("%w: slot %s (ID %d) đã có sự kiện lúc %v, sự kiện này lúc %v",
+			ErrStaleDeviceEvent, slot.SlotIdentifier, slot.ID, slot.LastEventTimestamp, eventTime)
+	}
+	log.Printf("Đã cập nhật trạng thái slot ID %d (Identifier: %s, LotID: %d) thành %s", slot.ID, slot.SlotIdentifier, slot.LotID, status)
 	return nil
 }
 
@@ -406,7 +463,7 @@ func (s *ParkingService) StartParkingSession(ctx context.Context, event domain.D
 	}
 
 	// Tùy chọn: Tìm một chỗ đỗ trống tự động
-	availableSlot, err := s.slotRepo.FindFirstAvailableByLotID(ctx, lotID)
+	availableSlot, err := s.slotRepo.FindFirstAvailableByLotID(ctx, lotID, "")
 	var sessionSlotID null.Int
 	if err == nil && availableSlot != nil {
 		sessionSlotID = null.IntFrom(int64(availableSlot.ID))
@@ -434,7 +491,7 @@ func (s *ParkingService) StartParkingSession(ctx context.Context, event domain.D
 		SlotID:           sessionSlotID,
 		Esp32ThingName:   event.DeviceID,
 		EntryTime:        entryTime,
-		PaymentStatus:    "pending",
+		PaymentStatus:    domain.PaymentStatusPending,
 		Status:           domain.SessionActive,
 		EntryGateEventID: null.StringFrom(event.EventID),
 	}
@@ -466,40 +523,18 @@ func (s *ParkingService) EndParkingSession(ctx context.Context, event domain.Dev
 		exitTime = time.Now().UTC()
 	}
 
-	activeSession.ExitTime = null.TimeFrom(exitTime)
-	activeSession.Status = domain.SessionCompleted
-	activeSession.ExitGateEventID = null.StringFrom(event.EventID)
-
-	duration := exitTime.Sub(activeSession.EntryTime)
-	activeSession.DurationMinutes = null.IntFrom(int64(duration.Minutes()))
-
-	// TODO: Tính toán phí dựa trên biểu phí (ví dụ: từ bảng `tariffs` liên kết với `parking_lots`)
-	if activeSession.DurationMinutes.Valid {
-		// Ví dụ: 1000 VND/phút, tối thiểu 5000 VND
-		fee := float64(activeSession.DurationMinutes.Int64) * 1000.0
-		if fee < 5000.0 && activeSession.DurationMinutes.Int64 > 0 { // Phí tối thiểu nếu có đỗ
-			fee = 5000.0
-		} else if activeSession.DurationMinutes.Int64 <= 0 { // Không tính phí nếu thời gian < 0 hoặc = 0
-			fee = 0
-		}
-		activeSession.CalculatedFee = null.FloatFrom(fee)
-	} else {
-		activeSession.CalculatedFee = null.FloatFrom(0)
+	lot, err := s.lotRepo.FindByID(ctx, activeSession.LotID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi kiểm tra bãi đỗ xe %d: %w", activeSession.LotID, err)
 	}
-	// activeSession.PaymentStatus sẽ được cập nhật sau
-
-	updatedSession, err := s.sessionRepo.Update(ctx, activeSession)
+	activeSession, err = s.checkExitPayment(ctx, lot, activeSession, exitTime)
 	if err != nil {
-		return nil, fmt.Errorf("lỗi cập nhật phiên đỗ xe: %w", err)
+		return nil, err
 	}
 
-	if activeSession.SlotID.Valid {
-		err = s.slotRepo.UpdateStatus(ctx, int(activeSession.SlotID.Int64), domain.StatusVacant, &exitTime, "session_end")
-		if err != nil {
-			log.Printf("Lỗi cập nhật trạng thái chỗ đỗ %d thành trống: %v", activeSession.SlotID.Int64, err)
-		} else {
-			log.Printf("Đã cập nhật chỗ đỗ ID %d thành trống.", activeSession.SlotID.Int64)
-		}
+	updatedSession, err := s.completeSession(ctx, activeSession, exitTime, "", event.EventID)
+	if err != nil {
+		return nil, err
 	}
 
 	log.Printf("Đã kết thúc phiên đỗ xe ID: %d. Thời gian đỗ: %d phút. Phí (tạm tính): %.2f",
@@ -636,6 +671,12 @@ func (s *ParkingService) VehicleCheckIn(ctx context.Context, dto domain.VehicleC
 	log.Printf("Service: Ghi nhận xe vào cổng (API): LotID=%d, ESP32='%s', Biển số='%s'",
 		dto.LotID, dto.Esp32ThingName, dto.VehicleIdentifier)
 
+	// Biển số luôn được lưu ở dạng chuẩn để tra cứu phiên active, đặt chỗ, vé tháng khớp nhau
+	dto.VehicleIdentifier = normalizeVehicleIdentifier(dto.VehicleIdentifier)
+	if dto.VehicleIdentifier == "" {
+		return nil, fmt.Errorf("%w: biển số trống", ErrInvalidPlateGrammar)
+	}
+
 	// 1. Xác thực LotID
 	lot, err := s.lotRepo.FindByID(ctx, dto.LotID)
 	if err != nil {
@@ -645,6 +686,10 @@ func (s *ParkingService) VehicleCheckIn(ctx context.Context, dto domain.VehicleC
 		return nil, fmt.Errorf("lỗi khi kiểm tra bãi đỗ xe: %w", err)
 	}
 
+	if !domain.IsValidVehicleClass(dto.VehicleClass) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVehicleClass, dto.VehicleClass)
+	}
+
 	// 2. Kiểm tra xem có phiên active nào cho biển số này trong bãi này chưa
 	// (Điều này quan trọng để tránh check-in trùng lặp)
 	existingActiveSession, err := s.sessionRepo.FindActiveByVehicleIdentifier(ctx, dto.LotID, dto.VehicleIdentifier)
@@ -656,6 +701,23 @@ func (s *ParkingService) VehicleCheckIn(ctx context.Context, dto domain.VehicleC
 		return nil, fmt.Errorf("%w: xe '%s' đã ở trong bãi", repository.ErrDuplicateEntry, dto.VehicleIdentifier)
 	}
 
+	// Biển số bị gắn cờ thì không cho check-in, trừ VIP hoặc khi admin chủ động override kèm lý do.
+	// Luồng LPR tại cổng đã kiểm tra trước đó nên không kiểm tra (và ghi nhật ký) lại.
+	if s.watchlistService != nil && !dto.WatchlistScreened {
+		entry, action, err := s.watchlistService.Screen(ctx, dto.LotID, dto.VehicleIdentifier, "",
+			domain.WatchlistSourceCheckIn, dto.OverrideWatchlist, dto.Operator, dto.OverrideReason)
+		if err != nil {
+			// Không kiểm tra được thì không cho vào, trừ khi admin chủ động override
+			if !dto.OverrideWatchlist || dto.Operator == "" || dto.OverrideReason == "" {
+				return nil, fmt.Errorf("%w: xe '%s': %v", ErrWatchlistUnavailable, dto.VehicleIdentifier, err)
+			}
+			log.Printf("Lỗi kiểm tra danh sách cảnh báo cho xe '%s', '%s' override (lý do: %s): %v",
+				dto.VehicleIdentifier, dto.Operator, dto.OverrideReason, err)
+		} else if action == domain.WatchlistActionDenied {
+			return nil, fmt.Errorf("%w: xe '%s' (%s)", ErrPlateFlagged, dto.VehicleIdentifier, entry.ReasonCode)
+		}
+	}
+
 	// 3. Xác định EntryTime
 	var entryTime time.Time
 	if dto.EntryTime != "" {
@@ -670,11 +732,27 @@ func (s *ParkingService) VehicleCheckIn(ctx context.Context, dto domain.VehicleC
 		entryTime = time.Now().UTC()
 	}
 
-	// 4. Tùy chọn: Tìm một chỗ đỗ trống tự động
+	// 4. Ưu tiên chỗ đã được giữ cho đặt chỗ của xe; nếu không có thì tìm một chỗ đỗ trống tự động
 	var sessionSlotID null.Int
-	// Chỉ tìm slot nếu lot này có cấu hình total_slots > 0 (nghĩa là quản lý slot cụ thể)
-	if lot.TotalSlots > 0 {
-		availableSlot, err := s.slotRepo.FindFirstAvailableByLotID(ctx, dto.LotID)
+	var reservation *domain.Reservation
+	if s.reservationService != nil {
+		reservation, err = s.reservationService.FindForCheckIn(ctx, dto.LotID, dto.VehicleIdentifier, entryTime)
+		if err != nil {
+			log.Printf("Lỗi tìm đặt chỗ cho xe '%s' tại bãi %d: %v. Check-in không dùng đặt chỗ.", dto.VehicleIdentifier, dto.LotID, err)
+			reservation = nil
+		}
+	}
+	if reservation != nil && reservation.SlotID.Valid {
+		reservedSlotID := int(reservation.SlotID.Int64)
+		sessionSlotID = null.IntFrom(int64(reservedSlotID))
+		if errTime := s.slotRepo.UpdateStatus(ctx, reservedSlotID, domain.StatusOccupied, &entryTime, "reservation_check_in"); errTime != nil {
+			log.Printf("Lỗi khi cập nhật trạng thái slot %d thành occupied: %v", reservedSlotID, errTime)
+		} else {
+			log.Printf("Đã gán chỗ đỗ ID %d theo đặt chỗ %d cho xe '%s'.", reservedSlotID, reservation.ID, dto.VehicleIdentifier)
+		}
+	} else if lot.TotalSlots > 0 {
+		// Chỉ tìm slot nếu lot này có cấu hình total_slots > 0 (nghĩa là quản lý slot cụ thể)
+		availableSlot, err := s.slotRepo.FindFirstAvailableByLotID(ctx, dto.LotID, dto.VehicleClass)
 		if err == nil && availableSlot != nil {
 			sessionSlotID = null.IntFrom(int64(availableSlot.ID))
 			// Cập nhật trạng thái slot này thành occupied
@@ -694,23 +772,53 @@ func (s *ParkingService) VehicleCheckIn(ctx context.Context, dto domain.VehicleC
 		log.Printf("Bãi đỗ %d không quản lý chỗ đỗ cụ thể (total_slots=0). Phiên sẽ không có slot_id.", dto.LotID)
 	}
 
-	// 5. Tạo bản ghi ParkingSession mới
+	// 5. Kiểm tra vé tháng: phiên được vé bao phí thì gắn subscription_id
+	var subscriptionID null.Int
+	if s.subscriptionService != nil {
+		pass, err := s.subscriptionService.FindCoveringPass(ctx, dto.LotID, dto.VehicleIdentifier, entryTime)
+		if err != nil {
+			log.Printf("Lỗi kiểm tra vé tháng cho xe '%s' tại bãi %d: %v. Phiên được tính phí bình thường.", dto.VehicleIdentifier, dto.LotID, err)
+		} else if pass != nil {
+			subscriptionID = null.IntFrom(int64(pass.ID))
+			log.Printf("Xe '%s' vào bãi %d bằng vé tháng %d (%s).", dto.VehicleIdentifier, dto.LotID, pass.ID, pass.HolderName)
+		}
+	}
+
+	// 6. Tạo bản ghi ParkingSession mới
 	session := &domain.ParkingSession{
 		LotID:             dto.LotID,
 		SlotID:            sessionSlotID,
 		Esp32ThingName:    dto.Esp32ThingName,
 		VehicleIdentifier: null.StringFrom(dto.VehicleIdentifier),
 		EntryTime:         entryTime,
-		PaymentStatus:     "pending",
+		PaymentStatus:     domain.PaymentStatusPending,
 		Status:            domain.SessionActive,
+		SubscriptionID:    subscriptionID,
 		// EntryGateEventID: dto.EntryGateEventID, // Nếu frontend gửi
 	}
+	if dto.VehicleClass != "" || dto.VehicleColor != "" || dto.VehicleMake != "" {
+		session.VehicleClass = null.NewString(dto.VehicleClass, dto.VehicleClass != "")
+		session.VehicleColor = null.NewString(dto.VehicleColor, dto.VehicleColor != "")
+		session.VehicleMake = null.NewString(dto.VehicleMake, dto.VehicleMake != "")
+		source := dto.VehicleSource
+		if source == "" {
+			source = domain.VehicleSourceOperator
+		}
+		session.VehicleSource = null.StringFrom(source)
+	}
 
 	createdSession, err := s.sessionRepo.Create(ctx, session)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi tạo phiên đỗ xe: %w", err)
 	}
 	log.Printf("Đã tạo phiên đỗ xe mới ID: %d cho xe '%s' tại bãi %d", createdSession.ID, dto.VehicleIdentifier, dto.LotID)
+
+	// 7. Đánh dấu đặt chỗ đã được sử dụng
+	if reservation != nil {
+		if err := s.reservationService.ConsumeReservation(ctx, reservation.ID, createdSession.ID); err != nil {
+			log.Printf("%v", err)
+		}
+	}
 	return createdSession, nil
 }
 
@@ -719,7 +827,7 @@ func (s *ParkingService) VehicleCheckOut(ctx context.Context, dto domain.Vehicle
 		dto.LotID, dto.Esp32ThingName, dto.VehicleIdentifier)
 
 	// 1. Xác thực LotID
-	_, err := s.lotRepo.FindByID(ctx, dto.LotID)
+	lot, err := s.lotRepo.FindByID(ctx, dto.LotID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return nil, fmt.Errorf("bãi đỗ xe với ID %d không tồn tại", dto.LotID)
@@ -727,8 +835,15 @@ func (s *ParkingService) VehicleCheckOut(ctx context.Context, dto domain.Vehicle
 		return nil, fmt.Errorf("lỗi khi kiểm tra bãi đỗ xe: %w", err)
 	}
 
-	// 2. Tìm phiên đang active cho biển số này trong bãi này
-	activeSession, err := s.sessionRepo.FindActiveByVehicleIdentifier(ctx, dto.LotID, dto.VehicleIdentifier)
+	// 2. Tìm phiên đang active cho biển số này trong bãi này (chỉ khớp chính xác biển số chuẩn hóa).
+	// Operator có thể chỉ định session_id từ danh sách biển số gần giống khi không khớp chính xác.
+	var activeSession *domain.ParkingSession
+	matchScore := 1.0
+	if dto.SessionID != 0 {
+		activeSession, matchScore, err = s.confirmSessionForExit(ctx, dto.LotID, dto.SessionID, dto.VehicleIdentifier)
+	} else {
+		activeSession, err = s.findActiveSessionForExit(ctx, dto.LotID, dto.VehicleIdentifier)
+	}
 	if err != nil {
 		if errors.Is(err, repository.ErrNoActiveSession) || errors.Is(err, repository.ErrNotFound) {
 			log.Printf("Không tìm thấy phiên đỗ xe đang hoạt động cho xe '%s' tại bãi %d.", dto.VehicleIdentifier, dto.LotID)
@@ -751,41 +866,76 @@ func (s *ParkingService) VehicleCheckOut(ctx context.Context, dto domain.Vehicle
 		exitTime = time.Now().UTC()
 	}
 
+	// 4. Pay-before-exit: phiên còn nợ không được kết thúc qua API
+	activeSession, err = s.checkExitPayment(ctx, lot, activeSession, exitTime)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedSession, err := s.completeSession(ctx, activeSession, exitTime, dto.VehicleClass, "")
+	if err != nil {
+		return nil, err
+	}
+	updatedSession.MatchScore = &matchScore
+	log.Printf("Đã kết thúc phiên đỗ xe ID: %d cho xe '%s'. Thời gian đỗ: %d phút. Phí (tạm tính): %.2f",
+		updatedSession.ID, dto.VehicleIdentifier, updatedSession.DurationMinutes.Int64, updatedSession.CalculatedFee.Float64)
+	return updatedSession, nil
+}
+
+// completeSession kết thúc phiên active tại thời điểm exitTime: tính phí, lưu phiên và trả chỗ đỗ.
+// Dùng chung cho check-out qua API và xe ra cổng nhận dạng bằng LPR (exitGateEventID khác rỗng).
+func (s *ParkingService) completeSession(ctx context.Context, activeSession *domain.ParkingSession, exitTime time.Time,
+	vehicleClass string, exitGateEventID string) (*domain.ParkingSession, error) {
 	// Đảm bảo exitTime không sớm hơn entryTime
 	if exitTime.Before(activeSession.EntryTime) {
 		log.Printf("Thời gian ra (%v) sớm hơn thời gian vào (%v) của phiên %d. Sử dụng thời gian vào làm thời gian ra.", exitTime, activeSession.EntryTime, activeSession.ID)
 		exitTime = activeSession.EntryTime
 	}
 
-	// 4. Cập nhật thông tin cho phiên
+	// 1. Cập nhật thông tin cho phiên
 	activeSession.ExitTime = null.TimeFrom(exitTime)
 	activeSession.Status = domain.SessionCompleted
-	// activeSession.ExitGateEventID = null.StringFrom(dto.ExitGateEventID) // Nếu frontend gửi
+	if exitGateEventID != "" {
+		activeSession.ExitGateEventID = null.StringFrom(exitGateEventID)
+	}
 
 	duration := exitTime.Sub(activeSession.EntryTime)
 	activeSession.DurationMinutes = null.IntFrom(int64(duration.Minutes()))
 
-	// 5. Tính toán phí (ví dụ đơn giản)
-	if activeSession.DurationMinutes.Valid {
-		fee := float64(activeSession.DurationMinutes.Int64) * 1000.0 // 1000 VND/phút
-		if fee < 5000.0 && activeSession.DurationMinutes.Int64 > 0 {
-			fee = 5000.0
-		} else if activeSession.DurationMinutes.Int64 <= 0 {
-			fee = 0
-		}
-		activeSession.CalculatedFee = null.FloatFrom(fee)
+	// 2. Tính toán phí theo biểu phí của bãi.
+	// Nếu phiên đã thanh toán tại cổng ra và xe ra trong thời gian ân hạn thì giữ nguyên phí đã thu.
+	if activeSession.ExitDeadline.Valid && activeSession.CalculatedFee.Valid && !exitTime.After(activeSession.ExitDeadline.Time) {
+		log.Printf("Phiên %d ra trong thời gian ân hạn (hạn chót %v). Giữ nguyên phí %.2f.",
+			activeSession.ID, activeSession.ExitDeadline.Time, activeSession.CalculatedFee.Float64)
 	} else {
-		activeSession.CalculatedFee = null.FloatFrom(0)
+		// Loại xe gửi kèm khi check-out được ưu tiên, không có thì dùng loại xe đã ghi nhận trên phiên
+		if vehicleClass == "" {
+			vehicleClass = activeSession.VehicleClass.String
+		}
+		previousFee := activeSession.CalculatedFee
+		breakdown, err := s.tariffService.CalculateSessionFee(ctx, activeSession, exitTime, vehicleClass)
+		if err != nil {
+			return nil, fmt.Errorf("lỗi tính phí đỗ xe: %w", err)
+		}
+		activeSession.CalculatedFee = null.FloatFrom(breakdown.Total)
+		activeSession.FeeBreakdown = breakdown
+		// Quá hạn ân hạn sau khi đã thanh toán: phần phí phát sinh thêm cần được thu tiếp
+		if previousFee.Valid && breakdown.Total > previousFee.Float64 && activeSession.PaymentStatus == domain.PaymentStatusPaid {
+			activeSession.PaymentStatus = domain.PaymentStatusPartiallyPaid
+		}
+	}
+	if activeSession.SubscriptionID.Valid {
+		activeSession.PaymentStatus = domain.PaymentStatusPaid
 	}
 	// activeSession.PaymentStatus sẽ được cập nhật bởi một quy trình thanh toán riêng
 
-	// 6. Lưu cập nhật phiên
+	// 3. Lưu cập nhật phiên
 	updatedSession, err := s.sessionRepo.Update(ctx, activeSession)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi cập nhật phiên đỗ xe: %w", err)
 	}
 
-	// 7. Cập nhật trạng thái chỗ đỗ (nếu có) thành vacant
+	// 4. Cập nhật trạng thái chỗ đỗ (nếu có) thành vacant
 	if activeSession.SlotID.Valid {
 		err = s.slotRepo.UpdateStatus(ctx, int(activeSession.SlotID.Int64), domain.StatusVacant, &exitTime, "session_check_out")
 		if err != nil {
@@ -796,7 +946,68 @@ func (s *ParkingService) VehicleCheckOut(ctx context.Context, dto domain.Vehicle
 		}
 	}
 
-	log.Printf("Đã kết thúc phiên đỗ xe ID: %d cho xe '%s'. Thời gian đỗ: %d phút. Phí (tạm tính): %.2f",
-		updatedSession.ID, dto.VehicleIdentifier, updatedSession.DurationMinutes.Int64, updatedSession.CalculatedFee.Float64)
 	return updatedSession, nil
 }
+
+// checkExitPayment áp dụng pay-before-exit cho các đường kết thúc phiên ngoài LPR cổng ra (check-out API, sự kiện cổng):
+// phiên đã thanh toán và còn trong thời gian ân hạn được ra ngay, các phiên khác được tính phí tới exitTime
+// và phải đã thanh toán đủ (hoặc miễn phí), nếu không trả về ErrPaymentOutstanding.
+func (s *ParkingService) checkExitPayment(ctx context.Context, lot *domain.ParkingLot, session *domain.ParkingSession,
+	exitTime time.Time) (*domain.ParkingSession, error) {
+	if !lot.RequirePaymentBeforeExit || s.paymentService == nil || session.SubscriptionID.Valid {
+		return session, nil
+	}
+	settled := func(status string) bool {
+		return status == domain.PaymentStatusPaid || status == domain.PaymentStatusWaived
+	}
+	if session.ExitDeadline.Valid && !exitTime.After(session.ExitDeadline.Time) && settled(session.PaymentStatus) {
+		return session, nil
+	}
+
+	quoted, err := s.QuoteExitFee(ctx, session, exitTime)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.paymentService.SyncSessionPaymentStatus(ctx, quoted.ID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi kiểm tra thanh toán của phiên %d: %w", quoted.ID, err)
+	}
+	if !settled(summary.PaymentStatus) {
+		return nil, fmt.Errorf("%w: phiên %d còn nợ %.2f (trạng thái: %s)",
+			ErrPaymentOutstanding, quoted.ID, summary.CalculatedFee-summary.PaidAmount, summary.PaymentStatus)
+	}
+	quoted.PaymentStatus = summary.PaymentStatus
+	return quoted, nil
+}
+
+// QuoteExitFee tính phí tạm tính đến thời điểm at cho phiên còn active (xe đang ở cổng ra) và lưu lại,
+// để phiên có thể được thanh toán trước khi kết thúc. Hạn chót ra bãi cũ (nếu có) bị hủy.
+func (s *ParkingService) QuoteExitFee(ctx context.Context, session *domain.ParkingSession, at time.Time) (*domain.ParkingSession, error) {
+	breakdown, err := s.tariffService.CalculateSessionFee(ctx, session, at, session.VehicleClass.String)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi tính phí đỗ xe: %w", err)
+	}
+	session.CalculatedFee = null.FloatFrom(breakdown.Total)
+	session.FeeBreakdown = breakdown
+	session.ExitDeadline = null.Time{}
+	updated, err := s.sessionRepo.Update(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi cập nhật phí tạm tính cho phiên %d: %w", session.ID, err)
+	}
+	log.Printf("Đã tính phí tạm tính %.2f cho phiên %d tại cổng ra", breakdown.Total, session.ID)
+	return updated, nil
+}
+
+// GrantExitWindow đặt hạn chót ra bãi = now + graceMinutes cho phiên đã thanh toán
+func (s *ParkingService) GrantExitWindow(ctx context.Context, sessionID int, graceMinutes int) (*domain.ParkingSession, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	session.ExitDeadline = null.TimeFrom(time.Now().UTC().Add(time.Duration(graceMinutes) * time.Minute))
+	updated, err := s.sessionRepo.Update(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi cập nhật hạn chót ra bãi cho phiên %d: %w", sessionID, err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidWebhookSignature = errors.New("chữ ký webhook không hợp lệ")
+
+// PaymentGateway - Interface cho cổng thanh toán online. PaymentService chỉ phụ thuộc vào interface này
+// để có thể thay cổng thanh toán thật (VNPay, MoMo...) mà không sửa logic nghiệp vụ.
+type PaymentGateway interface {
+	Name() string
+	CreatePayment(ctx context.Context, req GatewayPaymentRequest) (*GatewayPaymentResponse, error)
+	// ParseWebhook xác thực chữ ký và parse payload callback từ cổng thanh toán
+	ParseWebhook(payload []byte, signature string) (*GatewayWebhookEvent, error)
+	Refund(ctx context.Context, req GatewayRefundRequest) (*GatewayRefundResponse, error)
+}
+
+type GatewayPaymentRequest struct {
+	SessionID   int
+	Amount      float64
+	Currency    string
+	Description string
+}
+
+type GatewayPaymentResponse struct {
+	GatewayReference string
+	CheckoutURL      string
+}
+
+type GatewayWebhookEvent struct {
+	GatewayReference string  `json:"gateway_reference"`
+	Status           string  `json:"status"` // "succeeded" hoặc "failed"
+	Amount           float64 `json:"amount"`
+	FailureReason    string  `json:"failure_reason,omitempty"`
+}
+
+type GatewayRefundRequest struct {
+	GatewayReference string
+	Amount           float64
+	Reason           string
+}
+
+type GatewayRefundResponse struct {
+	RefundReference string
+}
+
+// FakePaymentGateway - Cổng thanh toán giả lập dùng cho môi trường dev/test.
+// Webhook được ký bằng HMAC-SHA256 (hex) của body với secret cấu hình.
+type FakePaymentGateway struct {
+	secret          []byte
+	checkoutBaseURL string
+}
+
+func NewFakePaymentGateway(secret string, checkoutBaseURL string) *FakePaymentGateway {
+	return &FakePaymentGateway{
+		secret:          []byte(secret),
+		checkoutBaseURL: strings.TrimRight(checkoutBaseURL, "/"),
+	}
+}
+
+func (g *FakePaymentGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakePaymentGateway) CreatePayment(ctx context.Context, req GatewayPaymentRequest) (*GatewayPaymentResponse, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("fake gateway: số tiền phải > 0")
+	}
+	reference := "fake_" + uuid.NewString()
+	return &GatewayPaymentResponse{
+		GatewayReference: reference,
+		CheckoutURL:      fmt.Sprintf("%s/%s?amount=%.0f", g.checkoutBaseURL, reference, req.Amount),
+	}, nil
+}
+
+// Sign tính chữ ký cho payload, dùng để giả lập callback từ cổng thanh toán
+func (g *FakePaymentGateway) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (g *FakePaymentGateway) ParseWebhook(payload []byte, signature string) (*GatewayWebhookEvent, error) {
+	expected := g.Sign(payload)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, ErrInvalidWebhookSignature
+	}
+	var event GatewayWebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("fake gateway: payload webhook không hợp lệ: %w", err)
+	}
+	if event.GatewayReference == "" {
+		return nil, fmt.Errorf("fake gateway: thiếu gateway_reference trong webhook")
+	}
+	return &event, nil
+}
+
+func (g *FakePaymentGateway) Refund(ctx context.Context, req GatewayRefundRequest) (*GatewayRefundResponse, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("fake gateway: số tiền hoàn phải > 0")
+	}
+	return &GatewayRefundResponse{RefundReference: "fake_refund_" + uuid.NewString()}, nil
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/guregu/null.v4"
+	"log"
+	"math"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPaymentNotAllowed    = errors.New("không thể thực hiện thanh toán cho phiên này")
+	ErrInvalidPaymentAmount = errors.New("số tiền thanh toán không hợp lệ")
+)
+
+const paymentCurrency = "VND"
+
+// Giao dịch online pending trong khoảng này được trừ vào số tiền còn có thể thanh toán, tránh mở giao dịch thứ hai
+// cho cùng số nợ; quá hạn coi như khách đã bỏ trang thanh toán.
+const pendingPaymentHold = 30 * time.Minute
+
+type PaymentService struct {
+	paymentRepo repository.PaymentRepository
+	refundRepo  repository.PaymentRefundRepository
+	sessionRepo repository.ParkingSessionRepository
+	gateway     PaymentGateway
+
+	// onSettled được gọi khi một khoản thanh toán / miễn phí làm phiên hết nợ (IoTService gán để mở rào ra pay-before-exit)
+	onSettled func(ctx context.Context, sessionID int, settledBy string)
+}
+
+func NewPaymentService(paymentRepo repository.PaymentRepository, refundRepo repository.PaymentRefundRepository,
+	sessionRepo repository.ParkingSessionRepository, gateway PaymentGateway) *PaymentService {
+	return &PaymentService{
+		paymentRepo: paymentRepo,
+		refundRepo:  refundRepo,
+		sessionRepo: sessionRepo,
+		gateway:     gateway,
+	}
+}
+
+// StartPayment tạo giao dịch online qua cổng thanh toán cho (một phần) số tiền còn nợ của phiên
+func (s *PaymentService) StartPayment(ctx context.Context, sessionID int, dto domain.StartPaymentDTO) (*domain.Payment, error) {
+	session, summary, err := s.loadPayableSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := resolvePaymentAmount(dto.Amount, summary.Outstanding)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.gateway.CreatePayment(ctx, GatewayPaymentRequest{
+		SessionID:   session.ID,
+		Amount:      amount,
+		Currency:    paymentCurrency,
+		Description: fmt.Sprintf("Phí đỗ xe phiên #%d", session.ID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lỗi tạo giao dịch tại cổng thanh toán %s: %w", s.gateway.Name(), err)
+	}
+
+	payment := &domain.Payment{
+		SessionID:        session.ID,
+		Method:           domain.PaymentMethodGateway,
+		Gateway:          null.StringFrom(s.gateway.Name()),
+		GatewayReference: null.StringFrom(resp.GatewayReference),
+		CheckoutURL:      null.NewString(resp.CheckoutURL, resp.CheckoutURL != ""),
+		Amount:           amount,
+		Currency:         paymentCurrency,
+		Status:           domain.PaymentRecordPending,
+	}
+	created, err := s.paymentRepo.Create(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lưu giao dịch thanh toán: %w", err)
+	}
+	log.Printf("PaymentService: Tạo giao dịch %d (%s) cho phiên %d, số tiền %.2f", created.ID, resp.GatewayReference, session.ID, amount)
+	return created, nil
+}
+
+// HandleWebhook xử lý callback từ cổng thanh toán. Callback lặp lại cho cùng giao dịch được bỏ qua.
+func (s *PaymentService) HandleWebhook(ctx context.Context, gatewayName string, payload []byte, signature string) (*domain.Payment, error) {
+	if gatewayName != s.gateway.Name() {
+		return nil, fmt.Errorf("%w: cổng thanh toán '%s' không được cấu hình", repository.ErrNotFound, gatewayName)
+	}
+	event, err := s.gateway.ParseWebhook(payload, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.paymentRepo.FindByGatewayReference(ctx, gatewayName, event.GatewayReference)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Status != domain.PaymentRecordPending {
+		log.Printf("PaymentService: Webhook lặp lại cho giao dịch %d (trạng thái hiện tại: %s), bỏ qua", payment.ID, payment.Status)
+		return payment, nil
+	}
+
+	to := domain.PaymentRecordFailed
+	failureReason := event.FailureReason
+	var paidAt *time.Time
+	if event.Status == string(domain.PaymentRecordSucceeded) {
+		if math.Abs(event.Amount-payment.Amount) > 0.005 {
+			failureReason = fmt.Sprintf("số tiền callback (%.2f) không khớp giao dịch (%.2f)", event.Amount, payment.Amount)
+			log.Printf("PaymentService: Giao dịch %d: %s", payment.ID, failureReason)
+		} else {
+			to = domain.PaymentRecordSucceeded
+			now := time.Now().UTC()
+			paidAt = &now
+		}
+	} else if failureReason == "" {
+		failureReason = "cổng thanh toán báo thất bại"
+	}
+
+	if err := s.paymentRepo.UpdateStatus(ctx, payment.ID, domain.PaymentRecordPending, to, failureReason, paidAt); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Một webhook khác đã xử lý giao dịch này trước
+			return s.paymentRepo.FindByID(ctx, payment.ID)
+		}
+		return nil, fmt.Errorf("lỗi cập nhật giao dịch thanh toán: %w", err)
+	}
+
+	summary, err := s.refreshSessionPaymentStatus(ctx, payment.SessionID)
+	if err != nil {
+		log.Printf("PaymentService: Lỗi cập nhật payment_status của phiên %d: %v", payment.SessionID, err)
+	} else if to == domain.PaymentRecordSucceeded {
+		s.notifySettled(ctx, summary, "gateway:"+gatewayName)
+	}
+	return s.paymentRepo.FindByID(ctx, payment.ID)
+}
+
+// RecordCashPayment ghi nhận tiền mặt do operator thu
+func (s *PaymentService) RecordCashPayment(ctx context.Context, sessionID int, dto domain.CashPaymentDTO, recordedBy string) (*domain.Payment, error) {
+	session, summary, err := s.loadPayableSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := resolvePaymentAmount(dto.Amount, summary.Outstanding)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	payment := &domain.Payment{
+		SessionID:  session.ID,
+		Method:     domain.PaymentMethodCash,
+		Amount:     amount,
+		Currency:   paymentCurrency,
+		Status:     domain.PaymentRecordSucceeded,
+		Note:       null.NewString(dto.Note, dto.Note != ""),
+		RecordedBy: null.NewString(recordedBy, recordedBy != ""),
+		PaidAt:     null.TimeFrom(now),
+	}
+	created, err := s.paymentRepo.Create(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lưu thanh toán tiền mặt: %w", err)
+	}
+	log.Printf("PaymentService: %s ghi nhận tiền mặt %.2f cho phiên %d", recordedBy, amount, session.ID)
+
+	summary, err = s.refreshSessionPaymentStatus(ctx, session.ID)
+	if err != nil {
+		log.Printf("PaymentService: Lỗi cập nhật payment_status của phiên %d: %v", session.ID, err)
+	} else {
+		s.notifySettled(ctx, summary, recordedBy)
+	}
+	return created, nil
+}
+
+// WaivePayment miễn toàn bộ số tiền còn nợ của phiên. Bắt buộc có lý do để đối soát.
+func (s *PaymentService) WaivePayment(ctx context.Context, sessionID int, dto domain.WaivePaymentDTO, recordedBy string) (*domain.Payment, error) {
+	reason := strings.TrimSpace(dto.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: cần nhập lý do miễn phí", ErrPaymentNotAllowed)
+	}
+	session, summary, err := s.loadPayableSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	payment := &domain.Payment{
+		SessionID:  session.ID,
+		Method:     domain.PaymentMethodWaiver,
+		Amount:     summary.Outstanding,
+		Currency:   paymentCurrency,
+		Status:     domain.PaymentRecordSucceeded,
+		Note:       null.StringFrom(reason),
+		RecordedBy: null.NewString(recordedBy, recordedBy != ""),
+		PaidAt:     null.TimeFrom(now),
+	}
+	created, err := s.paymentRepo.Create(ctx, payment)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lưu miễn phí: %w", err)
+	}
+	log.Printf("PaymentService: %s miễn phí %.2f cho phiên %d. Lý do: %s", recordedBy, summary.Outstanding, session.ID, reason)
+
+	summary, err = s.refreshSessionPaymentStatus(ctx, session.ID)
+	if err != nil {
+		log.Printf("PaymentService: Lỗi cập nhật payment_status của phiên %d: %v", session.ID, err)
+	} else {
+		s.notifySettled(ctx, summary, recordedBy)
+	}
+	return created, nil
+}
+
+// RefundPayment hoàn (một phần) tiền của một payment. Yêu cầu hoàn được lưu ở trạng thái pending trước khi gọi
+// cổng thanh toán để số tiền đã chuyển đi luôn có bản ghi; bản ghi pending vẫn được trừ vào số tiền có thể hoàn.
+func (s *PaymentService) RefundPayment(ctx context.Context, paymentID int, dto domain.RefundPaymentDTO, recordedBy string) (*domain.PaymentRefund, error) {
+	payment, err := s.paymentRepo.FindByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Method == domain.PaymentMethodWaiver {
+		return nil, fmt.Errorf("%w: không thể hoàn tiền cho khoản miễn phí", ErrPaymentNotAllowed)
+	}
+	if payment.Status != domain.PaymentRecordSucceeded && payment.Status != domain.PaymentRecordPartiallyRefunded {
+		return nil, fmt.Errorf("%w: giao dịch ở trạng thái '%s' không thể hoàn tiền", ErrPaymentNotAllowed, payment.Status)
+	}
+	amount, err := resolvePaymentAmount(dto.Amount, payment.Amount-payment.RefundedAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := s.refundRepo.CreatePending(ctx, &domain.PaymentRefund{
+		PaymentID:  payment.ID,
+		Amount:     amount,
+		Reason:     dto.Reason,
+		RecordedBy: null.NewString(recordedBy, recordedBy != ""),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: số tiền hoàn vượt quá số tiền còn lại của giao dịch (kể cả các yêu cầu hoàn đang xử lý)", ErrInvalidPaymentAmount)
+		}
+		return nil, fmt.Errorf("lỗi lưu yêu cầu hoàn tiền: %w", err)
+	}
+
+	if payment.Method == domain.PaymentMethodGateway {
+		resp, gwErr := s.gateway.Refund(ctx, GatewayRefundRequest{
+			GatewayReference: payment.GatewayReference.String,
+			Amount:           amount,
+			Reason:           dto.Reason,
+		})
+		if gwErr != nil {
+			refund.FailureReason = null.StringFrom(gwErr.Error())
+			if err := s.refundRepo.MarkFailed(ctx, refund); err != nil {
+				log.Printf("PaymentService: Lỗi đánh dấu hoàn tiền %d thất bại cho giao dịch %d: %v", refund.ID, payment.ID, err)
+			}
+			return nil, fmt.Errorf("lỗi hoàn tiền tại cổng thanh toán %s: %w", s.gateway.Name(), gwErr)
+		}
+		refund.GatewayReference = null.StringFrom(resp.RefundReference)
+	}
+
+	if err := s.refundRepo.Complete(ctx, refund); err != nil {
+		// Tiền có thể đã được hoàn tại cổng thanh toán: bản ghi vẫn pending (giữ số tiền) để đối soát thủ công
+		log.Printf("PaymentService: CẢNH BÁO: Hoàn tiền %d (giao dịch %d, mã cổng '%s') chưa được ghi nhận: %v",
+			refund.ID, payment.ID, refund.GatewayReference.String, err)
+		return nil, fmt.Errorf("lỗi ghi nhận hoàn tiền %d, cần đối soát: %w", refund.ID, err)
+	}
+	log.Printf("PaymentService: %s hoàn %.2f cho giao dịch %d (phiên %d). Lý do: %s", recordedBy, amount, payment.ID, payment.SessionID, dto.Reason)
+
+	if _, err := s.refreshSessionPaymentStatus(ctx, payment.SessionID); err != nil {
+		log.Printf("PaymentService: Lỗi cập nhật payment_status của phiên %d: %v", payment.SessionID, err)
+	}
+	return refund, nil
+}
+
+// GetSessionPayments trả về tổng hợp thanh toán và danh sách giao dịch (kèm hoàn tiền) của phiên
+func (s *PaymentService) GetSessionPayments(ctx context.Context, sessionID int) (*domain.SessionPaymentSummary, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.buildSummary(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summary.Payments {
+		refunds, err := s.refundRepo.FindByPaymentID(ctx, summary.Payments[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("lỗi lấy danh sách hoàn tiền: %w", err)
+		}
+		summary.Payments[i].Refunds = refunds
+	}
+	return summary, nil
+}
+
+// SyncSessionPaymentStatus tính lại payment_status sau khi phí của phiên thay đổi (ví dụ tính phí tại cổng ra)
+func (s *PaymentService) SyncSessionPaymentStatus(ctx context.Context, sessionID int) (*domain.SessionPaymentSummary, error) {
+	return s.refreshSessionPaymentStatus(ctx, sessionID)
+}
+
+// loadPayableSession kiểm tra phiên đã có phí và còn nợ
+func (s *PaymentService) loadPayableSession(ctx context.Context, sessionID int) (*domain.ParkingSession, *domain.SessionPaymentSummary, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !session.CalculatedFee.Valid {
+		return nil, nil, fmt.Errorf("%w: phiên %d chưa được tính phí", ErrPaymentNotAllowed, sessionID)
+	}
+	summary, err := s.buildSummary(ctx, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	if summary.PaymentStatus == domain.PaymentStatusWaived || summary.Outstanding <= 0 {
+		if summary.PendingAmount > 0 && summary.PaymentStatus != domain.PaymentStatusWaived {
+			return nil, nil, fmt.Errorf("%w: phiên %d đang có giao dịch %.2f chờ cổng thanh toán xác nhận",
+				ErrPaymentNotAllowed, sessionID, summary.PendingAmount)
+		}
+		return nil, nil, fmt.Errorf("%w: phiên %d không còn nợ (trạng thái: %s)", ErrPaymentNotAllowed, sessionID, summary.PaymentStatus)
+	}
+	return session, summary, nil
+}
+
+func (s *PaymentService) buildSummary(ctx context.Context, session *domain.ParkingSession) (*domain.SessionPaymentSummary, error) {
+	payments, err := s.paymentRepo.FindBySessionID(ctx, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi lấy danh sách thanh toán: %w", err)
+	}
+	if payments == nil {
+		payments = []domain.Payment{}
+	}
+	paid, pending, waived, lastFailed := 0.0, 0.0, false, false
+	pendingSince := time.Now().Add(-pendingPaymentHold)
+	for _, p := range payments {
+		switch p.Status {
+		case domain.PaymentRecordPending:
+			if p.CreatedAt.After(pendingSince) {
+				pending += p.Amount
+			}
+		case domain.PaymentRecordSucceeded, domain.PaymentRecordPartiallyRefunded, domain.PaymentRecordRefunded:
+			if p.Method == domain.PaymentMethodWaiver {
+				waived = true
+				continue
+			}
+			paid += p.Amount - p.RefundedAmount
+			lastFailed = false
+		case domain.PaymentRecordFailed:
+			lastFailed = true
+		}
+	}
+
+	summary := &domain.SessionPaymentSummary{
+		SessionID:     session.ID,
+		CalculatedFee: session.CalculatedFee.Float64,
+		PaidAmount:    math.Round(paid*100) / 100,
+		PendingAmount: math.Round(pending*100) / 100,
+		Payments:      payments,
+	}
+	due := math.Round((summary.CalculatedFee-summary.PaidAmount)*100) / 100
+	summary.Outstanding = math.Max(0, math.Round((due-summary.PendingAmount)*100)/100)
+
+	switch {
+	case waived:
+		summary.PaymentStatus = domain.PaymentStatusWaived
+		summary.Outstanding = 0
+	case session.CalculatedFee.Valid && due <= 0:
+		summary.PaymentStatus = domain.PaymentStatusPaid
+	case paid > 0:
+		summary.PaymentStatus = domain.PaymentStatusPartiallyPaid
+	case lastFailed:
+		summary.PaymentStatus = domain.PaymentStatusFailed
+	default:
+		summary.PaymentStatus = domain.PaymentStatusPending
+	}
+	return summary, nil
+}
+
+// refreshSessionPaymentStatus tính lại và lưu payment_status của phiên từ danh sách payments
+func (s *PaymentService) refreshSessionPaymentStatus(ctx context.Context, sessionID int) (*domain.SessionPaymentSummary, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.buildSummary(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if summary.PaymentStatus != session.PaymentStatus {
+		if err := s.sessionRepo.UpdatePaymentStatus(ctx, sessionID, summary.PaymentStatus); err != nil {
+			return nil, err
+		}
+		log.Printf("PaymentService: Phiên %d chuyển payment_status %s -> %s", sessionID, session.PaymentStatus, summary.PaymentStatus)
+	}
+	return summary, nil
+}
+
+// notifySettled báo phiên đã hết nợ (paid / waived) cho onSettled
+func (s *PaymentService) notifySettled(ctx context.Context, summary *domain.SessionPaymentSummary, settledBy string) {
+	if s.onSettled == nil {
+		return
+	}
+	if summary.PaymentStatus == domain.PaymentStatusPaid || summary.PaymentStatus == domain.PaymentStatusWaived {
+		s.onSettled(ctx, summary.SessionID, settledBy)
+	}
+}
+
+// resolvePaymentAmount: requested = 0 nghĩa là toàn bộ số còn lại
+func resolvePaymentAmount(requested float64, remaining float64) (float64, error) {
+	if remaining <= 0 {
+		return 0, fmt.Errorf("%w: không còn số tiền nào để xử lý", ErrInvalidPaymentAmount)
+	}
+	if requested == 0 {
+		return remaining, nil
+	}
+	if requested < 0 || requested > remaining+0.005 {
+		return 0, fmt.Errorf("%w: %.2f (tối đa %.2f)", ErrInvalidPaymentAmount, requested, remaining)
+	}
+	return requested, nil
+}
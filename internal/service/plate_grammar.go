@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+)
+
+var ErrInvalidPlateGrammar = errors.New("định dạng biển số không hợp lệ")
+
+// validatePlateGrammars kiểm tra các mã grammar bãi cấu hình đều đã được đăng ký
+func validatePlateGrammars(codes []string) error {
+	if err := plate.ValidateCodes(codes); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPlateGrammar, err)
+	}
+	return nil
+}
+
+// lotPlateGrammars trả về các grammar bãi chấp nhận, mặc định là các định dạng Việt Nam
+func lotPlateGrammars(lot *domain.ParkingLot) []string {
+	if lot == nil || len(lot.AcceptedPlateGrammars) == 0 {
+		return plate.DefaultVNGrammars
+	}
+	return lot.AcceptedPlateGrammars
+}
+
+// FilterLPRCandidatesForLot giữ lại các ứng viên LPR có định dạng bãi chấp nhận, giữ nguyên thứ tự
+func (s *ParkingService) FilterLPRCandidatesForLot(ctx context.Context, lotID int, candidates []domain.LPRCandidate) ([]domain.LPRCandidate, error) {
+	lot, err := s.lotRepo.FindByID(ctx, lotID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy bãi đỗ %d: %w", lotID, err)
+	}
+	accepted := make(map[string]bool)
+	for _, code := range lotPlateGrammars(lot) {
+		accepted[code] = true
+	}
+
+	var filtered []domain.LPRCandidate
+	for _, candidate := range candidates {
+		if accepted[candidate.Grammar] {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+	"smart_parking/internal/repository"
+	"sort"
+)
+
+// Ngưỡng điểm tối thiểu để một biển số đang active được gợi ý cho operator
+const minPlateSimilarityScore = 0.5
+
+// ErrPlateMismatch - phiên operator chọn không thuộc danh sách biển số gần giống của xe đang ra
+var ErrPlateMismatch = errors.New("biển số không khớp với phiên đỗ xe được chọn")
+
+// FindSimilarActiveSessions trả về tối đa limit phiên active trong bãi có biển số gần giống vehicleID,
+// xếp theo điểm giảm dần. Dùng khi không khớp chính xác phiên nào.
+func (s *ParkingService) FindSimilarActiveSessions(ctx context.Context, lotID int, vehicleID string, limit int) ([]domain.PlateCandidate, error) {
+	sessions, err := s.sessionRepo.GetActiveSessionsByLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []domain.PlateCandidate
+	for _, session := range sessions {
+		if !session.VehicleIdentifier.Valid {
+			continue
+		}
+		score := plate.Similarity(vehicleID, session.VehicleIdentifier.String)
+		if score < minPlateSimilarityScore {
+			continue
+		}
+		candidates = append(candidates, domain.PlateCandidate{
+			SessionID:         session.ID,
+			VehicleIdentifier: session.VehicleIdentifier.String,
+			EntryTime:         session.EntryTime,
+			Distance:          plate.Distance(vehicleID, session.VehicleIdentifier.String),
+			Score:             score,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].EntryTime.Before(candidates[j].EntryTime)
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// findActiveSessionForExit tìm phiên active của xe theo biển số chuẩn hóa, chỉ khớp chính xác. Biển số gần giống
+// không bao giờ tự kết thúc phiên: người gọi gợi ý FindSimilarActiveSessions để operator xác nhận biển số đúng.
+func (s *ParkingService) findActiveSessionForExit(ctx context.Context, lotID int, vehicleID string) (*domain.ParkingSession, error) {
+	return s.sessionRepo.FindActiveByVehicleIdentifier(ctx, lotID, normalizeVehicleIdentifier(vehicleID))
+}
+
+// confirmSessionForExit kiểm tra phiên operator chọn từ danh sách gợi ý: phiên phải đang active trong bãi và
+// biển số đủ giống vehicleID (cùng ngưỡng với FindSimilarActiveSessions). Trả về phiên kèm điểm khớp.
+func (s *ParkingService) confirmSessionForExit(ctx context.Context, lotID, sessionID int, vehicleID string) (*domain.ParkingSession, float64, error) {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if session.LotID != lotID || session.Status != domain.SessionActive {
+		return nil, 0, fmt.Errorf("%w: phiên %d không còn đỗ tại bãi %d", repository.ErrNoActiveSession, sessionID, lotID)
+	}
+	if !session.VehicleIdentifier.Valid {
+		return nil, 0, fmt.Errorf("%w: phiên %d chưa có biển số", ErrPlateMismatch, sessionID)
+	}
+	score := plate.Similarity(vehicleID, session.VehicleIdentifier.String)
+	if score < minPlateSimilarityScore {
+		return nil, 0, fmt.Errorf("%w: '%s' và '%s' (điểm %.3f)", ErrPlateMismatch, vehicleID, session.VehicleIdentifier.String, score)
+	}
+	return session, score, nil
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/guregu/null.v4"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/plate"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+var (
+	ErrInvalidReservation  = errors.New("đặt chỗ không hợp lệ")
+	ErrReservationConflict = errors.New("không còn chỗ trống cho khung giờ đặt")
+)
+
+type ReservationService struct {
+	reservationRepo repository.ReservationRepository
+	slotRepo        repository.ParkingSlotRepository
+	lotRepo         repository.ParkingLotRepository
+	noShowTimeout   time.Duration
+}
+
+func NewReservationService(reservationRepo repository.ReservationRepository, slotRepo repository.ParkingSlotRepository,
+	lotRepo repository.ParkingLotRepository, noShowTimeout time.Duration) *ReservationService {
+	return &ReservationService{
+		reservationRepo: reservationRepo,
+		slotRepo:        slotRepo,
+		lotRepo:         lotRepo,
+		noShowTimeout:   noShowTimeout,
+	}
+}
+
+// normalizeVehicleIdentifier chuẩn hóa biển số (plate.Canonical) trước khi lưu hoặc tra cứu, để phiên đỗ, đặt chỗ,
+// vé tháng và danh sách cảnh báo cùng so khớp được "51F-123.45" với "51F12345"
+func normalizeVehicleIdentifier(vehicleID string) string {
+	return plate.Canonical(vehicleID)
+}
+
+func (s *ReservationService) CreateReservation(ctx context.Context, dto domain.CreateReservationDTO, createdBy string) (*domain.Reservation, error) {
+	startTime, err := time.Parse(time.RFC3339, dto.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: start_time phải theo định dạng RFC3339", ErrInvalidReservation)
+	}
+	endTime, err := time.Parse(time.RFC3339, dto.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: end_time phải theo định dạng RFC3339", ErrInvalidReservation)
+	}
+	startTime, endTime = startTime.UTC(), endTime.UTC()
+	if !endTime.After(startTime) {
+		return nil, fmt.Errorf("%w: end_time phải sau start_time", ErrInvalidReservation)
+	}
+	if endTime.Before(time.Now().UTC()) {
+		return nil, fmt.Errorf("%w: khung giờ đặt đã kết thúc", ErrInvalidReservation)
+	}
+	plate := normalizeVehicleIdentifier(dto.VehicleIdentifier)
+	if plate == "" {
+		return nil, fmt.Errorf("%w: thiếu biển số", ErrInvalidReservation)
+	}
+
+	if _, err := s.lotRepo.FindByID(ctx, dto.LotID); err != nil {
+		return nil, err
+	}
+
+	if dto.SlotID != nil {
+		slot, err := s.slotRepo.FindByID(ctx, *dto.SlotID)
+		if err != nil {
+			return nil, err
+		}
+		if slot.LotID != dto.LotID {
+			return nil, fmt.Errorf("%w: chỗ đỗ %d không thuộc bãi %d", ErrInvalidReservation, slot.ID, dto.LotID)
+		}
+		if slot.Status == domain.StatusMaintenance {
+			return nil, fmt.Errorf("%w: chỗ đỗ %s đang bảo trì", ErrReservationConflict, slot.SlotIdentifier)
+		}
+		overlapping, err := s.reservationRepo.CountOverlapping(ctx, dto.LotID, dto.SlotID, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		if overlapping > 0 {
+			return nil, fmt.Errorf("%w: chỗ đỗ %s đã được đặt trong khung giờ này", ErrReservationConflict, slot.SlotIdentifier)
+		}
+	}
+
+	// Đặt chỗ "bất kỳ chỗ nào" chưa được gán chỗ cũng chiếm sức chứa của bãi, nên cả đặt chỗ cụ thể lẫn bất kỳ đều
+	// phải kiểm tra tổng số đặt chỗ chồng lấn không vượt số chỗ có thể dùng của bãi
+	if err := s.checkLotCapacity(ctx, dto.LotID, startTime, endTime); err != nil {
+		return nil, err
+	}
+
+	noShowAt := startTime.Add(s.noShowTimeout)
+	if noShowAt.After(endTime) {
+		noShowAt = endTime
+	}
+	reservation := &domain.Reservation{
+		LotID:             dto.LotID,
+		AnySlot:           dto.SlotID == nil,
+		VehicleIdentifier: plate,
+		StartTime:         startTime,
+		EndTime:           endTime,
+		NoShowAt:          noShowAt,
+		Status:            domain.ReservationScheduled,
+		Note:              null.NewString(dto.Note, dto.Note != ""),
+		CreatedBy:         null.NewString(createdBy, createdBy != ""),
+	}
+	if dto.SlotID != nil {
+		reservation.SlotID = null.IntFrom(int64(*dto.SlotID))
+	}
+
+	created, err := s.reservationRepo.Create(ctx, reservation)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("ReservationService: Tạo đặt chỗ %d cho xe '%s' tại bãi %d (%v - %v)", created.ID, plate, dto.LotID, startTime, endTime)
+
+	// Khung giờ đã bắt đầu: giữ chỗ ngay thay vì chờ job
+	if !startTime.After(time.Now().UTC()) {
+		s.holdReservation(ctx, created)
+	}
+	return created, nil
+}
+
+// checkLotCapacity trả về ErrReservationConflict nếu số đặt chỗ đang mở chồng lấn [start, end) của bãi đã bằng số chỗ
+// không bảo trì
+func (s *ReservationService) checkLotCapacity(ctx context.Context, lotID int, start, end time.Time) error {
+	slots, err := s.slotRepo.FindByLotID(ctx, lotID)
+	if err != nil {
+		return fmt.Errorf("lỗi lấy danh sách chỗ đỗ của bãi %d: %w", lotID, err)
+	}
+	usable := 0
+	for _, slot := range slots {
+		if slot.Status != domain.StatusMaintenance {
+			usable++
+		}
+	}
+	overlapping, err := s.reservationRepo.CountOverlapping(ctx, lotID, nil, start, end)
+	if err != nil {
+		return err
+	}
+	if overlapping >= usable {
+		return fmt.Errorf("%w: bãi %d đã kín đặt chỗ (%d/%d)", ErrReservationConflict, lotID, overlapping, usable)
+	}
+	return nil
+}
+
+func (s *ReservationService) GetReservation(ctx context.Context, id int) (*domain.Reservation, error) {
+	return s.reservationRepo.FindByID(ctx, id)
+}
+
+func (s *ReservationService) FindReservations(ctx context.Context, filter domain.ReservationFilterDTO) ([]domain.Reservation, error) {
+	if filter.VehicleIdentifier != nil {
+		plate := normalizeVehicleIdentifier(*filter.VehicleIdentifier)
+		filter.VehicleIdentifier = &plate
+	}
+	return s.reservationRepo.Find(ctx, filter)
+}
+
+func (s *ReservationService) CancelReservation(ctx context.Context, id int) (*domain.Reservation, error) {
+	reservation, err := s.reservationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.reservationRepo.UpdateStatus(ctx, id,
+		[]domain.ReservationStatus{domain.ReservationScheduled, domain.ReservationHeld}, domain.ReservationCancelled)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: đặt chỗ %d đang ở trạng thái %s", ErrInvalidReservation, id, reservation.Status)
+		}
+		return nil, err
+	}
+	if reservation.Status == domain.ReservationHeld {
+		s.releaseSlot(ctx, reservation, "reservation_cancelled")
+	}
+	return s.reservationRepo.FindByID(ctx, id)
+}
+
+// ProcessDueReservations được gọi định kỳ: giữ chỗ cho đặt chỗ đã tới giờ và nhả chỗ của xe không đến
+func (s *ReservationService) ProcessDueReservations(ctx context.Context, now time.Time) (held int, noShows int, err error) {
+	expired, err := s.reservationRepo.FindNoShows(ctx, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range expired {
+		reservation := &expired[i]
+		err := s.reservationRepo.UpdateStatus(ctx, reservation.ID,
+			[]domain.ReservationStatus{domain.ReservationScheduled, domain.ReservationHeld}, domain.ReservationNoShow)
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				log.Printf("Lỗi chuyển đặt chỗ %d sang no_show: %v", reservation.ID, err)
+			}
+			continue
+		}
+		if reservation.Status == domain.ReservationHeld {
+			s.releaseSlot(ctx, reservation, "reservation_no_show")
+		}
+		log.Printf("ReservationService: Đặt chỗ %d của xe '%s' quá hạn không đến, đã nhả chỗ", reservation.ID, reservation.VehicleIdentifier)
+		noShows++
+	}
+
+	due, err := s.reservationRepo.FindDueForHold(ctx, now)
+	if err != nil {
+		return 0, noShows, err
+	}
+	for i := range due {
+		if s.holdReservation(ctx, &due[i]) {
+			held++
+		}
+	}
+	return held, noShows, nil
+}
+
+// holdReservation gán chỗ (nếu đặt bất kỳ) và chuyển chỗ sang reserved. Trả về false nếu chưa giữ được, job sẽ thử lại.
+func (s *ReservationService) holdReservation(ctx context.Context, reservation *domain.Reservation) bool {
+	var slot *domain.ParkingSlot
+	var err error
+	if reservation.SlotID.Valid {
+		slot, err = s.slotRepo.FindByID(ctx, int(reservation.SlotID.Int64))
+		if err != nil {
+			log.Printf("Lỗi tìm chỗ đỗ %d cho đặt chỗ %d: %v", reservation.SlotID.Int64, reservation.ID, err)
+			return false
+		}
+		if slot.Status != domain.StatusVacant && slot.Status != domain.StatusReserved {
+			log.Printf("Chỗ đỗ %s của đặt chỗ %d đang ở trạng thái %s, chưa thể giữ chỗ", slot.SlotIdentifier, reservation.ID, slot.Status)
+			return false
+		}
+	} else {
+		slot, err = s.slotRepo.FindFirstAvailableByLotID(ctx, reservation.LotID, "")
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				log.Printf("Lỗi tìm chỗ trống cho đặt chỗ %d: %v", reservation.ID, err)
+			} else {
+				log.Printf("Bãi %d chưa có chỗ trống để giữ cho đặt chỗ %d", reservation.LotID, reservation.ID)
+			}
+			return false
+		}
+	}
+
+	if err := s.reservationRepo.Hold(ctx, reservation.ID, slot.ID); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Lỗi giữ chỗ cho đặt chỗ %d: %v", reservation.ID, err)
+		}
+		return false
+	}
+	now := time.Now().UTC()
+	if err := s.slotRepo.UpdateStatus(ctx, slot.ID, domain.StatusReserved, &now, "reservation"); err != nil {
+		log.Printf("Lỗi cập nhật chỗ đỗ %d thành reserved: %v", slot.ID, err)
+	}
+	reservation.SlotID = null.IntFrom(int64(slot.ID))
+	reservation.Status = domain.ReservationHeld
+	log.Printf("ReservationService: Giữ chỗ %s (ID: %d) cho đặt chỗ %d của xe '%s'", slot.SlotIdentifier, slot.ID, reservation.ID, reservation.VehicleIdentifier)
+	return true
+}
+
+// releaseSlot trả chỗ về vacant nếu chỗ vẫn đang reserved (xe khác có thể đã đỗ vào)
+func (s *ReservationService) releaseSlot(ctx context.Context, reservation *domain.Reservation, source string) {
+	if !reservation.SlotID.Valid {
+		return
+	}
+	slot, err := s.slotRepo.FindByID(ctx, int(reservation.SlotID.Int64))
+	if err != nil {
+		log.Printf("Lỗi tìm chỗ đỗ %d khi nhả đặt chỗ %d: %v", reservation.SlotID.Int64, reservation.ID, err)
+		return
+	}
+	if slot.Status != domain.StatusReserved {
+		return
+	}
+	now := time.Now().UTC()
+	if err := s.slotRepo.UpdateStatus(ctx, slot.ID, domain.StatusVacant, &now, source); err != nil {
+		log.Printf("Lỗi nhả chỗ đỗ %d của đặt chỗ %d: %v", slot.ID, reservation.ID, err)
+	}
+}
+
+// FindForCheckIn tìm đặt chỗ còn hiệu lực của xe khi vào bãi. Cho phép đến sớm tối đa bằng thời gian no-show.
+func (s *ReservationService) FindForCheckIn(ctx context.Context, lotID int, plate string, entryTime time.Time) (*domain.Reservation, error) {
+	reservation, err := s.reservationRepo.FindOpenForVehicle(ctx, lotID, normalizeVehicleIdentifier(plate), entryTime, entryTime.Add(s.noShowTimeout))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ConsumeReservation đánh dấu đặt chỗ đã dùng cho phiên đỗ xe
+func (s *ReservationService) ConsumeReservation(ctx context.Context, reservationID int, sessionID int) error {
+	if err := s.reservationRepo.Consume(ctx, reservationID, sessionID); err != nil {
+		return fmt.Errorf("lỗi đánh dấu đặt chỗ %d đã sử dụng: %w", reservationID, err)
+	}
+	log.Printf("ReservationService: Đặt chỗ %d đã được dùng cho phiên %d", reservationID, sessionID)
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/guregu/null.v4"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+var ErrInvalidSubscription = errors.New("vé tháng không hợp lệ")
+
+type SubscriptionService struct {
+	subscriptionRepo repository.SubscriptionRepository
+	sessionRepo      repository.ParkingSessionRepository
+	lotRepo          repository.ParkingLotRepository
+}
+
+func NewSubscriptionService(subscriptionRepo repository.SubscriptionRepository, sessionRepo repository.ParkingSessionRepository,
+	lotRepo repository.ParkingLotRepository) *SubscriptionService {
+	return &SubscriptionService{
+		subscriptionRepo: subscriptionRepo,
+		sessionRepo:      sessionRepo,
+		lotRepo:          lotRepo,
+	}
+}
+
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, dto domain.SubscriptionDTO) (*domain.Subscription, error) {
+	subscription := &domain.Subscription{IsActive: true}
+	if err := s.applySubscriptionDTO(ctx, subscription, dto); err != nil {
+		return nil, err
+	}
+	created, err := s.subscriptionRepo.Create(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SubscriptionService: Tạo vé tháng %d cho '%s' (%v)", created.ID, created.HolderName, created.VehicleIdentifiers)
+	return created, nil
+}
+
+func (s *SubscriptionService) GetSubscription(ctx context.Context, id int) (*domain.Subscription, error) {
+	return s.subscriptionRepo.FindByID(ctx, id)
+}
+
+func (s *SubscriptionService) FindSubscriptions(ctx context.Context, filter domain.SubscriptionFilterDTO) ([]domain.Subscription, error) {
+	if filter.VehicleIdentifier != nil {
+		plate := normalizeVehicleIdentifier(*filter.VehicleIdentifier)
+		filter.VehicleIdentifier = &plate
+	}
+	return s.subscriptionRepo.Find(ctx, filter)
+}
+
+func (s *SubscriptionService) UpdateSubscription(ctx context.Context, id int, dto domain.SubscriptionDTO) (*domain.Subscription, error) {
+	subscription, err := s.subscriptionRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applySubscriptionDTO(ctx, subscription, dto); err != nil {
+		return nil, err
+	}
+	return s.subscriptionRepo.Update(ctx, subscription)
+}
+
+func (s *SubscriptionService) DeleteSubscription(ctx context.Context, id int) error {
+	return s.subscriptionRepo.Delete(ctx, id)
+}
+
+func (s *SubscriptionService) applySubscriptionDTO(ctx context.Context, subscription *domain.Subscription, dto domain.SubscriptionDTO) error {
+	validFrom, err := time.Parse(time.RFC3339, dto.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("%w: valid_from phải theo định dạng RFC3339", ErrInvalidSubscription)
+	}
+	validUntil, err := time.Parse(time.RFC3339, dto.ValidUntil)
+	if err != nil {
+		return fmt.Errorf("%w: valid_until phải theo định dạng RFC3339", ErrInvalidSubscription)
+	}
+	if !validUntil.After(validFrom) {
+		return fmt.Errorf("%w: valid_until phải sau valid_from", ErrInvalidSubscription)
+	}
+
+	plates := make([]string, 0, len(dto.VehicleIdentifiers))
+	seen := make(map[string]bool)
+	for _, p := range dto.VehicleIdentifiers {
+		plate := normalizeVehicleIdentifier(p)
+		if plate == "" || seen[plate] {
+			continue
+		}
+		seen[plate] = true
+		plates = append(plates, plate)
+	}
+	if len(plates) == 0 {
+		return fmt.Errorf("%w: cần ít nhất một biển số", ErrInvalidSubscription)
+	}
+
+	for _, lotID := range dto.LotIDs {
+		if _, err := s.lotRepo.FindByID(ctx, lotID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%w: bãi đỗ %d không tồn tại", ErrInvalidSubscription, lotID)
+			}
+			return err
+		}
+	}
+
+	if (dto.AllowedStartTime == "") != (dto.AllowedEndTime == "") {
+		return fmt.Errorf("%w: cần cả allowed_start_time và allowed_end_time", ErrInvalidSubscription)
+	}
+	if dto.AllowedStartTime != "" {
+		if _, err := parseClock(dto.AllowedStartTime); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+		}
+		if _, err := parseClock(dto.AllowedEndTime); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+		}
+	}
+
+	tz := dto.Timezone
+	if tz == "" {
+		tz = domain.DefaultTariffTimezone
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("%w: timezone '%s' không hợp lệ", ErrInvalidSubscription, tz)
+	}
+
+	subscription.HolderName = dto.HolderName
+	subscription.HolderContact = null.NewString(dto.HolderContact, dto.HolderContact != "")
+	subscription.VehicleIdentifiers = plates
+	subscription.LotIDs = dto.LotIDs
+	if subscription.LotIDs == nil {
+		subscription.LotIDs = []int{}
+	}
+	subscription.ValidFrom = validFrom.UTC()
+	subscription.ValidUntil = validUntil.UTC()
+	subscription.WeekdayMask = dto.WeekdayMask
+	if subscription.WeekdayMask == 0 {
+		subscription.WeekdayMask = domain.WeekdayMaskAll
+	}
+	subscription.AllowedStartTime = null.NewString(dto.AllowedStartTime, dto.AllowedStartTime != "")
+	subscription.AllowedEndTime = null.NewString(dto.AllowedEndTime, dto.AllowedEndTime != "")
+	subscription.Timezone = tz
+	subscription.MaxConcurrentVehicles = dto.MaxConcurrentVehicles
+	if subscription.MaxConcurrentVehicles == 0 {
+		subscription.MaxConcurrentVehicles = 1
+	}
+	if dto.IsActive != nil {
+		subscription.IsActive = *dto.IsActive
+	}
+	subscription.Note = null.NewString(dto.Note, dto.Note != "")
+	return nil
+}
+
+// FindCoveringPass trả về vé tháng bao phí cho xe vào bãi tại thời điểm at:
+// còn hạn, đúng bãi, đúng ngày/khung giờ cho phép và chưa vượt số xe đồng thời. nil nếu không có.
+func (s *SubscriptionService) FindCoveringPass(ctx context.Context, lotID int, plate string, at time.Time) (*domain.Subscription, error) {
+	candidates, err := s.subscriptionRepo.FindValidForVehicle(ctx, lotID, normalizeVehicleIdentifier(plate), at)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		subscription := &candidates[i]
+		if !subscriptionAllowsTime(subscription, at) {
+			log.Printf("Vé tháng %d của xe '%s' không áp dụng vào thời điểm %v", subscription.ID, plate, at)
+			continue
+		}
+		active, err := s.sessionRepo.CountActiveBySubscription(ctx, subscription.ID)
+		if err != nil {
+			return nil, err
+		}
+		if active >= subscription.MaxConcurrentVehicles {
+			log.Printf("Vé tháng %d đã có %d/%d xe trong bãi, xe '%s' được tính phí như khách vãng lai",
+				subscription.ID, active, subscription.MaxConcurrentVehicles, plate)
+			continue
+		}
+		return subscription, nil
+	}
+	return nil, nil
+}
+
+// subscriptionAllowsTime kiểm tra ngày trong tuần và khung giờ cho phép theo timezone của vé
+func subscriptionAllowsTime(subscription *domain.Subscription, at time.Time) bool {
+	loc, err := time.LoadLocation(subscription.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := at.In(loc)
+	if subscription.WeekdayMask&(1<<uint(local.Weekday())) == 0 {
+		return false
+	}
+	if !subscription.AllowedStartTime.Valid || !subscription.AllowedEndTime.Valid {
+		return true
+	}
+	start, errStart := parseClock(subscription.AllowedStartTime.String)
+	end, errEnd := parseClock(subscription.AllowedEndTime.String)
+	if errStart != nil || errEnd != nil || start == end {
+		return true
+	}
+	return inClockWindow(minuteOfDay(local), start, end)
+}
@@ -0,0 +1,614 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/guregu/null.v4"
+	"log"
+	"math"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+var ErrInvalidTariff = errors.New("biểu phí không hợp lệ")
+
+var ErrTariffInUse = errors.New("biểu phí đã được dùng để tính phí cho phiên đỗ xe")
+
+// Cho phép effective_from lệch về quá khứ tối đa chừng này (độ trễ đồng hồ của client)
+const effectiveFromSkew = time.Minute
+
+const (
+	holidayDateLayout = "2006-01-02"
+	feeItemBase       = "base"
+	feeItemOvernight  = "overnight"
+)
+
+type TariffService struct {
+	tariffRepo  repository.TariffRepository
+	holidayRepo repository.HolidayRepository
+	lotRepo     repository.ParkingLotRepository
+}
+
+func NewTariffService(tariffRepo repository.TariffRepository, holidayRepo repository.HolidayRepository,
+	lotRepo repository.ParkingLotRepository) *TariffService {
+	return &TariffService{
+		tariffRepo:  tariffRepo,
+		holidayRepo: holidayRepo,
+		lotRepo:     lotRepo,
+	}
+}
+
+// --- Tariff CRUD ---
+func (s *TariffService) CreateTariff(ctx context.Context, lotID int, dto domain.TariffDTO) (*domain.Tariff, error) {
+	if _, err := s.lotRepo.FindByID(ctx, lotID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("%w: bãi đỗ xe với ID %d không tồn tại", repository.ErrNotFound, lotID)
+		}
+		return nil, fmt.Errorf("lỗi khi kiểm tra bãi đỗ xe: %w", err)
+	}
+
+	now := time.Now().UTC()
+	tariff := &domain.Tariff{LotID: lotID, Version: 1, EffectiveFrom: now}
+	if err := applyTariffDTO(tariff, dto); err != nil {
+		return nil, err
+	}
+	if err := checkEffectiveFrom(tariff, now); err != nil {
+		return nil, err
+	}
+	return s.tariffRepo.Create(ctx, tariff)
+}
+
+func (s *TariffService) GetTariffsByLotID(ctx context.Context, lotID int) ([]domain.Tariff, error) {
+	return s.tariffRepo.FindByLotID(ctx, lotID)
+}
+
+func (s *TariffService) GetTariffByID(ctx context.Context, lotID int, tariffID int) (*domain.Tariff, error) {
+	tariff, err := s.tariffRepo.FindByID(ctx, tariffID)
+	if err != nil {
+		return nil, err
+	}
+	if tariff.LotID != lotID {
+		return nil, repository.ErrNotFound
+	}
+	return tariff, nil
+}
+
+// UpdateTariff sửa trực tiếp phiên bản chưa có hiệu lực. Với phiên bản đã có hiệu lực, tạo phiên bản mới
+// để các phiên đỗ xe bắt đầu trước đó vẫn được tính theo giá cũ; riêng việc ngừng áp dụng (is_active = false)
+// được cập nhật tại chỗ và không đổi giá của phiên bản: các phiên vào trước lúc ngừng vẫn tính theo phiên bản này,
+// xe vào sau đó dùng biểu phí mặc định cho đến khi có phiên bản mới.
+func (s *TariffService) UpdateTariff(ctx context.Context, lotID int, tariffID int, dto domain.TariffDTO) (*domain.Tariff, error) {
+	existing, err := s.GetTariffByID(ctx, lotID, tariffID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	if existing.EffectiveFrom.After(now) {
+		if err := applyTariffDTO(existing, dto); err != nil {
+			return nil, err
+		}
+		if err := checkEffectiveFrom(existing, now); err != nil {
+			return nil, err
+		}
+		return s.tariffRepo.Update(ctx, existing)
+	}
+
+	if !dto.IsActive {
+		if !existing.IsActive {
+			return existing, nil
+		}
+		existing.IsActive = false
+		log.Printf("TariffService: Ngừng áp dụng biểu phí %d (v%d) của bãi %d", existing.ID, existing.Version, lotID)
+		return s.tariffRepo.Update(ctx, existing)
+	}
+
+	newVersion := &domain.Tariff{
+		LotID:             lotID,
+		Version:           existing.Version + 1,
+		PreviousVersionID: null.IntFrom(int64(existing.ID)),
+		EffectiveFrom:     now,
+	}
+	if err := applyTariffDTO(newVersion, dto); err != nil {
+		return nil, err
+	}
+	if err := checkEffectiveFrom(newVersion, now); err != nil {
+		return nil, err
+	}
+	if !newVersion.EffectiveFrom.After(existing.EffectiveFrom) {
+		return nil, fmt.Errorf("%w: effective_from của phiên bản mới phải sau %s", ErrInvalidTariff,
+			existing.EffectiveFrom.Format(time.RFC3339))
+	}
+	log.Printf("TariffService: Biểu phí %d đã có hiệu lực, tạo phiên bản %d hiệu lực từ %s",
+		existing.ID, newVersion.Version, newVersion.EffectiveFrom.Format(time.RFC3339))
+	return s.tariffRepo.Create(ctx, newVersion)
+}
+
+// DeleteTariff chỉ xóa được phiên bản chưa từng được dùng để tính phí
+func (s *TariffService) DeleteTariff(ctx context.Context, lotID int, tariffID int) error {
+	if _, err := s.GetTariffByID(ctx, lotID, tariffID); err != nil {
+		return err
+	}
+	inUse, err := s.tariffRepo.HasSessions(ctx, tariffID)
+	if err != nil {
+		return fmt.Errorf("lỗi kiểm tra phiên đỗ xe dùng biểu phí %d: %w", tariffID, err)
+	}
+	if inUse {
+		return fmt.Errorf("%w: hãy ngừng áp dụng (is_active = false) thay vì xóa", ErrTariffInUse)
+	}
+	return s.tariffRepo.Delete(ctx, tariffID)
+}
+
+// checkEffectiveFrom không cho effective_from lùi về quá khứ, tránh tính lại giá các phiên đỗ xe đã diễn ra
+func checkEffectiveFrom(tariff *domain.Tariff, now time.Time) error {
+	if tariff.EffectiveFrom.Before(now.Add(-effectiveFromSkew)) {
+		return fmt.Errorf("%w: effective_from không được ở quá khứ", ErrInvalidTariff)
+	}
+	return nil
+}
+
+// applyTariffDTO validate DTO và gán vào tariff
+func applyTariffDTO(tariff *domain.Tariff, dto domain.TariffDTO) error {
+	tariff.Name = dto.Name
+	tariff.IsActive = dto.IsActive
+	tariff.PricingMode = domain.TariffPricingMode(dto.PricingMode)
+
+	switch tariff.PricingMode {
+	case domain.PricingPerMinute:
+		tariff.BlockMinutes = 1
+	case domain.PricingHourly:
+		tariff.BlockMinutes = 60
+	case domain.PricingBlock:
+		if dto.BlockMinutes <= 0 {
+			return fmt.Errorf("%w: block_minutes phải > 0 khi pricing_mode = block", ErrInvalidTariff)
+		}
+		tariff.BlockMinutes = dto.BlockMinutes
+	default:
+		return fmt.Errorf("%w: pricing_mode '%s' không được hỗ trợ", ErrInvalidTariff, dto.PricingMode)
+	}
+
+	if (dto.OvernightStart == "") != (dto.OvernightEnd == "") {
+		return fmt.Errorf("%w: cần cấu hình cả overnight_start và overnight_end", ErrInvalidTariff)
+	}
+	if dto.OvernightStart != "" {
+		if _, err := parseClock(dto.OvernightStart); err != nil {
+			return fmt.Errorf("%w: overnight_start: %v", ErrInvalidTariff, err)
+		}
+		if _, err := parseClock(dto.OvernightEnd); err != nil {
+			return fmt.Errorf("%w: overnight_end: %v", ErrInvalidTariff, err)
+		}
+	}
+	for class, multiplier := range dto.VehicleMultipliers {
+		if multiplier <= 0 {
+			return fmt.Errorf("%w: hệ số cho loại xe '%s' phải > 0", ErrInvalidTariff, class)
+		}
+	}
+
+	tz := dto.Timezone
+	if tz == "" {
+		tz = domain.DefaultTariffTimezone
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("%w: timezone '%s' không hợp lệ", ErrInvalidTariff, tz)
+	}
+
+	tariff.BlockPrice = dto.BlockPrice
+	tariff.GracePeriodMinutes = dto.GracePeriodMinutes
+	tariff.MinimumFee = dto.MinimumFee
+	tariff.DailyCap = dto.DailyCap
+	tariff.OvernightStart = dto.OvernightStart
+	tariff.OvernightEnd = dto.OvernightEnd
+	tariff.OvernightFlatRate = dto.OvernightFlatRate
+	tariff.VehicleMultipliers = dto.VehicleMultipliers
+	tariff.Timezone = tz
+
+	if dto.EffectiveFrom != "" {
+		effectiveFrom, err := time.Parse(time.RFC3339, dto.EffectiveFrom)
+		if err != nil {
+			return fmt.Errorf("%w: effective_from phải theo định dạng RFC3339", ErrInvalidTariff)
+		}
+		tariff.EffectiveFrom = effectiveFrom.UTC()
+	}
+
+	windows := make([]domain.TariffScheduleWindow, 0, len(dto.Windows))
+	for _, w := range dto.Windows {
+		start, err := parseClock(w.StartTime)
+		if err != nil {
+			return fmt.Errorf("%w: khung '%s' start_time: %v", ErrInvalidTariff, w.Name, err)
+		}
+		end, err := parseClock(w.EndTime)
+		if err != nil {
+			return fmt.Errorf("%w: khung '%s' end_time: %v", ErrInvalidTariff, w.Name, err)
+		}
+		if start == end {
+			return fmt.Errorf("%w: khung '%s' có start_time trùng end_time", ErrInvalidTariff, w.Name)
+		}
+		window := domain.TariffScheduleWindow{
+			Name:         w.Name,
+			WeekdayMask:  w.WeekdayMask,
+			HolidayOnly:  w.HolidayOnly,
+			StartTime:    w.StartTime,
+			EndTime:      w.EndTime,
+			BlockMinutes: w.BlockMinutes,
+			BlockPrice:   w.BlockPrice,
+			Priority:     w.Priority,
+		}
+		if window.WeekdayMask == 0 {
+			window.WeekdayMask = domain.WeekdayMaskAll
+		}
+		if window.BlockMinutes == 0 {
+			window.BlockMinutes = tariff.BlockMinutes
+		}
+		windows = append(windows, window)
+	}
+	tariff.Windows = windows
+	return nil
+}
+
+// --- Holidays ---
+func (s *TariffService) CreateHoliday(ctx context.Context, dto domain.HolidayDTO) (*domain.Holiday, error) {
+	if _, err := time.Parse(holidayDateLayout, dto.Date); err != nil {
+		return nil, fmt.Errorf("%w: ngày lễ phải theo định dạng YYYY-MM-DD", ErrInvalidTariff)
+	}
+	holiday := &domain.Holiday{Date: dto.Date, Name: dto.Name}
+	if dto.LotID != nil {
+		if _, err := s.lotRepo.FindByID(ctx, *dto.LotID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil, fmt.Errorf("%w: bãi đỗ xe với ID %d không tồn tại", repository.ErrNotFound, *dto.LotID)
+			}
+			return nil, fmt.Errorf("lỗi khi kiểm tra bãi đỗ xe: %w", err)
+		}
+		holiday.LotID = null.IntFrom(int64(*dto.LotID))
+	}
+	return s.holidayRepo.Create(ctx, holiday)
+}
+
+func (s *TariffService) GetHolidays(ctx context.Context, lotID *int) ([]domain.Holiday, error) {
+	return s.holidayRepo.FindByLotID(ctx, lotID)
+}
+
+func (s *TariffService) DeleteHoliday(ctx context.Context, holidayID int) error {
+	return s.holidayRepo.Delete(ctx, holidayID)
+}
+
+// --- Fee calculation ---
+
+// GetEffectiveTariff trả về phiên bản biểu phí có hiệu lực tại thời điểm at,
+// hoặc biểu phí mặc định nếu bãi chưa cấu hình
+func (s *TariffService) GetEffectiveTariff(ctx context.Context, lotID int, at time.Time) (*domain.Tariff, error) {
+	tariff, err := s.tariffRepo.FindEffectiveByLotID(ctx, lotID, at)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.DefaultTariff(lotID), nil
+		}
+		return nil, fmt.Errorf("lỗi lấy biểu phí của bãi %d: %w", lotID, err)
+	}
+	return tariff, nil
+}
+
+// CalculateSessionFee là điểm tính phí dùng chung cho mọi luồng kết thúc phiên đỗ xe.
+// Phiên luôn được tính theo phiên bản biểu phí có hiệu lực tại thời điểm xe vào.
+func (s *TariffService) CalculateSessionFee(ctx context.Context, session *domain.ParkingSession, exitTime time.Time, vehicleClass string) (*domain.FeeBreakdown, error) {
+	if session.SubscriptionID.Valid {
+		// Phiên được vé tháng bao phí
+		subscriptionID := int(session.SubscriptionID.Int64)
+		log.Printf("TariffService: Phiên %d (bãi %d) được vé tháng %d bao phí", session.ID, session.LotID, subscriptionID)
+		return &domain.FeeBreakdown{
+			TariffName:     domain.SubscriptionTariffName,
+			Items:          []domain.FeeBreakdownItem{},
+			VehicleClass:   vehicleClass,
+			Multiplier:     1,
+			SubscriptionID: &subscriptionID,
+		}, nil
+	}
+
+	tariff, err := s.GetEffectiveTariff(ctx, session.LotID, session.EntryTime)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays := make(map[string]string)
+	if hasHolidayWindow(tariff) {
+		// Nới rộng 1 ngày mỗi phía vì ngày lễ được so theo timezone của biểu phí
+		list, err := s.holidayRepo.FindInRange(ctx, session.LotID, session.EntryTime.AddDate(0, 0, -1), exitTime.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, fmt.Errorf("lỗi lấy lịch ngày lễ: %w", err)
+		}
+		for _, h := range list {
+			holidays[h.Date] = h.Name
+		}
+	}
+
+	breakdown := CalculateFee(tariff, holidays, session.EntryTime, exitTime, vehicleClass)
+	log.Printf("TariffService: Phiên %d (bãi %d) tính phí theo biểu phí '%s' v%d: %.2f (%d dòng chi tiết)",
+		session.ID, session.LotID, tariff.Name, tariff.Version, breakdown.Total, len(breakdown.Items))
+	return breakdown, nil
+}
+
+func hasHolidayWindow(t *domain.Tariff) bool {
+	for _, w := range t.Windows {
+		if w.HolidayOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleWindow - khung giờ đã parse sẵn giờ bắt đầu/kết thúc (phút trong ngày)
+type scheduleWindow struct {
+	domain.TariffScheduleWindow
+	start int
+	end   int
+}
+
+type feeBucketKey struct {
+	date     string
+	label    string
+	windowID int
+}
+
+// CalculateFee tính phí cho khoảng [entry, exit) và trả về chi tiết phí.
+// Thời gian được chia thành các đoạn tại nửa đêm và tại biên của khung qua đêm/khung giờ (theo timezone
+// của biểu phí). Mỗi đoạn thuộc về khung qua đêm (giá cố định một lần mỗi đêm), một khung giờ có giá riêng,
+// hoặc giá cơ bản. Số block được tính trên tổng số phút của chuỗi đoạn liên tiếp cùng mức giá (block đang dở được
+// mang sang ngày sau, không làm tròn lại ở nửa đêm) và ghi vào ngày mà block bắt đầu; khi một mức giá khác xen vào,
+// đoạn sau bắt đầu block mới. Trần phí áp dụng theo ngày.
+func CalculateFee(t *domain.Tariff, holidays map[string]string, entry, exit time.Time, vehicleClass string) *domain.FeeBreakdown {
+	breakdown := &domain.FeeBreakdown{
+		TariffID:      t.ID,
+		TariffName:    t.Name,
+		TariffVersion: t.Version,
+		VehicleClass:  vehicleClass,
+		Multiplier:    1,
+		Items:         []domain.FeeBreakdownItem{},
+	}
+	if !exit.After(entry) {
+		return breakdown
+	}
+	if int(exit.Sub(entry).Minutes()) <= t.GracePeriodMinutes {
+		breakdown.GracePeriodApplied = true
+		return breakdown
+	}
+
+	loc := tariffLocation(t)
+	nightStart, nightEnd, hasOvernight := overnightWindow(t)
+	windows := parseScheduleWindows(t)
+
+	var clocks []int
+	if hasOvernight {
+		clocks = append(clocks, nightStart, nightEnd)
+	}
+	for _, w := range windows {
+		clocks = append(clocks, w.start, w.end)
+	}
+
+	// Mỗi item là một chuỗi đoạn liên tiếp có cùng khóa; runBefore là số phút cùng mức giá liền trước item
+	// (chuỗi nối tiếp qua nửa đêm), 0 nếu item mở đầu một chuỗi mới
+	var items []*domain.FeeBreakdownItem
+	var itemBlockMinutes []int
+	var runBefore []float64
+	var prevKey feeBucketKey
+	var runMinutes float64
+
+	cursor := entry.In(loc)
+	end := exit.In(loc)
+	for cursor.Before(end) {
+		next := nextBoundary(cursor, end, clocks)
+		date := cursor.Format(holidayDateLayout)
+
+		var key feeBucketKey
+		var unitPrice float64
+		var blockMinutes int
+		var holidayName string
+		if hasOvernight && inClockWindow(minuteOfDay(cursor), nightStart, nightEnd) {
+			key = feeBucketKey{date: nightKey(cursor, nightStart, nightEnd), label: feeItemOvernight}
+			unitPrice = t.OvernightFlatRate
+		} else if w, holiday := matchScheduleWindow(windows, cursor, holidays); w != nil {
+			key = feeBucketKey{date: date, label: w.Name, windowID: w.ID}
+			unitPrice, blockMinutes, holidayName = w.BlockPrice, w.BlockMinutes, holiday
+		} else {
+			key = feeBucketKey{date: date, label: feeItemBase}
+			unitPrice, blockMinutes = t.BlockPrice, t.BlockMinutes
+		}
+
+		var item *domain.FeeBreakdownItem
+		if len(items) > 0 && key == prevKey {
+			item = items[len(items)-1]
+		} else {
+			if len(items) == 0 || key.label != prevKey.label || key.windowID != prevKey.windowID {
+				runMinutes = 0
+			}
+			item = &domain.FeeBreakdownItem{
+				Date:      key.date,
+				Label:     key.label,
+				Holiday:   holidayName,
+				Start:     cursor.UTC(),
+				UnitPrice: unitPrice,
+			}
+			if key.windowID != 0 {
+				windowID := key.windowID
+				item.WindowID = &windowID
+			}
+			items = append(items, item)
+			itemBlockMinutes = append(itemBlockMinutes, blockMinutes)
+			runBefore = append(runBefore, runMinutes)
+		}
+		item.End = next.UTC()
+		item.Minutes += next.Sub(cursor).Minutes()
+		runMinutes += next.Sub(cursor).Minutes()
+		prevKey = key
+		cursor = next
+	}
+
+	dayTotals := make(map[string]float64)
+	for i, item := range items {
+		if item.Label == feeItemOvernight {
+			item.Blocks = 1
+		} else {
+			blockMinutes := itemBlockMinutes[i]
+			if blockMinutes <= 0 {
+				blockMinutes = 1
+			}
+			before := runBefore[i]
+			item.Blocks = startedBlocks(before+item.Minutes, blockMinutes) - startedBlocks(before, blockMinutes)
+		}
+		item.Amount = float64(item.Blocks) * item.UnitPrice
+		item.Minutes = math.Round(item.Minutes*100) / 100
+		dayTotals[item.Date] += item.Amount
+		breakdown.Subtotal += item.Amount
+		breakdown.Items = append(breakdown.Items, *item)
+	}
+
+	if t.DailyCap > 0 {
+		for _, dayTotal := range dayTotals {
+			if dayTotal > t.DailyCap {
+				breakdown.DailyCapDiscount += dayTotal - t.DailyCap
+			}
+		}
+	}
+
+	total := breakdown.Subtotal - breakdown.DailyCapDiscount
+	if multiplier, ok := t.VehicleMultipliers[vehicleClass]; ok && multiplier > 0 {
+		breakdown.Multiplier = multiplier
+		total *= multiplier
+	}
+	if total > 0 && total < t.MinimumFee {
+		breakdown.MinimumFeeAdjustment = t.MinimumFee - total
+		total = t.MinimumFee
+	}
+	breakdown.Total = math.Round(total*100) / 100
+	return breakdown
+}
+
+// startedBlocks - số block (mỗi block blockMinutes phút) đã bắt đầu sau minutes phút
+func startedBlocks(minutes float64, blockMinutes int) int {
+	return int(math.Ceil(minutes/float64(blockMinutes) - 1e-9))
+}
+
+func parseScheduleWindows(t *domain.Tariff) []scheduleWindow {
+	windows := make([]scheduleWindow, 0, len(t.Windows))
+	for _, w := range t.Windows {
+		start, errStart := parseClock(w.StartTime)
+		end, errEnd := parseClock(w.EndTime)
+		if errStart != nil || errEnd != nil || start == end {
+			log.Printf("TariffService: Bỏ qua khung giờ không hợp lệ '%s' (%s-%s) của biểu phí %d", w.Name, w.StartTime, w.EndTime, t.ID)
+			continue
+		}
+		if w.WeekdayMask == 0 {
+			w.WeekdayMask = domain.WeekdayMaskAll
+		}
+		if w.BlockMinutes <= 0 {
+			w.BlockMinutes = t.BlockMinutes
+		}
+		windows = append(windows, scheduleWindow{TariffScheduleWindow: w, start: start, end: end})
+	}
+	return windows
+}
+
+// matchScheduleWindow tìm khung giờ áp dụng cho thời điểm t. Vào ngày lễ, khung holiday_only được ưu tiên;
+// trong cùng loại, khung có priority cao hơn thắng. Trả về tên ngày lễ nếu khung được chọn là khung ngày lễ.
+func matchScheduleWindow(windows []scheduleWindow, t time.Time, holidays map[string]string) (*scheduleWindow, string) {
+	holidayName, isHoliday := holidays[t.Format(holidayDateLayout)]
+	m := minuteOfDay(t)
+	weekdayBit := 1 << uint(t.Weekday())
+
+	var best *scheduleWindow
+	for i := range windows {
+		w := &windows[i]
+		if !inClockWindow(m, w.start, w.end) {
+			continue
+		}
+		if w.HolidayOnly {
+			if !isHoliday {
+				continue
+			}
+		} else if w.WeekdayMask&weekdayBit == 0 {
+			continue
+		}
+		if best == nil || (w.HolidayOnly && !best.HolidayOnly) ||
+			(w.HolidayOnly == best.HolidayOnly && w.Priority > best.Priority) {
+			best = w
+		}
+	}
+	if best != nil && best.HolidayOnly {
+		return best, holidayName
+	}
+	return best, ""
+}
+
+func tariffLocation(t *domain.Tariff) *time.Location {
+	if t.Timezone != "" {
+		if loc, err := time.LoadLocation(t.Timezone); err == nil {
+			return loc
+		}
+		log.Printf("TariffService: Timezone '%s' không hợp lệ, sử dụng UTC", t.Timezone)
+	}
+	return time.UTC
+}
+
+func overnightWindow(t *domain.Tariff) (int, int, bool) {
+	if t.OvernightStart == "" || t.OvernightEnd == "" {
+		return 0, 0, false
+	}
+	start, errStart := parseClock(t.OvernightStart)
+	end, errEnd := parseClock(t.OvernightEnd)
+	if errStart != nil || errEnd != nil || start == end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseClock parse "HH:MM" thành số phút trong ngày
+func parseClock(s string) (int, error) {
+	parsed, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("giờ '%s' không đúng định dạng HH:MM", s)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// inClockWindow kiểm tra phút m có nằm trong khung [start, end), hỗ trợ khung qua nửa đêm
+func inClockWindow(m, start, end int) bool {
+	if start < end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// nightKey trả về ngày bắt đầu của đêm chứa thời điểm t (dùng để tính giá qua đêm một lần/đêm)
+func nightKey(t time.Time, start, end int) string {
+	if start > end && minuteOfDay(t) < end {
+		return t.AddDate(0, 0, -1).Format(holidayDateLayout)
+	}
+	return t.Format(holidayDateLayout)
+}
+
+// nextClockAfter trả về thời điểm gần nhất sau t có giờ trong ngày = minutes
+func nextClockAfter(t time.Time, minutes int) time.Time {
+	candidate := time.Date(t.Year(), t.Month(), t.Day(), minutes/60, minutes%60, 0, 0, t.Location())
+	if !candidate.After(t) {
+		candidate = time.Date(t.Year(), t.Month(), t.Day()+1, minutes/60, minutes%60, 0, 0, t.Location())
+	}
+	return candidate
+}
+
+// nextBoundary trả về mốc chia đoạn kế tiếp: nửa đêm, một trong các mốc giờ clocks, hoặc thời điểm kết thúc
+func nextBoundary(cursor, end time.Time, clocks []int) time.Time {
+	next := end
+	midnight := time.Date(cursor.Year(), cursor.Month(), cursor.Day()+1, 0, 0, 0, 0, cursor.Location())
+	if midnight.Before(next) {
+		next = midnight
+	}
+	for _, m := range clocks {
+		if candidate := nextClockAfter(cursor, m); candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
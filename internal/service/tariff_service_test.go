@@ -0,0 +1,179 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"smart_parking/internal/domain"
+)
+
+func TestCalculateFee(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	hourly := func(price float64) *domain.Tariff {
+		return &domain.Tariff{
+			Name:         "test",
+			PricingMode:  domain.PricingHourly,
+			BlockMinutes: 60,
+			BlockPrice:   price,
+			Timezone:     "UTC",
+		}
+	}
+
+	tests := []struct {
+		name         string
+		tariff       *domain.Tariff
+		holidays     map[string]string
+		entry, exit  time.Time
+		vehicleClass string
+		wantTotal    float64
+		wantBlocks   int
+		wantGrace    bool
+	}{
+		{
+			name:       "per_minute",
+			tariff:     &domain.Tariff{PricingMode: domain.PricingPerMinute, BlockMinutes: 1, BlockPrice: 1000, Timezone: "UTC"},
+			entry:      at(3, 10, 0),
+			exit:       at(3, 10, 10),
+			wantTotal:  10000,
+			wantBlocks: 10,
+		},
+		{
+			name:       "grace_period",
+			tariff:     &domain.Tariff{PricingMode: domain.PricingHourly, BlockMinutes: 60, BlockPrice: 10000, GracePeriodMinutes: 10, Timezone: "UTC"},
+			entry:      at(3, 10, 0),
+			exit:       at(3, 10, 5),
+			wantTotal:  0,
+			wantBlocks: 0,
+			wantGrace:  true,
+		},
+		{
+			name:       "hourly_across_midnight_is_one_block",
+			tariff:     hourly(10000),
+			entry:      at(3, 23, 30),
+			exit:       at(4, 0, 30),
+			wantTotal:  10000,
+			wantBlocks: 1,
+		},
+		{
+			name:       "hourly_across_midnight_remainder_carried",
+			tariff:     hourly(10000),
+			entry:      at(3, 23, 30),
+			exit:       at(4, 1, 10),
+			wantTotal:  20000,
+			wantBlocks: 2,
+		},
+		{
+			name:       "minimum_fee",
+			tariff:     &domain.Tariff{PricingMode: domain.PricingPerMinute, BlockMinutes: 1, BlockPrice: 100, MinimumFee: 5000, Timezone: "UTC"},
+			entry:      at(3, 10, 0),
+			exit:       at(3, 10, 5),
+			wantTotal:  5000,
+			wantBlocks: 5,
+		},
+		{
+			name: "daily_cap",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.DailyCap = 30000
+				return t
+			}(),
+			entry:      at(3, 8, 0),
+			exit:       at(3, 14, 0),
+			wantTotal:  30000,
+			wantBlocks: 6,
+		},
+		{
+			name: "overnight_flat_rate",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.OvernightStart, t.OvernightEnd, t.OvernightFlatRate = "22:00", "06:00", 20000
+				return t
+			}(),
+			entry:      at(3, 21, 0),
+			exit:       at(4, 7, 0),
+			wantTotal:  40000,
+			wantBlocks: 3,
+		},
+		{
+			name: "peak_window",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.Windows = []domain.TariffScheduleWindow{{ID: 1, Name: "peak", StartTime: "17:00", EndTime: "19:00", BlockMinutes: 60, BlockPrice: 20000}}
+				return t
+			}(),
+			entry:      at(3, 16, 30),
+			exit:       at(3, 18, 30),
+			wantTotal:  50000,
+			wantBlocks: 3,
+		},
+		{
+			name: "base_resumed_after_peak_starts_new_block",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.Windows = []domain.TariffScheduleWindow{{ID: 1, Name: "peak", StartTime: "17:00", EndTime: "19:00", BlockMinutes: 60, BlockPrice: 20000}}
+				return t
+			}(),
+			entry:      at(3, 16, 30),
+			exit:       at(3, 19, 20),
+			wantTotal:  60000,
+			wantBlocks: 4,
+		},
+		{
+			name: "holiday_window_wins",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.Windows = []domain.TariffScheduleWindow{
+					{ID: 1, Name: "day", StartTime: "06:00", EndTime: "22:00", BlockMinutes: 60, BlockPrice: 15000, Priority: 10},
+					{ID: 2, Name: "holiday", HolidayOnly: true, StartTime: "00:00", EndTime: "23:59", BlockMinutes: 60, BlockPrice: 30000},
+				}
+				return t
+			}(),
+			holidays:   map[string]string{"2025-03-03": "Lễ"},
+			entry:      at(3, 10, 0),
+			exit:       at(3, 11, 0),
+			wantTotal:  30000,
+			wantBlocks: 1,
+		},
+		{
+			name: "vehicle_multiplier",
+			tariff: func() *domain.Tariff {
+				t := hourly(10000)
+				t.VehicleMultipliers = map[string]float64{domain.VehicleClassMotorbike: 0.5}
+				return t
+			}(),
+			entry:        at(3, 10, 0),
+			exit:         at(3, 12, 0),
+			vehicleClass: domain.VehicleClassMotorbike,
+			wantTotal:    10000,
+			wantBlocks:   2,
+		},
+		{
+			name:      "exit_before_entry",
+			tariff:    hourly(10000),
+			entry:     at(3, 12, 0),
+			exit:      at(3, 10, 0),
+			wantTotal: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			breakdown := CalculateFee(tc.tariff, tc.holidays, tc.entry, tc.exit, tc.vehicleClass)
+			if breakdown.Total != tc.wantTotal {
+				t.Errorf("Total = %v, want %v (items: %+v)", breakdown.Total, tc.wantTotal, breakdown.Items)
+			}
+			blocks := 0
+			for _, item := range breakdown.Items {
+				blocks += item.Blocks
+			}
+			if blocks != tc.wantBlocks {
+				t.Errorf("blocks = %d, want %d (items: %+v)", blocks, tc.wantBlocks, breakdown.Items)
+			}
+			if breakdown.GracePeriodApplied != tc.wantGrace {
+				t.Errorf("GracePeriodApplied = %v, want %v", breakdown.GracePeriodApplied, tc.wantGrace)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+var ErrInvalidVehicleClass = errors.New("loại xe không hợp lệ")
+
+// VehicleClassifier - LPR provider có thể nhận diện loại xe / màu xe thì cài thêm interface này.
+// Provider không hỗ trợ thì gate event chỉ có biển số.
+type VehicleClassifier interface {
+	ClassifyVehicle(ctx context.Context, imageBytes []byte) (*domain.VehicleAttributes, error)
+}
+
+// rekognitionVehicleLabels ánh xạ nhãn DetectLabels sang loại xe của hệ thống
+var rekognitionVehicleLabels = map[string]string{
+	"Motorcycle":   domain.VehicleClassMotorbike,
+	"Scooter":      domain.VehicleClassMotorbike,
+	"Moped":        domain.VehicleClassMotorbike,
+	"Car":          domain.VehicleClassCar,
+	"Sedan":        domain.VehicleClassCar,
+	"Suv":          domain.VehicleClassCar,
+	"Van":          domain.VehicleClassCar,
+	"Pickup Truck": domain.VehicleClassCar,
+	"Truck":        domain.VehicleClassTruck,
+	"Bus":          domain.VehicleClassTruck,
+}
+
+// fuseVehicleAttributes hợp nhất thuộc tính xe của nhiều frame: loại xe chọn theo tổng confidence,
+// màu và hãng xe chọn theo số frame nhiều nhất. Confidence của loại xe là trung bình trên các frame đọc được.
+func fuseVehicleAttributes(frames []domain.VehicleAttributes) *domain.VehicleAttributes {
+	classWeights := make(map[string]float32)
+	classFrames := make(map[string]int)
+	colorVotes := make(map[string]int)
+	makeVotes := make(map[string]int)
+	for _, frame := range frames {
+		if frame.VehicleClass != "" {
+			classWeights[frame.VehicleClass] += frame.ClassConfidence
+			classFrames[frame.VehicleClass]++
+		}
+		if frame.Color != "" {
+			colorVotes[frame.Color]++
+		}
+		if frame.Make != "" {
+			makeVotes[frame.Make]++
+		}
+	}
+
+	fused := &domain.VehicleAttributes{
+		Color: majorityVote(colorVotes),
+		Make:  majorityVote(makeVotes),
+	}
+	for class, weight := range classWeights {
+		if weight > classWeights[fused.VehicleClass] || (weight == classWeights[fused.VehicleClass] && class < fused.VehicleClass) {
+			fused.VehicleClass = class
+		}
+	}
+	if fused.VehicleClass != "" {
+		fused.ClassConfidence = classWeights[fused.VehicleClass] / float32(classFrames[fused.VehicleClass])
+	}
+	if fused.IsEmpty() {
+		return nil
+	}
+	return fused
+}
+
+// majorityVote chọn giá trị nhiều phiếu nhất, hòa thì theo thứ tự chữ cái để kết quả ổn định
+func majorityVote(votes map[string]int) string {
+	winner := ""
+	for value, count := range votes {
+		if count > votes[winner] || (count == votes[winner] && value < winner) {
+			winner = value
+		}
+	}
+	return winner
+}
+
+// classifyGateFrames nhận diện thuộc tính xe trên từng frame rồi hợp nhất. Lỗi của từng frame chỉ được log.
+func classifyGateFrames(ctx context.Context, classifier VehicleClassifier, eventID string, frames [][]byte) *domain.VehicleAttributes {
+	var results []domain.VehicleAttributes
+	for i, frame := range frames {
+		attrs, err := classifier.ClassifyVehicle(ctx, frame)
+		if err != nil {
+			log.Printf("LPR: Lỗi nhận diện loại xe frame %d/%d của EventID=%s: %v", i+1, len(frames), eventID, err)
+			continue
+		}
+		if !attrs.IsEmpty() {
+			results = append(results, *attrs)
+		}
+	}
+	return fuseVehicleAttributes(results)
+}
+
+// UpdateSessionVehicle - Operator sửa loại xe / màu xe / hãng xe của phiên. Nếu phiên đang đỗ ở chỗ dành riêng
+// cho loại xe khác thì chuyển sang chỗ phù hợp còn trống (nếu có); phí khi ra được tính theo loại xe mới.
+func (s *ParkingService) UpdateSessionVehicle(ctx context.Context, sessionID int, dto domain.VehicleAttributesDTO) (*domain.ParkingSession, error) {
+	dto.VehicleClass = strings.TrimSpace(dto.VehicleClass)
+	if !domain.IsValidVehicleClass(dto.VehicleClass) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVehicleClass, dto.VehicleClass)
+	}
+
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session.VehicleClass = null.NewString(dto.VehicleClass, dto.VehicleClass != "")
+	session.VehicleColor = null.NewString(strings.TrimSpace(dto.Color), strings.TrimSpace(dto.Color) != "")
+	session.VehicleMake = null.NewString(strings.TrimSpace(dto.Make), strings.TrimSpace(dto.Make) != "")
+	session.VehicleSource = null.StringFrom(domain.VehicleSourceOperator)
+	session.VehicleCorrectedBy = null.NewString(dto.Operator, dto.Operator != "")
+
+	if session.Status == domain.SessionActive && session.SlotID.Valid && dto.VehicleClass != "" {
+		s.reassignSlotForVehicleClass(ctx, session, dto.VehicleClass)
+	}
+
+	updated, err := s.sessionRepo.Update(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi cập nhật thông tin xe của phiên %d: %w", sessionID, err)
+	}
+	log.Printf("Service: %s sửa thông tin xe của phiên %d: loại='%s', màu='%s', hãng='%s'",
+		dto.Operator, sessionID, updated.VehicleClass.String, updated.VehicleColor.String, updated.VehicleMake.String)
+	return updated, nil
+}
+
+// reassignSlotForVehicleClass chuyển phiên sang chỗ phù hợp với loại xe nếu chỗ hiện tại dành riêng cho loại khác.
+// Không còn chỗ phù hợp thì giữ nguyên chỗ cũ.
+func (s *ParkingService) reassignSlotForVehicleClass(ctx context.Context, session *domain.ParkingSession, vehicleClass string) {
+	currentSlotID := int(session.SlotID.Int64)
+	currentSlot, err := s.slotRepo.FindByID(ctx, currentSlotID)
+	if err != nil {
+		log.Printf("Lỗi lấy chỗ đỗ %d của phiên %d: %v", currentSlotID, session.ID, err)
+		return
+	}
+	if currentSlot.VehicleClass == "" || currentSlot.VehicleClass == vehicleClass {
+		return
+	}
+
+	newSlot, err := s.slotRepo.FindFirstAvailableByLotID(ctx, session.LotID, vehicleClass)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Lỗi tìm chỗ đỗ cho loại xe '%s' tại bãi %d: %v", vehicleClass, session.LotID, err)
+		} else {
+			log.Printf("Bãi %d không còn chỗ trống cho loại xe '%s', phiên %d giữ chỗ %s.",
+				session.LotID, vehicleClass, session.ID, currentSlot.SlotIdentifier)
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := s.slotRepo.UpdateStatus(ctx, newSlot.ID, domain.StatusOccupied, &now, "vehicle_class_correction"); err != nil {
+		log.Printf("Lỗi khi cập nhật trạng thái slot %d thành occupied: %v", newSlot.ID, err)
+		return
+	}
+	if err := s.slotRepo.UpdateStatus(ctx, currentSlotID, domain.StatusVacant, &now, "vehicle_class_correction"); err != nil {
+		log.Printf("Lỗi khi cập nhật trạng thái slot %d thành vacant: %v", currentSlotID, err)
+	}
+	session.SlotID = null.IntFrom(int64(newSlot.ID))
+	log.Printf("Đã chuyển phiên %d từ chỗ %s sang chỗ %s theo loại xe '%s'.",
+		session.ID, currentSlot.SlotIdentifier, newSlot.SlotIdentifier, vehicleClass)
+}
+
+// GateEventVehicle trả về thuộc tính xe đã nhận diện của gate event, nil nếu chưa có. Lỗi chỉ được log
+// để không chặn việc tạo phiên.
+func (s *IoTService) GateEventVehicle(ctx context.Context, eventID string) *domain.VehicleAttributes {
+	attrs, err := s.gateEventRepo.FindVehicleAttributes(ctx, eventID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Lỗi lấy thuộc tính xe của gate event %s: %v", eventID, err)
+		}
+		return nil
+	}
+	if attrs.IsEmpty() {
+		return nil
+	}
+	return attrs
+}
+
+// CorrectGateEventVehicle ghi đè thuộc tính xe của gate event bằng giá trị operator xác nhận
+func (s *IoTService) CorrectGateEventVehicle(ctx context.Context, eventID string, attrs domain.VehicleAttributes) {
+	if attrs.VehicleClass != "" {
+		attrs.ClassConfidence = 1.0
+	}
+	if err := s.gateEventRepo.UpdateVehicleAttributes(ctx, eventID, attrs); err != nil {
+		log.Printf("Lỗi cập nhật thuộc tính xe của gate event %s: %v", eventID, err)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"smart_parking/internal/domain"
+	"time"
+)
+
+// screenGateWatchlist kiểm tra biển số LPR tại cổng với danh sách cảnh báo trước khi tự động xử lý.
+// Trả về false nếu xe bị chặn: gate event chuyển sang requires_override và frontend nhận cảnh báo.
+// Không kiểm tra được danh sách (lỗi DB) thì xe cũng bị giữ chờ operator, trừ khi operator đã override.
+func (s *IoTService) screenGateWatchlist(ctx context.Context, request domain.LPRTriggerRequest, plate string) (bool, error) {
+	watchlistService := s.parkingService.watchlistService
+	if watchlistService == nil || plate == "" {
+		return true, nil
+	}
+	gateEvent, err := s.gateEventRepo.FindByEventID(ctx, request.EventID)
+	if err != nil {
+		return false, fmt.Errorf("không tìm thấy gate event: %w", err)
+	}
+
+	entry, action, err := watchlistService.Screen(ctx, gateEvent.LotID, plate, gateEvent.EventID,
+		domain.WatchlistSourceLPR, request.OverrideWatchlist, request.Operator, request.OverrideReason)
+	screenErr := err
+	if screenErr != nil {
+		log.Printf("Lỗi kiểm tra danh sách cảnh báo cho xe '%s' (EventID=%s): %v", plate, gateEvent.EventID, screenErr)
+		if request.OverrideWatchlist && request.Operator != "" && request.OverrideReason != "" {
+			log.Printf("Watchlist: '%s' override cho xe '%s' khi không kiểm tra được danh sách (lý do: %s), EventID=%s",
+				request.Operator, plate, request.OverrideReason, gateEvent.EventID)
+			return true, nil
+		}
+	} else if entry == nil {
+		return true, nil
+	}
+
+	lotName := ""
+	if lot, err := s.parkingService.GetParkingLotByID(ctx, gateEvent.LotID); err == nil {
+		lotName = lot.Name
+	}
+	if screenErr != nil {
+		notes := fmt.Sprintf("Không kiểm tra được danh sách cảnh báo cho xe %s, chờ operator xác nhận", plate)
+		if err := s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusRequiresOverride, notes); err != nil {
+			log.Printf("Lỗi cập nhật gate event %s sang requires_override: %v", gateEvent.EventID, err)
+		}
+		s.broadcastWatchlistNotification(domain.GateEventNotification{
+			EventID:           gateEvent.EventID,
+			LotID:             gateEvent.LotID,
+			LotName:           lotName,
+			DeviceID:          gateEvent.DeviceID,
+			GateDirection:     gateEvent.GateDirection,
+			EventType:         domain.GateEventWatchlistAlert,
+			Timestamp:         time.Now(),
+			SensorID:          gateEvent.SensorID,
+			DetectedPlate:     plate,
+			RequiresUserInput: true,
+			Message: fmt.Sprintf("Không kiểm tra được danh sách cảnh báo cho xe %s tại bãi %s. Vui lòng kiểm tra và xác nhận thủ công.",
+				plate, lotName),
+		})
+		return false, nil
+	}
+	notification := domain.GateEventNotification{
+		EventID:         gateEvent.EventID,
+		LotID:           gateEvent.LotID,
+		LotName:         lotName,
+		DeviceID:        gateEvent.DeviceID,
+		GateDirection:   gateEvent.GateDirection,
+		Timestamp:       time.Now(),
+		SensorID:        gateEvent.SensorID,
+		DetectedPlate:   plate,
+		WatchlistReason: entry.ReasonCode,
+	}
+
+	switch action {
+	case domain.WatchlistActionAllowed:
+		notification.EventType = domain.GateEventVIPArrived
+		notification.Message = fmt.Sprintf("Xe VIP %s tới cổng bãi %s.", plate, lotName)
+		s.broadcastWatchlistNotification(notification)
+		return true, nil
+	case domain.WatchlistActionOverridden:
+		log.Printf("Watchlist: '%s' override cho xe '%s' (%s) qua cổng, EventID=%s",
+			request.Operator, plate, entry.ReasonCode, gateEvent.EventID)
+		return true, nil
+	}
+
+	notes := fmt.Sprintf("Biển số %s bị gắn cờ '%s', chờ operator override", plate, entry.ReasonCode)
+	if err := s.gateEventRepo.UpdateStatus(ctx, gateEvent.EventID, domain.StatusRequiresOverride, notes); err != nil {
+		log.Printf("Lỗi cập nhật gate event %s sang requires_override: %v", gateEvent.EventID, err)
+	}
+	notification.EventType = domain.GateEventWatchlistAlert
+	notification.RequiresUserInput = true
+	notification.Message = fmt.Sprintf("CẢNH BÁO: Xe %s nằm trong danh sách '%s'. Không tự động xử lý tại bãi %s.",
+		plate, entry.ReasonCode, lotName)
+	s.broadcastWatchlistNotification(notification)
+
+	log.Printf("Watchlist: Chặn xe '%s' (%s) tại cổng %s, EventID=%s", plate, entry.ReasonCode, gateEvent.GateDirection, gateEvent.EventID)
+	return false, nil
+}
+
+func (s *IoTService) broadcastWatchlistNotification(notification domain.GateEventNotification) {
+	if s.webSocketManager != nil {
+		s.webSocketManager.BroadcastGateEvent(notification)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/guregu/null.v4"
+	"log"
+	"smart_parking/internal/domain"
+	"smart_parking/internal/repository"
+	"time"
+)
+
+var (
+	ErrInvalidWatchlistEntry = errors.New("mục danh sách cảnh báo không hợp lệ")
+	ErrPlateFlagged          = errors.New("biển số nằm trong danh sách cảnh báo")
+	ErrWatchlistUnavailable  = errors.New("không kiểm tra được danh sách cảnh báo biển số")
+)
+
+type WatchlistService struct {
+	watchlistRepo repository.WatchlistRepository
+}
+
+func NewWatchlistService(watchlistRepo repository.WatchlistRepository) *WatchlistService {
+	return &WatchlistService{watchlistRepo: watchlistRepo}
+}
+
+func (s *WatchlistService) CreateEntry(ctx context.Context, dto domain.WatchlistDTO, createdBy string) (*domain.WatchlistEntry, error) {
+	entry := &domain.WatchlistEntry{IsActive: true, CreatedBy: null.NewString(createdBy, createdBy != "")}
+	if err := applyWatchlistDTO(entry, dto); err != nil {
+		return nil, err
+	}
+	created, err := s.watchlistRepo.Create(ctx, entry)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("WatchlistService: '%s' gắn cờ '%s' cho biển số '%s' (ID %d)", createdBy, created.ReasonCode, created.VehicleIdentifier, created.ID)
+	return created, nil
+}
+
+func (s *WatchlistService) GetEntry(ctx context.Context, id int) (*domain.WatchlistEntry, error) {
+	return s.watchlistRepo.FindByID(ctx, id)
+}
+
+func (s *WatchlistService) FindEntries(ctx context.Context, filter domain.WatchlistFilterDTO) ([]domain.WatchlistEntry, error) {
+	if filter.VehicleIdentifier != nil {
+		plate := normalizeVehicleIdentifier(*filter.VehicleIdentifier)
+		filter.VehicleIdentifier = &plate
+	}
+	return s.watchlistRepo.Find(ctx, filter)
+}
+
+func (s *WatchlistService) UpdateEntry(ctx context.Context, id int, dto domain.WatchlistDTO) (*domain.WatchlistEntry, error) {
+	entry, err := s.watchlistRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWatchlistDTO(entry, dto); err != nil {
+		return nil, err
+	}
+	return s.watchlistRepo.Update(ctx, entry)
+}
+
+func (s *WatchlistService) DeleteEntry(ctx context.Context, id int) error {
+	return s.watchlistRepo.Delete(ctx, id)
+}
+
+func (s *WatchlistService) FindHits(ctx context.Context, filter domain.WatchlistHitFilterDTO) ([]domain.WatchlistHit, error) {
+	if filter.VehicleIdentifier != nil {
+		plate := normalizeVehicleIdentifier(*filter.VehicleIdentifier)
+		filter.VehicleIdentifier = &plate
+	}
+	return s.watchlistRepo.FindHits(ctx, filter)
+}
+
+func applyWatchlistDTO(entry *domain.WatchlistEntry, dto domain.WatchlistDTO) error {
+	plate := normalizeVehicleIdentifier(dto.VehicleIdentifier)
+	if plate == "" {
+		return fmt.Errorf("%w: biển số không được để trống", ErrInvalidWatchlistEntry)
+	}
+	expiresAt := null.Time{}
+	if dto.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, dto.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("%w: expires_at phải theo định dạng RFC3339", ErrInvalidWatchlistEntry)
+		}
+		expiresAt = null.TimeFrom(t.UTC())
+	}
+
+	entry.VehicleIdentifier = plate
+	entry.ReasonCode = domain.WatchlistReason(dto.ReasonCode)
+	entry.Note = null.NewString(dto.Note, dto.Note != "")
+	entry.ExpiresAt = expiresAt
+	if dto.IsActive != nil {
+		entry.IsActive = *dto.IsActive
+	}
+	return nil
+}
+
+// CheckPlate trả về mục cảnh báo có hiệu lực của biển số tại thời điểm at (mục cấm được ưu tiên hơn VIP), nil nếu không có
+func (s *WatchlistService) CheckPlate(ctx context.Context, plate string, at time.Time) (*domain.WatchlistEntry, error) {
+	entries, err := s.watchlistRepo.FindActiveByVehicle(ctx, normalizeVehicleIdentifier(plate), at)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+// Screen kiểm tra biển số và ghi nhật ký nếu bị gắn cờ. Trả về mục cảnh báo (nil nếu không có) và
+// hành động đã quyết định: VIP được cho qua, các mục khác bị chặn trừ khi admin chủ động override kèm lý do.
+func (s *WatchlistService) Screen(ctx context.Context, lotID int, plate string, gateEventID string, source string,
+	override bool, operator string, overrideReason string) (*domain.WatchlistEntry, string, error) {
+	entry, err := s.CheckPlate(ctx, plate, time.Now().UTC())
+	if err != nil || entry == nil {
+		return nil, "", err
+	}
+
+	action := domain.WatchlistActionDenied
+	if entry.AllowsEntry() {
+		action = domain.WatchlistActionAllowed
+	} else if override && operator != "" && overrideReason != "" {
+		// Override chỉ có hiệu lực khi biết người thực hiện (lấy từ JWT) và lý do để nhật ký phát hiện có người chịu trách nhiệm
+		action = domain.WatchlistActionOverridden
+	}
+
+	hit := &domain.WatchlistHit{
+		WatchlistID:       null.IntFrom(int64(entry.ID)),
+		VehicleIdentifier: entry.VehicleIdentifier,
+		ReasonCode:        entry.ReasonCode,
+		LotID:             lotID,
+		GateEventID:       null.NewString(gateEventID, gateEventID != ""),
+		Source:            source,
+		Action:            action,
+		Operator:          null.NewString(operator, operator != ""),
+	}
+	if action == domain.WatchlistActionOverridden {
+		hit.OverrideReason = null.StringFrom(overrideReason)
+	}
+	if _, err := s.watchlistRepo.CreateHit(ctx, hit); err != nil {
+		log.Printf("Lỗi ghi nhật ký phát hiện biển số '%s' trong danh sách cảnh báo: %v", plate, err)
+	}
+	log.Printf("WatchlistService: Phát hiện biển số '%s' (%s) tại bãi %d qua %s, hành động: %s",
+		entry.VehicleIdentifier, entry.ReasonCode, lotID, source, action)
+	return entry, action, nil
+}
@@ -33,6 +33,9 @@ import (
 func main() {
 	// 1. Load Configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Cấu hình không hợp lệ: %v", err)
+	}
 	log.Println("Cấu hình đã được tải.")
 
 	// 2. Setup Database Connection
@@ -64,7 +67,11 @@ func main() {
 	log.Println("Đã khởi tạo SQS client và IoT Data Plane client.")
 
 	rekognitionClient := rekognition.NewFromConfig(awsSDKCfg) // Khởi tạo Rekognition Client
-	lprService := service.NewLPRService(rekognitionClient)    // Khởi tạo LPRService
+	lprProvider, err := service.NewLPRProvider(cfg, rekognitionClient)
+	if err != nil {
+		log.Fatalf("Không thể khởi tạo LPR provider: %v", err)
+	}
+	log.Printf("Đã khởi tạo LPR provider: %s", lprProvider.Name())
 
 	// 5. Initialize Repositories
 	userRepo := postgresql.NewPgUserRepository(db) // Thêm User Repo
@@ -75,45 +82,110 @@ func main() {
 	sessionRepo := postgresql.NewPgParkingSessionRepository(db)
 	deviceRepo := postgresql.NewPgDeviceRepository(db)
 	gateEventRepo := postgresql.NewPgGateEventRepository(db) // Thêm GateEvent Repository
+	tariffRepo := postgresql.NewPgTariffRepository(db)
+	holidayRepo := postgresql.NewPgHolidayRepository(db)
+	paymentRepo := postgresql.NewPgPaymentRepository(db)
+	paymentRefundRepo := postgresql.NewPgPaymentRefundRepository(db)
+	reservationRepo := postgresql.NewPgReservationRepository(db)
+	subscriptionRepo := postgresql.NewPgSubscriptionRepository(db)
+	watchlistRepo := postgresql.NewPgWatchlistRepository(db)
+	lprAttemptRepo := postgresql.NewPgLPRAttemptRepository(db)
+	evidenceRepo := postgresql.NewPgEvidenceRepository(db)
+	lprCorrectionRepo := postgresql.NewPgLPRCorrectionRepository(db)
+	lprThresholdRepo := postgresql.NewPgLPRThresholdRepository(db)
+	deviceCommandRepo := postgresql.NewPgDeviceCommandRepository(db)
+	deviceEventDedupRepo := postgresql.NewPgDeviceEventDedupRepository(db)
 
 	// init websocket manager
-	webSocketManager := handler.NewWebSocketManager() // Giả sử bạn có một WebSocketManager interface
+	webSocketManager := handler.NewWebSocketManager(cfg) // Giả sử bạn có một WebSocketManager interface
 	go webSocketManager.Start()
 	log.Println("WebSocket Manager đã được khởi động.")
 
 	// 6. Initialize Services
 	authService := service.NewAuthService(userRepo, cfg.JWTSecret, cfg.JWTExpirationHours) // Thêm AuthService
+	tariffService := service.NewTariffService(tariffRepo, holidayRepo, parkingLotRepo)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepo, sessionRepo, parkingLotRepo)
+	watchlistService := service.NewWatchlistService(watchlistRepo)
+	reservationService := service.NewReservationService(reservationRepo, parkingSlotRepo, parkingLotRepo,
+		time.Duration(cfg.ReservationNoShowMinutes)*time.Minute)
+	var paymentGateway service.PaymentGateway
+	switch cfg.PaymentGateway {
+	case "fake":
+		paymentGateway = service.NewFakePaymentGateway(cfg.PaymentWebhookSecret, cfg.PaymentCheckoutBaseURL)
+	default:
+		log.Fatalf("Cổng thanh toán không được hỗ trợ: %s", cfg.PaymentGateway)
+	}
+	paymentService := service.NewPaymentService(paymentRepo, paymentRefundRepo, sessionRepo, paymentGateway)
 	parkingService := service.NewParkingService(parkingLotRepo, parkingSlotRepo, barrierRepo,
-		sessionRepo, deviceRepo, deviceEventsLogRepo)
-	iotService := service.NewIoTService(parkingService, iotDataPlaneClient, cfg, deviceEventsLogRepo)
-	iotServiceUpdated := service.NewIoTServiceUpdated(parkingService, iotDataPlaneClient,
-		cfg, deviceEventsLogRepo, gateEventRepo, webSocketManager)
+		sessionRepo, deviceRepo, deviceEventsLogRepo, tariffService, reservationService, subscriptionService, watchlistService,
+		paymentService)
+	evidenceStore, err := service.NewEvidenceStore(cfg, awsSDKCfg)
+	if err != nil {
+		log.Fatalf("Không thể khởi tạo evidence store: %v", err)
+	}
+	var evidenceService *service.EvidenceService
+	if evidenceStore != nil {
+		evidenceService = service.NewEvidenceService(evidenceRepo, sessionRepo, evidenceStore,
+			time.Duration(cfg.EvidenceRetentionDays)*24*time.Hour)
+		log.Printf("Đã khởi tạo evidence store: %s", evidenceStore.Name())
+	}
+	lprFeedbackService := service.NewLPRFeedbackService(lprCorrectionRepo, lprAttemptRepo, gateEventRepo, evidenceService)
+	lprThresholdService := service.NewLPRThresholdService(lprThresholdRepo, lprCorrectionRepo, cfg)
+	commandPublisher, err := service.NewCommandPublisher(cfg, iotDataPlaneClient)
+	if err != nil {
+		log.Fatalf("Không thể khởi tạo kênh gửi lệnh thiết bị: %v", err)
+	}
+	log.Printf("Đã khởi tạo kênh gửi lệnh thiết bị: %s", commandPublisher.Name())
+	commandTracker := service.NewCommandTracker(deviceCommandRepo, deviceRepo, commandPublisher, webSocketManager, cfg)
+	iotService := service.NewIoTService(parkingService, commandPublisher, cfg, deviceEventsLogRepo)
+	iotServiceUpdated := service.NewIoTServiceUpdated(parkingService, commandPublisher,
+		cfg, deviceEventsLogRepo, gateEventRepo, webSocketManager, paymentService, lprAttemptRepo, evidenceService, lprFeedbackService, lprThresholdService, commandTracker, deviceEventDedupRepo)
 
 	// 7. Initialize Auth Middleware
 	authMiddleware := middleware.NewAuthMiddleware(authService) // Khởi tạo Auth Middleware
 
-	// 8. Khởi tạo và Chạy SQS Consumer
+	// 8. Khởi tạo và Chạy các nguồn sự kiện thiết bị (EVENT_SOURCES: sqs, mqtt, http)
 	var wg sync.WaitGroup
 	consumerCtx, cancelConsumer := context.WithCancel(context.Background())
 
-	if cfg.SQSEventQueueURL == "" {
-		log.Println("CẢNH BÁO: SQS_EVENT_QUEUE_URL chưa được cấu hình. SQS Consumer sẽ không chạy.")
-	} else {
-		sqsConsumer := iot.NewSQSConsumer(sqsClient, cfg, iotServiceUpdated)
+	eventSources, httpEventSource, err := iot.NewEventSources(cfg, sqsClient)
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình nguồn sự kiện thiết bị: %v", err)
+	}
+	if len(eventSources) == 0 {
+		log.Println("CẢNH BÁO: Không có nguồn sự kiện thiết bị nào đang chạy.")
+	}
+	for _, source := range eventSources {
 		wg.Add(1)
-		go func() {
+		go func(source iot.EventSource) {
 			defer wg.Done()
-			log.Println("SQS Consumer đang bắt đầu lắng nghe queue:", cfg.SQSEventQueueURL)
-			sqsConsumer.Start(consumerCtx)
-			log.Println("SQS Consumer đã dừng.")
-		}()
+			if err := source.Run(consumerCtx, iotServiceUpdated); err != nil {
+				log.Printf("Nguồn sự kiện %s dừng do lỗi: %v", source.Name(), err)
+				return
+			}
+			log.Printf("Nguồn sự kiện %s đã dừng.", source.Name())
+		}(source)
 	}
 
 	// start background job để cleanup gate events
 	go startGateEventCleanupJob(gateEventRepo)
+	// background job xóa dedup key sự kiện thiết bị đã hết hạn
+	go startDeviceEventDedupPurgeJob(iotServiceUpdated)
+	// background job giữ chỗ cho đặt chỗ tới giờ và nhả chỗ khi xe không đến
+	go startReservationJob(reservationService, cfg.ReservationCheckInterval)
+	// background job xóa ảnh bằng chứng quá thời gian lưu giữ
+	if evidenceService != nil {
+		go startEvidencePurgeJob(evidenceService, cfg.EvidencePurgeInterval)
+	}
+	// background job tự điều chỉnh ngưỡng confidence LPR theo tỉ lệ operator phải sửa
+	if cfg.LPRAutoTuneEnabled {
+		go startLPRAutoTuneJob(lprThresholdService, cfg.LPRAutoTuneInterval)
+	}
+	// background job gửi lại lệnh rào chắn chưa được thiết bị xác nhận
+	go startCommandRetryJob(commandTracker, cfg.CommandCheckInterval)
 
 	// 9. Setup HTTP Router
-	router := api.SetupRouter(authService, parkingService, iotService, authMiddleware, lprService, iotServiceUpdated, webSocketManager) // Truyền authService và authMiddleware
+	router := api.SetupRouter(authService, parkingService, iotService, authMiddleware, lprProvider, iotServiceUpdated, webSocketManager, tariffService, paymentService, reservationService, subscriptionService, watchlistService, evidenceService, lprFeedbackService, lprThresholdService, cfg.WebSocketAuthTimeout, httpEventSource, cfg.EventIngestToken) // Truyền authService và authMiddleware
 
 	// 10. Start HTTP Server
 	srv := &http.Server{
@@ -140,11 +212,14 @@ func main() {
 	defer cancelShutdown()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server buộc phải tắt: %v", err)
+		// Vẫn chờ nguồn sự kiện drain, không thoát ngay để tránh bỏ dở message đang xử lý
+		log.Printf("Server buộc phải tắt: %v", err)
 	}
 
-	if cfg.SQSEventQueueURL != "" {
-		log.Println("Đang chờ SQS consumer dừng (tối đa 5 giây)...")
+	if len(eventSources) > 0 {
+		// Nguồn sự kiện tự drain trong EventSourceDrainTimeout, chờ thêm để kịp xóa các message đã xử lý
+		waitTimeout := cfg.EventSourceDrainTimeout + 5*time.Second
+		log.Printf("Đang chờ các nguồn sự kiện xử lý nốt sự kiện đã nhận và dừng (tối đa %s)...", waitTimeout)
 		c := make(chan struct{})
 		go func() {
 			defer close(c)
@@ -152,9 +227,9 @@ func main() {
 		}()
 		select {
 		case <-c:
-			log.Println("SQS consumer đã dừng hoàn toàn.")
-		case <-time.After(5 * time.Second):
-			log.Println("SQS consumer không dừng trong thời gian chờ.")
+			log.Println("Các nguồn sự kiện đã dừng hoàn toàn.")
+		case <-time.After(waitTimeout):
+			log.Println("Nguồn sự kiện không dừng trong thời gian chờ.")
 		}
 	}
 
@@ -176,3 +251,85 @@ func startGateEventCleanupJob(gateEventRepo repository.GateEventRepository) {
 		cancel()
 	}
 }
+
+func startDeviceEventDedupPurgeJob(iotService *service.IoTService) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		count, err := iotService.PurgeDeviceEventDedup(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Lỗi xóa dedup key sự kiện thiết bị: %v", err)
+		} else if count > 0 {
+			log.Printf("Đã xóa %d dedup key sự kiện thiết bị hết hạn", count)
+		}
+		cancel()
+	}
+}
+
+func startReservationJob(reservationService *service.ReservationService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		held, noShows, err := reservationService.ProcessDueReservations(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Lỗi xử lý đặt chỗ định kỳ: %v", err)
+		} else if held > 0 || noShows > 0 {
+			log.Printf("Đặt chỗ: đã giữ %d chỗ, nhả %d đặt chỗ không đến", held, noShows)
+		}
+		cancel()
+	}
+}
+
+func startEvidencePurgeJob(evidenceService *service.EvidenceService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		purged, err := evidenceService.PurgeExpired(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Lỗi purge ảnh bằng chứng: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("Evidence: đã purge %d ảnh quá thời gian lưu giữ", purged)
+		}
+		cancel()
+	}
+}
+
+func startLPRAutoTuneJob(thresholdService *service.LPRThresholdService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		changed, err := thresholdService.AutoTune(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Lỗi tự điều chỉnh ngưỡng LPR: %v", err)
+		}
+		if changed > 0 {
+			log.Printf("LPR: đã tự điều chỉnh %d ngưỡng confidence", changed)
+		}
+		cancel()
+	}
+}
+
+func startCommandRetryJob(commandTracker *service.CommandTracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		retried, expired, err := commandTracker.ProcessDueCommands(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("Lỗi xử lý lệnh thiết bị chờ xác nhận: %v", err)
+		} else if retried > 0 || expired > 0 {
+			log.Printf("Lệnh thiết bị: đã gửi lại %d lệnh, %d lệnh hết hạn", retried, expired)
+		}
+		cancel()
+	}
+}
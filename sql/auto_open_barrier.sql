@@ -0,0 +1,5 @@
+-- File: sql/auto_open_barrier.sql
+-- Migration: tự động mở rào vào sau khi tạo phiên từ kết quả LPR, bật/tắt theo từng bãi
+
+ALTER TABLE parking_lots
+    ADD COLUMN IF NOT EXISTS auto_open_entry_barrier BOOLEAN NOT NULL DEFAULT FALSE;
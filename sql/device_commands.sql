@@ -0,0 +1,37 @@
+-- File: sql/device_commands.sql
+-- Migration: theo dõi lệnh điều khiển rào chắn gửi xuống ESP32 (gửi, xác nhận, thực thi, hết hạn) và gửi lại khi
+-- thiết bị không xác nhận
+
+CREATE TABLE IF NOT EXISTS device_commands
+(
+    request_id       VARCHAR(64) PRIMARY KEY,
+    esp32_thing_name VARCHAR(255) NOT NULL,
+    barrier_type     VARCHAR(20)  NOT NULL,
+    command          VARCHAR(20)  NOT NULL,
+    topic            VARCHAR(255) NOT NULL,
+    payload          JSONB        NOT NULL,
+    status           VARCHAR(20)  NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sent', 'acknowledged', 'executed', 'timed_out', 'failed')),
+    attempts         INT          NOT NULL DEFAULT 0,
+    max_attempts     INT          NOT NULL,
+    last_error       TEXT,
+    next_retry_at    TIMESTAMPTZ,
+    alert_sent       BOOLEAN      NOT NULL DEFAULT FALSE,
+    requested_by     VARCHAR(100),
+    sent_at          TIMESTAMPTZ,
+    acknowledged_at  TIMESTAMPTZ,
+    executed_at      TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_device_commands_updated_at
+    BEFORE UPDATE ON device_commands
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- Job gửi lại quét các lệnh chưa được xác nhận tới hạn
+CREATE INDEX IF NOT EXISTS idx_device_commands_due ON device_commands (next_retry_at)
+    WHERE status IN ('pending', 'sent');
+-- barrier_state tìm lệnh gần nhất của rào đang chờ thực thi
+CREATE INDEX IF NOT EXISTS idx_device_commands_barrier ON device_commands (esp32_thing_name, barrier_type, created_at DESC)
+    WHERE status IN ('sent', 'acknowledged');
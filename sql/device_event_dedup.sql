@@ -0,0 +1,19 @@
+-- File: sql/device_event_dedup.sql
+-- Migration: chống xử lý trùng sự kiện thiết bị (SQS / MQTT gửi lại ít nhất một lần) và ghi lại quyết định xử lý
+-- của từng sự kiện trong device_events_log
+
+CREATE TABLE IF NOT EXISTS device_event_dedup
+(
+    dedup_key     TEXT PRIMARY KEY,            -- device_id|message_type|id sự kiện|timestamp thiết bị
+    device_id     VARCHAR(255) NOT NULL,
+    message_type  VARCHAR(100) NOT NULL,
+    first_seen_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at  TIMESTAMPTZ,                 -- NULL = đang xử lý, expires_at là hạn giữ key trong lúc xử lý
+    expires_at    TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_event_dedup_expires ON device_event_dedup (expires_at);
+
+-- processed_status: 'pending', 'processed', 'error', 'duplicate' (đã xử lý trước đó), 'stale' (cũ hơn trạng thái hiện tại)
+ALTER TABLE device_events_log ADD COLUMN IF NOT EXISTS dedup_key TEXT;
+CREATE INDEX IF NOT EXISTS idx_device_events_log_dedup_key ON device_events_log (dedup_key);
@@ -0,0 +1,38 @@
+-- File: sql/evidence.sql
+-- Migration: lưu ảnh camera tại cổng làm bằng chứng (giải quyết khiếu nại), kèm hash SHA-256 và chính sách lưu giữ.
+-- File ảnh nằm trong evidence store (filesystem hoặc S3), bảng này chỉ giữ metadata; khi purge thì xóa file
+-- nhưng giữ lại bản ghi và hash để đối chiếu.
+
+CREATE TABLE IF NOT EXISTS evidence_images
+(
+    id             SERIAL PRIMARY KEY,
+    gate_event_id  VARCHAR(255) REFERENCES gate_events (event_id) ON DELETE SET NULL,
+    session_id     INT          REFERENCES parking_sessions (id) ON DELETE SET NULL,
+    lot_id         INT          NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    gate_direction VARCHAR(10)  NOT NULL CHECK (gate_direction IN ('entry', 'exit')),
+    camera_id      VARCHAR(100),
+    backend        VARCHAR(20)  NOT NULL,              -- filesystem | s3
+    storage_key    TEXT         NOT NULL,
+    sha256         CHAR(64)     NOT NULL,
+    content_type   VARCHAR(100) NOT NULL,
+    size_bytes     BIGINT       NOT NULL,
+    legal_hold     BOOLEAN      NOT NULL DEFAULT FALSE, -- Đang khiếu nại: không purge
+    captured_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    purged_at      TIMESTAMPTZ,                        -- Đã xóa file theo chính sách lưu giữ
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Cùng một frame gửi lại cho cùng gate event chỉ lưu một lần
+CREATE UNIQUE INDEX IF NOT EXISTS idx_evidence_images_event_hash ON evidence_images (gate_event_id, sha256);
+CREATE INDEX IF NOT EXISTS idx_evidence_images_session ON evidence_images (session_id);
+CREATE INDEX IF NOT EXISTS idx_evidence_images_purge ON evidence_images (captured_at)
+    WHERE purged_at IS NULL AND NOT legal_hold;
+
+CREATE TRIGGER update_evidence_images_updated_at
+    BEFORE UPDATE ON evidence_images
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- Mỗi lần nhận dạng LPR trỏ tới ảnh đã dùng
+ALTER TABLE lpr_attempts
+    ADD COLUMN IF NOT EXISTS evidence_id INT REFERENCES evidence_images (id) ON DELETE SET NULL;
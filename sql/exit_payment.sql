@@ -0,0 +1,15 @@
+-- File: sql/exit_payment.sql
+-- Migration: chính sách thanh toán trước khi ra (pay-before-exit) theo từng bãi
+
+-- Cấu hình theo bãi: bật giữ rào ra khi chưa thanh toán, thời gian ân hạn sau khi thanh toán
+ALTER TABLE parking_lots
+    ADD COLUMN IF NOT EXISTS require_payment_before_exit BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS exit_grace_minutes          INT     NOT NULL DEFAULT 15 CHECK (exit_grace_minutes >= 0);
+
+-- Hạn chót xe phải ra sau khi đã thanh toán; quá hạn sẽ tính phí lại tại cổng ra
+ALTER TABLE parking_sessions
+    ADD COLUMN IF NOT EXISTS exit_deadline TIMESTAMPTZ;
+
+-- Tra cứu nhanh gate event đang giữ rào chờ thanh toán
+CREATE INDEX IF NOT EXISTS idx_gate_events_awaiting_payment ON gate_events (session_id, device_id)
+    WHERE status = 'awaiting_payment';
@@ -0,0 +1,19 @@
+-- File: sql/lpr_attempts.sql
+-- Migration: lưu từng lần nhận dạng biển số (mỗi frame ảnh) của một gate event để bỏ phiếu nhiều frame
+
+CREATE TABLE IF NOT EXISTS lpr_attempts
+(
+    id             SERIAL PRIMARY KEY,
+    event_id       VARCHAR(255) NOT NULL REFERENCES gate_events (event_id) ON DELETE CASCADE,
+    provider       VARCHAR(50)  NOT NULL,
+    camera_id      VARCHAR(100),
+    detected_plate VARCHAR(20),                    -- NULL nếu frame không nhận dạng được biển số
+    display_plate  VARCHAR(30),
+    grammar        VARCHAR(32),
+    confidence     DECIMAL(5, 4),
+    candidates     JSONB        NOT NULL DEFAULT '[]'::jsonb, -- Các ứng viên kèm confidence từng ký tự, khung bao
+    error_message  TEXT,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lpr_attempts_event ON lpr_attempts (event_id, created_at);
@@ -0,0 +1,22 @@
+-- File: sql/lpr_feedback.sql
+-- Migration: ghi lại mỗi lần operator sửa / xác nhận biển số LPR (ảnh, kết quả máy, biển số đúng)
+-- làm dữ liệu gán nhãn để đo độ chính xác và huấn luyện lại bộ nhận dạng
+
+CREATE TABLE IF NOT EXISTS lpr_corrections
+(
+    id                 SERIAL PRIMARY KEY,
+    event_id           VARCHAR(255) REFERENCES gate_events (event_id) ON DELETE SET NULL,
+    lot_id             INT          NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    camera_id          VARCHAR(100),
+    evidence_id        INT          REFERENCES evidence_images (id) ON DELETE SET NULL,
+    machine_plate      VARCHAR(20),                  -- NULL = máy không đọc được biển số
+    machine_confidence DECIMAL(5, 4),
+    corrected_plate    VARCHAR(20)  NOT NULL,
+    is_correct         BOOLEAN      NOT NULL,        -- Máy đọc đúng (operator chỉ xác nhận)
+    source             VARCHAR(20)  NOT NULL CHECK (source IN ('manual_override', 'create_session')),
+    operator           VARCHAR(100),
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lpr_corrections_lot ON lpr_corrections (lot_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_lpr_corrections_camera ON lpr_corrections (camera_id, created_at DESC);
@@ -0,0 +1,36 @@
+-- File: sql/lpr_thresholds.sql
+-- Migration: ngưỡng confidence LPR riêng cho từng bãi / camera, tự điều chỉnh theo tỉ lệ operator phải sửa,
+-- kèm lịch sử mọi lần thay đổi ngưỡng
+
+CREATE TABLE IF NOT EXISTS lpr_thresholds
+(
+    id         SERIAL PRIMARY KEY,
+    lot_id     INT           NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    camera_id  VARCHAR(100)  NOT NULL DEFAULT '', -- '' = áp dụng cho mọi camera của bãi
+    threshold  DECIMAL(5, 4) NOT NULL CHECK (threshold > 0 AND threshold <= 1),
+    auto_tune  BOOLEAN       NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (lot_id, camera_id)
+);
+
+CREATE TRIGGER update_lpr_thresholds_updated_at
+    BEFORE UPDATE ON lpr_thresholds
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- Không khóa ngoại tới lpr_thresholds để lịch sử còn lại khi ngưỡng bị xóa
+CREATE TABLE IF NOT EXISTS lpr_threshold_changes
+(
+    id              SERIAL PRIMARY KEY,
+    lot_id          INT           NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    camera_id       VARCHAR(100)  NOT NULL DEFAULT '',
+    old_threshold   DECIMAL(5, 4),                 -- NULL = tạo mới
+    new_threshold   DECIMAL(5, 4),                 -- NULL = xóa, quay về ngưỡng mặc định
+    reason          VARCHAR(20)   NOT NULL CHECK (reason IN ('manual', 'auto_tune')),
+    changed_by      VARCHAR(100),                  -- Operator, NULL khi tự điều chỉnh
+    correction_rate DECIMAL(5, 4),                 -- Tỉ lệ sửa trong cửa sổ khi tự điều chỉnh
+    sample_size     INT,
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lpr_threshold_changes_lot ON lpr_threshold_changes (lot_id, created_at DESC);
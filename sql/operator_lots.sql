@@ -0,0 +1,13 @@
+-- File: sql/operator_lots.sql
+-- Migration: phân công bãi đỗ cho operator; operator chỉ nhận sự kiện WebSocket của các bãi được phân công
+
+CREATE TABLE IF NOT EXISTS user_lot_assignments
+(
+    user_id     INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    lot_id      INT          NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    assigned_by VARCHAR(100),
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, lot_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_lot_assignments_lot ON user_lot_assignments (lot_id);
@@ -0,0 +1,49 @@
+-- File: sql/payments.sql
+-- Migration: bảng payments (nhiều lần thanh toán cho một phiên) và payment_refunds
+
+CREATE TABLE IF NOT EXISTS payments
+(
+    id                SERIAL PRIMARY KEY,
+    session_id        INT            NOT NULL REFERENCES parking_sessions (id) ON DELETE CASCADE,
+    method            VARCHAR(20)    NOT NULL CHECK (method IN ('gateway', 'cash', 'waiver')),
+    gateway           VARCHAR(50),
+    gateway_reference VARCHAR(255) UNIQUE,
+    checkout_url      TEXT,
+    amount            DECIMAL(10, 2) NOT NULL CHECK (amount >= 0),
+    refunded_amount   DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    currency          VARCHAR(3)     NOT NULL DEFAULT 'VND',
+    status            VARCHAR(20)    NOT NULL DEFAULT 'pending', -- 'pending', 'succeeded', 'failed', 'partially_refunded', 'refunded'
+    failure_reason    TEXT,
+    note              TEXT,                                       -- Ghi chú, bắt buộc với waiver (lý do miễn phí)
+    recorded_by       VARCHAR(100),                               -- Username operator/admin thực hiện
+    paid_at           TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_waiver_has_reason CHECK (method <> 'waiver' OR note IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_session_id ON payments (session_id);
+
+CREATE TRIGGER update_payments_updated_at
+    BEFORE UPDATE
+    ON payments
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS payment_refunds
+(
+    id                SERIAL PRIMARY KEY,
+    payment_id        INT            NOT NULL REFERENCES payments (id) ON DELETE CASCADE,
+    amount            DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    reason            TEXT           NOT NULL,
+    status            VARCHAR(20)    NOT NULL,                    -- 'pending', 'succeeded', 'failed'
+    gateway_reference VARCHAR(255),
+    failure_reason    TEXT,
+    recorded_by       VARCHAR(100),
+    created_at        TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_refunds_payment_id ON payment_refunds (payment_id);
+
+-- Mở rộng payment_status của phiên cho thanh toán một phần
+COMMENT ON COLUMN parking_sessions.payment_status IS 'pending, partially_paid, paid, failed, waived';
@@ -0,0 +1,32 @@
+-- File: sql/plate_canonical.sql
+-- Migration: chuẩn hóa biển số đã lưu về dạng plate.Canonical (chữ in hoa, bỏ gạch, chấm, khoảng trắng) để tra cứu
+-- phiên đỗ, đặt chỗ, vé tháng và danh sách cảnh báo khớp với biển số nhận dạng tại cổng. Chạy lại nhiều lần không đổi kết quả.
+
+UPDATE parking_sessions
+SET vehicle_identifier = regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g')
+WHERE vehicle_identifier IS NOT NULL
+  AND vehicle_identifier <> regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g');
+
+UPDATE reservations
+SET vehicle_identifier = regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g')
+WHERE vehicle_identifier <> regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g');
+
+UPDATE plate_watchlist
+SET vehicle_identifier = regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g')
+WHERE vehicle_identifier <> regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g');
+
+UPDATE watchlist_hits
+SET vehicle_identifier = regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g')
+WHERE vehicle_identifier <> regexp_replace(upper(vehicle_identifier), '[^[:alnum:]]', '', 'g');
+
+-- Vé tháng: chuẩn hóa từng biển số, bỏ biển số trùng sau chuẩn hóa, giữ thứ tự ban đầu
+UPDATE subscriptions s
+SET vehicle_identifiers = ARRAY(
+        SELECT n.canonical
+        FROM unnest(s.vehicle_identifiers) WITH ORDINALITY AS v(plate, pos),
+             LATERAL (SELECT regexp_replace(upper(v.plate), '[^[:alnum:]]', '', 'g') AS canonical) n
+        GROUP BY n.canonical
+        ORDER BY min(v.pos))
+WHERE EXISTS (SELECT 1
+              FROM unnest(s.vehicle_identifiers) AS v(plate)
+              WHERE v.plate <> regexp_replace(upper(v.plate), '[^[:alnum:]]', '', 'g'));
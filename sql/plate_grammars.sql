@@ -0,0 +1,6 @@
+-- File: sql/plate_grammars.sql
+-- Migration: mỗi bãi chọn các định dạng biển số (grammar) được chấp nhận khi nhận dạng LPR.
+-- Mảng rỗng = dùng các định dạng Việt Nam mặc định (vn_diplomatic, vn_military, vn_car, vn_motorbike)
+
+ALTER TABLE parking_lots
+    ADD COLUMN IF NOT EXISTS accepted_plate_grammars TEXT[] NOT NULL DEFAULT '{}';
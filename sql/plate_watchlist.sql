@@ -0,0 +1,41 @@
+-- File: sql/plate_watchlist.sql
+-- Migration: danh sách cảnh báo biển số (xe mất cắp, cấm vào, nợ phí, VIP) và nhật ký phát hiện tại cổng
+
+CREATE TABLE IF NOT EXISTS plate_watchlist
+(
+    id                 SERIAL PRIMARY KEY,
+    vehicle_identifier VARCHAR(20)  NOT NULL,
+    reason_code        VARCHAR(20)  NOT NULL
+        CHECK (reason_code IN ('stolen', 'banned', 'unpaid_debt', 'vip')),
+    note               TEXT,
+    expires_at         TIMESTAMPTZ,                  -- NULL = không hết hạn
+    is_active          BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_by         VARCHAR(100),
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_plate_watchlist_vehicle ON plate_watchlist (vehicle_identifier) WHERE is_active;
+
+CREATE TRIGGER update_plate_watchlist_updated_at
+    BEFORE UPDATE ON plate_watchlist
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- Mỗi lần biển số trong danh sách bị phát hiện (LPR tại cổng hoặc check-in qua API) đều được ghi lại
+CREATE TABLE IF NOT EXISTS watchlist_hits
+(
+    id                 SERIAL PRIMARY KEY,
+    watchlist_id       INT          REFERENCES plate_watchlist (id) ON DELETE SET NULL,
+    vehicle_identifier VARCHAR(20)  NOT NULL,
+    reason_code        VARCHAR(20)  NOT NULL,
+    lot_id             INT          NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    gate_event_id      VARCHAR(100),
+    source             VARCHAR(20)  NOT NULL CHECK (source IN ('lpr', 'check_in')),
+    action             VARCHAR(20)  NOT NULL CHECK (action IN ('denied', 'allowed', 'overridden')),
+    operator           VARCHAR(100),
+    override_reason    VARCHAR(255),
+    detected_at        TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_hits_vehicle ON watchlist_hits (vehicle_identifier, detected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_watchlist_hits_lot ON watchlist_hits (lot_id, detected_at DESC);
@@ -0,0 +1,33 @@
+-- File: sql/reservations.sql
+-- Migration: đặt chỗ theo biển số, giữ slot ở trạng thái 'reserved' trong khung giờ đặt
+
+CREATE TABLE IF NOT EXISTS reservations
+(
+    id                 SERIAL PRIMARY KEY,
+    lot_id             INT          NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    slot_id            INT          REFERENCES parking_slots (id) ON DELETE SET NULL, -- NULL = chưa gán chỗ cụ thể
+    any_slot           BOOLEAN      NOT NULL DEFAULT FALSE,                           -- Đặt "bất kỳ chỗ nào trong bãi"
+    vehicle_identifier VARCHAR(20)  NOT NULL,
+    start_time         TIMESTAMPTZ  NOT NULL,
+    end_time           TIMESTAMPTZ  NOT NULL,
+    no_show_at         TIMESTAMPTZ  NOT NULL,
+    status             VARCHAR(20)  NOT NULL DEFAULT 'scheduled'
+        CHECK (status IN ('scheduled', 'held', 'consumed', 'cancelled', 'no_show')),
+    session_id         INT          REFERENCES parking_sessions (id) ON DELETE SET NULL,
+    note               TEXT,
+    created_by         VARCHAR(100),
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_open_by_lot ON reservations (lot_id, start_time)
+    WHERE status IN ('scheduled', 'held');
+CREATE INDEX IF NOT EXISTS idx_reservations_open_by_slot ON reservations (slot_id)
+    WHERE status IN ('scheduled', 'held');
+CREATE INDEX IF NOT EXISTS idx_reservations_vehicle ON reservations (lot_id, vehicle_identifier)
+    WHERE status IN ('scheduled', 'held');
+
+CREATE TRIGGER update_reservations_updated_at
+    BEFORE UPDATE ON reservations
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
@@ -0,0 +1,37 @@
+-- File: sql/subscriptions.sql
+-- Migration: vé tháng / thẻ mùa (season pass) cho phép xe vào bãi không cần operator và miễn phí theo phiên
+
+CREATE TABLE IF NOT EXISTS subscriptions
+(
+    id                      SERIAL PRIMARY KEY,
+    holder_name             VARCHAR(255) NOT NULL,
+    holder_contact          VARCHAR(255),
+    vehicle_identifiers     TEXT[]       NOT NULL,                        -- Biển số (đã chuẩn hóa) được dùng vé
+    lot_ids                 INT[]        NOT NULL DEFAULT '{}',           -- Rỗng = mọi bãi
+    valid_from              TIMESTAMPTZ  NOT NULL,
+    valid_until             TIMESTAMPTZ  NOT NULL,
+    weekday_mask            SMALLINT     NOT NULL DEFAULT 127 CHECK (weekday_mask BETWEEN 1 AND 127), -- bit 0 = Chủ nhật
+    allowed_start_time      VARCHAR(5),                                   -- 'HH:MM', NULL = cả ngày
+    allowed_end_time        VARCHAR(5),                                   -- 'HH:MM', có thể qua nửa đêm
+    timezone                VARCHAR(64)  NOT NULL DEFAULT 'Asia/Ho_Chi_Minh',
+    max_concurrent_vehicles INT          NOT NULL DEFAULT 1 CHECK (max_concurrent_vehicles > 0),
+    is_active               BOOLEAN      NOT NULL DEFAULT TRUE,
+    note                    TEXT,
+    created_at              TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at              TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (valid_until > valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_vehicle_identifiers ON subscriptions USING GIN (vehicle_identifiers);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_validity ON subscriptions (valid_from, valid_until) WHERE is_active;
+
+CREATE TRIGGER update_subscriptions_updated_at
+    BEFORE UPDATE ON subscriptions
+    FOR EACH ROW EXECUTE PROCEDURE set_updated_at_column();
+
+-- Phiên đỗ xe được vé tháng bao phí
+ALTER TABLE parking_sessions
+    ADD COLUMN IF NOT EXISTS subscription_id INT REFERENCES subscriptions (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_parking_sessions_active_subscription ON parking_sessions (subscription_id)
+    WHERE status = 'active' AND subscription_id IS NOT NULL;
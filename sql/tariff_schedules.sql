@@ -0,0 +1,51 @@
+-- File: sql/tariff_schedules.sql
+-- Migration: phiên bản biểu phí theo thời gian hiệu lực, khung giờ theo ngày trong tuần, lịch ngày lễ
+-- và lưu chi tiết phí (fee_breakdown) cho phiên đỗ xe
+
+-- Phiên bản biểu phí: nhiều phiên bản active cùng tồn tại, phân biệt bởi effective_from
+ALTER TABLE tariffs
+    ADD COLUMN IF NOT EXISTS version             INT         NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS previous_version_id INT         REFERENCES tariffs (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS effective_from      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE tariffs SET effective_from = created_at WHERE effective_from > created_at;
+
+DROP INDEX IF EXISTS idx_tariffs_one_active_per_lot;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tariffs_active_effective_from ON tariffs (lot_id, effective_from) WHERE is_active;
+
+-- Thời điểm ngừng áp dụng: phiên bản đã có hiệu lực rồi mới ngừng vẫn là giá của các phiên vào trước đó
+ALTER TABLE tariffs
+    ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+-- Khung giờ có giá riêng của một phiên bản biểu phí
+CREATE TABLE IF NOT EXISTS tariff_schedule_windows
+(
+    id            SERIAL PRIMARY KEY,
+    tariff_id     INT            NOT NULL REFERENCES tariffs (id) ON DELETE CASCADE,
+    name          VARCHAR(100)   NOT NULL,
+    weekday_mask  SMALLINT       NOT NULL DEFAULT 127 CHECK (weekday_mask BETWEEN 0 AND 127), -- bit 0 = Chủ nhật
+    holiday_only  BOOLEAN        NOT NULL DEFAULT FALSE,
+    start_time    VARCHAR(5)     NOT NULL,                                                    -- 'HH:MM'
+    end_time      VARCHAR(5)     NOT NULL,                                                    -- 'HH:MM', có thể qua nửa đêm
+    block_minutes INT            NOT NULL CHECK (block_minutes > 0),
+    block_price   DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    priority      INT            NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_tariff_schedule_windows_tariff_id ON tariff_schedule_windows (tariff_id);
+
+-- Lịch ngày lễ, lot_id NULL = áp dụng cho toàn hệ thống
+CREATE TABLE IF NOT EXISTS holidays
+(
+    id           SERIAL PRIMARY KEY,
+    lot_id       INT          REFERENCES parking_lots (id) ON DELETE CASCADE,
+    holiday_date DATE         NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_holidays_lot_date ON holidays (COALESCE(lot_id, 0), holiday_date);
+
+-- Chi tiết phí lưu cùng calculated_fee
+ALTER TABLE parking_sessions
+    ADD COLUMN IF NOT EXISTS fee_breakdown JSONB;
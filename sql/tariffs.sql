@@ -0,0 +1,33 @@
+-- File: sql/tariffs.sql
+-- Migration để thêm bảng tariffs (biểu phí theo bãi đỗ)
+
+CREATE TABLE IF NOT EXISTS tariffs
+(
+    id                   SERIAL PRIMARY KEY,
+    lot_id               INT            NOT NULL REFERENCES parking_lots (id) ON DELETE CASCADE,
+    name                 VARCHAR(100)   NOT NULL,
+    is_active            BOOLEAN        NOT NULL DEFAULT FALSE,
+    pricing_mode         VARCHAR(20)    NOT NULL CHECK (pricing_mode IN ('per_minute', 'hourly', 'block')),
+    block_minutes        INT            NOT NULL DEFAULT 60 CHECK (block_minutes > 0),
+    block_price          DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    grace_period_minutes INT            NOT NULL DEFAULT 0,
+    minimum_fee          DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    daily_cap            DECIMAL(10, 2) NOT NULL DEFAULT 0,                    -- 0 = không giới hạn
+    overnight_start      VARCHAR(5),                                           -- 'HH:MM'
+    overnight_end        VARCHAR(5),                                           -- 'HH:MM'
+    overnight_flat_rate  DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    vehicle_multipliers  JSONB          NOT NULL DEFAULT '{}'::jsonb,          -- {"motorbike": 0.5, "truck": 2}
+    timezone             VARCHAR(64)    NOT NULL DEFAULT 'Asia/Ho_Chi_Minh',
+    created_at           TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Mỗi bãi chỉ có tối đa một biểu phí active
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tariffs_one_active_per_lot ON tariffs (lot_id) WHERE is_active;
+CREATE INDEX IF NOT EXISTS idx_tariffs_lot_id ON tariffs (lot_id);
+
+CREATE TRIGGER update_tariffs_updated_at
+    BEFORE UPDATE
+    ON tariffs
+    FOR EACH ROW
+EXECUTE PROCEDURE set_updated_at_column();
@@ -0,0 +1,22 @@
+-- File: sql/vehicle_attributes.sql
+-- Migration: loại xe, màu xe, hãng xe nhận diện từ ảnh camera (hoặc operator sửa) trên gate event và phiên đỗ xe;
+-- chỗ đỗ có thể dành riêng cho một loại xe
+
+ALTER TABLE gate_events
+    ADD COLUMN IF NOT EXISTS vehicle_class            VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS vehicle_class_confidence DECIMAL(5, 4),
+    ADD COLUMN IF NOT EXISTS vehicle_color            VARCHAR(30),
+    ADD COLUMN IF NOT EXISTS vehicle_make             VARCHAR(50);
+
+ALTER TABLE parking_sessions
+    ADD COLUMN IF NOT EXISTS vehicle_class     VARCHAR(20), -- motorbike, car, truck - dùng cho hệ số giá và chọn chỗ đỗ
+    ADD COLUMN IF NOT EXISTS vehicle_color     VARCHAR(30),
+    ADD COLUMN IF NOT EXISTS vehicle_make      VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS vehicle_source    VARCHAR(20), -- lpr | operator
+    ADD COLUMN IF NOT EXISTS vehicle_corrected_by VARCHAR(100);
+
+-- NULL = chỗ đỗ dùng chung cho mọi loại xe
+ALTER TABLE parking_slots
+    ADD COLUMN IF NOT EXISTS vehicle_class VARCHAR(20);
+
+CREATE INDEX IF NOT EXISTS idx_parking_slots_lot_class ON parking_slots (lot_id, vehicle_class, status);